@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"redquill-backend/pkg/common"
+	"redquill-backend/pkg/config"
+	"redquill-backend/pkg/services"
+
+	"redquill-backend/pkg/utils"
+)
+
+// rotatekey 使用新主密钥重新加密 llm_models 中的 API Key。
+// 用法：LLM_MASTER_KEY_OLD=<旧密钥> LLM_MASTER_KEY=<新密钥> go run ./pkg/cmd/rotatekey
+func main() {
+	cfg := config.Load()
+
+	oldSecret := os.Getenv("LLM_MASTER_KEY_OLD")
+	if oldSecret == "" {
+		log.Fatal("LLM_MASTER_KEY_OLD is required")
+	}
+	if oldSecret == cfg.LLMMasterKey {
+		log.Fatal("LLM_MASTER_KEY_OLD equals LLM_MASTER_KEY, nothing to rotate")
+	}
+
+	ctx := context.Background()
+	mongoClient, err := utils.Connect(ctx, cfg.MongoURI)
+	if err != nil {
+		log.Fatalf("failed to connect to MongoDB: %v", err)
+	}
+	defer func() { _ = mongoClient.Disconnect(context.Background()) }()
+
+	svc := services.NewLLMModelService(mongoClient, cfg.DBName)
+
+	rotated, err := svc.RotateMasterKey(ctx, common.DeriveKey(oldSecret), common.DeriveKey(cfg.LLMMasterKey))
+	if err != nil {
+		log.Fatalf("rotate failed after %d models: %v", rotated, err)
+	}
+	log.Printf("re-encrypted %d models", rotated)
+
+	// 顺带加密仍为明文的历史Key
+	common.SetMasterKey(cfg.LLMMasterKey)
+	encrypted, err := svc.EncryptLegacyAPIKeys(ctx)
+	if err != nil {
+		log.Fatalf("encrypt legacy keys failed: %v", err)
+	}
+	log.Printf("encrypted %d legacy plaintext keys", encrypted)
+}
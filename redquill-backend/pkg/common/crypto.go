@@ -0,0 +1,159 @@
+package common
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+)
+
+// masterKey 主密钥，用于包裹（wrap）每条记录各自的数据密钥
+var masterKey []byte
+
+// DeriveKey 由任意长度的主密钥字符串派生出 32 字节 AES-256 密钥
+func DeriveKey(secret string) []byte {
+	h := sha256.Sum256([]byte(secret))
+	return h[:]
+}
+
+// SetMasterKey 设置全局主密钥，服务启动时调用
+func SetMasterKey(secret string) {
+	masterKey = DeriveKey(secret)
+}
+
+// MasterKey 返回当前主密钥
+func MasterKey() []byte {
+	return masterKey
+}
+
+// SealSecret 信封加密：生成随机数据密钥加密明文，再用主密钥包裹数据密钥。
+// 返回 base64 编码的密文与被包裹的数据密钥。
+func SealSecret(key []byte, plain string) (ciphertext string, wrappedKey string, err error) {
+	if len(key) == 0 {
+		return "", "", errors.New("master key not configured")
+	}
+
+	dek := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dek); err != nil {
+		return "", "", err
+	}
+
+	ct, err := gcmSeal(dek, []byte(plain))
+	if err != nil {
+		return "", "", err
+	}
+	wk, err := gcmSeal(key, dek)
+	if err != nil {
+		return "", "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(ct), base64.StdEncoding.EncodeToString(wk), nil
+}
+
+// OpenSecret 解密 SealSecret 的结果
+func OpenSecret(key []byte, ciphertext, wrappedKey string) (string, error) {
+	if len(key) == 0 {
+		return "", errors.New("master key not configured")
+	}
+
+	dek, err := unwrapKey(key, wrappedKey)
+	if err != nil {
+		return "", err
+	}
+
+	ct, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", err
+	}
+	plain, err := gcmOpen(dek, ct)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plain), nil
+}
+
+// RewrapKey 使用新主密钥重新包裹数据密钥，密文本身无需改动
+func RewrapKey(wrappedKey string, oldKey, newKey []byte) (string, error) {
+	dek, err := unwrapKey(oldKey, wrappedKey)
+	if err != nil {
+		return "", err
+	}
+
+	wk, err := gcmSeal(newKey, dek)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(wk), nil
+}
+
+// IsWrappedWith 判断数据密钥是否可由该主密钥解开，用于跳过已轮换的文档
+func IsWrappedWith(key []byte, wrappedKey string) bool {
+	_, err := unwrapKey(key, wrappedKey)
+	return err == nil
+}
+
+// MaskSecret 生成脱敏展示值，例如 sk-****abcd
+func MaskSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	if len(secret) <= 8 {
+		return "****"
+	}
+	return secret[:3] + "****" + secret[len(secret)-4:]
+}
+
+func unwrapKey(key []byte, wrappedKey string) ([]byte, error) {
+	wk, err := base64.StdEncoding.DecodeString(wrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	dek, err := gcmOpen(key, wk)
+	if err != nil {
+		return nil, errors.New("failed to unwrap data key: wrong master key?")
+	}
+	return dek, nil
+}
+
+// gcmSeal AES-GCM 加密，输出为 nonce||ciphertext
+func gcmSeal(key, plain []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, plain, nil), nil
+}
+
+// gcmOpen AES-GCM 解密，输入为 nonce||ciphertext
+func gcmOpen(key, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ct := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+
+	return gcm.Open(nil, nonce, ct, nil)
+}
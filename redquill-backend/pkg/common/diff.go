@@ -0,0 +1,184 @@
+package common
+
+import (
+	"strings"
+)
+
+// 差异操作类型
+const (
+	DiffEqual  = "equal"
+	DiffInsert = "insert"
+	DiffDelete = "delete"
+)
+
+// maxDiffEditDistance 编辑距离超过该值时不再逐行比较，中间部分整体视为删除后插入
+const maxDiffEditDistance = 1000
+
+// DiffOp 一段连续的相同操作
+type DiffOp struct {
+	Op    string   `json:"op"` // equal|insert|delete
+	Lines []string `json:"lines"`
+}
+
+// DiffStats 差异统计（按行/段落计数）
+type DiffStats struct {
+	Added     int `json:"added"`
+	Removed   int `json:"removed"`
+	Unchanged int `json:"unchanged"`
+}
+
+// SplitLines 按行切分文本，兼容 \r\n
+func SplitLines(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.TrimSuffix(text, "\n")
+	if text == "" {
+		return []string{}
+	}
+	return strings.Split(text, "\n")
+}
+
+// SplitParagraphs 按段落切分文本：去掉首尾空白（包括全角缩进）并忽略空行，
+// 只调整缩进或空行的修改不计为差异
+func SplitParagraphs(text string) []string {
+	paragraphs := []string{}
+	for _, line := range SplitLines(text) {
+		if p := strings.TrimSpace(line); p != "" {
+			paragraphs = append(paragraphs, p)
+		}
+	}
+	return paragraphs
+}
+
+// Diff 使用 Myers 算法比较两组行，返回合并后的连续操作及统计
+func Diff(a, b []string) ([]DiffOp, DiffStats) {
+	// 去掉公共前缀和后缀，缩小比较范围
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	d := &diffBuilder{ops: []DiffOp{}}
+	d.add(DiffEqual, a[:prefix]...)
+	for _, e := range myersEdits(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]) {
+		d.add(e.op, e.line)
+	}
+	d.add(DiffEqual, a[len(a)-suffix:]...)
+	return d.ops, d.stats
+}
+
+type diffEdit struct {
+	op   string
+	line string
+}
+
+type diffBuilder struct {
+	ops   []DiffOp
+	stats DiffStats
+}
+
+func (d *diffBuilder) add(op string, lines ...string) {
+	if len(lines) == 0 {
+		return
+	}
+	switch op {
+	case DiffInsert:
+		d.stats.Added += len(lines)
+	case DiffDelete:
+		d.stats.Removed += len(lines)
+	default:
+		d.stats.Unchanged += len(lines)
+	}
+	if n := len(d.ops); n > 0 && d.ops[n-1].Op == op {
+		d.ops[n-1].Lines = append(d.ops[n-1].Lines, lines...)
+		return
+	}
+	d.ops = append(d.ops, DiffOp{Op: op, Lines: append([]string{}, lines...)})
+}
+
+// myersEdits 计算最短编辑脚本；trace[d] 只保存第 d 步开始时 k∈[-d-1, d+1] 的端点，内存为 O(D²)
+func myersEdits(a, b []string) []diffEdit {
+	n, m := len(a), len(b)
+	max := n + m
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	var trace [][]int
+
+	for d := 0; d <= max; d++ {
+		if d > maxDiffEditDistance {
+			return replaceEdits(a, b)
+		}
+		trace = append(trace, append([]int{}, v[offset-d-1:offset+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrackEdits(a, b, trace)
+			}
+		}
+	}
+	return replaceEdits(a, b)
+}
+
+func backtrackEdits(a, b []string, trace [][]int) []diffEdit {
+	x, y := len(a), len(b)
+	var edits []diffEdit
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		at := func(k int) int { return v[k+d+1] }
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			edits = append(edits, diffEdit{op: DiffEqual, line: a[x-1]})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				edits = append(edits, diffEdit{op: DiffInsert, line: b[y-1]})
+			} else {
+				edits = append(edits, diffEdit{op: DiffDelete, line: a[x-1]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+// replaceEdits 差异过大时整体删除后插入
+func replaceEdits(a, b []string) []diffEdit {
+	edits := make([]diffEdit, 0, len(a)+len(b))
+	for _, line := range a {
+		edits = append(edits, diffEdit{op: DiffDelete, line: line})
+	}
+	for _, line := range b {
+		edits = append(edits, diffEdit{op: DiffInsert, line: line})
+	}
+	return edits
+}
@@ -0,0 +1,80 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// ErrNoJSON 文本中没有找到合法的JSON
+var ErrNoJSON = errors.New("no valid JSON found in output")
+
+var jsonFencePattern = regexp.MustCompile("(?s)```[a-zA-Z]*[ \t]*\r?\n?(.*?)```")
+
+// ExtractJSON 从模型输出中提取JSON：优先取 ```json 代码块，否则取第一个完整的对象/数组。
+// rest 为JSON之后的剩余文本（例如先输出元数据JSON再输出正文的模板）。
+func ExtractJSON(text string) (jsonText string, rest string, err error) {
+	trimmed := strings.TrimSpace(text)
+	if json.Valid([]byte(trimmed)) {
+		return trimmed, "", nil
+	}
+
+	// 代码块
+	for _, loc := range jsonFencePattern.FindAllStringSubmatchIndex(text, -1) {
+		candidate := strings.TrimSpace(text[loc[2]:loc[3]])
+		if json.Valid([]byte(candidate)) {
+			return candidate, strings.TrimSpace(text[loc[1]:]), nil
+		}
+	}
+
+	// 第一个括号平衡的对象或数组
+	for start := 0; start < len(text); start++ {
+		if text[start] != '{' && text[start] != '[' {
+			continue
+		}
+		end := matchBracket(text, start)
+		if end < 0 {
+			continue
+		}
+		candidate := text[start : end+1]
+		if json.Valid([]byte(candidate)) {
+			return candidate, strings.TrimSpace(text[end+1:]), nil
+		}
+	}
+
+	return "", "", ErrNoJSON
+}
+
+// matchBracket 返回与 start 处括号匹配的位置，忽略字符串中的括号
+func matchBracket(text string, start int) int {
+	depth := 0
+	inString := false
+	escaped := false
+	for i := start; i < len(text); i++ {
+		ch := text[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case ch == '\\':
+				escaped = true
+			case ch == '"':
+				inString = false
+			}
+			continue
+		}
+		switch ch {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
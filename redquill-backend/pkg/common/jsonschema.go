@@ -0,0 +1,179 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ValidateJSONSchema 按 JSON Schema 的常用子集校验数据，返回全部不符合项（为空表示通过）。
+// 支持 type、properties、required、items、enum、minItems、maxItems、minLength、minimum、maximum。
+// schema 与 value 可以来自 BSON 解码，校验前统一转换为 encoding/json 的类型。
+func ValidateJSONSchema(schema map[string]interface{}, value interface{}) []string {
+	if len(schema) == 0 {
+		return nil
+	}
+	var normSchema map[string]interface{}
+	if err := normalizeJSON(schema, &normSchema); err != nil {
+		return []string{fmt.Sprintf("invalid schema: %v", err)}
+	}
+	var normValue interface{}
+	if err := normalizeJSON(value, &normValue); err != nil {
+		return []string{fmt.Sprintf("invalid value: %v", err)}
+	}
+
+	var errs []string
+	validateNode(normSchema, normValue, "$", &errs)
+	return errs
+}
+
+// normalizeJSON 通过JSON编解码统一数据类型
+func normalizeJSON(in interface{}, out interface{}) error {
+	b, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, out)
+}
+
+func validateNode(schema map[string]interface{}, value interface{}, path string, errs *[]string) {
+	if types := schemaTypes(schema["type"]); len(types) > 0 {
+		matched := false
+		for _, t := range types {
+			if matchesType(t, value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			*errs = append(*errs, fmt.Sprintf("%s: expected %s, got %s", path, strings.Join(types, "|"), jsonTypeOf(value)))
+			return
+		}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok && len(enum) > 0 {
+		found := false
+		for _, candidate := range enum {
+			if fmt.Sprint(candidate) == fmt.Sprint(value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			*errs = append(*errs, fmt.Sprintf("%s: value %v is not one of %v", path, value, enum))
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, r := range required {
+				key, _ := r.(string)
+				if _, exists := v[key]; key != "" && !exists {
+					*errs = append(*errs, fmt.Sprintf("%s.%s: required", path, key))
+				}
+			}
+		}
+		if props, ok := schema["properties"].(map[string]interface{}); ok {
+			keys := make([]string, 0, len(props))
+			for key := range props {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				propSchema, ok := props[key].(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if child, exists := v[key]; exists {
+					validateNode(propSchema, child, path+"."+key, errs)
+				}
+			}
+		}
+	case []interface{}:
+		if min, ok := schema["minItems"].(float64); ok && float64(len(v)) < min {
+			*errs = append(*errs, fmt.Sprintf("%s: expected at least %d items, got %d", path, int(min), len(v)))
+		}
+		if max, ok := schema["maxItems"].(float64); ok && float64(len(v)) > max {
+			*errs = append(*errs, fmt.Sprintf("%s: expected at most %d items, got %d", path, int(max), len(v)))
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				validateNode(items, item, fmt.Sprintf("%s[%d]", path, i), errs)
+			}
+		}
+	case string:
+		if min, ok := schema["minLength"].(float64); ok && float64(len([]rune(v))) < min {
+			*errs = append(*errs, fmt.Sprintf("%s: expected at least %d characters", path, int(min)))
+		}
+	case float64:
+		if min, ok := schema["minimum"].(float64); ok && v < min {
+			*errs = append(*errs, fmt.Sprintf("%s: %v is less than minimum %v", path, v, min))
+		}
+		if max, ok := schema["maximum"].(float64); ok && v > max {
+			*errs = append(*errs, fmt.Sprintf("%s: %v is greater than maximum %v", path, v, max))
+		}
+	}
+}
+
+// schemaTypes 解析 type 字段，支持字符串或字符串数组
+func schemaTypes(t interface{}) []string {
+	switch v := t.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var out []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func matchesType(t string, value interface{}) bool {
+	switch t {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		f, ok := value.(float64)
+		return ok && f == float64(int64(f))
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	}
+	return true
+}
+
+func jsonTypeOf(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", value)
+}
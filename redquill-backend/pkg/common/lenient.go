@@ -0,0 +1,240 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var leadingNumberPattern = regexp.MustCompile(`-?\d+(\.\d+)?`)
+
+// DecodeLenient 按 json 标签将模型输出解码到结构体，并宽松转换类型：
+// 字符串形式的数字（如 "8"、"8分"）转换为数值，需要数组的位置给出单个值时视为单元素数组，
+// 需要字符串的位置给出数组时以顿号连接。
+// 返回输出中缺失（或类型无法转换）的字段路径，数组元素以 [] 表示，例如 chapters[].title；
+// skip 中的路径由服务端填写，不计入缺失。
+func DecodeLenient(data interface{}, out interface{}, skip ...string) ([]string, error) {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return nil, errors.New("decode target must be a non-nil pointer")
+	}
+
+	var value interface{}
+	if err := normalizeJSON(data, &value); err != nil {
+		return nil, err
+	}
+	if rv.Elem().Kind() == reflect.Struct {
+		if _, ok := value.(map[string]interface{}); !ok {
+			return nil, fmt.Errorf("expected object, got %s", jsonTypeOf(value))
+		}
+	}
+
+	d := &lenientDecoder{skip: make(map[string]bool), seen: make(map[string]bool)}
+	for _, path := range skip {
+		d.skip[path] = true
+	}
+	d.decode(value, rv.Elem(), "")
+	return d.missing, nil
+}
+
+type lenientDecoder struct {
+	skip    map[string]bool
+	seen    map[string]bool
+	missing []string
+}
+
+func (d *lenientDecoder) addMissing(path string) {
+	if path == "" || d.seen[path] {
+		return
+	}
+	d.seen[path] = true
+	d.missing = append(d.missing, path)
+}
+
+func (d *lenientDecoder) decode(value interface{}, rv reflect.Value, path string) {
+	if value == nil {
+		return
+	}
+
+	switch rv.Kind() {
+	case reflect.String:
+		rv.SetString(lenientString(value))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if f, ok := lenientFloat(value); ok {
+			rv.SetInt(int64(f))
+		} else {
+			d.addMissing(path)
+		}
+	case reflect.Float32, reflect.Float64:
+		if f, ok := lenientFloat(value); ok {
+			rv.SetFloat(f)
+		} else {
+			d.addMissing(path)
+		}
+	case reflect.Bool:
+		rv.SetBool(lenientBool(value))
+	case reflect.Slice:
+		items, ok := value.([]interface{})
+		if !ok {
+			items = []interface{}{value}
+		}
+		slice := reflect.MakeSlice(rv.Type(), 0, len(items))
+		for _, item := range items {
+			if item == nil {
+				continue
+			}
+			elem := reflect.New(rv.Type().Elem()).Elem()
+			d.decode(item, elem, path+"[]")
+			slice = reflect.Append(slice, elem)
+		}
+		rv.Set(slice)
+	case reflect.Map:
+		obj, ok := value.(map[string]interface{})
+		if !ok || rv.Type().Key().Kind() != reflect.String {
+			d.addMissing(path)
+			return
+		}
+		m := reflect.MakeMapWithSize(rv.Type(), len(obj))
+		for key, item := range obj {
+			elem := reflect.New(rv.Type().Elem()).Elem()
+			d.decode(item, elem, joinPath(path, key))
+			m.SetMapIndex(reflect.ValueOf(key).Convert(rv.Type().Key()), elem)
+		}
+		rv.Set(m)
+	case reflect.Struct:
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			d.addMissing(path)
+			return
+		}
+		d.decodeStruct(obj, rv, path)
+	case reflect.Ptr:
+		ptr := reflect.New(rv.Type().Elem())
+		d.decode(value, ptr.Elem(), path)
+		rv.Set(ptr)
+	case reflect.Interface:
+		rv.Set(reflect.ValueOf(value))
+	}
+}
+
+func (d *lenientDecoder) decodeStruct(obj map[string]interface{}, rv reflect.Value, path string) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		// 不持久化的字段不属于模型输出
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" || field.Tag.Get("bson") == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fieldPath := joinPath(path, name)
+		if d.skip[fieldPath] {
+			continue
+		}
+
+		fv := rv.Field(i)
+		value, exists := obj[name]
+		if !exists || value == nil {
+			d.addMissing(fieldPath)
+			fillEmpty(fv)
+			continue
+		}
+		d.decode(value, fv, fieldPath)
+	}
+}
+
+// fillEmpty 缺失的数组和映射（包括嵌套结构体中的）输出为空值而不是 null
+func fillEmpty(rv reflect.Value) {
+	switch rv.Kind() {
+	case reflect.Slice:
+		if rv.IsNil() {
+			rv.Set(reflect.MakeSlice(rv.Type(), 0, 0))
+		}
+	case reflect.Map:
+		if rv.IsNil() {
+			rv.Set(reflect.MakeMap(rv.Type()))
+		}
+	case reflect.Struct:
+		for i := 0; i < rv.NumField(); i++ {
+			if rv.Type().Field(i).IsExported() && rv.Type().Field(i).Tag.Get("bson") != "-" {
+				fillEmpty(rv.Field(i))
+			}
+		}
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// lenientString 将任意JSON值转换为字符串
+func lenientString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case []interface{}:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			if item != nil {
+				parts = append(parts, lenientString(item))
+			}
+		}
+		return strings.Join(parts, "、")
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
+
+// lenientFloat 将数值或以数字开头的字符串转换为数值
+func lenientFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		match := leadingNumberPattern.FindString(v)
+		if match == "" {
+			return 0, false
+		}
+		f, err := strconv.ParseFloat(match, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// lenientBool 将布尔值、数值或 "true"/"是" 等字符串转换为布尔值
+func lenientBool(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "true", "yes", "1", "是":
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// MergePatchUpdate 将 JSON Merge Patch（RFC 7396）转换为 MongoDB 的 $set/$unset：
+// 嵌套对象逐层合并，只修改补丁中出现的字段；null 将字段重置为零值（映射中的键则被删除）。
+// 字段按 model 的 json 标签校验并转换为对应类型，路径使用 bson 字段名；readOnly 中的字段不允许修改。
+func MergePatchUpdate(patch map[string]interface{}, model interface{}, readOnly ...string) (set, unset map[string]interface{}, err error) {
+	rt := reflect.TypeOf(model)
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	if rt.Kind() != reflect.Struct {
+		return nil, nil, fmt.Errorf("patch target must be a struct")
+	}
+
+	p := &mergePatcher{
+		readOnly: make(map[string]bool),
+		set:      make(map[string]interface{}),
+		unset:    make(map[string]interface{}),
+	}
+	for _, path := range readOnly {
+		p.readOnly[path] = true
+	}
+	if err := p.patchStruct(patch, rt, "", ""); err != nil {
+		return nil, nil, err
+	}
+	if len(p.set) == 0 && len(p.unset) == 0 {
+		return nil, nil, fmt.Errorf("patch has no fields")
+	}
+	return p.set, p.unset, nil
+}
+
+type mergePatcher struct {
+	readOnly map[string]bool
+	set      map[string]interface{}
+	unset    map[string]interface{}
+}
+
+func (p *mergePatcher) patchStruct(patch map[string]interface{}, rt reflect.Type, jsonPath, bsonPath string) error {
+	fields := make(map[string]reflect.StructField, rt.NumField())
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if !field.IsExported() || name == "-" || field.Tag.Get("bson") == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field
+	}
+
+	for key, value := range patch {
+		field, ok := fields[key]
+		fieldJSONPath := joinPath(jsonPath, key)
+		if !ok {
+			return fmt.Errorf("unknown field: %s", fieldJSONPath)
+		}
+		if p.readOnly[fieldJSONPath] {
+			return fmt.Errorf("field %s cannot be patched", fieldJSONPath)
+		}
+		bsonName := strings.Split(field.Tag.Get("bson"), ",")[0]
+		if bsonName == "" {
+			bsonName = strings.ToLower(field.Name)
+		}
+		if err := p.patchValue(value, field.Type, fieldJSONPath, joinPath(bsonPath, bsonName)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *mergePatcher) patchValue(value interface{}, rt reflect.Type, jsonPath, bsonPath string) error {
+	obj, isObject := value.(map[string]interface{})
+	switch {
+	case value == nil:
+		zero := reflect.New(rt).Elem()
+		fillEmpty(zero)
+		p.set[bsonPath] = zero.Interface()
+		return nil
+	case isObject && rt.Kind() == reflect.Struct:
+		return p.patchStruct(obj, rt, jsonPath, bsonPath)
+	case isObject && rt.Kind() == reflect.Map && rt.Key().Kind() == reflect.String:
+		for key, item := range obj {
+			if key == "" || strings.ContainsAny(key, ".$") {
+				return fmt.Errorf("invalid key %q in %s", key, jsonPath)
+			}
+			if item == nil {
+				p.unset[bsonPath+"."+key] = ""
+				continue
+			}
+			if err := p.setDecoded(item, rt.Elem(), jsonPath+"."+key, bsonPath+"."+key); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return p.setDecoded(value, rt, jsonPath, bsonPath)
+	}
+}
+
+// setDecoded 按字段类型解码补丁值，类型不符时返回错误
+func (p *mergePatcher) setDecoded(value interface{}, rt reflect.Type, jsonPath, bsonPath string) error {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	decoded := reflect.New(rt)
+	if err := json.Unmarshal(raw, decoded.Interface()); err != nil {
+		return fmt.Errorf("invalid value for %s: expected %s", jsonPath, rt.String())
+	}
+	p.set[bsonPath] = decoded.Elem().Interface()
+	return nil
+}
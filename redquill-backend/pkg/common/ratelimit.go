@@ -0,0 +1,71 @@
+package common
+
+import (
+	"sync"
+	"time"
+)
+
+// SlidingWindowLimiter 进程内滑动窗口计数器，适用于单实例部署
+type SlidingWindowLimiter struct {
+	mu     sync.Mutex
+	window time.Duration
+	hits   map[string][]time.Time
+}
+
+// NewSlidingWindowLimiter 创建滑动窗口计数器
+func NewSlidingWindowLimiter(window time.Duration) *SlidingWindowLimiter {
+	return &SlidingWindowLimiter{
+		window: window,
+		hits:   make(map[string][]time.Time),
+	}
+}
+
+// Allow 在同一次加锁内检查并记录一次请求，并发请求不会同时通过最后一个名额；
+// 超限时不记录，返回 false 以及窗口内最早一次请求过期的时间
+func (l *SlidingWindowLimiter) Allow(key string, limit int, now time.Time) (bool, time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	hits := l.prune(key, now)
+	if len(hits) >= limit {
+		return false, hits[len(hits)-limit].Add(l.window)
+	}
+	l.hits[key] = append(hits, now)
+	return true, time.Time{}
+}
+
+// Release 撤销 Allow 在 at 时刻记录的一次请求，用于后续检查未通过时归还名额
+func (l *SlidingWindowLimiter) Release(key string, at time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	hits := l.hits[key]
+	for i := len(hits) - 1; i >= 0; i-- {
+		if hits[i].Equal(at) {
+			hits = append(hits[:i], hits[i+1:]...)
+			break
+		}
+	}
+	if len(hits) == 0 {
+		delete(l.hits, key)
+		return
+	}
+	l.hits[key] = hits
+}
+
+// prune 清理窗口外的记录，需持有锁
+func (l *SlidingWindowLimiter) prune(key string, now time.Time) []time.Time {
+	hits := l.hits[key]
+	cutoff := now.Add(-l.window)
+	i := 0
+	for i < len(hits) && !hits[i].After(cutoff) {
+		i++
+	}
+	hits = hits[i:]
+	if len(hits) == 0 {
+		delete(l.hits, key)
+		return nil
+	}
+	l.hits[key] = hits
+	return hits
+}
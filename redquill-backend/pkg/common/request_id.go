@@ -0,0 +1,19 @@
+package common
+
+import "context"
+
+type requestIDKey struct{}
+
+// WithRequestID 在上下文中记录请求ID，使后台执行的生成也能关联到发起的请求
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	if requestID == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext 上下文中的请求ID，没有时为空
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
@@ -3,7 +3,9 @@ package config
 import (
 	"log"
 	"os"
+	"redquill-backend/pkg/models"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -15,6 +17,25 @@ type Config struct {
 	DBName   string
     JWTSecret string
     JWTTTLMin int
+	// AdminEmails 启动时提升为管理员的用户邮箱
+	AdminEmails []string
+	// LLMMasterKey 加密LLM API Key的主密钥
+	LLMMasterKey string
+	// QuotaBackend 每分钟请求计数方式：memory（进程内，单实例）| usage（用量记录，多实例）
+	QuotaBackend string
+	// DefaultUserQuota / DefaultModelQuota 未单独配置配额时的默认值，0 表示不限制
+	DefaultUserQuota  models.Quota
+	DefaultModelQuota models.Quota
+	// JobWorkers / JobQueueSize 异步生成任务的并发数与排队上限
+	JobWorkers   int
+	JobQueueSize int
+	// TrashRetentionDays 删除的小说在回收站中的保留天数；TrashPurgeIntervalMin 清理过期小说的间隔
+	TrashRetentionDays    int
+	TrashPurgeIntervalMin int
+	// ImportMaxSizeMB 导入稿件文件的大小上限
+	ImportMaxSizeMB int
+	// GenerationLogRetentionDays 生成日志的保留天数，0 表示永久保留
+	GenerationLogRetentionDays int
 }
 
 func Load() Config {
@@ -41,6 +62,25 @@ func Load() Config {
         DBName:   getenv("MONGO_DB", "redquill"),
         JWTSecret: getenv("JWT_SECRET", "dev-secret-change-me"),
         JWTTTLMin: atoi(getenv("JWT_TTL_MIN", "120"), 120),
+		AdminEmails: splitList(os.Getenv("ADMIN_EMAILS")),
+		LLMMasterKey: getenv("LLM_MASTER_KEY", "dev-master-key-change-me"),
+		QuotaBackend: getenv("QUOTA_BACKEND", "memory"),
+		DefaultUserQuota: models.Quota{
+			RequestsPerMinute: atoi(os.Getenv("QUOTA_USER_RPM"), 0),
+			TokensPerDay:      int64(atoi(os.Getenv("QUOTA_USER_TOKENS_PER_DAY"), 0)),
+			CostPerMonth:      atof(os.Getenv("QUOTA_USER_COST_PER_MONTH"), 0),
+		},
+		DefaultModelQuota: models.Quota{
+			RequestsPerMinute: atoi(os.Getenv("QUOTA_MODEL_RPM"), 0),
+			TokensPerDay:      int64(atoi(os.Getenv("QUOTA_MODEL_TOKENS_PER_DAY"), 0)),
+			CostPerMonth:      atof(os.Getenv("QUOTA_MODEL_COST_PER_MONTH"), 0),
+		},
+		JobWorkers:   atoi(os.Getenv("JOB_WORKERS"), 2),
+		JobQueueSize: atoi(os.Getenv("JOB_QUEUE_SIZE"), 100),
+		TrashRetentionDays:    atoi(os.Getenv("TRASH_RETENTION_DAYS"), 30),
+		TrashPurgeIntervalMin: atoi(os.Getenv("TRASH_PURGE_INTERVAL_MIN"), 60),
+		ImportMaxSizeMB:       atoi(os.Getenv("IMPORT_MAX_SIZE_MB"), 20),
+		GenerationLogRetentionDays: atoi(os.Getenv("GENERATION_LOG_RETENTION_DAYS"), 30),
 	}
 }
 
@@ -58,4 +98,19 @@ func atoi(s string, def int) int {
     return def
 }
 
+func atof(s string, def float64) float64 {
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	return def
+}
 
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
@@ -0,0 +1,74 @@
+// Package handlers
+/*
+/@Author: urmsone urmsone@163.com
+/@Date: 2025/10/20 20:44
+/@Name: chapter_review_handler.go
+/@Description: Chapter quality review handlers implementation
+/*/
+
+package handlers
+
+import (
+	"net/http"
+	"redquill-backend/pkg/models"
+	"redquill-backend/pkg/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// PostChapterReviewsHandler 审核章节：POST /chapter/:id/review
+// 使用 quality_review 模板生成审核报告，保存后更新章节的质量指标
+func PostChapterReviewsHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			LLMModelID string `json:"llm_model_id" binding:"required"`
+			models.GenerationOptions
+		}
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		chapter, ok := authorizeChapter(c, client, dbName, c.Param("id"), true)
+		if !ok {
+			return
+		}
+		if !checkPromptTemplate(c, client, dbName, req.TemplateID, "quality_review") {
+			return
+		}
+		req.UserID = c.GetString("uid")
+
+		review, err := services.NewChapterReviewService(client, dbName).ReviewChapter(
+			c.Request.Context(),
+			chapter.ID,
+			req.LLMModelID,
+			req.GenerationOptions,
+		)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, review)
+	}
+}
+
+// ListChapterReviewsHandler 获取章节的审核报告：GET /chapter/:id/reviews
+func ListChapterReviewsHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		chapter, ok := authorizeChapter(c, client, dbName, c.Param("id"), false)
+		if !ok {
+			return
+		}
+
+		reviews, err := services.NewChapterReviewService(client, dbName).ListChapterReviews(c.Request.Context(), chapter.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, reviews)
+	}
+}
@@ -0,0 +1,79 @@
+// Package handlers
+/*
+/@Author: urmsone urmsone@163.com
+/@Date: 2025/10/20 20:44
+/@Name: chapter_revision_handler.go
+/@Description: Chapter revision handlers implementation
+/*/
+
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"redquill-backend/pkg/models"
+	"redquill-backend/pkg/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// PostChapterRevisionsHandler 按审核报告修订章节：POST /chapter/:id/revise
+// 修订结果保存为新版本；auto 为 true 时循环 审核 → 修订 直到评分达到阈值或轮数上限
+func PostChapterRevisionsHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			services.RevisionOptions
+			models.GenerationOptions
+		}
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		chapter, ok := authorizeChapter(c, client, dbName, c.Param("id"), true)
+		if !ok {
+			return
+		}
+		if !checkPromptTemplate(c, client, dbName, req.TemplateID, "chapter_revision") {
+			return
+		}
+		req.UserID = c.GetString("uid")
+
+		run, err := services.NewChapterRevisionService(client, dbName).ReviseChapter(
+			c.Request.Context(),
+			chapter.ID,
+			req.RevisionOptions,
+			req.GenerationOptions,
+		)
+		if err != nil {
+			if errors.Is(err, services.ErrNoReview) || errors.Is(err, services.ErrNoRevisionIssues) || errors.Is(err, services.ErrChapterConflict) {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, run)
+	}
+}
+
+// ListChapterRevisionsHandler 获取章节的修订记录：GET /chapter/:id/revisions
+func ListChapterRevisionsHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		chapter, ok := authorizeChapter(c, client, dbName, c.Param("id"), false)
+		if !ok {
+			return
+		}
+
+		runs, err := services.NewChapterRevisionService(client, dbName).ListChapterRevisions(c.Request.Context(), chapter.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, runs)
+	}
+}
@@ -0,0 +1,146 @@
+// Package handlers
+/*
+/@Author: urmsone urmsone@163.com
+/@Date: 2025/10/20 20:44
+/@Name: chapter_version_handler.go
+/@Description: Chapter version handlers implementation
+/*/
+
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"redquill-backend/pkg/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ListChapterVersionsHandler 获取章节版本列表（不含正文）：GET /chapter/:id/versions
+func ListChapterVersionsHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		chapter, ok := authorizeChapter(c, client, dbName, c.Param("id"), false)
+		if !ok {
+			return
+		}
+
+		versions, err := services.NewChapterVersionService(client, dbName).ListChapterVersions(c.Request.Context(), chapter.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, versions)
+	}
+}
+
+// GetChapterVersionHandler 获取章节的指定版本：GET /chapter/:id/versions/:version
+func GetChapterVersionHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		version, err := strconv.Atoi(c.Param("version"))
+		if err != nil || version < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid version"})
+			return
+		}
+
+		chapter, ok := authorizeChapter(c, client, dbName, c.Param("id"), false)
+		if !ok {
+			return
+		}
+
+		out, err := services.NewChapterVersionService(client, dbName).GetChapterVersion(c.Request.Context(), chapter.ID, version)
+		if err != nil {
+			writeChapterVersionError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+// GetChapterDiffHandler 比较章节两个版本：GET /chapter/:id/diff?from=1&to=2&mode=line|paragraph
+// from 默认为 to 的上一版本，to 默认为当前版本
+func GetChapterDiffHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		from, err := parseVersionQuery(c, "from")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		to, err := parseVersionQuery(c, "to")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		mode := c.DefaultQuery("mode", services.DiffModeLine)
+		if mode != services.DiffModeLine && mode != services.DiffModeParagraph {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be line or paragraph"})
+			return
+		}
+
+		chapter, ok := authorizeChapter(c, client, dbName, c.Param("id"), false)
+		if !ok {
+			return
+		}
+
+		diff, err := services.NewChapterVersionService(client, dbName).DiffChapterVersions(c.Request.Context(), chapter.ID, from, to, mode)
+		if err != nil {
+			writeChapterVersionError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, diff)
+	}
+}
+
+// PostChapterRollbackHandler 将章节回滚到指定版本：POST /chapter/:id/rollback
+func PostChapterRollbackHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Version int `json:"version" binding:"required,min=1"`
+		}
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		chapter, ok := authorizeChapter(c, client, dbName, c.Param("id"), true)
+		if !ok {
+			return
+		}
+
+		chapter, err := services.NewChapterVersionService(client, dbName).RollbackChapter(c.Request.Context(), chapter.ID, req.Version)
+		if err != nil {
+			writeChapterVersionError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, chapter)
+	}
+}
+
+// parseVersionQuery 解析可选的版本号查询参数，未提供时返回0
+func parseVersionQuery(c *gin.Context, key string) (int, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return 0, nil
+	}
+	version, err := strconv.Atoi(raw)
+	if err != nil || version < 1 {
+		return 0, errors.New("invalid " + key + " version")
+	}
+	return version, nil
+}
+
+// writeChapterVersionError 将章节版本错误转换为HTTP响应
+func writeChapterVersionError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrChapterVersionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
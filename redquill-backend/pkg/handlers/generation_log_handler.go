@@ -0,0 +1,83 @@
+// Package handlers
+/*
+/@Author: urmsone urmsone@163.com
+/@Date: 2025/10/20 20:44
+/@Name: generation_log_handler.go
+/@Description: Generation audit log handlers implementation
+/*/
+
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"redquill-backend/pkg/common"
+	"redquill-backend/pkg/services"
+)
+
+// ListGenerationLogsHandler 分页查询生成日志：GET /generation-logs
+// 支持 novel_id、user_id（仅管理员）、template_type、template_id、llm_model_id、request_id、status 与 from/to 过滤，
+// 列表不包含Prompt与模型输出，详情通过 GET /generation-log/:id 获取
+func ListGenerationLogsHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, size, sortExpr, _ := common.ParseCommonQueryParams(c.Request.URL.Query())
+
+		from, err := parseTimeParam(c.Query("from"), false)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		to, err := parseTimeParam(c.Query("to"), true)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		result, err := services.NewGenerationLogService(client, dbName).ListGenerationLogs(
+			c.Request.Context(),
+			c.GetString("uid"),
+			c.GetString("role"),
+			services.GenerationLogFilter{
+				UserID:       c.Query("user_id"),
+				NovelID:      c.Query("novel_id"),
+				TemplateType: c.Query("template_type"),
+				TemplateID:   c.Query("template_id"),
+				LLMModelID:   c.Query("llm_model_id"),
+				RequestID:    c.Query("request_id"),
+				Status:       c.Query("status"),
+				From:         from,
+				To:           to,
+			},
+			page, size, sortExpr,
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// GetGenerationLogHandler 获取生成日志详情，仅日志所属用户或管理员可查看
+func GetGenerationLogHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		entry, err := services.NewGenerationLogService(client, dbName).GetGenerationLogs(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			if errors.Is(err, services.ErrGenerationLogNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !isSelfOrAdmin(c, entry.UserID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "no permission to access this generation log"})
+			return
+		}
+		c.JSON(http.StatusOK, entry)
+	}
+}
@@ -0,0 +1,151 @@
+// Package handlers
+/*
+/@Author: urmsone urmsone@163.com
+/@Date: 2025/10/20 20:44
+/@Name: job_handler.go
+/@Description: Asynchronous generation job handlers implementation
+/*/
+
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"redquill-backend/pkg/common"
+	"redquill-backend/pkg/models"
+	"redquill-backend/pkg/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// PostJobsHandler 提交异步生成任务
+func PostJobsHandler(client *mongo.Client, dbName string, runner *services.JobRunner) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Type         string                 `json:"type" binding:"required"`
+			NovelID      string                 `json:"novel_id" binding:"required"`
+			LLMModelID   string                 `json:"llm_model_id" binding:"required"`
+			InputData    map[string]interface{} `json:"input_data" binding:"required"`
+			TemplateType string                 `json:"template_type"`
+			models.GenerationOptions
+		}
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !models.IsValidJobType(req.Type) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job type"})
+			return
+		}
+		if req.Type == models.JobTypeLLM && req.TemplateType == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "template_type is required for llm jobs"})
+			return
+		}
+		if req.Type == models.JobTypeCharactersFromOutline {
+			if outlineID, _ := req.InputData["outline_id"].(string); outlineID == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "input_data.outline_id is required"})
+				return
+			}
+		}
+
+		if _, ok := authorizeNovel(c, client, dbName, req.NovelID, true); !ok {
+			return
+		}
+		templateType := models.GenerationJob{Type: req.Type, TemplateType: req.TemplateType}.PromptTemplateType()
+		if !checkPromptTemplate(c, client, dbName, req.TemplateID, templateType) {
+			return
+		}
+
+		job, err := runner.Submit(c.Request.Context(), models.GenerationJob{
+			Type:         req.Type,
+			NovelID:      req.NovelID,
+			LLMModelID:   req.LLMModelID,
+			InputData:    req.InputData,
+			TemplateType: req.TemplateType,
+			Options:      req.GenerationOptions,
+			UserID:       c.GetString("uid"),
+		})
+		if err != nil {
+			if errors.Is(err, services.ErrJobQueueFull) {
+				c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusAccepted, job)
+	}
+}
+
+// GetJobsHandler 查询任务状态与结果
+func GetJobsHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		job, ok := authorizeJob(c, client, dbName, c.Param("id"))
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, job)
+	}
+}
+
+// ListJobsHandler 分页查询任务，支持 novel_id 与 status 过滤
+func ListJobsHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, size, sortExpr, _ := common.ParseCommonQueryParams(c.Request.URL.Query())
+		result, err := services.NewJobService(client, dbName).ListJobs(
+			c.Request.Context(),
+			c.GetString("uid"),
+			c.GetString("role"),
+			c.Query("novel_id"),
+			c.Query("status"),
+			page, size, sortExpr,
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// DeleteJobsHandler 取消任务
+func DeleteJobsHandler(client *mongo.Client, dbName string, runner *services.JobRunner) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := authorizeJob(c, client, dbName, c.Param("id")); !ok {
+			return
+		}
+
+		job, err := runner.Cancel(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			if errors.Is(err, services.ErrJobFinished) {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "status": job.Status})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, job)
+	}
+}
+
+// authorizeJob 获取任务并校验当前用户是否为任务创建者或管理员
+func authorizeJob(c *gin.Context, client *mongo.Client, dbName, jobID string) (models.GenerationJob, bool) {
+	job, err := services.NewJobService(client, dbName).GetJobs(c.Request.Context(), jobID)
+	if err != nil {
+		if errors.Is(err, services.ErrJobNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return models.GenerationJob{}, false
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return models.GenerationJob{}, false
+	}
+	if !isSelfOrAdmin(c, job.UserID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "no permission to access this job"})
+		return models.GenerationJob{}, false
+	}
+	return job, true
+}
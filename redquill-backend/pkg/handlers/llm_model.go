@@ -31,6 +31,7 @@ func PostLLMModelsHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
 			CostPerToken     float64               `json:"cost_per_token"`
 			Status           string                `json:"status" binding:"required"`
 			Config           models.LLMModelConfig `json:"config" binding:"required"`
+			Quota            *models.Quota         `json:"quota"`
 		}
 
 		if err := c.ShouldBindJSON(&req); err != nil {
@@ -53,6 +54,7 @@ func PostLLMModelsHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
 			req.CostPerToken,
 			req.Status,
 			req.Config,
+			req.Quota,
 			creatorID,
 			creator,
 		)
@@ -62,6 +64,7 @@ func PostLLMModelsHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
 			return
 		}
 
+		services.RedactLLMModel(&llmModel)
 		c.JSON(http.StatusCreated, llmModel)
 	}
 }
@@ -75,6 +78,10 @@ func GetLLMModelsHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
 			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 			return
 		}
+		services.RedactLLMModel(&llmModel)
+		if c.GetString("role") != models.RoleAdmin {
+			llmModel.Config.APIKey = ""
+		}
 		c.JSON(http.StatusOK, llmModel)
 	}
 }
@@ -93,6 +100,7 @@ func PutLLMModelsHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
 			CostPerToken     *float64               `json:"cost_per_token"`
 			Status           *string                `json:"status"`
 			Config           *models.LLMModelConfig `json:"config"`
+			Quota            *models.Quota          `json:"quota"`
 		}
 
 		if err := c.ShouldBindJSON(&req); err != nil {
@@ -112,6 +120,7 @@ func PutLLMModelsHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
 			req.CostPerToken,
 			req.Status,
 			req.Config,
+			req.Quota,
 		)
 
 		if err != nil {
@@ -119,6 +128,7 @@ func PutLLMModelsHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
 			return
 		}
 
+		services.RedactLLMModel(&llmModel)
 		c.JSON(http.StatusOK, llmModel)
 	}
 }
@@ -144,6 +154,13 @@ func ListLLMModelsHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		// API Key 仅返回脱敏值，非管理员不返回
+		for i := range result.Items {
+			services.RedactLLMModel(&result.Items[i])
+			if c.GetString("role") != models.RoleAdmin {
+				result.Items[i].Config.APIKey = ""
+			}
+		}
 		c.JSON(http.StatusOK, result)
 	}
 }
@@ -174,6 +191,7 @@ func TestLLMModelsHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
 		testReq := models.LLMModelTestRequest{
 			Messages: messages,
 			Stream:   req.Stream,
+			UserID:   c.GetString("uid"),
 		}
 
 		// 如果请求流式响应
@@ -0,0 +1,147 @@
+// Package handlers
+/*
+/@Author: urmsone urmsone@163.com
+/@Date: 2025/10/20 20:44
+/@Name: novel_export_handler.go
+/@Description: Novel export handlers implementation
+/*/
+
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"redquill-backend/pkg/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// exportContentTypes 各导出格式的 Content-Type
+var exportContentTypes = map[string]string{
+	services.ExportFormatEPUB:     "application/epub+zip",
+	services.ExportFormatMarkdown: "text/markdown; charset=utf-8",
+	services.ExportFormatTXT:      "text/plain; charset=utf-8",
+	services.ExportFormatDOCX:     "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+}
+
+// ExportNovelHandler 导出小说：GET /novel/:id/export?format=epub|md|txt|docx&from=1&to=50&summaries=true&appendix=true&zip=true
+// 章节数超过 services.ExportZipChapterThreshold 时，md/txt/docx 自动按章节打包为 zip 流式返回
+func ExportNovelHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		opts := services.ExportOptions{
+			Format:    c.DefaultQuery("format", services.ExportFormatEPUB),
+			Summaries: c.Query("summaries") == "true",
+			Appendix:  c.Query("appendix") == "true",
+		}
+		if !services.IsExportFormat(opts.Format) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported export format: " + opts.Format})
+			return
+		}
+		var err error
+		if opts.FromChapter, err = parseChapterNumberQuery(c, "from"); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if opts.ToChapter, err = parseChapterNumberQuery(c, "to"); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if opts.FromChapter > 0 && opts.ToChapter > 0 && opts.FromChapter > opts.ToChapter {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must not be greater than to"})
+			return
+		}
+		bundle := c.Query("zip") == "true"
+		if bundle && opts.Format == services.ExportFormatEPUB {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "zip bundles are not available for epub"})
+			return
+		}
+
+		novel, ok := authorizeNovel(c, client, dbName, c.Param("id"), false)
+		if !ok {
+			return
+		}
+		exportService := services.NewNovelExportService(client, dbName)
+		ctx := c.Request.Context()
+
+		count, err := exportService.CountExportChapters(ctx, novel.ID, opts)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if count == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": services.ErrNoChaptersToExport.Error()})
+			return
+		}
+		name := exportFileName(novel.Title, opts)
+
+		if bundle || (opts.Format != services.ExportFormatEPUB && count > services.ExportZipChapterThreshold) {
+			// 边读边写，响应头发出后无法再返回JSON错误，只能中断连接
+			c.Header("Content-Disposition", attachmentDisposition(name, "zip"))
+			c.Header("Content-Type", "application/zip")
+			c.Status(http.StatusOK)
+			if err := exportService.ExportZip(ctx, novel.ID, opts, c.Writer); err != nil {
+				log.Printf("Failed to stream export of novel %s: %v", novel.ID, err)
+				c.Abort()
+			}
+			return
+		}
+
+		// 先完整生成再返回，生成失败时仍可返回JSON错误
+		var buf bytes.Buffer
+		if err := exportService.Export(ctx, novel.ID, opts, &buf); err != nil {
+			if errors.Is(err, services.ErrNoChaptersToExport) {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Header("Content-Disposition", attachmentDisposition(name, opts.Format))
+		c.Data(http.StatusOK, exportContentTypes[opts.Format], buf.Bytes())
+	}
+}
+
+// parseChapterNumberQuery 解析章节序号查询参数，缺省时返回 0
+func parseChapterNumberQuery(c *gin.Context, key string) (int, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid %s: must be a positive chapter number", key)
+	}
+	return n, nil
+}
+
+// exportFileName 下载文件名（不含扩展名），导出部分章节时附上章节范围
+func exportFileName(title string, opts services.ExportOptions) string {
+	switch {
+	case opts.FromChapter > 0 && opts.ToChapter > 0:
+		return fmt.Sprintf("%s 第%d-%d章", title, opts.FromChapter, opts.ToChapter)
+	case opts.FromChapter > 0:
+		return fmt.Sprintf("%s 第%d章起", title, opts.FromChapter)
+	case opts.ToChapter > 0:
+		return fmt.Sprintf("%s 第1-%d章", title, opts.ToChapter)
+	}
+	return title
+}
+
+// attachmentDisposition 构建下载文件名，非ASCII书名使用 RFC 5987 编码
+func attachmentDisposition(title, ext string) string {
+	name := strings.TrimSpace(title)
+	if name == "" {
+		name = "novel"
+	}
+	name = strings.NewReplacer("/", "_", "\\", "_", "\"", "_").Replace(name) + "." + ext
+	return fmt.Sprintf(`attachment; filename="novel.%s"; filename*=UTF-8''%s`, ext, url.PathEscape(name))
+}
@@ -9,6 +9,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"redquill-backend/pkg/models"
 	"redquill-backend/pkg/services"
@@ -18,13 +19,14 @@ import (
 )
 
 // GenerateStoryCoreHandler 生成故事核心
-func GenerateStoryCoreHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+func GenerateStoryCoreHandler(client *mongo.Client, dbName string, hub *services.StreamHub) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req struct {
 			NovelID    string                 `json:"novel_id" binding:"required"`
 			LLMModelID string                 `json:"llm_model_id" binding:"required"`
 			InputData  map[string]interface{} `json:"input_data" binding:"required"`
 			Stream     bool                   `json:"stream,omitempty"`
+			models.GenerationOptions
 		}
 
 		if err := c.ShouldBindJSON(&req); err != nil {
@@ -32,9 +34,17 @@ func GenerateStoryCoreHandler(client *mongo.Client, dbName string) gin.HandlerFu
 			return
 		}
 
+		if _, ok := authorizeNovel(c, client, dbName, req.NovelID, true); !ok {
+			return
+		}
+		if !checkPromptTemplate(c, client, dbName, req.TemplateID, "story_core") {
+			return
+		}
+		req.UserID = c.GetString("uid")
+
 		// 如果请求流式响应
 		if req.Stream {
-			GenerateStoryCoreStreamHandler(client, dbName, req.NovelID, req.LLMModelID, req.InputData)(c)
+			GenerateStoryCoreStreamHandler(client, dbName, hub, req.NovelID, req.LLMModelID, req.InputData, req.GenerationOptions)(c)
 			return
 		}
 
@@ -43,6 +53,7 @@ func GenerateStoryCoreHandler(client *mongo.Client, dbName string) gin.HandlerFu
 			req.NovelID,
 			req.LLMModelID,
 			req.InputData,
+			req.GenerationOptions,
 		)
 
 		if err != nil {
@@ -55,63 +66,32 @@ func GenerateStoryCoreHandler(client *mongo.Client, dbName string) gin.HandlerFu
 }
 
 // GenerateStoryCoreStreamHandler 流式生成故事核心
-func GenerateStoryCoreStreamHandler(client *mongo.Client, dbName string, novelID, llmModelID string, inputData map[string]interface{}) gin.HandlerFunc {
+func GenerateStoryCoreStreamHandler(client *mongo.Client, dbName string, hub *services.StreamHub, novelID, llmModelID string, inputData map[string]interface{}, opts models.GenerationOptions) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 设置流式响应头
-		c.Header("Content-Type", "text/event-stream")
-		c.Header("Cache-Control", "no-cache")
-		c.Header("Connection", "keep-alive")
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Headers", "Cache-Control")
-
 		// 创建流式生成请求
 		generationReq := models.GenerationRequest{
-			NovelID:      novelID,
-			LLMModelID:   llmModelID,
-			InputData:    inputData,
-			TemplateType: "story_core",
-			Stream:       true,
+			NovelID:           novelID,
+			LLMModelID:        llmModelID,
+			InputData:         inputData,
+			TemplateType:      "story_core",
+			Stream:            true,
+			GenerationOptions: opts,
 		}
 
-		// 调用流式生成服务
-		templateService := services.NewPromptTemplateService(client, dbName)
-		response, err := templateService.GenerateWithLLMStream(c.Request.Context(), generationReq)
-		if err != nil {
-			c.SSEvent("error", gin.H{"error": err.Error()})
-			return
-		}
-
-		// 发送流式数据
-		chunkCount := 0
-		for chunk := range response {
-			chunkCount++
-			if chunk.Error != nil {
-				c.SSEvent("error", gin.H{"error": chunk.Error.Error()})
-				return
-			}
-
-			// 发送数据块
-			c.SSEvent("data", gin.H{
-				"content": chunk.Content,
-				"done":    chunk.Done,
-			})
-			c.Writer.Flush()
-
-			if chunk.Done {
-				break
-			}
-		}
+		// 后台执行流式生成，断线后可通过 GET /generate/stream/:generation_id 续传
+		serveGenerationStream(c, hub.StartGeneration(c.Request.Context(), generationReq, inputData), 0)
 	}
 }
 
 // GenerateWorldviewHandler 生成世界观
-func GenerateWorldviewHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+func GenerateWorldviewHandler(client *mongo.Client, dbName string, hub *services.StreamHub) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req struct {
 			NovelID    string                 `json:"novel_id" binding:"required"`
 			LLMModelID string                 `json:"llm_model_id" binding:"required"`
 			InputData  map[string]interface{} `json:"input_data" binding:"required"`
 			Stream     bool                   `json:"stream,omitempty"`
+			models.GenerationOptions
 		}
 
 		if err := c.ShouldBindJSON(&req); err != nil {
@@ -119,9 +99,17 @@ func GenerateWorldviewHandler(client *mongo.Client, dbName string) gin.HandlerFu
 			return
 		}
 
+		if _, ok := authorizeNovel(c, client, dbName, req.NovelID, true); !ok {
+			return
+		}
+		if !checkPromptTemplate(c, client, dbName, req.TemplateID, "worldview") {
+			return
+		}
+		req.UserID = c.GetString("uid")
+
 		// 如果请求流式响应
 		if req.Stream {
-			GenerateWorldviewStreamHandler(client, dbName, req.NovelID, req.LLMModelID, req.InputData)(c)
+			GenerateWorldviewStreamHandler(client, dbName, hub, req.NovelID, req.LLMModelID, req.InputData, req.GenerationOptions)(c)
 			return
 		}
 
@@ -130,6 +118,7 @@ func GenerateWorldviewHandler(client *mongo.Client, dbName string) gin.HandlerFu
 			req.NovelID,
 			req.LLMModelID,
 			req.InputData,
+			req.GenerationOptions,
 		)
 
 		if err != nil {
@@ -142,13 +131,14 @@ func GenerateWorldviewHandler(client *mongo.Client, dbName string) gin.HandlerFu
 }
 
 // GenerateCharacterHandler 生成角色
-func GenerateCharacterHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+func GenerateCharacterHandler(client *mongo.Client, dbName string, hub *services.StreamHub) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req struct {
 			NovelID    string                 `json:"novel_id" binding:"required"`
 			LLMModelID string                 `json:"llm_model_id" binding:"required"`
 			InputData  map[string]interface{} `json:"input_data" binding:"required"`
 			Stream     bool                   `json:"stream,omitempty"`
+			models.GenerationOptions
 		}
 
 		if err := c.ShouldBindJSON(&req); err != nil {
@@ -156,9 +146,17 @@ func GenerateCharacterHandler(client *mongo.Client, dbName string) gin.HandlerFu
 			return
 		}
 
+		if _, ok := authorizeNovel(c, client, dbName, req.NovelID, true); !ok {
+			return
+		}
+		if !checkPromptTemplate(c, client, dbName, req.TemplateID, "character") {
+			return
+		}
+		req.UserID = c.GetString("uid")
+
 		// 如果请求流式响应
 		if req.Stream {
-			GenerateCharacterStreamHandler(client, dbName, req.NovelID, req.LLMModelID, req.InputData)(c)
+			GenerateCharacterStreamHandler(client, dbName, hub, req.NovelID, req.LLMModelID, req.InputData, req.GenerationOptions)(c)
 			return
 		}
 
@@ -167,6 +165,7 @@ func GenerateCharacterHandler(client *mongo.Client, dbName string) gin.HandlerFu
 			req.NovelID,
 			req.LLMModelID,
 			req.InputData,
+			req.GenerationOptions,
 		)
 
 		if err != nil {
@@ -179,13 +178,14 @@ func GenerateCharacterHandler(client *mongo.Client, dbName string) gin.HandlerFu
 }
 
 // GenerateChapterHandler 生成章节
-func GenerateChapterHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+func GenerateChapterHandler(client *mongo.Client, dbName string, hub *services.StreamHub) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req struct {
 			NovelID    string                 `json:"novel_id" binding:"required"`
 			LLMModelID string                 `json:"llm_model_id" binding:"required"`
 			InputData  map[string]interface{} `json:"input_data" binding:"required"`
 			Stream     bool                   `json:"stream,omitempty"`
+			models.GenerationOptions
 		}
 
 		if err := c.ShouldBindJSON(&req); err != nil {
@@ -193,9 +193,17 @@ func GenerateChapterHandler(client *mongo.Client, dbName string) gin.HandlerFunc
 			return
 		}
 
+		if _, ok := authorizeNovel(c, client, dbName, req.NovelID, true); !ok {
+			return
+		}
+		if !checkPromptTemplate(c, client, dbName, req.TemplateID, "chapter") {
+			return
+		}
+		req.UserID = c.GetString("uid")
+
 		// 如果请求流式响应
 		if req.Stream {
-			GenerateChapterStreamHandler(client, dbName, req.NovelID, req.LLMModelID, req.InputData)(c)
+			GenerateChapterStreamHandler(client, dbName, hub, req.NovelID, req.LLMModelID, req.InputData, req.GenerationOptions)(c)
 			return
 		}
 
@@ -204,9 +212,14 @@ func GenerateChapterHandler(client *mongo.Client, dbName string) gin.HandlerFunc
 			req.NovelID,
 			req.LLMModelID,
 			req.InputData,
+			req.GenerationOptions,
 		)
 
 		if err != nil {
+			if errors.Is(err, services.ErrChapterConflict) {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			}
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
@@ -216,54 +229,24 @@ func GenerateChapterHandler(client *mongo.Client, dbName string) gin.HandlerFunc
 }
 
 // GenerateChapterStreamHandler 流式生成章节
-func GenerateChapterStreamHandler(client *mongo.Client, dbName string, novelID, llmModelID string, inputData map[string]interface{}) gin.HandlerFunc {
+func GenerateChapterStreamHandler(client *mongo.Client, dbName string, hub *services.StreamHub, novelID, llmModelID string, inputData map[string]interface{}, opts models.GenerationOptions) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 设置流式响应头
-		c.Header("Content-Type", "text/event-stream")
-		c.Header("Cache-Control", "no-cache")
-		c.Header("Connection", "keep-alive")
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Headers", "Cache-Control")
-
 		// 准备LLM输入数据（处理章节大纲信息等）
 		generationService := services.NewNovelGenerationService(client, dbName)
 		llmInputData := generationService.PrepareChapterInputData(c.Request.Context(), novelID, inputData)
 
 		// 创建流式生成请求
 		generationReq := models.GenerationRequest{
-			NovelID:      novelID,
-			LLMModelID:   llmModelID,
-			InputData:    llmInputData,
-			TemplateType: "chapter",
-			Stream:       true,
-		}
-
-		// 调用流式生成服务
-		templateService := services.NewPromptTemplateService(client, dbName)
-		response, err := templateService.GenerateWithLLMStream(c.Request.Context(), generationReq)
-		if err != nil {
-			c.SSEvent("error", gin.H{"error": err.Error()})
-			return
+			NovelID:           novelID,
+			LLMModelID:        llmModelID,
+			InputData:         llmInputData,
+			TemplateType:      "chapter",
+			Stream:            true,
+			GenerationOptions: opts,
 		}
 
-		// 发送流式数据
-		for chunk := range response {
-			if chunk.Error != nil {
-				c.SSEvent("error", gin.H{"error": chunk.Error.Error()})
-				return
-			}
-
-			// 发送数据块
-			c.SSEvent("data", gin.H{
-				"content": chunk.Content,
-				"done":    chunk.Done,
-			})
-			c.Writer.Flush()
-
-			if chunk.Done {
-				break
-			}
-		}
+		// 后台执行流式生成，断线后可通过 GET /generate/stream/:generation_id 续传
+		serveGenerationStream(c, hub.StartGeneration(c.Request.Context(), generationReq, inputData), 0)
 	}
 }
 
@@ -276,6 +259,7 @@ func GenerateWithLLMHandler(client *mongo.Client, dbName string) gin.HandlerFunc
 			InputData    map[string]interface{} `json:"input_data" binding:"required"`
 			TemplateType string                 `json:"template_type" binding:"required"`
 			Stream       bool                   `json:"stream,omitempty"`
+			models.GenerationOptions
 		}
 
 		if err := c.ShouldBindJSON(&req); err != nil {
@@ -283,12 +267,21 @@ func GenerateWithLLMHandler(client *mongo.Client, dbName string) gin.HandlerFunc
 			return
 		}
 
+		if _, ok := authorizeNovel(c, client, dbName, req.NovelID, true); !ok {
+			return
+		}
+		if !checkPromptTemplate(c, client, dbName, req.TemplateID, req.TemplateType) {
+			return
+		}
+		req.UserID = c.GetString("uid")
+
 		generationReq := models.GenerationRequest{
-			NovelID:      req.NovelID,
-			LLMModelID:   req.LLMModelID,
-			InputData:    req.InputData,
-			TemplateType: req.TemplateType,
-			Stream:       req.Stream,
+			NovelID:           req.NovelID,
+			LLMModelID:        req.LLMModelID,
+			InputData:         req.InputData,
+			TemplateType:      req.TemplateType,
+			Stream:            req.Stream,
+			GenerationOptions: req.GenerationOptions,
 		}
 
 		response, err := services.NewPromptTemplateService(client, dbName).GenerateWithLLM(
@@ -306,107 +299,43 @@ func GenerateWithLLMHandler(client *mongo.Client, dbName string) gin.HandlerFunc
 }
 
 // GenerateWorldviewStreamHandler 流式生成世界观
-func GenerateWorldviewStreamHandler(client *mongo.Client, dbName string, novelID, llmModelID string, inputData map[string]interface{}) gin.HandlerFunc {
+func GenerateWorldviewStreamHandler(client *mongo.Client, dbName string, hub *services.StreamHub, novelID, llmModelID string, inputData map[string]interface{}, opts models.GenerationOptions) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 设置流式响应头
-		c.Header("Content-Type", "text/event-stream")
-		c.Header("Cache-Control", "no-cache")
-		c.Header("Connection", "keep-alive")
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Headers", "Cache-Control")
-
 		// 创建流式生成请求
 		generationReq := models.GenerationRequest{
-			NovelID:      novelID,
-			LLMModelID:   llmModelID,
-			InputData:    inputData,
-			TemplateType: "worldview",
-			Stream:       true,
+			NovelID:           novelID,
+			LLMModelID:        llmModelID,
+			InputData:         inputData,
+			TemplateType:      "worldview",
+			Stream:            true,
+			GenerationOptions: opts,
 		}
 
-		// 调用流式生成服务
-		templateService := services.NewPromptTemplateService(client, dbName)
-		response, err := templateService.GenerateWithLLMStream(c.Request.Context(), generationReq)
-		if err != nil {
-			c.SSEvent("error", gin.H{"error": err.Error()})
-			return
-		}
-
-		// 发送流式数据
-		chunkCount := 0
-		for chunk := range response {
-			chunkCount++
-			if chunk.Error != nil {
-				c.SSEvent("error", gin.H{"error": chunk.Error.Error()})
-				return
-			}
-
-			// 发送数据块
-			c.SSEvent("data", gin.H{
-				"content": chunk.Content,
-				"done":    chunk.Done,
-			})
-			c.Writer.Flush()
-
-			if chunk.Done {
-				break
-			}
-		}
+		// 后台执行流式生成，断线后可通过 GET /generate/stream/:generation_id 续传
+		serveGenerationStream(c, hub.StartGeneration(c.Request.Context(), generationReq, inputData), 0)
 	}
 }
 
 // GenerateCharacterStreamHandler 流式生成角色
-func GenerateCharacterStreamHandler(client *mongo.Client, dbName string, novelID, llmModelID string, inputData map[string]interface{}) gin.HandlerFunc {
+func GenerateCharacterStreamHandler(client *mongo.Client, dbName string, hub *services.StreamHub, novelID, llmModelID string, inputData map[string]interface{}, opts models.GenerationOptions) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 设置流式响应头
-		c.Header("Content-Type", "text/event-stream")
-		c.Header("Cache-Control", "no-cache")
-		c.Header("Connection", "keep-alive")
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Headers", "Cache-Control")
-
 		// 创建流式生成请求
 		generationReq := models.GenerationRequest{
-			NovelID:      novelID,
-			LLMModelID:   llmModelID,
-			InputData:    inputData,
-			TemplateType: "character",
-			Stream:       true,
-		}
-
-		// 调用流式生成服务
-		templateService := services.NewPromptTemplateService(client, dbName)
-		response, err := templateService.GenerateWithLLMStream(c.Request.Context(), generationReq)
-		if err != nil {
-			c.SSEvent("error", gin.H{"error": err.Error()})
-			return
+			NovelID:           novelID,
+			LLMModelID:        llmModelID,
+			InputData:         inputData,
+			TemplateType:      "character",
+			Stream:            true,
+			GenerationOptions: opts,
 		}
 
-		// 发送流式数据
-		chunkCount := 0
-		for chunk := range response {
-			chunkCount++
-			if chunk.Error != nil {
-				c.SSEvent("error", gin.H{"error": chunk.Error.Error()})
-				return
-			}
-
-			// 发送数据块
-			c.SSEvent("data", gin.H{
-				"content": chunk.Content,
-				"done":    chunk.Done,
-			})
-			c.Writer.Flush()
-
-			if chunk.Done {
-				break
-			}
-		}
+		// 后台执行流式生成，断线后可通过 GET /generate/stream/:generation_id 续传
+		serveGenerationStream(c, hub.StartGeneration(c.Request.Context(), generationReq, inputData), 0)
 	}
 }
 
 // GenerateCharactersFromOutlineHandler 根据大纲流式生成角色
-func GenerateCharactersFromOutlineHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+func GenerateCharactersFromOutlineHandler(client *mongo.Client, dbName string, hub *services.StreamHub) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req struct {
 			NovelID          string `json:"novel_id" binding:"required"`
@@ -415,6 +344,7 @@ func GenerateCharactersFromOutlineHandler(client *mongo.Client, dbName string) g
 			StoryCore        string `json:"story_core" binding:"required"`
 			Worldview        string `json:"worldview" binding:"required"`
 			UserRequirements string `json:"user_requirements"`
+			models.GenerationOptions
 		}
 
 		if err := c.ShouldBindJSON(&req); err != nil {
@@ -422,12 +352,13 @@ func GenerateCharactersFromOutlineHandler(client *mongo.Client, dbName string) g
 			return
 		}
 
-		// 设置流式响应头
-		c.Header("Content-Type", "text/event-stream")
-		c.Header("Cache-Control", "no-cache")
-		c.Header("Connection", "keep-alive")
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Headers", "Cache-Control")
+		if _, ok := authorizeNovel(c, client, dbName, req.NovelID, true); !ok {
+			return
+		}
+		if !checkPromptTemplate(c, client, dbName, req.TemplateID, "batch_character") {
+			return
+		}
+		req.UserID = c.GetString("uid")
 
 		// 构建输入数据
 		inputData := map[string]interface{}{
@@ -439,40 +370,97 @@ func GenerateCharactersFromOutlineHandler(client *mongo.Client, dbName string) g
 
 		// 创建流式生成请求
 		generationReq := models.GenerationRequest{
-			NovelID:      req.NovelID,
-			LLMModelID:   req.LLMModelID,
-			InputData:    inputData,
-			TemplateType: "batch_character",
-			Stream:       true,
+			NovelID:           req.NovelID,
+			LLMModelID:        req.LLMModelID,
+			InputData:         inputData,
+			TemplateType:      "batch_character",
+			Stream:            true,
+			GenerationOptions: req.GenerationOptions,
 		}
 
-		// 调用流式生成服务
-		templateService := services.NewPromptTemplateService(client, dbName)
-		response, err := templateService.GenerateWithLLMStream(c.Request.Context(), generationReq)
-		if err != nil {
-			c.SSEvent("error", gin.H{"error": err.Error()})
-			return
+		// 后台执行流式生成，断线后可通过 GET /generate/stream/:generation_id 续传
+		serveGenerationStream(c, hub.StartGeneration(c.Request.Context(), generationReq, inputData), 0)
+	}
+}
+
+// previewTemplateTypes /generate/* 端点对应的模板类型，供预览接口的 ?type= 使用
+var previewTemplateTypes = map[string]string{
+	"story-core":              "story_core",
+	"worldview":               "worldview",
+	"character":               "character",
+	"characters-from-outline": "batch_character",
+	"outline":                 "outline",
+	"chapter":                 "chapter",
+}
+
+// PreviewGenerationHandler 预览生成请求渲染后的Prompt与各部分的预估token数，不调用模型：POST /generate/preview?type=chapter
+// 请求体与 /generate/* 相同，可原样粘贴；生成类型取自 template_type，未指定时取自 ?type= 指定的端点，
+// 请求体只含 outline_content 等字段时按 characters-from-outline 处理
+func PreviewGenerationHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			NovelID      string                 `json:"novel_id" binding:"required"`
+			LLMModelID   string                 `json:"llm_model_id"`
+			InputData    map[string]interface{} `json:"input_data"`
+			TemplateType string                 `json:"template_type"`
+			// characters-from-outline 的请求字段
+			OutlineContent   string `json:"outline_content"`
+			StoryCore        string `json:"story_core"`
+			Worldview        string `json:"worldview"`
+			UserRequirements string `json:"user_requirements"`
+			models.GenerationOptions
 		}
 
-		// 发送流式数据
-		chunkCount := 0
-		for chunk := range response {
-			chunkCount++
-			if chunk.Error != nil {
-				c.SSEvent("error", gin.H{"error": chunk.Error.Error()})
-				return
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.TemplateType == "" {
+			if endpoint := c.Query("type"); endpoint != "" {
+				templateType, ok := previewTemplateTypes[endpoint]
+				if !ok {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "unknown type, expected one of story-core, worldview, character, characters-from-outline, outline, chapter"})
+					return
+				}
+				req.TemplateType = templateType
+			} else if req.InputData == nil && req.OutlineContent != "" {
+				req.TemplateType = "batch_character"
 			}
+		}
+		if req.TemplateType == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "template_type or ?type= is required"})
+			return
+		}
 
-			// 发送数据块
-			c.SSEvent("data", gin.H{
-				"content": chunk.Content,
-				"done":    chunk.Done,
-			})
-			c.Writer.Flush()
+		if _, ok := authorizeNovel(c, client, dbName, req.NovelID, false); !ok {
+			return
+		}
+		if !checkPromptTemplate(c, client, dbName, req.TemplateID, req.TemplateType) {
+			return
+		}
 
-			if chunk.Done {
-				break
+		inputData := req.InputData
+		if inputData == nil && req.TemplateType == "batch_character" {
+			inputData = map[string]interface{}{
+				"outline_content":   req.OutlineContent,
+				"story_core":        req.StoryCore,
+				"worldview":         req.Worldview,
+				"user_requirements": req.UserRequirements,
 			}
 		}
+
+		preview, err := services.NewPromptTemplateService(client, dbName).PreviewPrompt(c.Request.Context(), models.GenerationRequest{
+			NovelID:           req.NovelID,
+			LLMModelID:        req.LLMModelID,
+			InputData:         inputData,
+			TemplateType:      req.TemplateType,
+			GenerationOptions: req.GenerationOptions,
+		})
+		if err != nil {
+			writePromptTemplateError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, preview)
 	}
 }
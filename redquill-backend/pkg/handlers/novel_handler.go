@@ -9,10 +9,12 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"redquill-backend/pkg/common"
 	"redquill-backend/pkg/models"
 	"redquill-backend/pkg/services"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -25,6 +27,7 @@ func PostNovelsHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
 			Title            string                  `json:"title" binding:"required"`
 			Status           string                  `json:"status" binding:"required"`
 			CurrentPhase     string                  `json:"current_phase" binding:"required"`
+			Shared           bool                    `json:"shared"`
 			ProjectBlueprint models.ProjectBlueprint `json:"project_blueprint" binding:"required"`
 			AIContext        models.AIContext        `json:"ai_context"`
 		}
@@ -43,6 +46,7 @@ func PostNovelsHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
 			authorID,
 			req.Status,
 			req.CurrentPhase,
+			req.Shared,
 			req.ProjectBlueprint,
 			req.AIContext,
 		)
@@ -60,9 +64,8 @@ func PostNovelsHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
 func GetNovelsHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id := c.Param("id")
-		novel, err := services.NewNovelService(client, dbName).GetNovels(c.Request.Context(), id)
-		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		novel, ok := authorizeNovel(c, client, dbName, id, false)
+		if !ok {
 			return
 		}
 		c.JSON(http.StatusOK, novel)
@@ -77,6 +80,7 @@ func PutNovelsHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
 			Title            *string                  `json:"title"`
 			Status           *string                  `json:"status"`
 			CurrentPhase     *string                  `json:"current_phase"`
+			Shared           *bool                    `json:"shared"`
 			ProjectBlueprint *models.ProjectBlueprint `json:"project_blueprint"`
 			AIContext        *models.AIContext        `json:"ai_context"`
 			ExtraInfo        *map[string]interface{}  `json:"extra_info"`
@@ -87,12 +91,17 @@ func PutNovelsHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
 			return
 		}
 
+		if _, ok := authorizeNovel(c, client, dbName, id, true); !ok {
+			return
+		}
+
 		novel, err := services.NewNovelService(client, dbName).PutNovels(
 			c.Request.Context(),
 			id,
 			req.Title,
 			req.Status,
 			req.CurrentPhase,
+			req.Shared,
 			req.ProjectBlueprint,
 			req.AIContext,
 			req.ExtraInfo,
@@ -107,15 +116,48 @@ func PutNovelsHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
 	}
 }
 
-// DeleteNovelsHandler 删除小说
-func DeleteNovelsHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+// DeleteNovelsHandler 删除小说：小说及其全部内容移入回收站，保留期限内可恢复
+func DeleteNovelsHandler(client *mongo.Client, dbName string, retention time.Duration) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id := c.Param("id")
-		if err := services.NewNovelService(client, dbName).DeleteNovels(c.Request.Context(), id); err != nil {
+		if _, ok := authorizeNovel(c, client, dbName, id, true); !ok {
+			return
+		}
+		novel, err := services.NewNovelService(client, dbName).DeleteNovels(c.Request.Context(), id, retention)
+		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		c.Status(http.StatusOK)
+		c.JSON(http.StatusOK, novel)
+	}
+}
+
+// RestoreNovelsHandler 从回收站恢复小说：POST /novel/:id/restore
+func RestoreNovelsHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		novel, err := services.NewNovelService(client, dbName).RestoreNovels(c.Request.Context(), c.Param("id"), c.GetString("uid"), c.GetString("role"))
+		if err != nil {
+			if errors.Is(err, services.ErrNovelNotInTrash) {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			}
+			writeNovelAccessError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, novel)
+	}
+}
+
+// ListTrashedNovelsHandler 分页查询回收站中的小说
+func ListTrashedNovelsHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, size, sortExpr, q := common.ParseCommonQueryParams(c.Request.URL.Query())
+		result, err := services.NewNovelService(client, dbName).ListTrashedNovels(c.Request.Context(), c.GetString("uid"), c.GetString("role"), page, size, sortExpr, q)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, result)
 	}
 }
 
@@ -123,7 +165,7 @@ func DeleteNovelsHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
 func ListNovelsHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		page, size, sortExpr, q := common.ParseCommonQueryParams(c.Request.URL.Query())
-		result, err := services.NewNovelService(client, dbName).ListNovels(c.Request.Context(), page, size, sortExpr, q)
+		result, err := services.NewNovelService(client, dbName).ListNovels(c.Request.Context(), c.GetString("uid"), c.GetString("role"), page, size, sortExpr, q)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
@@ -150,6 +192,10 @@ func PostStoryCoresHandler(client *mongo.Client, dbName string) gin.HandlerFunc
 			return
 		}
 
+		if _, ok := authorizeNovel(c, client, dbName, req.NovelID, true); !ok {
+			return
+		}
+
 		storyCore, err := services.NewNovelService(client, dbName).PostStoryCores(
 			c.Request.Context(),
 			req.NovelID,
@@ -174,6 +220,9 @@ func PostStoryCoresHandler(client *mongo.Client, dbName string) gin.HandlerFunc
 func GetStoryCoresHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		novelID := c.Param("novel_id")
+		if _, ok := authorizeNovel(c, client, dbName, novelID, false); !ok {
+			return
+		}
 		storyCores, err := services.NewNovelService(client, dbName).GetStoryCores(c.Request.Context(), novelID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -199,6 +248,10 @@ func PostWorldviewsHandler(client *mongo.Client, dbName string) gin.HandlerFunc
 			return
 		}
 
+		if _, ok := authorizeNovel(c, client, dbName, req.NovelID, true); !ok {
+			return
+		}
+
 		worldview, err := services.NewNovelService(client, dbName).PostWorldviews(
 			c.Request.Context(),
 			req.NovelID,
@@ -221,6 +274,9 @@ func PostWorldviewsHandler(client *mongo.Client, dbName string) gin.HandlerFunc
 func GetWorldviewsHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		novelID := c.Param("novel_id")
+		if _, ok := authorizeNovel(c, client, dbName, novelID, false); !ok {
+			return
+		}
 		worldview, err := services.NewNovelService(client, dbName).GetWorldviews(c.Request.Context(), novelID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -246,6 +302,10 @@ func PostCharactersHandler(client *mongo.Client, dbName string) gin.HandlerFunc
 			return
 		}
 
+		if _, ok := authorizeNovel(c, client, dbName, req.NovelID, true); !ok {
+			return
+		}
+
 		character, err := services.NewNovelService(client, dbName).PostCharacters(
 			c.Request.Context(),
 			req.NovelID,
@@ -268,6 +328,9 @@ func PostCharactersHandler(client *mongo.Client, dbName string) gin.HandlerFunc
 func GetCharactersHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		novelID := c.Param("novel_id")
+		if _, ok := authorizeNovel(c, client, dbName, novelID, false); !ok {
+			return
+		}
 		characters, err := services.NewNovelService(client, dbName).GetCharacters(c.Request.Context(), novelID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -296,6 +359,10 @@ func PostChaptersHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
 			return
 		}
 
+		if _, ok := authorizeNovel(c, client, dbName, req.NovelID, true); !ok {
+			return
+		}
+
 		chapter, err := services.NewNovelService(client, dbName).PostChapters(
 			c.Request.Context(),
 			req.NovelID,
@@ -306,9 +373,15 @@ func PostChaptersHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
 			req.Outline,
 			req.QualityMetrics,
 			req.CharacterDevelopment,
+			models.ChapterVersionSourceManual,
+			c.GetString("uid"),
 		)
 
 		if err != nil {
+			if errors.Is(err, services.ErrChapterConflict) {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			}
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
@@ -317,10 +390,79 @@ func PostChaptersHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
 	}
 }
 
+// PutChaptersHandler 更新章节
+func PutChaptersHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			ChapterNumber        *int                   `json:"chapter_number,omitempty"`
+			Title                *string                `json:"title,omitempty"`
+			Content              *string                `json:"content,omitempty"`
+			Summary              *string                `json:"summary,omitempty"`
+			Outline              *models.ChapterOutline `json:"outline,omitempty"`
+			QualityMetrics       *models.QualityMetrics `json:"quality_metrics,omitempty"`
+			CharacterDevelopment *map[string]string     `json:"character_development,omitempty"`
+		}
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		chapter, ok := authorizeChapter(c, client, dbName, c.Param("id"), true)
+		if !ok {
+			return
+		}
+
+		chapter, err := services.NewNovelService(client, dbName).PutChapters(
+			c.Request.Context(),
+			chapter.ID,
+			c.GetString("uid"),
+			req.ChapterNumber,
+			req.Title,
+			req.Content,
+			req.Summary,
+			req.Outline,
+			req.QualityMetrics,
+			req.CharacterDevelopment,
+		)
+
+		if err != nil {
+			if errors.Is(err, services.ErrChapterNumberTaken) || errors.Is(err, services.ErrChapterConflict) {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, chapter)
+	}
+}
+
+// DeleteChaptersHandler 删除章节
+func DeleteChaptersHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		chapter, ok := authorizeChapter(c, client, dbName, c.Param("id"), true)
+		if !ok {
+			return
+		}
+
+		if err := services.NewNovelService(client, dbName).DeleteChapters(c.Request.Context(), chapter.ID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Chapter deleted successfully"})
+	}
+}
+
 // GetChaptersHandler 获取章节列表
 func GetChaptersHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		novelID := c.Param("novel_id")
+		if _, ok := authorizeNovel(c, client, dbName, novelID, false); !ok {
+			return
+		}
 		chapters, err := services.NewNovelService(client, dbName).GetChapters(c.Request.Context(), novelID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -334,9 +476,8 @@ func GetChaptersHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
 func GetChapterHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id := c.Param("id")
-		chapter, err := services.NewNovelService(client, dbName).GetChapter(c.Request.Context(), id)
-		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		chapter, ok := authorizeChapter(c, client, dbName, id, false)
+		if !ok {
 			return
 		}
 		c.JSON(http.StatusOK, chapter)
@@ -357,6 +498,10 @@ func PostWritingSessionsHandler(client *mongo.Client, dbName string) gin.Handler
 			return
 		}
 
+		if _, ok := authorizeNovel(c, client, dbName, req.NovelID, true); !ok {
+			return
+		}
+
 		session, err := services.NewNovelService(client, dbName).PostWritingSessions(
 			c.Request.Context(),
 			req.NovelID,
@@ -377,6 +522,9 @@ func PostWritingSessionsHandler(client *mongo.Client, dbName string) gin.Handler
 func GetWritingSessionsHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		novelID := c.Param("novel_id")
+		if _, ok := authorizeNovel(c, client, dbName, novelID, false); !ok {
+			return
+		}
 		session, err := services.NewNovelService(client, dbName).GetWritingSessions(c.Request.Context(), novelID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -385,3 +533,38 @@ func GetWritingSessionsHandler(client *mongo.Client, dbName string) gin.HandlerF
 		c.JSON(http.StatusOK, session)
 	}
 }
+
+// authorizeNovel 校验当前用户对小说的读/写权限，校验失败时直接写入404/403响应
+func authorizeNovel(c *gin.Context, client *mongo.Client, dbName, novelID string, write bool) (models.Novel, bool) {
+	novel, err := services.NewNovelService(client, dbName).AuthorizeNovel(c.Request.Context(), novelID, c.GetString("uid"), c.GetString("role"), write)
+	if err != nil {
+		writeNovelAccessError(c, err)
+		return models.Novel{}, false
+	}
+	return novel, true
+}
+
+// authorizeChapter 获取章节并校验当前用户对其所属小说的访问权限
+func authorizeChapter(c *gin.Context, client *mongo.Client, dbName, chapterID string, write bool) (models.Chapter, bool) {
+	chapter, err := services.NewNovelService(client, dbName).GetChapter(c.Request.Context(), chapterID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "chapter not found"})
+		return models.Chapter{}, false
+	}
+	if _, ok := authorizeNovel(c, client, dbName, chapter.NovelID, write); !ok {
+		return models.Chapter{}, false
+	}
+	return chapter, true
+}
+
+// writeNovelAccessError 将小说权限校验错误转换为HTTP响应
+func writeNovelAccessError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrNovelNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrNovelForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,126 @@
+// Package handlers
+/*
+/@Author: urmsone urmsone@163.com
+/@Date: 2025/10/20 20:44
+/@Name: novel_import_handler.go
+/@Description: Manuscript import handlers implementation
+/*/
+
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"redquill-backend/pkg/middleware"
+	"redquill-backend/pkg/models"
+	"redquill-backend/pkg/services"
+	"redquill-backend/pkg/utils/manuscript"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ImportNovelHandler 导入稿件：POST /novel/:id/import（multipart/form-data）
+// 表单字段：file（必填，.txt/.md/.epub）、format（可选，默认按扩展名判断）、start_chapter、
+// analyze=true 时提交 chapter_analysis 任务，需同时提供 llm_model_id；
+// 该任务逐章调用模型，导入前先检查用户与模型的配额，超限返回 429
+func ImportNovelHandler(client *mongo.Client, dbName string, runner *services.JobRunner, maxSize int64, quotas services.DefaultQuotas) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize)
+
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("manuscript exceeds %d bytes", maxSize)})
+				return
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+			return
+		}
+
+		format := c.PostForm("format")
+		if format == "" {
+			format = manuscript.DetectFormat(fileHeader.Filename)
+		}
+		if format != manuscript.FormatTXT && format != manuscript.FormatMarkdown && format != manuscript.FormatEPUB {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported import format, expected txt, md or epub"})
+			return
+		}
+		opts := services.ImportOptions{Format: format}
+		if raw := c.PostForm("start_chapter"); raw != "" {
+			if opts.StartChapter, err = strconv.Atoi(raw); err != nil || opts.StartChapter < 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid start_chapter: must be a positive chapter number"})
+				return
+			}
+		}
+		analyze := c.PostForm("analyze") == "true"
+		llmModelID := c.PostForm("llm_model_id")
+		if analyze && llmModelID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "llm_model_id is required when analyze is true"})
+			return
+		}
+
+		novel, ok := authorizeNovel(c, client, dbName, c.Param("id"), true)
+		if !ok {
+			return
+		}
+		userID := c.GetString("uid")
+		if analyze {
+			_, err := services.NewQuotaService(client, dbName).CheckGenerationQuota(c.Request.Context(), userID, []string{llmModelID}, quotas, nil)
+			var exceeded *services.QuotaExceededError
+			if errors.As(err, &exceeded) {
+				middleware.AbortQuotaExceeded(c, exceeded)
+				return
+			}
+		}
+
+		file, err := fileHeader.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		defer file.Close()
+		data, err := io.ReadAll(file)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		result, err := services.NewNovelImportService(client, dbName).ImportManuscript(c.Request.Context(), novel.ID, userID, data, opts)
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidManuscript) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			// 部分章节已保存，返回已导入的章节便于重试时指定 start_chapter
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "chapters": result.Chapters})
+			return
+		}
+
+		if analyze {
+			chapterIDs := make([]interface{}, 0, len(result.Chapters))
+			for _, chapter := range result.Chapters {
+				chapterIDs = append(chapterIDs, chapter.ID)
+			}
+			job, err := runner.Submit(c.Request.Context(), models.GenerationJob{
+				Type:       models.JobTypeChapterAnalysis,
+				NovelID:    novel.ID,
+				LLMModelID: llmModelID,
+				InputData:  map[string]interface{}{"chapter_ids": chapterIDs},
+				UserID:     userID,
+			})
+			if err != nil {
+				result.AnalysisError = err.Error()
+			} else {
+				result.Job = &job
+			}
+		}
+
+		c.JSON(http.StatusCreated, result)
+	}
+}
@@ -0,0 +1,414 @@
+// Package handlers
+/*
+/@Author: urmsone urmsone@163.com
+/@Date: 2025/10/20 20:44
+/@Name: novel_setting_handler.go
+/@Description: Story core, worldview and character update handlers implementation
+/*/
+
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"redquill-backend/pkg/models"
+	"redquill-backend/pkg/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// PutStoryCoresHandler 更新故事核心
+func PutStoryCoresHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Title               *string `json:"title,omitempty"`
+			CoreConflict        *string `json:"core_conflict,omitempty"`
+			Theme               *string `json:"theme,omitempty"`
+			Innovation          *string `json:"innovation,omitempty"`
+			CommercialPotential *string `json:"commercial_potential,omitempty"`
+			TargetAudience      *string `json:"target_audience,omitempty"`
+		}
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		expectedVersion, ok := parseIfMatch(c)
+		if !ok {
+			return
+		}
+
+		storyCore, ok := authorizeStoryCore(c, client, dbName, c.Param("id"), true)
+		if !ok {
+			return
+		}
+
+		storyCore, err := services.NewNovelService(client, dbName).PutStoryCores(
+			c.Request.Context(),
+			storyCore.ID,
+			expectedVersion,
+			req.Title,
+			req.CoreConflict,
+			req.Theme,
+			req.Innovation,
+			req.CommercialPotential,
+			req.TargetAudience,
+		)
+		if err != nil {
+			writeUpdateError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, storyCore)
+	}
+}
+
+// PatchStoryCoresHandler 按 JSON Merge Patch 局部更新故事核心
+func PatchStoryCoresHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var patch map[string]interface{}
+		if err := c.ShouldBindJSON(&patch); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		expectedVersion, ok := parseIfMatch(c)
+		if !ok {
+			return
+		}
+
+		storyCore, ok := authorizeStoryCore(c, client, dbName, c.Param("id"), true)
+		if !ok {
+			return
+		}
+
+		storyCore, err := services.NewNovelService(client, dbName).PatchStoryCores(c.Request.Context(), storyCore.ID, expectedVersion, patch)
+		if err != nil {
+			writeUpdateError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, storyCore)
+	}
+}
+
+// DeleteStoryCoresHandler 删除故事核心
+func DeleteStoryCoresHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		storyCore, ok := authorizeStoryCore(c, client, dbName, c.Param("id"), true)
+		if !ok {
+			return
+		}
+
+		if err := services.NewNovelService(client, dbName).DeleteStoryCores(c.Request.Context(), storyCore.ID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Story core deleted successfully"})
+	}
+}
+
+// SelectStoryCoreHandler 将故事核心设为小说采用的故事核心
+func SelectStoryCoreHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		storyCore, ok := authorizeStoryCore(c, client, dbName, c.Param("id"), true)
+		if !ok {
+			return
+		}
+
+		storyCore, err := services.NewNovelService(client, dbName).SelectStoryCore(c.Request.Context(), storyCore.ID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, storyCore)
+	}
+}
+
+// ListWorldviewsHandler 获取小说的全部世界观
+func ListWorldviewsHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		novelID := c.Param("novel_id")
+		if _, ok := authorizeNovel(c, client, dbName, novelID, false); !ok {
+			return
+		}
+		worldviews, err := services.NewNovelService(client, dbName).ListWorldviews(c.Request.Context(), novelID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, worldviews)
+	}
+}
+
+// PutWorldviewsHandler 更新世界观
+func PutWorldviewsHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			PowerSystem      *models.PowerSystem      `json:"power_system,omitempty"`
+			SocietyStructure *models.SocietyStructure `json:"society_structure,omitempty"`
+			Geography        *models.Geography        `json:"geography,omitempty"`
+			SpecialRules     *[]string                `json:"special_rules,omitempty"`
+		}
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		expectedVersion, ok := parseIfMatch(c)
+		if !ok {
+			return
+		}
+
+		worldview, ok := authorizeWorldview(c, client, dbName, c.Param("id"), true)
+		if !ok {
+			return
+		}
+
+		worldview, err := services.NewNovelService(client, dbName).PutWorldviews(
+			c.Request.Context(),
+			worldview.ID,
+			expectedVersion,
+			req.PowerSystem,
+			req.SocietyStructure,
+			req.Geography,
+			req.SpecialRules,
+		)
+		if err != nil {
+			writeUpdateError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, worldview)
+	}
+}
+
+// PatchWorldviewsHandler 按 JSON Merge Patch 局部更新世界观
+func PatchWorldviewsHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var patch map[string]interface{}
+		if err := c.ShouldBindJSON(&patch); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		expectedVersion, ok := parseIfMatch(c)
+		if !ok {
+			return
+		}
+
+		worldview, ok := authorizeWorldview(c, client, dbName, c.Param("id"), true)
+		if !ok {
+			return
+		}
+
+		worldview, err := services.NewNovelService(client, dbName).PatchWorldviews(c.Request.Context(), worldview.ID, expectedVersion, patch)
+		if err != nil {
+			writeUpdateError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, worldview)
+	}
+}
+
+// DeleteWorldviewsHandler 删除世界观
+func DeleteWorldviewsHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		worldview, ok := authorizeWorldview(c, client, dbName, c.Param("id"), true)
+		if !ok {
+			return
+		}
+
+		if err := services.NewNovelService(client, dbName).DeleteWorldviews(c.Request.Context(), worldview.ID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Worldview deleted successfully"})
+	}
+}
+
+// SelectWorldviewHandler 将世界观设为小说采用的世界观
+func SelectWorldviewHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		worldview, ok := authorizeWorldview(c, client, dbName, c.Param("id"), true)
+		if !ok {
+			return
+		}
+
+		worldview, err := services.NewNovelService(client, dbName).SelectWorldview(c.Request.Context(), worldview.ID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, worldview)
+	}
+}
+
+// GetCharacterHandler 获取单个角色
+func GetCharacterHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		character, ok := authorizeCharacter(c, client, dbName, c.Param("id"), false)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, character)
+	}
+}
+
+// PutCharactersHandler 更新角色
+func PutCharactersHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Name           *string                `json:"name,omitempty"`
+			Type           *string                `json:"type,omitempty"`
+			CoreAttributes *models.CoreAttributes `json:"core_attributes,omitempty"`
+			SoulProfile    *models.SoulProfile    `json:"soul_profile,omitempty"`
+			GrowthTrack    *[]models.GrowthEvent  `json:"growth_track,omitempty"`
+		}
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		expectedVersion, ok := parseIfMatch(c)
+		if !ok {
+			return
+		}
+
+		character, ok := authorizeCharacter(c, client, dbName, c.Param("id"), true)
+		if !ok {
+			return
+		}
+
+		character, err := services.NewNovelService(client, dbName).PutCharacters(
+			c.Request.Context(),
+			character.ID,
+			expectedVersion,
+			req.Name,
+			req.Type,
+			req.CoreAttributes,
+			req.SoulProfile,
+			req.GrowthTrack,
+		)
+		if err != nil {
+			writeUpdateError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, character)
+	}
+}
+
+// PatchCharactersHandler 按 JSON Merge Patch 局部更新角色
+func PatchCharactersHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var patch map[string]interface{}
+		if err := c.ShouldBindJSON(&patch); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		expectedVersion, ok := parseIfMatch(c)
+		if !ok {
+			return
+		}
+
+		character, ok := authorizeCharacter(c, client, dbName, c.Param("id"), true)
+		if !ok {
+			return
+		}
+
+		character, err := services.NewNovelService(client, dbName).PatchCharacters(c.Request.Context(), character.ID, expectedVersion, patch)
+		if err != nil {
+			writeUpdateError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, character)
+	}
+}
+
+// DeleteCharactersHandler 删除角色
+func DeleteCharactersHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		character, ok := authorizeCharacter(c, client, dbName, c.Param("id"), true)
+		if !ok {
+			return
+		}
+
+		if err := services.NewNovelService(client, dbName).DeleteCharacters(c.Request.Context(), character.ID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Character deleted successfully"})
+	}
+}
+
+// authorizeStoryCore 获取故事核心并校验当前用户对其所属小说的访问权限
+func authorizeStoryCore(c *gin.Context, client *mongo.Client, dbName, id string, write bool) (models.StoryCore, bool) {
+	storyCore, err := services.NewNovelService(client, dbName).GetStoryCore(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "story core not found"})
+		return models.StoryCore{}, false
+	}
+	if _, ok := authorizeNovel(c, client, dbName, storyCore.NovelID, write); !ok {
+		return models.StoryCore{}, false
+	}
+	return storyCore, true
+}
+
+// authorizeWorldview 获取世界观并校验当前用户对其所属小说的访问权限
+func authorizeWorldview(c *gin.Context, client *mongo.Client, dbName, id string, write bool) (models.Worldview, bool) {
+	worldview, err := services.NewNovelService(client, dbName).GetWorldview(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "worldview not found"})
+		return models.Worldview{}, false
+	}
+	if _, ok := authorizeNovel(c, client, dbName, worldview.NovelID, write); !ok {
+		return models.Worldview{}, false
+	}
+	return worldview, true
+}
+
+// authorizeCharacter 获取角色并校验当前用户对其所属小说的访问权限
+func authorizeCharacter(c *gin.Context, client *mongo.Client, dbName, id string, write bool) (models.Character, bool) {
+	character, err := services.NewNovelService(client, dbName).GetCharacter(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "character not found"})
+		return models.Character{}, false
+	}
+	if _, ok := authorizeNovel(c, client, dbName, character.NovelID, write); !ok {
+		return models.Character{}, false
+	}
+	return character, true
+}
+
+// parseIfMatch 解析 If-Match 请求头中的文档版本号（可带引号），未提供时返回nil
+func parseIfMatch(c *gin.Context) (*int, bool) {
+	raw := strings.Trim(strings.TrimPrefix(strings.TrimSpace(c.GetHeader("If-Match")), "W/"), `"`)
+	if raw == "" || raw == "*" {
+		return nil, true
+	}
+	version, err := strconv.Atoi(raw)
+	if err != nil || version < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "If-Match must be a document version"})
+		return nil, false
+	}
+	return &version, true
+}
+
+// writeUpdateError 将更新错误转换为HTTP响应
+func writeUpdateError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrVersionConflict) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
@@ -27,6 +27,10 @@ func PostOutlinesHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
 			return
 		}
 
+		if _, ok := authorizeNovel(c, client, dbName, req.NovelID, true); !ok {
+			return
+		}
+
 		outline, err := services.NewNovelService(client, dbName).PostOutlines(
 			c.Request.Context(),
 			req,
@@ -49,6 +53,9 @@ func GetOutlinesHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "novel_id is required"})
 			return
 		}
+		if _, ok := authorizeNovel(c, client, dbName, novelID, false); !ok {
+			return
+		}
 
 		outlines, err := services.NewNovelService(client, dbName).GetOutlines(
 			c.Request.Context(),
@@ -73,13 +80,8 @@ func GetOutlineHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
 			return
 		}
 
-		outline, err := services.NewNovelService(client, dbName).GetOutline(
-			c.Request.Context(),
-			id,
-		)
-
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		outline, ok := authorizeOutline(c, client, dbName, id, false)
+		if !ok {
 			return
 		}
 
@@ -109,6 +111,10 @@ func PutOutlinesHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
 			return
 		}
 
+		if _, ok := authorizeOutline(c, client, dbName, id, true); !ok {
+			return
+		}
+
 		outline, err := services.NewNovelService(client, dbName).PutOutlines(
 			c.Request.Context(),
 			id,
@@ -137,6 +143,10 @@ func DeleteOutlinesHandler(client *mongo.Client, dbName string) gin.HandlerFunc
 			return
 		}
 
+		if _, ok := authorizeOutline(c, client, dbName, id, true); !ok {
+			return
+		}
+
 		err := services.NewNovelService(client, dbName).DeleteOutlines(
 			c.Request.Context(),
 			id,
@@ -158,6 +168,8 @@ func ListOutlinesHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
 
 		result, err := services.NewNovelService(client, dbName).ListOutlines(
 			c.Request.Context(),
+			c.GetString("uid"),
+			c.GetString("role"),
 			page,
 			size,
 			sortExpr,
@@ -174,13 +186,14 @@ func ListOutlinesHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
 }
 
 // GenerateOutlineHandler 生成大纲
-func GenerateOutlineHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+func GenerateOutlineHandler(client *mongo.Client, dbName string, hub *services.StreamHub) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req struct {
 			NovelID    string                 `json:"novel_id" binding:"required"`
 			LLMModelID string                 `json:"llm_model_id" binding:"required"`
 			InputData  map[string]interface{} `json:"input_data" binding:"required"`
 			Stream     bool                   `json:"stream,omitempty"`
+			models.GenerationOptions
 		}
 
 		if err := c.ShouldBindJSON(&req); err != nil {
@@ -188,9 +201,17 @@ func GenerateOutlineHandler(client *mongo.Client, dbName string) gin.HandlerFunc
 			return
 		}
 
+		if _, ok := authorizeNovel(c, client, dbName, req.NovelID, true); !ok {
+			return
+		}
+		if !checkPromptTemplate(c, client, dbName, req.TemplateID, "outline") {
+			return
+		}
+		req.UserID = c.GetString("uid")
+
 		// 如果请求流式响应
 		if req.Stream {
-			GenerateOutlineStreamHandler(client, dbName, req.NovelID, req.LLMModelID, req.InputData)(c)
+			GenerateOutlineStreamHandler(client, dbName, hub, req.NovelID, req.LLMModelID, req.InputData, req.GenerationOptions)(c)
 			return
 		}
 
@@ -199,6 +220,7 @@ func GenerateOutlineHandler(client *mongo.Client, dbName string) gin.HandlerFunc
 			req.NovelID,
 			req.LLMModelID,
 			req.InputData,
+			req.GenerationOptions,
 		)
 
 		if err != nil {
@@ -211,49 +233,32 @@ func GenerateOutlineHandler(client *mongo.Client, dbName string) gin.HandlerFunc
 }
 
 // GenerateOutlineStreamHandler 流式生成大纲
-func GenerateOutlineStreamHandler(client *mongo.Client, dbName string, novelID, llmModelID string, inputData map[string]interface{}) gin.HandlerFunc {
+func GenerateOutlineStreamHandler(client *mongo.Client, dbName string, hub *services.StreamHub, novelID, llmModelID string, inputData map[string]interface{}, opts models.GenerationOptions) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 设置流式响应头
-		c.Header("Content-Type", "text/event-stream")
-		c.Header("Cache-Control", "no-cache")
-		c.Header("Connection", "keep-alive")
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Headers", "Cache-Control")
-
 		// 创建流式生成请求
 		generationReq := models.GenerationRequest{
-			NovelID:      novelID,
-			LLMModelID:   llmModelID,
-			InputData:    inputData,
-			TemplateType: "outline",
-			Stream:       true,
+			NovelID:           novelID,
+			LLMModelID:        llmModelID,
+			InputData:         inputData,
+			TemplateType:      "outline",
+			Stream:            true,
+			GenerationOptions: opts,
 		}
 
-		// 调用流式生成服务
-		templateService := services.NewPromptTemplateService(client, dbName)
-		response, err := templateService.GenerateWithLLMStream(c.Request.Context(), generationReq)
-		if err != nil {
-			c.SSEvent("error", gin.H{"error": err.Error()})
-			return
-		}
+		// 后台执行流式生成，断线后可通过 GET /generate/stream/:generation_id 续传
+		serveGenerationStream(c, hub.StartGeneration(c.Request.Context(), generationReq, inputData), 0)
+	}
+}
 
-		// 发送流式数据
-		for chunk := range response {
-			if chunk.Error != nil {
-				c.SSEvent("error", gin.H{"error": chunk.Error.Error()})
-				return
-			}
-
-			// 发送数据块
-			c.SSEvent("data", gin.H{
-				"content": chunk.Content,
-				"done":    chunk.Done,
-			})
-			c.Writer.Flush()
-
-			if chunk.Done {
-				break
-			}
-		}
+// authorizeOutline 获取大纲并校验当前用户对其所属小说的访问权限
+func authorizeOutline(c *gin.Context, client *mongo.Client, dbName, outlineID string, write bool) (models.Outline, bool) {
+	outline, err := services.NewNovelService(client, dbName).GetOutline(c.Request.Context(), outlineID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "outline not found"})
+		return models.Outline{}, false
+	}
+	if _, ok := authorizeNovel(c, client, dbName, outline.NovelID, write); !ok {
+		return models.Outline{}, false
 	}
+	return outline, true
 }
@@ -0,0 +1,201 @@
+// Package handlers
+/*
+/@Author: urmsone urmsone@163.com
+/@Date: 2025/10/20 20:44
+/@Name: prompt_template_handler.go
+/@Description: Prompt template handlers implementation
+/*/
+
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"redquill-backend/pkg/common"
+	"redquill-backend/pkg/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// PostPromptTemplatesHandler 创建Prompt模板（仅管理员），同一类型可有多个模板
+func PostPromptTemplatesHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Name        string   `json:"name" binding:"required"`
+			Type        string   `json:"type" binding:"required"`
+			Phase       string   `json:"phase"`
+			Content     string   `json:"content" binding:"required"`
+			Variables   []string `json:"variables"`
+			Description string   `json:"description"`
+			// 输出JSON Schema，不传时使用该类型的默认Schema
+			OutputSchema map[string]interface{} `json:"output_schema"`
+		}
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !services.IsPromptTemplateType(req.Type) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid template type"})
+			return
+		}
+
+		template, err := services.NewPromptTemplateService(client, dbName).PostPromptTemplates(
+			c.Request.Context(),
+			req.Name,
+			req.Type,
+			req.Phase,
+			req.Content,
+			req.Variables,
+			req.Description,
+			req.OutputSchema,
+			c.GetString("uid"),
+			c.GetString("username"),
+		)
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, template)
+	}
+}
+
+// GetPromptTemplatesHandler 获取Prompt模板详情
+func GetPromptTemplatesHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		template, err := services.NewPromptTemplateService(client, dbName).GetPromptTemplate(c.Request.Context(), id)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, template)
+	}
+}
+
+// PutPromptTemplatesHandler 更新Prompt模板（仅管理员）
+func PutPromptTemplatesHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		var req struct {
+			Name        *string   `json:"name"`
+			Phase       *string   `json:"phase"`
+			Content     *string   `json:"content"`
+			Variables   *[]string `json:"variables"`
+			Description *string   `json:"description"`
+			// 输出JSON Schema，传空对象表示不校验
+			OutputSchema *map[string]interface{} `json:"output_schema"`
+		}
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		template, err := services.NewPromptTemplateService(client, dbName).PutPromptTemplates(
+			c.Request.Context(),
+			id,
+			req.Name,
+			req.Phase,
+			req.Content,
+			req.Variables,
+			req.Description,
+			req.OutputSchema,
+		)
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, template)
+	}
+}
+
+// ListPromptTemplatesHandler 分页查询Prompt模板列表，支持 type 过滤
+func ListPromptTemplatesHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, size, sortExpr, q := common.ParseCommonQueryParams(c.Request.URL.Query())
+		result, err := services.NewPromptTemplateService(client, dbName).ListPromptTemplates(c.Request.Context(), c.Query("type"), page, size, sortExpr, q)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// DeletePromptTemplatesHandler 删除Prompt模板（仅管理员），内置模板与默认模板不可删除
+func DeletePromptTemplatesHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := services.NewPromptTemplateService(client, dbName).DeletePromptTemplates(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			writePromptTemplateError(c, err)
+			return
+		}
+		c.Status(http.StatusOK)
+	}
+}
+
+// SetDefaultPromptTemplateHandler 设为所属类型的默认模板（仅管理员）
+func SetDefaultPromptTemplateHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		template, err := services.NewPromptTemplateService(client, dbName).SetDefaultPromptTemplate(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			writePromptTemplateError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, template)
+	}
+}
+
+// PutNovelPromptTemplatesHandler 设置小说按模板类型使用的Prompt模板：PUT /novel/:id/prompt-templates
+// 请求体为模板类型到模板ID的映射，替换原有设置；传空对象恢复使用默认模板
+func PutNovelPromptTemplatesHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req map[string]string
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		novel, ok := authorizeNovel(c, client, dbName, c.Param("id"), true)
+		if !ok {
+			return
+		}
+
+		overrides, err := services.NewPromptTemplateService(client, dbName).PutNovelPromptTemplates(c.Request.Context(), novel.ID, req)
+		if err != nil {
+			writePromptTemplateError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"novel_id": novel.ID, "prompt_templates": overrides})
+	}
+}
+
+// checkPromptTemplate 校验请求指定的模板存在且类型与生成类型一致，流式生成开始前即可返回错误
+func checkPromptTemplate(c *gin.Context, client *mongo.Client, dbName, templateID, templateType string) bool {
+	if templateID == "" {
+		return true
+	}
+	if _, err := services.NewPromptTemplateService(client, dbName).GetPromptTemplateOfType(c.Request.Context(), templateID, templateType); err != nil {
+		writePromptTemplateError(c, err)
+		return false
+	}
+	return true
+}
+
+// writePromptTemplateError 将Prompt模板错误转换为HTTP响应
+func writePromptTemplateError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrPromptTemplateNotFound), errors.Is(err, services.ErrNovelNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPromptTemplateProtected):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
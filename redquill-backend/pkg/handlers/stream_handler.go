@@ -0,0 +1,96 @@
+// Package handlers
+/*
+/@Author: urmsone urmsone@163.com
+/@Date: 2025/10/20 20:44
+/@Name: stream_handler.go
+/@Description: Resumable SSE generation stream handlers implementation
+/*/
+
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"redquill-backend/pkg/services"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetGenerationStreamHandler 断线重连：GET /generate/stream/:generation_id
+// 先补发 Last-Event-ID（或 last_event_id 参数）之后的事件，再继续推送实时数据
+func GetGenerationStreamHandler(hub *services.StreamHub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, ok := hub.Get(c.Param("generation_id"))
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "generation not found or expired"})
+			return
+		}
+		if !isSelfOrAdmin(c, session.UserID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "no permission to access this generation"})
+			return
+		}
+
+		lastEventID := c.GetHeader("Last-Event-ID")
+		if lastEventID == "" {
+			lastEventID = c.Query("last_event_id")
+		}
+		var lastID int64
+		if lastEventID != "" {
+			id, err := strconv.ParseInt(lastEventID, 10, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid Last-Event-ID"})
+				return
+			}
+			lastID = id
+		}
+
+		serveGenerationStream(c, session, lastID)
+	}
+}
+
+// serveGenerationStream 将会话中 lastID 之后的事件以SSE推送给客户端，直到生成结束或客户端断开
+func serveGenerationStream(c *gin.Context, session *services.StreamSession, lastID int64) {
+	// 设置流式响应头
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("Access-Control-Allow-Origin", "*")
+	c.Header("Access-Control-Allow-Headers", "Cache-Control, Last-Event-ID")
+	c.Header("Access-Control-Expose-Headers", "X-Generation-ID")
+	c.Header("X-Generation-ID", session.ID)
+	c.Status(http.StatusOK)
+
+	for {
+		events, updated, done := session.Since(lastID)
+		for _, event := range events {
+			if err := writeSSEEvent(c, event); err != nil {
+				return
+			}
+			lastID = event.ID
+		}
+		c.Writer.Flush()
+
+		if done {
+			return
+		}
+
+		select {
+		case <-updated:
+		case <-c.Request.Context().Done():
+			// 客户端断开，生成继续在后台执行，可凭 generation_id 续传
+			return
+		}
+	}
+}
+
+// writeSSEEvent 写入带 id 的SSE事件
+func writeSSEEvent(c *gin.Context, event services.StreamEvent) error {
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Event, data)
+	return err
+}
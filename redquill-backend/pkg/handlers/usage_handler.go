@@ -0,0 +1,79 @@
+// Package handlers
+/*
+/@Author: urmsone urmsone@163.com
+/@Date: 2025/10/20 20:44
+/@Name: usage_handler.go
+/@Description: LLM usage handlers implementation
+/*/
+
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"redquill-backend/pkg/models"
+	"redquill-backend/pkg/services"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// GetUsageHandler 用量聚合统计：GET /usage?group_by=user|model|novel&from&to
+// 管理员可查看全部用户，其他角色仅能查看自己的用量
+func GetUsageHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupBy := c.DefaultQuery("group_by", "user")
+
+		from, err := parseTimeParam(c.Query("from"), false)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		to, err := parseTimeParam(c.Query("to"), true)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		userID := ""
+		if c.GetString("role") != models.RoleAdmin {
+			userID = c.GetString("uid")
+		}
+
+		items, err := services.NewUsageService(client, dbName).AggregateUsage(c.Request.Context(), groupBy, from, to, userID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"group_by": groupBy,
+			"from":     from,
+			"to":       to,
+			"items":    items,
+		})
+	}
+}
+
+// parseTimeParam 解析时间参数，支持unix秒、RFC3339 和 2006-01-02；
+// 日期格式作为结束时间时取当天末尾
+func parseTimeParam(value string, endOfDay bool) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if ts, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return ts, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.Unix(), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		if endOfDay {
+			return t.Add(24*time.Hour - time.Second).Unix(), nil
+		}
+		return t.Unix(), nil
+	}
+	return 0, fmt.Errorf("invalid time: %s", value)
+}
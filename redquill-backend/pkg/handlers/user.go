@@ -5,7 +5,9 @@ import (
 	"redquill-backend/pkg/common"
 	"redquill-backend/pkg/config"
 	"redquill-backend/pkg/middleware"
+	"redquill-backend/pkg/models"
 	"redquill-backend/pkg/services"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -24,7 +26,8 @@ func ListUsersHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
 }
 
 // POST /api/v1/user (register)
-func PostUsersHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+// ADMIN_EMAILS 中的邮箱不能公开注册：邮箱未经验证，抢先注册的账号会在重启时被提升为管理员
+func PostUsersHandler(client *mongo.Client, dbName string, cfg config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req struct {
 			Name     string `json:"name" binding:"required"`
@@ -35,6 +38,10 @@ func PostUsersHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		if containsEmail(cfg.AdminEmails, req.Email) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "this email is reserved"})
+			return
+		}
 		user, err := services.NewUserService(client, dbName).PostUsers(c.Request.Context(), req.Name, req.Email, req.Password)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -48,6 +55,10 @@ func PostUsersHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
 func GetUsersHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id := c.Param("id")
+		if !isSelfOrAdmin(c, id) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "no permission to access this user"})
+			return
+		}
 		user, err := services.NewUserService(client, dbName).GetUsers(c.Request.Context(), id)
 		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
@@ -58,19 +69,40 @@ func GetUsersHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
 }
 
 // PUT /api/v1/user/:id
-func PutUsersHandler(client *mongo.Client, dbName string) gin.HandlerFunc {
+func PutUsersHandler(client *mongo.Client, dbName string, cfg config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id := c.Param("id")
 		var req struct {
-			Name     *string `json:"name"`
-			Email    *string `json:"email"`
-			Password *string `json:"password"`
+			Name     *string       `json:"name"`
+			Email    *string       `json:"email"`
+			Password *string       `json:"password"`
+			Role     *string       `json:"role"`
+			Quota    *models.Quota `json:"quota"`
 		}
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		user, err := services.NewUserService(client, dbName).PutUsers(c.Request.Context(), id, req.Name, req.Email, req.Password)
+		if !isSelfOrAdmin(c, id) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "no permission to modify this user"})
+			return
+		}
+		// 只有管理员可以修改角色
+		if req.Role != nil && c.GetString("role") != models.RoleAdmin {
+			c.JSON(http.StatusForbidden, gin.H{"error": "only admin can change roles"})
+			return
+		}
+		// 只有管理员可以修改配额
+		if req.Quota != nil && c.GetString("role") != models.RoleAdmin {
+			c.JSON(http.StatusForbidden, gin.H{"error": "only admin can change quotas"})
+			return
+		}
+		// 邮箱未经验证，非管理员不能改为 ADMIN_EMAILS 中的邮箱，否则重启后会被提升为管理员
+		if req.Email != nil && c.GetString("role") != models.RoleAdmin && containsEmail(cfg.AdminEmails, *req.Email) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "this email is reserved"})
+			return
+		}
+		user, err := services.NewUserService(client, dbName).PutUsers(c.Request.Context(), id, req.Name, req.Email, req.Password, req.Role, req.Quota)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
@@ -107,11 +139,27 @@ func LoginHandler(client *mongo.Client, dbName string, cfg config.Config) gin.Ha
 			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 			return
 		}
-		token, err := middleware.GenerateJWT(cfg, user.ID, user.Name, user.Email)
+		token, err := middleware.GenerateJWT(cfg, user.ID, user.Name, user.Email, user.Role)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"token": token})
+		c.JSON(http.StatusOK, gin.H{"token": token, "role": user.Role})
+	}
+}
+
+// isSelfOrAdmin 当前用户是否为目标用户本人或管理员
+func isSelfOrAdmin(c *gin.Context, userID string) bool {
+	return c.GetString("uid") == userID || c.GetString("role") == models.RoleAdmin
+}
+
+// containsEmail 邮箱是否在列表中（不区分大小写）
+func containsEmail(list []string, email string) bool {
+	email = strings.TrimSpace(email)
+	for _, item := range list {
+		if strings.EqualFold(item, email) {
+			return true
+		}
 	}
+	return false
 }
@@ -1,27 +1,33 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/mongo"
 	"redquill-backend/pkg/config"
+	"redquill-backend/pkg/models"
+	"redquill-backend/pkg/services"
 )
 
 type JWTClaims struct {
 	UserID   string `json:"uid"`
 	Username string `json:"username"`
 	Email    string `json:"email"`
+	Role     string `json:"role"`
 	jwt.RegisteredClaims
 }
 
-func GenerateJWT(cfg config.Config, userID, username, email string) (string, error) {
+func GenerateJWT(cfg config.Config, userID, username, email, role string) (string, error) {
 	claims := JWTClaims{
 		UserID:   userID,
 		Username: username,
 		Email:    email,
+		Role:     role,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(cfg.JWTTTLMin) * time.Minute)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -43,7 +49,9 @@ func ParseJWT(cfg config.Config, token string) (*JWTClaims, error) {
 	return nil, jwt.ErrTokenInvalidClaims
 }
 
-func AuthRequired(cfg config.Config) gin.HandlerFunc {
+// AuthRequired 校验JWT，并按 users 中的当前记录设置用户信息与角色，
+// 角色调整与删除用户立即生效，不必等待令牌过期
+func AuthRequired(cfg config.Config, client *mongo.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		auth := c.GetHeader("Authorization")
 		if !strings.HasPrefix(auth, "Bearer ") {
@@ -56,9 +64,19 @@ func AuthRequired(cfg config.Config) gin.HandlerFunc {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
 			return
 		}
-		c.Set("uid", claims.UserID)
-		c.Set("username", claims.Username)
-		c.Set("email", claims.Email)
+		user, err := services.NewUserService(client, cfg.DBName).GetUsers(c.Request.Context(), claims.UserID)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "user no longer exists"})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to load user"})
+			return
+		}
+		c.Set("uid", user.ID)
+		c.Set("username", user.Name)
+		c.Set("email", user.Email)
+		c.Set("role", models.NormalizeRole(user.Role))
 		c.Next()
 	}
 }
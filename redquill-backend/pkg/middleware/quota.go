@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+	"redquill-backend/pkg/common"
+	"redquill-backend/pkg/config"
+	"redquill-backend/pkg/services"
+)
+
+// llmModelServiceRoute 模型ID位于路由参数 :id 的受配额限制接口
+const llmModelServiceRoute = "/api/v1/llm-model/:id/service"
+
+// QuotaLimit 检查当前用户与本次请求可能调用的模型的配额，超限返回 429 及重置时间。
+// 模型取自请求体中的 llm_model_id、review_llm_model_id 与 fallback_llm_model_ids，
+// /llm-model/:id/service 没有 llm_model_id 时取路由参数 :id；需在 AuthRequired 之后使用。
+func QuotaLimit(cfg config.Config, client *mongo.Client) gin.HandlerFunc {
+	var limiter *common.SlidingWindowLimiter
+	if cfg.QuotaBackend != "usage" {
+		limiter = common.NewSlidingWindowLimiter(time.Minute)
+	}
+
+	defaults := services.DefaultQuotas{User: cfg.DefaultUserQuota, Model: cfg.DefaultModelQuota}
+
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		userID := c.GetString("uid")
+		body := peekQuotaModels(c)
+		if body.LLMModelID == "" && c.FullPath() == llmModelServiceRoute {
+			body.LLMModelID = c.Param("id")
+		}
+
+		// 通过时占用的每分钟请求名额保留，超限时服务已归还
+		_, err := services.NewQuotaService(client, cfg.DBName).CheckGenerationQuota(ctx, userID, body.modelIDs(), defaults, limiter)
+		var exceeded *services.QuotaExceededError
+		if errors.As(err, &exceeded) {
+			AbortQuotaExceeded(c, exceeded)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// AbortQuotaExceeded 返回 429，Retry-After 与 X-RateLimit-Reset 为配额重置时间
+func AbortQuotaExceeded(c *gin.Context, exceeded *services.QuotaExceededError) {
+	retryAfter := int(time.Until(exceeded.ResetAt).Seconds()) + 1
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.Header("X-RateLimit-Reset", strconv.FormatInt(exceeded.ResetAt.Unix(), 10))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+		"error":       exceeded.Error(),
+		"scope":       exceeded.Scope,
+		"subject":     exceeded.Subject,
+		"quota":       exceeded.Quota,
+		"limit":       exceeded.Limit,
+		"used":        exceeded.Used,
+		"reset_at":    exceeded.ResetAt.Unix(),
+		"retry_after": retryAfter,
+	})
+}
+
+// quotaModels 请求体中会被调用的模型
+type quotaModels struct {
+	LLMModelID       string   `json:"llm_model_id"`
+	ReviewLLMModelID string   `json:"review_llm_model_id"`
+	FallbackModelIDs []string `json:"fallback_llm_model_ids"`
+}
+
+// modelIDs 会被调用的模型ID，生成模型在前
+func (m quotaModels) modelIDs() []string {
+	return append([]string{m.LLMModelID, m.ReviewLLMModelID}, m.FallbackModelIDs...)
+}
+
+// peekQuotaModels 读取请求体中的模型ID，并恢复请求体供后续handler绑定
+func peekQuotaModels(c *gin.Context) quotaModels {
+	var req quotaModels
+	if c.Request.Body == nil {
+		return req
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return req
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	_ = json.Unmarshal(body, &req)
+	return req
+}
@@ -1,6 +1,8 @@
 package middleware
 
 import (
+	"redquill-backend/pkg/common"
+
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
@@ -15,6 +17,7 @@ func RequestID() gin.HandlerFunc {
 		}
 		c.Writer.Header().Set(RequestIDKey, requestID)
 		c.Set(RequestIDKey, requestID)
+		c.Request = c.Request.WithContext(common.WithRequestID(c.Request.Context(), requestID))
 		c.Next()
 	}
 }
@@ -0,0 +1,36 @@
+// Package models
+/*
+/@Author: urmsone urmsone@163.com
+/@Date: 2025/10/20 20:44
+/@Name: chapter_review_model.go
+/@Description: Chapter quality review data structure
+/*/
+
+package models
+
+// ChapterReview 章节质量审核报告（quality_review 模板的完整输出）
+type ChapterReview struct {
+	ID                      string        `json:"id" bson:"_id,omitempty"`
+	ChapterID               string        `json:"chapter_id" bson:"chapter_id"`
+	NovelID                 string        `json:"novel_id" bson:"novel_id"`
+	ChapterVersion          int           `json:"chapter_version,omitempty" bson:"chapter_version,omitempty"` // 审核时章节的当前版本
+	LLMModelID              string        `json:"llm_model_id" bson:"llm_model_id"`                           // 请求的审核模型
+	ServedModelID           string        `json:"served_model_id" bson:"served_model_id"`                     // 实际提供服务的模型
+	OverallScore            float64       `json:"overall_score" bson:"overall_score"`                         // 1-10
+	Strengths               []string      `json:"strengths" bson:"strengths"`
+	Issues                  []ReviewIssue `json:"issues" bson:"issues"`
+	OptimizationSuggestions []string      `json:"optimization_suggestions" bson:"optimization_suggestions"`
+	TokenCount              int64         `json:"token_count" bson:"token_count"`
+	CreatorID               string        `json:"creator_id" bson:"creator_id"`
+	Ctime                   int64         `json:"ctime" bson:"ctime"`
+
+	MissingFields []string `json:"missing_fields,omitempty" bson:"-"` // 审核时模型未输出的字段，仅在审核接口返回
+}
+
+// ReviewIssue 审核发现的问题
+type ReviewIssue struct {
+	Type        string `json:"type" bson:"type"` // role_inconsistency|pacing_issue|logic_error
+	Location    string `json:"location" bson:"location"`
+	Description string `json:"description" bson:"description"`
+	Suggestion  string `json:"suggestion" bson:"suggestion"`
+}
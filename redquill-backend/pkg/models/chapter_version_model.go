@@ -0,0 +1,75 @@
+// Package models
+/*
+/@Author: urmsone urmsone@163.com
+/@Date: 2025/10/20 20:44
+/@Name: chapter_version_model.go
+/@Description: Chapter version and revision data structure
+/*/
+
+package models
+
+// 章节版本来源
+const (
+	ChapterVersionSourceOriginal   = "original"   // 启用版本管理前已有的内容
+	ChapterVersionSourceManual     = "manual"     // 通过创建章节接口提交
+	ChapterVersionSourceGeneration = "generation" // AI生成
+	ChapterVersionSourceEdit       = "edit"       // 编辑章节
+	ChapterVersionSourceRevision   = "revision"   // 按审核报告修订
+	ChapterVersionSourceImport     = "import"     // 从稿件文件导入
+)
+
+// ChapterVersion 章节内容的一个版本，修改章节时保留历史内容
+type ChapterVersion struct {
+	ID        string `json:"id" bson:"_id,omitempty"`
+	ChapterID string `json:"chapter_id" bson:"chapter_id"`
+	NovelID   string `json:"novel_id" bson:"novel_id"`
+	Version   int    `json:"version" bson:"version"` // 从1开始递增
+	Title     string `json:"title" bson:"title"`
+	Content   string `json:"content" bson:"content"`
+	Summary   string `json:"summary" bson:"summary"`
+	WordCount int    `json:"word_count" bson:"word_count"`
+	Source    string `json:"source" bson:"source"`                           // original|manual|generation|edit|revision|import
+	ReviewID  string `json:"review_id,omitempty" bson:"review_id,omitempty"` // 修订依据的审核报告
+	CreatorID string `json:"creator_id" bson:"creator_id"`
+	Ctime     int64  `json:"ctime" bson:"ctime"`
+
+	Active bool `json:"active" bson:"-"` // 是否为章节当前版本
+}
+
+// 修订任务状态
+const (
+	RevisionStatusRevised          = "revised"           // 单次修订完成
+	RevisionStatusThresholdReached = "threshold_reached" // 自动模式评分达到阈值
+	RevisionStatusMaxIterations    = "max_iterations"    // 自动模式达到最大轮数
+	RevisionStatusFailed           = "failed"
+)
+
+// ChapterRevisionRun 一次修订请求（自动模式下包含多轮 审核 → 修订）
+type ChapterRevisionRun struct {
+	ID            string          `json:"id" bson:"_id,omitempty"`
+	ChapterID     string          `json:"chapter_id" bson:"chapter_id"`
+	NovelID       string          `json:"novel_id" bson:"novel_id"`
+	Auto          bool            `json:"auto" bson:"auto"`
+	Threshold     float64         `json:"threshold,omitempty" bson:"threshold,omitempty"`
+	MaxIterations int             `json:"max_iterations,omitempty" bson:"max_iterations,omitempty"`
+	Rounds        []RevisionRound `json:"rounds" bson:"rounds"`
+	FinalScore    float64         `json:"final_score" bson:"final_score"` // 最后一次审核的评分
+	Status        string          `json:"status" bson:"status"`
+	Error         string          `json:"error,omitempty" bson:"error,omitempty"`
+	CreatorID     string          `json:"creator_id" bson:"creator_id"`
+	Ctime         int64           `json:"ctime" bson:"ctime"`
+}
+
+// RevisionRound 一轮修订
+type RevisionRound struct {
+	Round         int           `json:"round" bson:"round"`
+	ReviewID      string        `json:"review_id" bson:"review_id"`                         // 本轮依据的审核报告
+	ScoreBefore   float64       `json:"score_before" bson:"score_before"`                   // 修订前评分
+	ScoreAfter    *float64      `json:"score_after,omitempty" bson:"score_after,omitempty"` // 修订后重新审核的评分，仅自动模式
+	Issues        []ReviewIssue `json:"issues" bson:"issues"`
+	RevisionNotes []string      `json:"revision_notes" bson:"revision_notes"`
+	Version       int           `json:"version" bson:"version"` // 修订生成的章节版本
+	ServedModelID string        `json:"served_model_id" bson:"served_model_id"`
+	TokenCount    int64         `json:"token_count" bson:"token_count"`
+	Ctime         int64         `json:"ctime" bson:"ctime"`
+}
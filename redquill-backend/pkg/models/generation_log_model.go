@@ -0,0 +1,49 @@
+// Package models
+/*
+/@Author: urmsone urmsone@163.com
+/@Date: 2025/10/20 20:44
+/@Name: generation_log_model.go
+/@Description: Generation audit log data structure
+/*/
+
+package models
+
+import "time"
+
+// GenerationLog 一次LLM生成的审计日志：发送的Prompt、模型原始输出与解析结果
+type GenerationLog struct {
+	ID               string                 `json:"id" bson:"_id,omitempty"`
+	RequestID        string                 `json:"request_id,omitempty" bson:"request_id,omitempty"` // 发起生成的HTTP请求ID（X-Request-ID）
+	UserID           string                 `json:"user_id" bson:"user_id"`
+	NovelID          string                 `json:"novel_id" bson:"novel_id"`
+	TemplateType     string                 `json:"template_type" bson:"template_type"`
+	TemplateID       string                 `json:"template_id,omitempty" bson:"template_id,omitempty"`
+	TemplateVersion  int                    `json:"template_version,omitempty" bson:"template_version,omitempty"`
+	LLMModelID       string                 `json:"llm_model_id" bson:"llm_model_id"`                                   // 请求的模型
+	ServedModelID    string                 `json:"served_llm_model_id,omitempty" bson:"served_llm_model_id,omitempty"` // 实际提供服务的模型，回退时与请求的不同
+	Model            string                 `json:"model,omitempty" bson:"model,omitempty"`                             // 厂商的模型名称
+	Stream           bool                   `json:"stream" bson:"stream"`
+	Messages         []GenerationLogMessage `json:"messages,omitempty" bson:"messages,omitempty"`
+	Response         string                 `json:"response,omitempty" bson:"response,omitempty"` // 模型原始输出
+	Result           map[string]interface{} `json:"result,omitempty" bson:"result,omitempty"`     // 解析后的结构化结果
+	PromptTokens     int64                  `json:"prompt_tokens" bson:"prompt_tokens"`
+	CompletionTokens int64                  `json:"completion_tokens" bson:"completion_tokens"`
+	TotalTokens      int64                  `json:"total_tokens" bson:"total_tokens"`
+	LatencyMs        int64                  `json:"latency_ms" bson:"latency_ms"`
+	Status           string                 `json:"status" bson:"status"` // success|failed
+	Error            string                 `json:"error,omitempty" bson:"error,omitempty"`
+	Ctime            int64                  `json:"ctime" bson:"ctime"`
+	CreatedAt        time.Time              `json:"-" bson:"created_at"` // TTL索引字段
+}
+
+// GenerationLogMessage 发送给模型的一条消息
+type GenerationLogMessage struct {
+	Role    string `json:"role" bson:"role"`
+	Content string `json:"content" bson:"content"`
+}
+
+// 生成日志状态
+const (
+	GenerationLogStatusSuccess = "success"
+	GenerationLogStatusFailed  = "failed"
+)
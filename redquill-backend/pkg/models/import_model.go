@@ -0,0 +1,49 @@
+// Package models
+/*
+/@Author: urmsone urmsone@163.com
+/@Date: 2025/10/20 20:44
+/@Name: import_model.go
+/@Description: Manuscript import and chapter analysis data structure
+/*/
+
+package models
+
+// ImportResult 稿件导入结果
+type ImportResult struct {
+	NovelID  string            `json:"novel_id"`
+	Format   string            `json:"format"` // txt|md|epub
+	Chapters []ImportedChapter `json:"chapters"`
+	Job      *GenerationJob    `json:"job,omitempty"` // 请求分析时提交的 chapter_analysis 任务
+
+	AnalysisError string `json:"analysis_error,omitempty"` // 章节已导入但分析任务提交失败的原因
+}
+
+// ImportedChapter 导入的一章，不含正文
+type ImportedChapter struct {
+	ID            string `json:"id"`
+	ChapterNumber int    `json:"chapter_number"`
+	Title         string `json:"title"`
+	WordCount     int    `json:"word_count"`
+	HasSummary    bool   `json:"has_summary"` // 稿件中已带有章节概要
+}
+
+// ChapterAnalysis 模型对一章的分析
+type ChapterAnalysis struct {
+	Summary              string            `json:"summary"`
+	Goal                 string            `json:"goal"`
+	KeyEvents            []string          `json:"key_events"`
+	CharacterDevelopment map[string]string `json:"character_development"`
+}
+
+// ChapterAnalysisResult chapter_analysis 任务的结果
+type ChapterAnalysisResult struct {
+	Analyzed int                      `json:"analyzed" bson:"analyzed"`
+	Failed   []ChapterAnalysisFailure `json:"failed,omitempty" bson:"failed,omitempty"`
+}
+
+// ChapterAnalysisFailure 分析失败的章节
+type ChapterAnalysisFailure struct {
+	ChapterID     string `json:"chapter_id" bson:"chapter_id"`
+	ChapterNumber int    `json:"chapter_number" bson:"chapter_number"`
+	Error         string `json:"error" bson:"error"`
+}
@@ -0,0 +1,76 @@
+// Package models
+/*
+/@Author: urmsone urmsone@163.com
+/@Date: 2025/10/20 20:44
+/@Name: job_model.go
+/@Description: Asynchronous generation job data structure
+/*/
+
+package models
+
+// 任务状态
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+	JobStatusCancelled = "cancelled"
+)
+
+// 任务类型
+const (
+	JobTypeStoryCore             = "story_core"
+	JobTypeWorldview             = "worldview"
+	JobTypeCharacter             = "character"
+	JobTypeCharactersFromOutline = "characters_from_outline"
+	JobTypeOutline               = "outline"
+	JobTypeChapter               = "chapter"
+	JobTypeLLM                   = "llm"
+	JobTypeChapterAnalysis       = "chapter_analysis"
+)
+
+// GenerationJob 异步生成任务
+type GenerationJob struct {
+	ID           string                 `json:"id" bson:"_id,omitempty"`
+	Type         string                 `json:"type" bson:"type"` // story_core|worldview|character|characters_from_outline|outline|chapter|llm|chapter_analysis
+	NovelID      string                 `json:"novel_id" bson:"novel_id"`
+	LLMModelID   string                 `json:"llm_model_id" bson:"llm_model_id"`
+	InputData    map[string]interface{} `json:"input_data" bson:"input_data"`
+	TemplateType string                 `json:"template_type,omitempty" bson:"template_type,omitempty"` // 仅 llm 类型使用
+	Options      GenerationOptions      `json:"options" bson:"options"`
+	UserID       string                 `json:"user_id" bson:"user_id"`
+	RequestID    string                 `json:"request_id,omitempty" bson:"request_id,omitempty"` // 提交任务的请求ID，写入生成日志
+	Status       string                 `json:"status" bson:"status"`
+	Result       interface{}            `json:"result,omitempty" bson:"result,omitempty"` // 成功时为生成并保存的实体
+	Error        string                 `json:"error,omitempty" bson:"error,omitempty"`
+	Ctime        int64                  `json:"ctime" bson:"ctime"`
+	Mtime        int64                  `json:"mtime" bson:"mtime"`
+	StartedAt    int64                  `json:"started_at,omitempty" bson:"started_at,omitempty"`
+	FinishedAt   int64                  `json:"finished_at,omitempty" bson:"finished_at,omitempty"`
+}
+
+// IsValidJobType 是否为支持的任务类型
+func IsValidJobType(jobType string) bool {
+	switch jobType {
+	case JobTypeStoryCore, JobTypeWorldview, JobTypeCharacter, JobTypeCharactersFromOutline,
+		JobTypeOutline, JobTypeChapter, JobTypeLLM, JobTypeChapterAnalysis:
+		return true
+	}
+	return false
+}
+
+// PromptTemplateType 任务使用的Prompt模板类型
+func (j GenerationJob) PromptTemplateType() string {
+	switch j.Type {
+	case JobTypeLLM:
+		return j.TemplateType
+	case JobTypeCharactersFromOutline:
+		return "batch_character"
+	}
+	return j.Type
+}
+
+// IsFinished 任务是否已结束
+func (j GenerationJob) IsFinished() bool {
+	return j.Status == JobStatusSucceeded || j.Status == JobStatusFailed || j.Status == JobStatusCancelled
+}
@@ -20,6 +20,7 @@ type LLMModel struct {
 	CostPerToken     float64        `json:"cost_per_token" bson:"cost_per_token"`
 	Status           string         `json:"status" bson:"status"`
 	Config           LLMModelConfig `json:"config" bson:"config"`
+	Quota            *Quota         `json:"quota,omitempty" bson:"quota,omitempty"` // 为空时使用全局默认配额
 	UsageCount       int64          `json:"usage_count" bson:"usage_count"`
 	CreatorID        string         `json:"creator_id" bson:"creator_id"`
 	Creator          string         `json:"creator" bson:"creator"`
@@ -30,18 +31,29 @@ type LLMModel struct {
 // LLMModelConfig LLM模型配置
 type LLMModelConfig struct {
 	Provider    string  `json:"provider" bson:"provider"`
-	APIKey      string  `json:"api_key" bson:"api_key"`
+	APIKey      string  `json:"api_key" bson:"api_key,omitempty"` // 仅用于请求入参与脱敏展示，不再明文落库
 	BaseURL     string  `json:"base_url" bson:"base_url"`
 	ModelName   string  `json:"model_name" bson:"model_name"`
 	Temperature float64 `json:"temperature" bson:"temperature"`
 	MaxTokens   int     `json:"max_tokens" bson:"max_tokens"`
 	Timeout     int     `json:"timeout" bson:"timeout"`
+
+	// 重试配置
+	MaxRetries   int    `json:"max_retries" bson:"max_retries"`       // 最大重试次数，0 或 -1 不重试；创建时为0则使用默认值
+	RetryDelayMs int    `json:"retry_delay_ms" bson:"retry_delay_ms"` // 重试基础间隔（毫秒），0 使用默认值
+	Backoff      string `json:"backoff" bson:"backoff"`               // 退避策略：linear | exponential（默认）
+
+	// 信封加密后的API Key
+	APIKeyCipher     string `json:"-" bson:"api_key_cipher,omitempty"`      // 数据密钥加密的API Key
+	APIKeyWrappedKey string `json:"-" bson:"api_key_wrapped_key,omitempty"` // 主密钥包裹的数据密钥
+	APIKeyMask       string `json:"-" bson:"api_key_mask,omitempty"`        // 脱敏展示值，如 sk-****abcd
 }
 
 // LLMModelTestRequest LLM模型测试请求
 type LLMModelTestRequest struct {
 	Messages []LLMTestMessage `json:"messages"`
 	Stream   bool             `json:"stream,omitempty"`
+	UserID   string           `json:"-"` // 调用用户ID，用于用量统计
 }
 
 // LLMMessage LLM消息
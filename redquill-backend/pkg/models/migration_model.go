@@ -0,0 +1,17 @@
+// Package models
+/*
+/@Author: urmsone urmsone@163.com
+/@Date: 2025/10/20 20:44
+/@Name: migration_model.go
+/@Description: Applied schema/data migration record
+/*/
+
+package models
+
+// SchemaMigration 已执行的迁移，保存在 schema_migrations 集合中
+type SchemaMigration struct {
+	ID          string `json:"id" bson:"_id"` // 迁移标识，例如 prompt_template:system.chapter@v2
+	Description string `json:"description" bson:"description"`
+	Result      string `json:"result" bson:"result"` // 迁移执行结果说明
+	AppliedAt   int64  `json:"applied_at" bson:"applied_at"`
+}
@@ -15,12 +15,16 @@ type Novel struct {
 	AuthorID     string `json:"author_id" bson:"author_id"`
 	Status       string `json:"status" bson:"status"`               // drafting|writing|completed|paused
 	CurrentPhase string `json:"current_phase" bson:"current_phase"` // story_core|worldview|characters|outlining|writing
+	Shared       bool   `json:"shared" bson:"shared"`               // 共享后其他用户（含读者）可只读访问
 	Ctime        int64  `json:"ctime" bson:"ctime"`
 	Mtime        int64  `json:"mtime" bson:"mtime"`
+	DeletedAt    int64  `json:"deleted_at,omitempty" bson:"deleted_at,omitempty"` // 移入回收站的时间，为0表示未删除
+	PurgeAt      int64  `json:"purge_at,omitempty" bson:"purge_at,omitempty"`     // 回收站保留期限，到期后彻底删除
 
 	ProjectBlueprint ProjectBlueprint     `json:"project_blueprint" bson:"project_blueprint"`
 	AIContext        AIContext            `json:"ai_context" bson:"ai_context"`
 	ExtraInfo        map[string]interface{} `json:"extra_info" bson:"extra_info"` // 存放各个阶段AI生成返回的信息
+	PromptTemplates  map[string]string    `json:"prompt_templates,omitempty" bson:"prompt_templates,omitempty"` // 按模板类型覆盖使用的Prompt模板ID，例如 chapter -> 模板ID
 }
 
 // ProjectBlueprint 项目蓝图
@@ -51,7 +55,12 @@ type StoryCore struct {
 	Innovation          string `json:"innovation" bson:"innovation"`
 	CommercialPotential string `json:"commercial_potential" bson:"commercial_potential"`
 	TargetAudience      string `json:"target_audience" bson:"target_audience"`
+	Selected            bool   `json:"selected" bson:"selected"` // 小说采用的故事核心，生成时优先使用
+	Version             int    `json:"version" bson:"version"`   // 每次修改递增，用于 If-Match 并发控制
 	Ctime               int64  `json:"ctime" bson:"ctime"`
+	Mtime               int64  `json:"mtime" bson:"mtime"`
+
+	MissingFields []string `json:"missing_fields,omitempty" bson:"-"` // 生成时模型未输出的字段，仅在生成接口返回
 }
 
 // Worldview 世界观
@@ -62,7 +71,12 @@ type Worldview struct {
 	SocietyStructure SocietyStructure `json:"society_structure" bson:"society_structure"`
 	Geography        Geography        `json:"geography" bson:"geography"`
 	SpecialRules     []string         `json:"special_rules" bson:"special_rules"`
+	Selected         bool             `json:"selected" bson:"selected"` // 小说采用的世界观，生成时优先使用
+	Version          int              `json:"version" bson:"version"`   // 每次修改递增，用于 If-Match 并发控制
 	Ctime            int64            `json:"ctime" bson:"ctime"`
+	Mtime            int64            `json:"mtime" bson:"mtime"`
+
+	MissingFields []string `json:"missing_fields,omitempty" bson:"-"` // 生成时模型未输出的字段，仅在生成接口返回
 }
 
 // PowerSystem 力量体系
@@ -102,7 +116,11 @@ type Character struct {
 	CoreAttributes CoreAttributes `json:"core_attributes" bson:"core_attributes"`
 	SoulProfile    SoulProfile    `json:"soul_profile" bson:"soul_profile"`
 	GrowthTrack    []GrowthEvent  `json:"growth_track" bson:"growth_track"`
+	Version        int            `json:"version" bson:"version"` // 每次修改递增，用于 If-Match 并发控制
 	Ctime          int64          `json:"updated_at" bson:"updated_at"`
+	Mtime          int64          `json:"mtime" bson:"mtime"`
+
+	MissingFields []string `json:"missing_fields,omitempty" bson:"-"` // 生成时模型未输出的字段，仅在生成接口返回
 }
 
 // CoreAttributes 核心属性
@@ -162,7 +180,11 @@ type Chapter struct {
 	Outline              ChapterOutline    `json:"outline" bson:"outline"`
 	QualityMetrics       QualityMetrics    `json:"quality_metrics" bson:"quality_metrics"`
 	CharacterDevelopment map[string]string `json:"character_development" bson:"character_development"`
+	ActiveVersion        int               `json:"active_version" bson:"active_version"` // 当前内容对应的版本号，见 ChapterVersion
 	Ctime                int64             `json:"ctime" bson:"ctime"`
+	Mtime                int64             `json:"mtime" bson:"mtime"`
+
+	MissingFields []string `json:"missing_fields,omitempty" bson:"-"` // 生成时模型未输出的字段，仅在生成接口返回
 }
 
 // ChapterOutline 章节大纲
@@ -177,6 +199,7 @@ type QualityMetrics struct {
 	Score            int      `json:"score" bson:"score"`
 	Strengths        []string `json:"strengths" bson:"strengths"`
 	ImprovementAreas []string `json:"improvement_areas" bson:"improvement_areas"`
+	ReviewID         string   `json:"review_id,omitempty" bson:"review_id,omitempty"` // 来源审核报告，为空表示章节生成时的自评
 }
 
 // WritingSession 创作会话
@@ -206,6 +229,8 @@ type Outline struct {
 	KeyThemes    []string      `json:"key_themes" bson:"key_themes"`
 	Ctime        int64         `json:"ctime" bson:"ctime"`
 	Mtime        int64         `json:"mtime" bson:"mtime"`
+
+	MissingFields []string `json:"missing_fields,omitempty" bson:"-"` // 生成时模型未输出的字段，仅在生成接口返回
 }
 
 // ChapterInfo 章节信息
@@ -17,6 +17,11 @@ type PromptTemplate struct {
 	Content     string `json:"content" bson:"content"`
 	Variables   []string `json:"variables" bson:"variables"`
 	Description string `json:"description" bson:"description"`
+	OutputSchema map[string]interface{} `json:"output_schema,omitempty" bson:"output_schema,omitempty"` // 输出JSON Schema，为空时不校验
+	Key          string `json:"key,omitempty" bson:"key,omitempty"`         // 内置模板的稳定标识，例如 system.chapter；用户创建的模板为空
+	Version      int    `json:"version,omitempty" bson:"version,omitempty"` // 内置模板内容的版本
+	SeedChecksum string `json:"-" bson:"seed_checksum,omitempty"`          // 写入内置内容时的内容校验和，与当前内容不一致说明已被修改
+	IsDefault    bool   `json:"is_default" bson:"is_default"`              // 同一类型的默认模板，未指定模板时使用
 	UsageCount  int64  `json:"usage_count" bson:"usage_count"`
 	CreatorID   string `json:"creator_id" bson:"creator_id"`
 	Creator     string `json:"creator" bson:"creator"`
@@ -31,14 +36,27 @@ type GenerationRequest struct {
 	InputData    map[string]interface{} `json:"input_data" binding:"required"`
 	TemplateType string                 `json:"template_type" binding:"required"`
 	Stream       bool                   `json:"stream,omitempty"`
+	GenerationOptions
+}
+
+// GenerationOptions 生成的可选参数
+type GenerationOptions struct {
+	FallbackModelIDs []string `json:"fallback_llm_model_ids,omitempty" bson:"fallback_llm_model_ids,omitempty"` // 首选模型限流/故障时依次尝试的模型
+	UserID           string   `json:"-" bson:"-"`                                                            // 发起生成的用户，由handler从JWT填充
+	// 仅章节生成：生成后自动运行 quality_review，ReviewLLMModelID 为空时使用生成模型
+	AutoReview       bool   `json:"auto_review,omitempty" bson:"auto_review,omitempty"`
+	ReviewLLMModelID string `json:"review_llm_model_id,omitempty" bson:"review_llm_model_id,omitempty"`
+	// 指定使用的Prompt模板，类型必须与生成类型一致；为空时依次使用小说的模板覆盖、该类型的默认模板
+	TemplateID string `json:"template_id,omitempty" bson:"template_id,omitempty"`
 }
 
 // GenerationResponse 生成响应
 type GenerationResponse struct {
-	Success     bool                   `json:"success"`
-	Message     string                 `json:"message"`
-	Data        map[string]interface{} `json:"data,omitempty"`
-	Error       string                 `json:"error,omitempty"`
-	UsageCount  int64                  `json:"usage_count,omitempty"`
-	TokenCount  int64                  `json:"token_count,omitempty"`
+	Success       bool                   `json:"success"`
+	Message       string                 `json:"message"`
+	Data          map[string]interface{} `json:"data,omitempty"`
+	Error         string                 `json:"error,omitempty"`
+	UsageCount    int64                  `json:"usage_count,omitempty"`
+	TokenCount    int64                  `json:"token_count,omitempty"`
+	ServedModelID string                 `json:"served_model_id,omitempty"` // 实际提供服务的模型ID（发生回退时与请求的模型不同）
 }
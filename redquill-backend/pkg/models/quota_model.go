@@ -0,0 +1,21 @@
+// Package models
+/*
+/@Author: urmsone urmsone@163.com
+/@Date: 2025/10/20 20:44
+/@Name: quota_model.go
+/@Description: Quota data structure
+/*/
+
+package models
+
+// Quota 配额，0 表示不限制
+type Quota struct {
+	RequestsPerMinute int     `json:"requests_per_minute" bson:"requests_per_minute"` // 每分钟请求数
+	TokensPerDay      int64   `json:"tokens_per_day" bson:"tokens_per_day"`           // 每日token数（自然日）
+	CostPerMonth      float64 `json:"cost_per_month" bson:"cost_per_month"`           // 每月费用（自然月）
+}
+
+// IsZero 是否未设置任何限制
+func (q Quota) IsZero() bool {
+	return q.RequestsPerMinute == 0 && q.TokensPerDay == 0 && q.CostPerMonth == 0
+}
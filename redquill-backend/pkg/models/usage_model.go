@@ -0,0 +1,47 @@
+// Package models
+/*
+/@Author: urmsone urmsone@163.com
+/@Date: 2025/10/20 20:44
+/@Name: usage_model.go
+/@Description: LLM usage record data structure
+/*/
+
+package models
+
+// UsageRecord LLM调用用量记录，每次LLM调用一条
+type UsageRecord struct {
+	ID               string  `json:"id" bson:"_id,omitempty"`
+	UserID           string  `json:"user_id" bson:"user_id"`
+	NovelID          string  `json:"novel_id" bson:"novel_id"`
+	LLMModelID       string  `json:"llm_model_id" bson:"llm_model_id"` // 实际提供服务的模型
+	ModelName        string  `json:"model_name" bson:"model_name"`
+	TemplateType     string  `json:"template_type" bson:"template_type"`
+	Source           string  `json:"source" bson:"source"` // generation|service|test
+	PromptTokens     int64   `json:"prompt_tokens" bson:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens" bson:"completion_tokens"`
+	TotalTokens      int64   `json:"total_tokens" bson:"total_tokens"`
+	Cost             float64 `json:"cost" bson:"cost"` // TotalTokens * CostPerToken
+	LatencyMs        int64   `json:"latency_ms" bson:"latency_ms"`
+	Success          bool    `json:"success" bson:"success"`
+	Error            string  `json:"error,omitempty" bson:"error,omitempty"`
+	Ctime            int64   `json:"ctime" bson:"ctime"`
+}
+
+// UsageSummary 用量聚合结果
+type UsageSummary struct {
+	Key              string  `json:"key" bson:"_id"` // 分组键：user_id / llm_model_id / novel_id
+	Calls            int64   `json:"calls" bson:"calls"`
+	SuccessCalls     int64   `json:"success_calls" bson:"success_calls"`
+	PromptTokens     int64   `json:"prompt_tokens" bson:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens" bson:"completion_tokens"`
+	TotalTokens      int64   `json:"total_tokens" bson:"total_tokens"`
+	Cost             float64 `json:"cost" bson:"cost"`
+	AvgLatencyMs     float64 `json:"avg_latency_ms" bson:"avg_latency_ms"`
+}
+
+// 用量来源
+const (
+	UsageSourceGeneration = "generation"
+	UsageSourceService    = "service"
+	UsageSourceTest       = "test"
+)
@@ -8,11 +8,37 @@
 
 package models
 
+// 用户角色
+const (
+	RoleAdmin  = "admin"  // 管理员：管理模型、用户与系统模板
+	RoleWriter = "writer" // 作者：创作并管理自己的作品
+	RoleViewer = "viewer" // 读者：只能阅读共享的作品
+)
+
 type User struct {
 	ID       string `json:"id" bson:"_id,omitempty"`
 	Name     string `json:"name" bson:"name"`
 	Email    string `json:"email" bson:"email"`
-	Password string `json:"-" bson:"password"` // hashed
+	Password string `json:"-" bson:"password"`                      // hashed
+	Role     string `json:"role" bson:"role"`                       // admin|writer|viewer
+	Quota    *Quota `json:"quota,omitempty" bson:"quota,omitempty"` // 为空时使用全局默认配额
 	Ctime    int64  `json:"ctime" bson:"ctime"`
 	Mtime    int64  `json:"mtime" bson:"mtime"`
 }
+
+// IsValidRole 判断角色是否合法
+func IsValidRole(role string) bool {
+	switch role {
+	case RoleAdmin, RoleWriter, RoleViewer:
+		return true
+	}
+	return false
+}
+
+// NormalizeRole 兼容历史数据，未设置角色的用户视为作者
+func NormalizeRole(role string) string {
+	if role == "" {
+		return RoleWriter
+	}
+	return role
+}
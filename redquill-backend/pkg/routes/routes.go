@@ -1,14 +1,18 @@
 package routes
 
 import (
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/mongo"
 	"redquill-backend/pkg/config"
 	"redquill-backend/pkg/handlers"
 	"redquill-backend/pkg/middleware"
+	"redquill-backend/pkg/models"
+	"redquill-backend/pkg/services"
 )
 
-func Register(r *gin.Engine, cfg config.Config, mongoClient *mongo.Client) {
+func Register(r *gin.Engine, cfg config.Config, mongoClient *mongo.Client, jobs *services.JobRunner, streams *services.StreamHub) {
 	// Health
 	r.GET("/healthz", handlers.HealthHandler(mongoClient))
 
@@ -19,75 +23,138 @@ func Register(r *gin.Engine, cfg config.Config, mongoClient *mongo.Client) {
 		v1.POST("/login", handlers.LoginHandler(mongoClient, cfg.DBName, cfg))
 
 		// users
-		v1.POST("/user", handlers.PostUsersHandler(mongoClient, cfg.DBName)) // registration
+		v1.POST("/user", handlers.PostUsersHandler(mongoClient, cfg.DBName, cfg)) // registration
 
 		auth := v1.Group("")
-		auth.Use(middleware.AuthRequired(cfg))
-		auth.GET("/users", handlers.ListUsersHandler(mongoClient, cfg.DBName))
+		auth.Use(middleware.AuthRequired(cfg, mongoClient))
+
+		// 角色分组：writer 可写作与生成，admin 负责模型与系统模板管理
+		writer := auth.Group("")
+		writer.Use(middleware.RequireRole(models.RoleAdmin, models.RoleWriter))
+		// 生成类接口受用户与模型配额限制
+		quota := writer.Group("")
+		quota.Use(middleware.QuotaLimit(cfg, mongoClient))
+		admin := auth.Group("")
+		admin.Use(middleware.RequireRole(models.RoleAdmin))
+
+		admin.GET("/users", handlers.ListUsersHandler(mongoClient, cfg.DBName))
 		auth.GET("/user/:id", handlers.GetUsersHandler(mongoClient, cfg.DBName))
-		auth.PUT("/user/:id", handlers.PutUsersHandler(mongoClient, cfg.DBName))
-		auth.DELETE("/user/:id", handlers.DeleteUsersHandler(mongoClient, cfg.DBName))
+		auth.PUT("/user/:id", handlers.PutUsersHandler(mongoClient, cfg.DBName, cfg))
+		admin.DELETE("/user/:id", handlers.DeleteUsersHandler(mongoClient, cfg.DBName))
 
 		// LLM models
-		auth.POST("/llm-model", handlers.PostLLMModelsHandler(mongoClient, cfg.DBName))
+		admin.POST("/llm-model", handlers.PostLLMModelsHandler(mongoClient, cfg.DBName))
 		auth.GET("/llm-models", handlers.ListLLMModelsHandler(mongoClient, cfg.DBName))
 		auth.GET("/llm-model/:id", handlers.GetLLMModelsHandler(mongoClient, cfg.DBName))
-		auth.PUT("/llm-model/:id", handlers.PutLLMModelsHandler(mongoClient, cfg.DBName))
-		auth.DELETE("/llm-model/:id", handlers.DeleteLLMModelsHandler(mongoClient, cfg.DBName))
-		auth.POST("/llm-model/:id/test", handlers.TestLLMModelsHandler(mongoClient, cfg.DBName))
-		auth.POST("/llm-model/:id/service", handlers.ServiceLLMModelsHandler(mongoClient, cfg.DBName))
+		admin.PUT("/llm-model/:id", handlers.PutLLMModelsHandler(mongoClient, cfg.DBName))
+		admin.DELETE("/llm-model/:id", handlers.DeleteLLMModelsHandler(mongoClient, cfg.DBName))
+		admin.POST("/llm-model/:id/test", handlers.TestLLMModelsHandler(mongoClient, cfg.DBName))
+		quota.POST("/llm-model/:id/service", handlers.ServiceLLMModelsHandler(mongoClient, cfg.DBName))
+
+		// Usage - 用量统计（非管理员只能看到自己的用量）
+		auth.GET("/usage", handlers.GetUsageHandler(mongoClient, cfg.DBName))
+
+		// Generation logs - 生成审计日志（非管理员只能看到自己的日志）
+		auth.GET("/generation-logs", handlers.ListGenerationLogsHandler(mongoClient, cfg.DBName))
+		auth.GET("/generation-log/:id", handlers.GetGenerationLogHandler(mongoClient, cfg.DBName))
+
+		// Prompt templates - 系统模板仅管理员可修改
+		admin.POST("/prompt-template", handlers.PostPromptTemplatesHandler(mongoClient, cfg.DBName))
+		auth.GET("/prompt-templates", handlers.ListPromptTemplatesHandler(mongoClient, cfg.DBName))
+		auth.GET("/prompt-template/:id", handlers.GetPromptTemplatesHandler(mongoClient, cfg.DBName))
+		admin.PUT("/prompt-template/:id", handlers.PutPromptTemplatesHandler(mongoClient, cfg.DBName))
+		admin.DELETE("/prompt-template/:id", handlers.DeletePromptTemplatesHandler(mongoClient, cfg.DBName))
+		admin.POST("/prompt-template/:id/default", handlers.SetDefaultPromptTemplateHandler(mongoClient, cfg.DBName))
 
 		// Prompts
-		auth.POST("/prompt", handlers.PostPromptsHandler(mongoClient, cfg.DBName))
+		writer.POST("/prompt", handlers.PostPromptsHandler(mongoClient, cfg.DBName))
 		auth.GET("/prompts", handlers.ListPromptsHandler(mongoClient, cfg.DBName))
 		auth.GET("/prompt/:id", handlers.GetPromptsHandler(mongoClient, cfg.DBName))
-		auth.PUT("/prompt/:id", handlers.PutPromptsHandler(mongoClient, cfg.DBName))
-		auth.DELETE("/prompt/:id", handlers.DeletePromptsHandler(mongoClient, cfg.DBName))
+		writer.PUT("/prompt/:id", handlers.PutPromptsHandler(mongoClient, cfg.DBName))
+		writer.DELETE("/prompt/:id", handlers.DeletePromptsHandler(mongoClient, cfg.DBName))
 
 		// Novels
-		auth.POST("/novel", handlers.PostNovelsHandler(mongoClient, cfg.DBName))
+		writer.POST("/novel", handlers.PostNovelsHandler(mongoClient, cfg.DBName))
 		auth.GET("/novels", handlers.ListNovelsHandler(mongoClient, cfg.DBName))
 		auth.GET("/novel/:id", handlers.GetNovelsHandler(mongoClient, cfg.DBName))
-		auth.PUT("/novel/:id", handlers.PutNovelsHandler(mongoClient, cfg.DBName))
-		auth.DELETE("/novel/:id", handlers.DeleteNovelsHandler(mongoClient, cfg.DBName))
+		writer.PUT("/novel/:id", handlers.PutNovelsHandler(mongoClient, cfg.DBName))
+		writer.DELETE("/novel/:id", handlers.DeleteNovelsHandler(mongoClient, cfg.DBName, time.Duration(cfg.TrashRetentionDays)*24*time.Hour))
+		writer.POST("/novel/:id/restore", handlers.RestoreNovelsHandler(mongoClient, cfg.DBName))
+		auth.GET("/novel/:id/export", handlers.ExportNovelHandler(mongoClient, cfg.DBName))
+		writer.PUT("/novel/:id/prompt-templates", handlers.PutNovelPromptTemplatesHandler(mongoClient, cfg.DBName))
+		writer.POST("/novel/:id/import", handlers.ImportNovelHandler(mongoClient, cfg.DBName, jobs, int64(cfg.ImportMaxSizeMB)<<20,
+			services.DefaultQuotas{User: cfg.DefaultUserQuota, Model: cfg.DefaultModelQuota}))
+		writer.GET("/novels/trash", handlers.ListTrashedNovelsHandler(mongoClient, cfg.DBName))
 
 		// Story cores - 使用不同的路径前缀避免冲突
-		auth.POST("/story-core", handlers.PostStoryCoresHandler(mongoClient, cfg.DBName))
+		writer.POST("/story-core", handlers.PostStoryCoresHandler(mongoClient, cfg.DBName))
 		auth.GET("/story-cores/:novel_id", handlers.GetStoryCoresHandler(mongoClient, cfg.DBName))
+		writer.PUT("/story-core/:id", handlers.PutStoryCoresHandler(mongoClient, cfg.DBName))
+		writer.PATCH("/story-core/:id", handlers.PatchStoryCoresHandler(mongoClient, cfg.DBName))
+		writer.DELETE("/story-core/:id", handlers.DeleteStoryCoresHandler(mongoClient, cfg.DBName))
+		writer.POST("/story-core/:id/select", handlers.SelectStoryCoreHandler(mongoClient, cfg.DBName))
 
 		// Worldviews - 使用不同的路径前缀避免冲突
-		auth.POST("/worldview", handlers.PostWorldviewsHandler(mongoClient, cfg.DBName))
-		auth.GET("/worldview/:novel_id", handlers.GetWorldviewsHandler(mongoClient, cfg.DBName))
+		writer.POST("/worldview", handlers.PostWorldviewsHandler(mongoClient, cfg.DBName))
+		auth.GET("/worldview/:novel_id", handlers.GetWorldviewsHandler(mongoClient, cfg.DBName)) // 小说采用的世界观
+		auth.GET("/worldviews/:novel_id", handlers.ListWorldviewsHandler(mongoClient, cfg.DBName))
+		writer.PUT("/worldview/:id", handlers.PutWorldviewsHandler(mongoClient, cfg.DBName))
+		writer.PATCH("/worldview/:id", handlers.PatchWorldviewsHandler(mongoClient, cfg.DBName))
+		writer.DELETE("/worldview/:id", handlers.DeleteWorldviewsHandler(mongoClient, cfg.DBName))
+		writer.POST("/worldview/:id/select", handlers.SelectWorldviewHandler(mongoClient, cfg.DBName))
 
 		// Characters - 使用不同的路径前缀避免冲突
-		auth.POST("/character", handlers.PostCharactersHandler(mongoClient, cfg.DBName))
+		writer.POST("/character", handlers.PostCharactersHandler(mongoClient, cfg.DBName))
 		auth.GET("/characters/:novel_id", handlers.GetCharactersHandler(mongoClient, cfg.DBName))
+		auth.GET("/character/:id", handlers.GetCharacterHandler(mongoClient, cfg.DBName))
+		writer.PUT("/character/:id", handlers.PutCharactersHandler(mongoClient, cfg.DBName))
+		writer.PATCH("/character/:id", handlers.PatchCharactersHandler(mongoClient, cfg.DBName))
+		writer.DELETE("/character/:id", handlers.DeleteCharactersHandler(mongoClient, cfg.DBName))
 
 		// Chapters - 使用不同的路径前缀避免冲突
-		auth.POST("/chapter", handlers.PostChaptersHandler(mongoClient, cfg.DBName))
+		writer.POST("/chapter", handlers.PostChaptersHandler(mongoClient, cfg.DBName))
 		auth.GET("/chapters/:novel_id", handlers.GetChaptersHandler(mongoClient, cfg.DBName))
 		auth.GET("/chapter/:id", handlers.GetChapterHandler(mongoClient, cfg.DBName))
+		writer.PUT("/chapter/:id", handlers.PutChaptersHandler(mongoClient, cfg.DBName))
+		writer.DELETE("/chapter/:id", handlers.DeleteChaptersHandler(mongoClient, cfg.DBName))
+		auth.GET("/chapter/:id/versions", handlers.ListChapterVersionsHandler(mongoClient, cfg.DBName))
+		auth.GET("/chapter/:id/versions/:version", handlers.GetChapterVersionHandler(mongoClient, cfg.DBName))
+		auth.GET("/chapter/:id/diff", handlers.GetChapterDiffHandler(mongoClient, cfg.DBName))
+		writer.POST("/chapter/:id/rollback", handlers.PostChapterRollbackHandler(mongoClient, cfg.DBName))
+		quota.POST("/chapter/:id/review", handlers.PostChapterReviewsHandler(mongoClient, cfg.DBName))
+		auth.GET("/chapter/:id/reviews", handlers.ListChapterReviewsHandler(mongoClient, cfg.DBName))
+		quota.POST("/chapter/:id/revise", handlers.PostChapterRevisionsHandler(mongoClient, cfg.DBName))
+		auth.GET("/chapter/:id/revisions", handlers.ListChapterRevisionsHandler(mongoClient, cfg.DBName))
 
 		// Writing sessions - 使用不同的路径前缀避免冲突
-		auth.POST("/writing-session", handlers.PostWritingSessionsHandler(mongoClient, cfg.DBName))
+		writer.POST("/writing-session", handlers.PostWritingSessionsHandler(mongoClient, cfg.DBName))
 		auth.GET("/writing-session/:novel_id", handlers.GetWritingSessionsHandler(mongoClient, cfg.DBName))
 
 		// Outlines - 大纲管理
-		auth.POST("/outline", handlers.PostOutlinesHandler(mongoClient, cfg.DBName))
+		writer.POST("/outline", handlers.PostOutlinesHandler(mongoClient, cfg.DBName))
 		auth.GET("/outlines", handlers.ListOutlinesHandler(mongoClient, cfg.DBName))
 		auth.GET("/outline/:id", handlers.GetOutlineHandler(mongoClient, cfg.DBName))
-		auth.PUT("/outline/:id", handlers.PutOutlinesHandler(mongoClient, cfg.DBName))
-		auth.DELETE("/outline/:id", handlers.DeleteOutlinesHandler(mongoClient, cfg.DBName))
+		writer.PUT("/outline/:id", handlers.PutOutlinesHandler(mongoClient, cfg.DBName))
+		writer.DELETE("/outline/:id", handlers.DeleteOutlinesHandler(mongoClient, cfg.DBName))
 		auth.GET("/outlines/:novel_id", handlers.GetOutlinesHandler(mongoClient, cfg.DBName))
 
 		// Novel generation - AI生成功能
-		auth.POST("/generate/story-core", handlers.GenerateStoryCoreHandler(mongoClient, cfg.DBName))
-		auth.POST("/generate/worldview", handlers.GenerateWorldviewHandler(mongoClient, cfg.DBName))
-		auth.POST("/generate/character", handlers.GenerateCharacterHandler(mongoClient, cfg.DBName))
-		auth.POST("/generate/characters-from-outline", handlers.GenerateCharactersFromOutlineHandler(mongoClient, cfg.DBName))
-		auth.POST("/generate/outline", handlers.GenerateOutlineHandler(mongoClient, cfg.DBName))
-		auth.POST("/generate/chapter", handlers.GenerateChapterHandler(mongoClient, cfg.DBName))
-		auth.POST("/generate/llm", handlers.GenerateWithLLMHandler(mongoClient, cfg.DBName))
+		quota.POST("/generate/story-core", handlers.GenerateStoryCoreHandler(mongoClient, cfg.DBName, streams))
+		quota.POST("/generate/worldview", handlers.GenerateWorldviewHandler(mongoClient, cfg.DBName, streams))
+		quota.POST("/generate/character", handlers.GenerateCharacterHandler(mongoClient, cfg.DBName, streams))
+		quota.POST("/generate/characters-from-outline", handlers.GenerateCharactersFromOutlineHandler(mongoClient, cfg.DBName, streams))
+		quota.POST("/generate/outline", handlers.GenerateOutlineHandler(mongoClient, cfg.DBName, streams))
+		quota.POST("/generate/chapter", handlers.GenerateChapterHandler(mongoClient, cfg.DBName, streams))
+		quota.POST("/generate/llm", handlers.GenerateWithLLMHandler(mongoClient, cfg.DBName))
+		// 预览不调用模型，不计入配额
+		writer.POST("/generate/preview", handlers.PreviewGenerationHandler(mongoClient, cfg.DBName))
+		auth.GET("/generate/stream/:generation_id", handlers.GetGenerationStreamHandler(streams))
+
+		// Generation jobs - 异步生成任务
+		quota.POST("/jobs", handlers.PostJobsHandler(mongoClient, cfg.DBName, jobs))
+		auth.GET("/jobs", handlers.ListJobsHandler(mongoClient, cfg.DBName))
+		auth.GET("/jobs/:id", handlers.GetJobsHandler(mongoClient, cfg.DBName))
+		writer.DELETE("/jobs/:id", handlers.DeleteJobsHandler(mongoClient, cfg.DBName, jobs))
 
 	}
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"redquill-backend/pkg/common"
 	"redquill-backend/pkg/config"
 	"redquill-backend/pkg/middleware"
 	"redquill-backend/pkg/routes"
@@ -16,8 +17,11 @@ import (
 )
 
 type HTTPServer struct {
-	engine *gin.Engine
-	server *http.Server
+	engine  *gin.Engine
+	server  *http.Server
+	jobs    *services.JobRunner
+	streams *services.StreamHub
+	purger  *services.TrashPurger
 }
 
 func NewHTTPServer(cfg config.Config, mongoClient *mongo.Client) *HTTPServer {
@@ -30,15 +34,69 @@ func NewHTTPServer(cfg config.Config, mongoClient *mongo.Client) *HTTPServer {
 	engine.Use(middleware.RequestID())
 	engine.Use(gin.Logger())
 
+	// 设置API Key加密主密钥，并加密历史明文Key
+	common.SetMasterKey(cfg.LLMMasterKey)
+	if n, err := services.NewLLMModelService(mongoClient, cfg.DBName).EncryptLegacyAPIKeys(context.Background()); err != nil {
+		log.Printf("Failed to encrypt legacy API keys: %v", err)
+	} else if n > 0 {
+		log.Printf("Encrypted %d legacy LLM API keys", n)
+	}
+
+	// 合并重复章节后创建章节唯一索引
+	chapterVersions := services.NewChapterVersionService(mongoClient, cfg.DBName)
+	if n, err := chapterVersions.MergeDuplicateChapters(context.Background()); err != nil {
+		log.Printf("Failed to merge duplicate chapters: %v", err)
+	} else {
+		if n > 0 {
+			log.Printf("Merged %d duplicate chapters into version history", n)
+		}
+		if err := chapterVersions.EnsureChapterIndexes(context.Background()); err != nil {
+			log.Printf("Failed to create chapter indexes: %v", err)
+		}
+	}
+
+	// 生成日志索引与按保留天数过期的TTL索引
+	retention := time.Duration(cfg.GenerationLogRetentionDays) * 24 * time.Hour
+	if err := services.NewGenerationLogService(mongoClient, cfg.DBName).EnsureGenerationLogIndexes(context.Background(), retention); err != nil {
+		log.Printf("Failed to create generation log indexes: %v", err)
+	}
+
+	// 用量记录索引
+	if err := services.NewUsageService(mongoClient, cfg.DBName).EnsureUsageIndexes(context.Background()); err != nil {
+		log.Printf("Failed to create usage record indexes: %v", err)
+	}
+
 	// 初始化Prompt模板
 	if err := services.InitializePromptTemplates(mongoClient, cfg.DBName); err != nil {
 		log.Fatal("Failed to initialize prompt templates:", err)
 	}
 
-	routes.Register(engine, cfg, mongoClient)
+	// 根据 ADMIN_EMAILS 提升管理员
+	if len(cfg.AdminEmails) > 0 {
+		if err := services.NewUserService(mongoClient, cfg.DBName).PromoteAdmins(context.Background(), cfg.AdminEmails); err != nil {
+			log.Printf("Failed to promote admin users: %v", err)
+		}
+	}
+
+	// 启动异步生成任务执行器
+	jobs := services.NewJobRunner(mongoClient, cfg.DBName, cfg.JobWorkers, cfg.JobQueueSize,
+		services.DefaultQuotas{User: cfg.DefaultUserQuota, Model: cfg.DefaultModelQuota})
+	jobs.Start()
+
+	// 流式生成会话，支持断线续传
+	streams := services.NewStreamHub(mongoClient, cfg.DBName, services.DefaultStreamTTL)
+
+	// 定期彻底删除回收站中过期的小说
+	purger := services.NewTrashPurger(mongoClient, cfg.DBName, time.Duration(cfg.TrashPurgeIntervalMin)*time.Minute)
+	purger.Start()
+
+	routes.Register(engine, cfg, mongoClient, jobs, streams)
 
 	hs := &HTTPServer{
-		engine: engine,
+		engine:  engine,
+		jobs:    jobs,
+		streams: streams,
+		purger:  purger,
 	}
 	hs.server = &http.Server{
 		Addr:              fmt.Sprintf(":%d", cfg.HTTPPort),
@@ -61,4 +119,8 @@ func (s *HTTPServer) Shutdown() {
 	if err := s.server.Shutdown(ctx); err != nil {
 		log.Printf("server shutdown error: %v", err)
 	}
+	// 执行中的任务会被中断，下次启动时重新排队
+	s.jobs.Stop()
+	s.streams.Stop()
+	s.purger.Stop()
 }
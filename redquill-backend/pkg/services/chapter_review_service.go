@@ -0,0 +1,208 @@
+// Package services
+/*
+/@Author: urmsone urmsone@163.com
+/@Date: 2025/10/20 20:44
+/@Name: chapter_review_service.go
+/@Description: Chapter quality review service implementation
+/*/
+
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"redquill-backend/pkg/common"
+	"redquill-backend/pkg/models"
+)
+
+// ChapterReviewService 章节质量审核服务
+type ChapterReviewService struct {
+	client *mongo.Client
+	dbName string
+}
+
+// NewChapterReviewService 创建章节质量审核服务
+func NewChapterReviewService(client *mongo.Client, dbName string) *ChapterReviewService {
+	return &ChapterReviewService{
+		client: client,
+		dbName: dbName,
+	}
+}
+
+// ReviewChapter 使用 quality_review 模板审核章节，保存完整报告并据此更新章节的质量指标；
+// 审核期间章节内容已被修改时只保存报告，不覆盖新内容的质量指标
+func (s *ChapterReviewService) ReviewChapter(ctx context.Context, chapterID, llmModelID string, opts models.GenerationOptions) (models.ChapterReview, error) {
+	novelService := NewNovelService(s.client, s.dbName)
+	chapter, err := novelService.GetChapter(ctx, chapterID)
+	if err != nil {
+		return models.ChapterReview{}, err
+	}
+	if strings.TrimSpace(chapter.Content) == "" {
+		return models.ChapterReview{}, errors.New("chapter has no content to review")
+	}
+
+	// 构建输入数据
+	generationService := NewNovelGenerationService(s.client, s.dbName)
+	inputData := map[string]interface{}{
+		"chapter_content":   chapter.Content,
+		"chapter_metadata":  buildChapterMetadataContent(chapter),
+		"story_core":        "",
+		"worldview":         "",
+		"quality_standards": "",
+	}
+	if storyCore, err := novelService.GetSelectedStoryCore(ctx, chapter.NovelID); err == nil {
+		inputData["story_core"] = generationService.buildStoryCoreContent(storyCore)
+	}
+	if worldview, err := novelService.GetWorldviews(ctx, chapter.NovelID); err == nil {
+		inputData["worldview"] = generationService.buildWorldviewContent(worldview)
+	}
+
+	// 调用LLM审核
+	response, err := NewPromptTemplateService(s.client, s.dbName).GenerateWithLLM(ctx, models.GenerationRequest{
+		NovelID:           chapter.NovelID,
+		LLMModelID:        llmModelID,
+		InputData:         inputData,
+		TemplateType:      "quality_review",
+		GenerationOptions: opts,
+	})
+	if err != nil {
+		return models.ChapterReview{}, err
+	}
+	if !response.Success {
+		return models.ChapterReview{}, errors.New(response.Error)
+	}
+
+	// 解析审核报告
+	var review models.ChapterReview
+	missing, err := common.DecodeLenient(response.Data, &review, "id", "chapter_id", "novel_id", "chapter_version", "llm_model_id", "served_model_id", "token_count", "creator_id", "ctime")
+	if err != nil {
+		return models.ChapterReview{}, fmt.Errorf("%w: %v", ErrGenerationParse, err)
+	}
+	if len(missing) > 0 {
+		log.Printf("Review of chapter %s is missing fields: %s", chapterID, strings.Join(missing, ", "))
+	}
+	review.ChapterID = chapterID
+	review.NovelID = chapter.NovelID
+	review.ChapterVersion = chapter.ActiveVersion
+	review.LLMModelID = llmModelID
+	review.ServedModelID = response.ServedModelID
+	review.TokenCount = response.TokenCount
+	review.CreatorID = opts.UserID
+	review.Ctime = time.Now().Unix()
+
+	// 保存到数据库
+	coll := s.client.Database(s.dbName).Collection("chapter_reviews")
+	res, err := coll.InsertOne(ctx, review)
+	if err != nil {
+		return models.ChapterReview{}, err
+	}
+	if oid, ok := res.InsertedID.(primitive.ObjectID); ok {
+		review.ID = oid.Hex()
+	}
+
+	// 更新章节质量指标
+	updated, err := s.updateQualityMetrics(ctx, chapter, qualityMetricsFromReview(review))
+	if err != nil {
+		return models.ChapterReview{}, err
+	}
+	if !updated {
+		log.Printf("Chapter %s changed during review %s, quality metrics not updated", chapterID, review.ID)
+	}
+
+	review.MissingFields = missing
+	return review, nil
+}
+
+// AutoReviewChapter 章节生成后的自动审核：使用 opts.ReviewLLMModelID，未指定时使用生成模型
+func (s *ChapterReviewService) AutoReviewChapter(ctx context.Context, chapterID, generationModelID string, opts models.GenerationOptions) (models.ChapterReview, error) {
+	reviewModelID := opts.ReviewLLMModelID
+	if reviewModelID == "" {
+		reviewModelID = generationModelID
+	}
+	return s.ReviewChapter(ctx, chapterID, reviewModelID, models.GenerationOptions{
+		FallbackModelIDs: opts.FallbackModelIDs,
+		UserID:           opts.UserID,
+	})
+}
+
+// ListChapterReviews 获取章节的审核报告，按时间倒序
+func (s *ChapterReviewService) ListChapterReviews(ctx context.Context, chapterID string) ([]models.ChapterReview, error) {
+	coll := s.client.Database(s.dbName).Collection("chapter_reviews")
+
+	cursor, err := coll.Find(ctx, bson.M{"chapter_id": chapterID}, options.Find().SetSort(bson.D{{Key: "ctime", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	reviews := []models.ChapterReview{}
+	if err := cursor.All(ctx, &reviews); err != nil {
+		return nil, err
+	}
+
+	return reviews, nil
+}
+
+// updateQualityMetrics 更新章节质量指标，仅在章节当前版本仍为审核时的版本（正文未被修改）时写入，返回是否已更新
+func (s *ChapterReviewService) updateQualityMetrics(ctx context.Context, chapter models.Chapter, metrics models.QualityMetrics) (bool, error) {
+	coll := s.client.Database(s.dbName).Collection("chapters")
+	oid, err := primitive.ObjectIDFromHex(chapter.ID)
+	if err != nil {
+		return false, errors.New("invalid id")
+	}
+
+	filter := bson.M{"_id": oid, "active_version": chapter.ActiveVersion}
+	if chapter.ActiveVersion == 0 {
+		filter["active_version"] = bson.M{"$in": bson.A{nil, 0}}
+	}
+	res, err := coll.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"quality_metrics": metrics}})
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount > 0, nil
+}
+
+// qualityMetricsFromReview 由审核报告生成章节质量指标：问题描述与优化建议作为待改进项
+func qualityMetricsFromReview(review models.ChapterReview) models.QualityMetrics {
+	improvementAreas := make([]string, 0, len(review.Issues)+len(review.OptimizationSuggestions))
+	for _, issue := range review.Issues {
+		if issue.Description != "" {
+			improvementAreas = append(improvementAreas, issue.Description)
+		}
+	}
+	improvementAreas = append(improvementAreas, review.OptimizationSuggestions...)
+
+	return models.QualityMetrics{
+		Score:            int(math.Round(review.OverallScore)),
+		Strengths:        review.Strengths,
+		ImprovementAreas: improvementAreas,
+		ReviewID:         review.ID,
+	}
+}
+
+// buildChapterMetadataContent 构建章节元数据文本
+func buildChapterMetadataContent(chapter models.Chapter) string {
+	content := fmt.Sprintf("第%d章 %s\n", chapter.ChapterNumber, chapter.Title)
+	if chapter.Summary != "" {
+		content += fmt.Sprintf("概要：%s\n", chapter.Summary)
+	}
+	if chapter.Outline.Goal != "" {
+		content += fmt.Sprintf("章节目标：%s\n", chapter.Outline.Goal)
+	}
+	if len(chapter.Outline.KeyEvents) > 0 {
+		content += fmt.Sprintf("关键事件：%s\n", strings.Join(chapter.Outline.KeyEvents, "、"))
+	}
+	content += fmt.Sprintf("字数：%d", chapter.WordCount)
+	return content
+}
@@ -0,0 +1,379 @@
+// Package services
+/*
+/@Author: urmsone urmsone@163.com
+/@Date: 2025/10/20 20:44
+/@Name: chapter_revision_service.go
+/@Description: Review-driven chapter revision service implementation
+/*/
+
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"redquill-backend/pkg/common"
+	"redquill-backend/pkg/models"
+)
+
+// 自动修订的默认阈值与轮数上限
+const (
+	DefaultRevisionThreshold     = 8
+	DefaultRevisionMaxIterations = 3
+	maxRevisionIterations        = 5
+)
+
+var (
+	// ErrNoReview 章节还没有审核报告
+	ErrNoReview = errors.New("chapter has no review, run POST /chapter/:id/review first")
+	// ErrNoRevisionIssues 审核报告中没有需要修订的问题
+	ErrNoRevisionIssues = errors.New("no issues to revise")
+)
+
+// RevisionOptions 修订参数
+type RevisionOptions struct {
+	LLMModelID    string               `json:"llm_model_id" binding:"required"`
+	ReviewID      string               `json:"review_id,omitempty"`     // 依据的审核报告，为空时使用最新一次
+	IssueIndexes  []int                `json:"issue_indexes,omitempty"` // 只处理报告中的这些问题（下标从0开始）
+	Issues        []models.ReviewIssue `json:"issues,omitempty"`        // 用户自行指定的问题，优先于报告
+	Instructions  string               `json:"instructions,omitempty"`  // 额外的修改要求
+	Auto          bool                 `json:"auto,omitempty"`          // 循环 审核 → 修订 直到达到阈值或轮数上限
+	Threshold     float64              `json:"threshold,omitempty"`
+	MaxIterations int                  `json:"max_iterations,omitempty"`
+}
+
+// ChapterRevisionService 章节修订服务
+type ChapterRevisionService struct {
+	client *mongo.Client
+	dbName string
+}
+
+// NewChapterRevisionService 创建章节修订服务
+func NewChapterRevisionService(client *mongo.Client, dbName string) *ChapterRevisionService {
+	return &ChapterRevisionService{
+		client: client,
+		dbName: dbName,
+	}
+}
+
+// ReviseChapter 按审核报告修订章节，修订结果保存为新版本并成为章节当前内容。
+// 自动模式下先审核（没有报告时），评分低于阈值则修订并重新审核，直到达到阈值或轮数上限；每一轮都记录在修订记录中。
+func (s *ChapterRevisionService) ReviseChapter(ctx context.Context, chapterID string, req RevisionOptions, opts models.GenerationOptions) (models.ChapterRevisionRun, error) {
+	reviewService := NewChapterReviewService(s.client, s.dbName)
+	// 自动模式重新审核的模型，未指定时使用修订模型
+	reviewModelID := opts.ReviewLLMModelID
+	if reviewModelID == "" {
+		reviewModelID = req.LLMModelID
+	}
+	// 指定的模板用于修订，审核使用 quality_review 的模板
+	reviewOpts := opts
+	reviewOpts.TemplateID = ""
+
+	run := models.ChapterRevisionRun{
+		ChapterID: chapterID,
+		Auto:      req.Auto,
+		Rounds:    []models.RevisionRound{},
+		CreatorID: opts.UserID,
+		Ctime:     time.Now().Unix(),
+	}
+	if req.Auto {
+		run.Threshold = req.Threshold
+		if run.Threshold <= 0 {
+			run.Threshold = DefaultRevisionThreshold
+		}
+		run.MaxIterations = req.MaxIterations
+		if run.MaxIterations <= 0 {
+			run.MaxIterations = DefaultRevisionMaxIterations
+		}
+		if run.MaxIterations > maxRevisionIterations {
+			run.MaxIterations = maxRevisionIterations
+		}
+	}
+
+	// 依据的审核报告
+	review, err := s.getReview(ctx, chapterID, req.ReviewID)
+	if errors.Is(err, ErrNoReview) && req.Auto {
+		review, err = reviewService.ReviewChapter(ctx, chapterID, reviewModelID, reviewOpts)
+	}
+	if err != nil {
+		return models.ChapterRevisionRun{}, err
+	}
+	run.NovelID = review.NovelID
+	run.FinalScore = review.OverallScore
+
+	// 第一轮使用用户选择的问题，之后的轮次使用新报告的全部问题
+	issues, err := selectIssues(review, req.Issues, req.IssueIndexes)
+	if err != nil {
+		return models.ChapterRevisionRun{}, err
+	}
+
+	var runErr error
+	for round := 1; ; round++ {
+		if req.Auto && review.OverallScore >= run.Threshold {
+			run.Status = models.RevisionStatusThresholdReached
+			break
+		}
+		if req.Auto && round > run.MaxIterations {
+			run.Status = models.RevisionStatusMaxIterations
+			break
+		}
+
+		revision, err := s.reviseOnce(ctx, chapterID, review, issues, req, opts)
+		if err != nil {
+			run.Status = models.RevisionStatusFailed
+			run.Error = err.Error()
+			runErr = err
+			break
+		}
+		revision.Round = round
+		run.Rounds = append(run.Rounds, revision)
+
+		if !req.Auto {
+			run.Status = models.RevisionStatusRevised
+			break
+		}
+
+		// 重新审核修订后的内容
+		review, err = reviewService.ReviewChapter(ctx, chapterID, reviewModelID, reviewOpts)
+		if err != nil {
+			run.Status = models.RevisionStatusFailed
+			run.Error = err.Error()
+			break
+		}
+		score := review.OverallScore
+		run.Rounds[len(run.Rounds)-1].ScoreAfter = &score
+		run.FinalScore = score
+		issues, _ = selectIssues(review, nil, nil)
+	}
+
+	// 保存修订记录
+	coll := s.client.Database(s.dbName).Collection("chapter_revisions")
+	res, err := coll.InsertOne(ctx, run)
+	if err != nil {
+		return models.ChapterRevisionRun{}, err
+	}
+	if oid, ok := res.InsertedID.(primitive.ObjectID); ok {
+		run.ID = oid.Hex()
+	}
+
+	// 单次修订失败时直接返回错误；自动模式返回已完成的轮次
+	if runErr != nil && len(run.Rounds) == 0 {
+		return run, runErr
+	}
+	return run, nil
+}
+
+// ListChapterRevisions 获取章节的修订记录，按时间倒序
+func (s *ChapterRevisionService) ListChapterRevisions(ctx context.Context, chapterID string) ([]models.ChapterRevisionRun, error) {
+	coll := s.client.Database(s.dbName).Collection("chapter_revisions")
+
+	cursor, err := coll.Find(ctx, bson.M{"chapter_id": chapterID}, options.Find().SetSort(bson.D{{Key: "ctime", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	runs := []models.ChapterRevisionRun{}
+	if err := cursor.All(ctx, &runs); err != nil {
+		return nil, err
+	}
+
+	return runs, nil
+}
+
+// getReview 获取指定的审核报告，reviewID 为空时取章节最新的报告
+func (s *ChapterRevisionService) getReview(ctx context.Context, chapterID, reviewID string) (models.ChapterReview, error) {
+	coll := s.client.Database(s.dbName).Collection("chapter_reviews")
+
+	filter := bson.M{"chapter_id": chapterID}
+	if reviewID != "" {
+		oid, err := primitive.ObjectIDFromHex(reviewID)
+		if err != nil {
+			return models.ChapterReview{}, errors.New("invalid review id")
+		}
+		filter["_id"] = oid
+	}
+
+	var review models.ChapterReview
+	opts := options.FindOne().SetSort(bson.D{{Key: "ctime", Value: -1}})
+	if err := coll.FindOne(ctx, filter, opts).Decode(&review); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			if reviewID != "" {
+				return models.ChapterReview{}, errors.New("review not found")
+			}
+			return models.ChapterReview{}, ErrNoReview
+		}
+		return models.ChapterReview{}, err
+	}
+	return review, nil
+}
+
+// selectIssues 确定本次修订处理的问题：用户指定的问题优先，其次为报告中选中的问题，否则为报告全部问题
+func selectIssues(review models.ChapterReview, custom []models.ReviewIssue, indexes []int) ([]models.ReviewIssue, error) {
+	if len(custom) > 0 {
+		return custom, nil
+	}
+	if len(indexes) == 0 {
+		return review.Issues, nil
+	}
+
+	issues := make([]models.ReviewIssue, 0, len(indexes))
+	for _, i := range indexes {
+		if i < 0 || i >= len(review.Issues) {
+			return nil, fmt.Errorf("issue index %d out of range, review has %d issues", i, len(review.Issues))
+		}
+		issues = append(issues, review.Issues[i])
+	}
+	return issues, nil
+}
+
+// reviseOnce 使用 chapter_revision 模板修订一次，保存为新版本并更新章节当前内容
+func (s *ChapterRevisionService) reviseOnce(ctx context.Context, chapterID string, review models.ChapterReview, issues []models.ReviewIssue, req RevisionOptions, opts models.GenerationOptions) (models.RevisionRound, error) {
+	if len(issues) == 0 && len(review.OptimizationSuggestions) == 0 && req.Instructions == "" {
+		return models.RevisionRound{}, ErrNoRevisionIssues
+	}
+
+	novelService := NewNovelService(s.client, s.dbName)
+	chapter, err := novelService.GetChapter(ctx, chapterID)
+	if err != nil {
+		return models.RevisionRound{}, err
+	}
+
+	// 构建输入数据
+	generationService := NewNovelGenerationService(s.client, s.dbName)
+	inputData := map[string]interface{}{
+		"novel_title":       "",
+		"story_core":        "",
+		"worldview":         "",
+		"chapter_title":     chapter.Title,
+		"chapter_content":   chapter.Content,
+		"issues":            buildIssuesContent(issues),
+		"suggestions":       strings.Join(review.OptimizationSuggestions, "\n"),
+		"user_instructions": req.Instructions,
+	}
+	if storyCore, err := novelService.GetSelectedStoryCore(ctx, chapter.NovelID); err == nil {
+		inputData["novel_title"] = storyCore.Title
+		inputData["story_core"] = generationService.buildStoryCoreContent(storyCore)
+	}
+	if worldview, err := novelService.GetWorldviews(ctx, chapter.NovelID); err == nil {
+		inputData["worldview"] = generationService.buildWorldviewContent(worldview)
+	}
+
+	// 调用LLM修订
+	response, err := NewPromptTemplateService(s.client, s.dbName).GenerateWithLLM(ctx, models.GenerationRequest{
+		NovelID:           chapter.NovelID,
+		LLMModelID:        req.LLMModelID,
+		InputData:         inputData,
+		TemplateType:      "chapter_revision",
+		GenerationOptions: opts,
+	})
+	if err != nil {
+		return models.RevisionRound{}, err
+	}
+	if !response.Success {
+		return models.RevisionRound{}, errors.New(response.Error)
+	}
+
+	var revised struct {
+		Title         string   `json:"title"`
+		Content       string   `json:"content"`
+		Summary       string   `json:"summary"`
+		RevisionNotes []string `json:"revision_notes"`
+	}
+	if _, err := common.DecodeLenient(response.Data, &revised); err != nil {
+		return models.RevisionRound{}, fmt.Errorf("%w: %v", ErrGenerationParse, err)
+	}
+	if strings.TrimSpace(revised.Content) == "" {
+		return models.RevisionRound{}, fmt.Errorf("%w: revised content is empty", ErrGenerationParse)
+	}
+
+	// 修订前保留原始内容，再把修订结果保存为新版本
+	versionService := NewChapterVersionService(s.client, s.dbName)
+	if err := versionService.ensureOriginalVersion(ctx, chapter, opts.UserID); err != nil {
+		return models.RevisionRound{}, err
+	}
+	if revised.Title != "" {
+		chapter.Title = revised.Title
+	}
+	if revised.Summary != "" {
+		chapter.Summary = revised.Summary
+	}
+	chapter.Content = revised.Content
+	chapter.WordCount = len([]rune(revised.Content))
+	version, err := versionService.postChapterVersions(ctx, chapter, models.ChapterVersionSourceRevision, review.ID, opts.UserID)
+	if err != nil {
+		return models.RevisionRound{}, err
+	}
+	if err := s.applyRevision(ctx, chapter, version.Version); err != nil {
+		if errors.Is(err, ErrChapterConflict) {
+			versionService.discardChapterVersion(ctx, version)
+		}
+		return models.RevisionRound{}, err
+	}
+
+	return models.RevisionRound{
+		ReviewID:      review.ID,
+		ScoreBefore:   review.OverallScore,
+		Issues:        issues,
+		RevisionNotes: revised.RevisionNotes,
+		Version:       version.Version,
+		ServedModelID: response.ServedModelID,
+		TokenCount:    response.TokenCount,
+		Ctime:         time.Now().Unix(),
+	}, nil
+}
+
+// applyRevision 更新章节当前内容并指向修订版本；原有质量指标针对旧内容，一并清空。
+// 修订期间章节已被修改时返回 ErrChapterConflict
+func (s *ChapterRevisionService) applyRevision(ctx context.Context, chapter models.Chapter, version int) error {
+	coll := s.client.Database(s.dbName).Collection("chapters")
+	oid, err := primitive.ObjectIDFromHex(chapter.ID)
+	if err != nil {
+		return errors.New("invalid id")
+	}
+
+	res, err := coll.UpdateOne(ctx, chapterSnapshotFilter(oid, chapter.ActiveVersion), bson.M{"$set": bson.M{
+		"title":           chapter.Title,
+		"content":         chapter.Content,
+		"summary":         chapter.Summary,
+		"word_count":      chapter.WordCount,
+		"quality_metrics": models.QualityMetrics{},
+		"active_version":  version,
+		"mtime":           time.Now().Unix(),
+	}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrChapterConflict
+	}
+	return nil
+}
+
+// buildIssuesContent 构建问题列表文本
+func buildIssuesContent(issues []models.ReviewIssue) string {
+	if len(issues) == 0 {
+		return "无"
+	}
+
+	content := ""
+	for i, issue := range issues {
+		content += fmt.Sprintf("%d. [%s] %s", i+1, issue.Type, issue.Description)
+		if issue.Location != "" {
+			content += fmt.Sprintf("（位置：%s）", issue.Location)
+		}
+		if issue.Suggestion != "" {
+			content += fmt.Sprintf("\n   修改建议：%s", issue.Suggestion)
+		}
+		content += "\n"
+	}
+	return content
+}
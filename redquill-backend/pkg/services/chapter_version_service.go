@@ -0,0 +1,507 @@
+// Package services
+/*
+/@Author: urmsone urmsone@163.com
+/@Date: 2025/10/20 20:44
+/@Name: chapter_version_service.go
+/@Description: Chapter version history service implementation
+/*/
+
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"redquill-backend/pkg/common"
+	"redquill-backend/pkg/models"
+)
+
+// 版本差异比较方式
+const (
+	DiffModeLine      = "line"
+	DiffModeParagraph = "paragraph"
+)
+
+// ErrChapterVersionNotFound 章节版本不存在
+var ErrChapterVersionNotFound = errors.New("chapter version not found")
+
+// ErrChapterConflict 章节在保存期间已被其他请求修改
+var ErrChapterConflict = errors.New("chapter has been modified by another request, reload and retry")
+
+// chapterVersionAttempts 并发保存时分配版本号的最大尝试次数
+const chapterVersionAttempts = 5
+
+// ChapterDiff 章节两个版本正文的差异
+type ChapterDiff struct {
+	ChapterID string           `json:"chapter_id"`
+	Mode      string           `json:"mode"` // line|paragraph
+	From      int              `json:"from"`
+	To        int              `json:"to"`
+	FromTitle string           `json:"from_title"`
+	ToTitle   string           `json:"to_title"`
+	Ops       []common.DiffOp  `json:"ops"`
+	Stats     common.DiffStats `json:"stats"`
+}
+
+// ChapterVersionService 章节版本服务
+type ChapterVersionService struct {
+	client *mongo.Client
+	dbName string
+}
+
+// NewChapterVersionService 创建章节版本服务
+func NewChapterVersionService(client *mongo.Client, dbName string) *ChapterVersionService {
+	return &ChapterVersionService{
+		client: client,
+		dbName: dbName,
+	}
+}
+
+// latestVersion 返回章节的最新版本号，没有版本时返回0
+func (s *ChapterVersionService) latestVersion(ctx context.Context, chapterID string) (int, error) {
+	coll := s.client.Database(s.dbName).Collection("chapter_versions")
+
+	var latest models.ChapterVersion
+	opts := options.FindOne().SetSort(bson.D{{Key: "version", Value: -1}})
+	if err := coll.FindOne(ctx, bson.M{"chapter_id": chapterID}, opts).Decode(&latest); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return latest.Version, nil
+}
+
+// originalVersion 没有版本记录的章节以当前内容作为版本1，供只读接口展示，不写入数据库
+func originalVersion(chapter models.Chapter) models.ChapterVersion {
+	return models.ChapterVersion{
+		ChapterID: chapter.ID,
+		NovelID:   chapter.NovelID,
+		Version:   1,
+		Title:     chapter.Title,
+		Content:   chapter.Content,
+		Summary:   chapter.Summary,
+		WordCount: chapter.WordCount,
+		Source:    models.ChapterVersionSourceOriginal,
+		Ctime:     chapter.Ctime,
+	}
+}
+
+// ensureOriginalVersion 启用版本管理前创建的章节没有版本记录，首次写入（保存、修订、回滚）前将当前内容保存为版本1
+func (s *ChapterVersionService) ensureOriginalVersion(ctx context.Context, chapter models.Chapter, creatorID string) error {
+	latest, err := s.latestVersion(ctx, chapter.ID)
+	if err != nil || latest > 0 {
+		return err
+	}
+	original := originalVersion(chapter)
+	original.CreatorID = creatorID
+	original.Ctime = time.Now().Unix()
+	if _, err := s.insertChapterVersion(ctx, original); err != nil {
+		// 并发请求已补建版本1
+		if mongo.IsDuplicateKeyError(err) {
+			return nil
+		}
+		return err
+	}
+
+	oid, err := primitive.ObjectIDFromHex(chapter.ID)
+	if err != nil {
+		return errors.New("invalid id")
+	}
+	_, err = s.client.Database(s.dbName).Collection("chapters").UpdateOne(ctx,
+		bson.M{"_id": oid, "active_version": bson.M{"$in": bson.A{nil, 0}}},
+		bson.M{"$set": bson.M{"active_version": 1}},
+	)
+	return err
+}
+
+// chapterSnapshotFilter 章节更新条件：当前版本仍为读取时的版本才更新，否则说明章节已被并发修改。
+// 没有版本记录的章节（当前版本为0）会在首次写入前补建版本1，两者都视为未修改
+func chapterSnapshotFilter(oid primitive.ObjectID, activeVersion int) bson.M {
+	if activeVersion <= 1 {
+		return bson.M{"_id": oid, "active_version": bson.M{"$in": bson.A{nil, 0, 1}}}
+	}
+	return bson.M{"_id": oid, "active_version": activeVersion}
+}
+
+// discardChapterVersion 章节更新冲突时删除已写入但未生效的版本
+func (s *ChapterVersionService) discardChapterVersion(ctx context.Context, version models.ChapterVersion) {
+	coll := s.client.Database(s.dbName).Collection("chapter_versions")
+	if _, err := coll.DeleteOne(ctx, bson.M{"chapter_id": version.ChapterID, "version": version.Version}); err != nil {
+		log.Printf("Failed to discard chapter version %s/%d: %v", version.ChapterID, version.Version, err)
+	}
+}
+
+// setActiveVersion 更新章节当前版本及相关字段
+func (s *ChapterVersionService) setActiveVersion(ctx context.Context, chapterID string, set bson.M) error {
+	coll := s.client.Database(s.dbName).Collection("chapters")
+	oid, err := primitive.ObjectIDFromHex(chapterID)
+	if err != nil {
+		return errors.New("invalid id")
+	}
+
+	_, err = coll.UpdateOne(ctx, bson.M{"_id": oid}, bson.M{"$set": set})
+	return err
+}
+
+// postChapterVersions 将章节当前内容保存为新版本；版本号由 (chapter_id, version) 唯一索引保证不重复，
+// 并发保存占用同一版本号时重新分配，多次失败返回 ErrChapterConflict
+func (s *ChapterVersionService) postChapterVersions(ctx context.Context, chapter models.Chapter, source, reviewID, creatorID string) (models.ChapterVersion, error) {
+	version := models.ChapterVersion{
+		ChapterID: chapter.ID,
+		NovelID:   chapter.NovelID,
+		Title:     chapter.Title,
+		Content:   chapter.Content,
+		Summary:   chapter.Summary,
+		WordCount: chapter.WordCount,
+		Source:    source,
+		ReviewID:  reviewID,
+		CreatorID: creatorID,
+		Ctime:     time.Now().Unix(),
+	}
+
+	for attempt := 0; attempt < chapterVersionAttempts; attempt++ {
+		latest, err := s.latestVersion(ctx, chapter.ID)
+		if err != nil {
+			return models.ChapterVersion{}, err
+		}
+		version.Version = latest + 1
+
+		inserted, err := s.insertChapterVersion(ctx, version)
+		if err == nil {
+			return inserted, nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return models.ChapterVersion{}, err
+		}
+	}
+	return models.ChapterVersion{}, ErrChapterConflict
+}
+
+// insertChapterVersion 写入一个版本
+func (s *ChapterVersionService) insertChapterVersion(ctx context.Context, version models.ChapterVersion) (models.ChapterVersion, error) {
+	coll := s.client.Database(s.dbName).Collection("chapter_versions")
+	res, err := coll.InsertOne(ctx, version)
+	if err != nil {
+		return models.ChapterVersion{}, err
+	}
+	if oid, ok := res.InsertedID.(primitive.ObjectID); ok {
+		version.ID = oid.Hex()
+	}
+	return version, nil
+}
+
+// ListChapterVersions 获取章节的版本列表（不含正文），按版本号升序
+func (s *ChapterVersionService) ListChapterVersions(ctx context.Context, chapterID string) ([]models.ChapterVersion, error) {
+	coll := s.client.Database(s.dbName).Collection("chapter_versions")
+
+	chapter, err := NewNovelService(s.client, s.dbName).GetChapter(ctx, chapterID)
+	if err != nil {
+		return nil, err
+	}
+	if chapter.ActiveVersion == 0 {
+		chapter.ActiveVersion = 1
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "version", Value: 1}}).
+		SetProjection(bson.M{"content": 0})
+	cursor, err := coll.Find(ctx, bson.M{"chapter_id": chapterID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	versions := []models.ChapterVersion{}
+	if err := cursor.All(ctx, &versions); err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		original := originalVersion(chapter)
+		original.Content = ""
+		versions = append(versions, original)
+	}
+	for i := range versions {
+		versions[i].Active = versions[i].Version == chapter.ActiveVersion
+	}
+
+	return versions, nil
+}
+
+// GetChapterVersion 获取章节的指定版本
+func (s *ChapterVersionService) GetChapterVersion(ctx context.Context, chapterID string, version int) (models.ChapterVersion, error) {
+	coll := s.client.Database(s.dbName).Collection("chapter_versions")
+
+	chapter, err := NewNovelService(s.client, s.dbName).GetChapter(ctx, chapterID)
+	if err != nil {
+		return models.ChapterVersion{}, err
+	}
+	if chapter.ActiveVersion == 0 {
+		chapter.ActiveVersion = 1
+	}
+
+	var out models.ChapterVersion
+	if err := coll.FindOne(ctx, bson.M{"chapter_id": chapterID, "version": version}).Decode(&out); err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			return models.ChapterVersion{}, err
+		}
+		// 没有版本记录的旧章节，当前内容即版本1
+		if version != 1 {
+			return models.ChapterVersion{}, ErrChapterVersionNotFound
+		}
+		latest, err := s.latestVersion(ctx, chapterID)
+		if err != nil {
+			return models.ChapterVersion{}, err
+		}
+		if latest > 0 {
+			return models.ChapterVersion{}, ErrChapterVersionNotFound
+		}
+		out = originalVersion(chapter)
+	}
+	out.Active = out.Version == chapter.ActiveVersion
+
+	return out, nil
+}
+
+// DiffChapterVersions 比较章节两个版本的正文，from 默认为 to 的上一版本，to 默认为当前版本；
+// mode 为 line（逐行）或 paragraph（逐段，忽略缩进与空行）
+func (s *ChapterVersionService) DiffChapterVersions(ctx context.Context, chapterID string, from, to int, mode string) (ChapterDiff, error) {
+	if mode == "" {
+		mode = DiffModeLine
+	}
+	if mode != DiffModeLine && mode != DiffModeParagraph {
+		return ChapterDiff{}, fmt.Errorf("invalid diff mode: %s", mode)
+	}
+
+	if to == 0 {
+		chapter, err := NewNovelService(s.client, s.dbName).GetChapter(ctx, chapterID)
+		if err != nil {
+			return ChapterDiff{}, err
+		}
+		to = chapter.ActiveVersion
+		if to == 0 {
+			to = 1
+		}
+	}
+	if from == 0 {
+		from = to - 1
+	}
+	if from < 1 {
+		return ChapterDiff{}, ErrChapterVersionNotFound
+	}
+
+	fromVersion, err := s.GetChapterVersion(ctx, chapterID, from)
+	if err != nil {
+		return ChapterDiff{}, err
+	}
+	toVersion, err := s.GetChapterVersion(ctx, chapterID, to)
+	if err != nil {
+		return ChapterDiff{}, err
+	}
+
+	split := common.SplitLines
+	if mode == DiffModeParagraph {
+		split = common.SplitParagraphs
+	}
+	ops, stats := common.Diff(split(fromVersion.Content), split(toVersion.Content))
+
+	return ChapterDiff{
+		ChapterID: chapterID,
+		Mode:      mode,
+		From:      from,
+		To:        to,
+		FromTitle: fromVersion.Title,
+		ToTitle:   toVersion.Title,
+		Ops:       ops,
+		Stats:     stats,
+	}, nil
+}
+
+// RollbackChapter 将章节内容恢复为指定版本，并把当前版本指向该版本（不新增版本）
+func (s *ChapterVersionService) RollbackChapter(ctx context.Context, chapterID string, version int) (models.Chapter, error) {
+	chapter, err := NewNovelService(s.client, s.dbName).GetChapter(ctx, chapterID)
+	if err != nil {
+		return models.Chapter{}, err
+	}
+	if err := s.ensureOriginalVersion(ctx, chapter, ""); err != nil {
+		return models.Chapter{}, err
+	}
+	target, err := s.GetChapterVersion(ctx, chapterID, version)
+	if err != nil {
+		return models.Chapter{}, err
+	}
+
+	// 原有质量指标针对回滚前的内容，一并清空
+	if err := s.setActiveVersion(ctx, chapterID, bson.M{
+		"title":           target.Title,
+		"content":         target.Content,
+		"summary":         target.Summary,
+		"word_count":      target.WordCount,
+		"quality_metrics": models.QualityMetrics{},
+		"active_version":  target.Version,
+		"mtime":           time.Now().Unix(),
+	}); err != nil {
+		return models.Chapter{}, err
+	}
+
+	return NewNovelService(s.client, s.dbName).GetChapter(ctx, chapterID)
+}
+
+// MergeDuplicateChapters 合并启用版本管理前重复生成的章节：同一小说同一章节序号只保留最新的章节，
+// 较早的章节按时间顺序转为其历史版本，审核报告与修订记录一并迁移。返回删除的章节数
+func (s *ChapterVersionService) MergeDuplicateChapters(ctx context.Context) (int, error) {
+	db := s.client.Database(s.dbName)
+
+	cursor, err := db.Collection("chapters").Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"novel_id": "$novel_id", "chapter_number": "$chapter_number"},
+			"ids":   bson.M{"$push": "$_id"},
+			"count": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	var groups []struct {
+		IDs []primitive.ObjectID `bson:"ids"`
+	}
+	if err := cursor.All(ctx, &groups); err != nil {
+		return 0, err
+	}
+
+	merged := 0
+	for _, group := range groups {
+		n, err := s.mergeChapters(ctx, group.IDs)
+		if err != nil {
+			return merged, err
+		}
+		merged += n
+	}
+	return merged, nil
+}
+
+// mergeChapters 在一个事务内将一组重复章节合并到最新的章节，失败时版本历史保持不变
+func (s *ChapterVersionService) mergeChapters(ctx context.Context, ids []primitive.ObjectID) (int, error) {
+	session, err := s.client.StartSession()
+	if err != nil {
+		return 0, err
+	}
+	defer session.EndSession(ctx)
+
+	result, err := session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return s.mergeChapterGroup(sc, ids)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return result.(int), nil
+}
+
+// mergeChapterGroup 合并一组重复章节：重新编号全部版本、迁移审核与修订记录并删除较早的章节，需在事务内调用
+func (s *ChapterVersionService) mergeChapterGroup(ctx context.Context, ids []primitive.ObjectID) (int, error) {
+	db := s.client.Database(s.dbName)
+	versionColl := db.Collection("chapter_versions")
+
+	cursor, err := db.Collection("chapters").Find(ctx, bson.M{"_id": bson.M{"$in": ids}},
+		options.Find().SetSort(bson.D{{Key: "ctime", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return 0, err
+	}
+	var chapters []models.Chapter
+	if err := cursor.All(ctx, &chapters); err != nil {
+		return 0, err
+	}
+	if len(chapters) < 2 {
+		return 0, nil
+	}
+	keep := chapters[len(chapters)-1]
+
+	// 按章节创建顺序收集全部版本，没有版本记录的章节以其内容作为一个版本
+	var history []models.ChapterVersion
+	chapterIDs := make([]string, 0, len(chapters))
+	for _, chapter := range chapters {
+		chapterIDs = append(chapterIDs, chapter.ID)
+		vc, err := versionColl.Find(ctx, bson.M{"chapter_id": chapter.ID}, options.Find().SetSort(bson.D{{Key: "version", Value: 1}}))
+		if err != nil {
+			return 0, err
+		}
+		var versions []models.ChapterVersion
+		if err := vc.All(ctx, &versions); err != nil {
+			return 0, err
+		}
+		if len(versions) == 0 {
+			versions = []models.ChapterVersion{{
+				Title:     chapter.Title,
+				Content:   chapter.Content,
+				Summary:   chapter.Summary,
+				WordCount: chapter.WordCount,
+				Source:    models.ChapterVersionSourceOriginal,
+				Ctime:     chapter.Ctime,
+			}}
+		}
+		history = append(history, versions...)
+	}
+
+	// 重新编号并归属到保留的章节
+	docs := make([]interface{}, 0, len(history))
+	for i, version := range history {
+		version.ID = ""
+		version.ChapterID = keep.ID
+		version.NovelID = keep.NovelID
+		version.Version = i + 1
+		docs = append(docs, version)
+	}
+	if _, err := versionColl.DeleteMany(ctx, bson.M{"chapter_id": bson.M{"$in": chapterIDs}}); err != nil {
+		return 0, err
+	}
+	if _, err := versionColl.InsertMany(ctx, docs); err != nil {
+		return 0, err
+	}
+	if err := s.setActiveVersion(ctx, keep.ID, bson.M{"active_version": len(history)}); err != nil {
+		return 0, err
+	}
+
+	removed := chapterIDs[:len(chapterIDs)-1]
+	for _, name := range []string{"chapter_reviews", "chapter_revisions"} {
+		if _, err := db.Collection(name).UpdateMany(ctx, bson.M{"chapter_id": bson.M{"$in": removed}}, bson.M{"$set": bson.M{"chapter_id": keep.ID}}); err != nil {
+			return 0, err
+		}
+	}
+	removedOIDs := make([]primitive.ObjectID, 0, len(removed))
+	for _, id := range removed {
+		if oid, err := primitive.ObjectIDFromHex(id); err == nil {
+			removedOIDs = append(removedOIDs, oid)
+		}
+	}
+	res, err := db.Collection("chapters").DeleteMany(ctx, bson.M{"_id": bson.M{"$in": removedOIDs}})
+	if err != nil {
+		return 0, err
+	}
+	return int(res.DeletedCount), nil
+}
+
+// EnsureChapterIndexes 创建章节唯一索引（需先合并重复章节）与版本唯一索引
+func (s *ChapterVersionService) EnsureChapterIndexes(ctx context.Context) error {
+	db := s.client.Database(s.dbName)
+
+	if _, err := db.Collection("chapters").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "novel_id", Value: 1}, {Key: "chapter_number", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}); err != nil {
+		return err
+	}
+	_, err := db.Collection("chapter_versions").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "chapter_id", Value: 1}, {Key: "version", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
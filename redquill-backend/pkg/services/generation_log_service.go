@@ -0,0 +1,293 @@
+// Package services
+/*
+/@Author: urmsone urmsone@163.com
+/@Date: 2025/10/20 20:44
+/@Name: generation_log_service.go
+/@Description: Generation audit log service implementation
+/*/
+
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"redquill-backend/pkg/common"
+	"redquill-backend/pkg/models"
+	"redquill-backend/pkg/utils/llm"
+)
+
+// ErrGenerationLogNotFound 生成日志不存在（或已过期删除）
+var ErrGenerationLogNotFound = errors.New("generation log not found")
+
+// generationLogTTLIndex 生成日志过期索引的名称
+const generationLogTTLIndex = "created_at_ttl"
+
+// GenerationLogService 生成日志服务
+type GenerationLogService struct {
+	client *mongo.Client
+	dbName string
+}
+
+// NewGenerationLogService 创建生成日志服务
+func NewGenerationLogService(client *mongo.Client, dbName string) *GenerationLogService {
+	return &GenerationLogService{
+		client: client,
+		dbName: dbName,
+	}
+}
+
+func (s *GenerationLogService) collection() *mongo.Collection {
+	return s.client.Database(s.dbName).Collection("generation_logs")
+}
+
+// GenerationLogFilter 生成日志的查询条件，空值表示不限；From/To 为unix秒
+type GenerationLogFilter struct {
+	UserID       string
+	NovelID      string
+	TemplateType string
+	TemplateID   string
+	LLMModelID   string
+	RequestID    string
+	Status       string
+	From         int64
+	To           int64
+}
+
+// PagedGenerationLogs 分页生成日志结果
+type PagedGenerationLogs struct {
+	Items      []models.GenerationLog `json:"items"`
+	Pagination common.Pagination      `json:"pagination"`
+}
+
+// PostGenerationLogs 写入一条生成日志，返回日志ID
+func (s *GenerationLogService) PostGenerationLogs(ctx context.Context, entry models.GenerationLog) (string, error) {
+	now := time.Now()
+	entry.ID = ""
+	if entry.Ctime == 0 {
+		entry.Ctime = now.Unix()
+	}
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = now
+	}
+
+	res, err := s.collection().InsertOne(ctx, entry)
+	if err != nil {
+		return "", err
+	}
+	if oid, ok := res.InsertedID.(primitive.ObjectID); ok {
+		return oid.Hex(), nil
+	}
+	return "", nil
+}
+
+// SetGenerationLogResult 写入流式生成结束后解析出的结果，解析失败时将日志标记为失败
+func (s *GenerationLogService) SetGenerationLogResult(ctx context.Context, id string, result map[string]interface{}, parseErr error) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.New("invalid id")
+	}
+
+	set := bson.M{"result": result}
+	if parseErr != nil {
+		set = bson.M{
+			"status": models.GenerationLogStatusFailed,
+			"error":  parseErr.Error(),
+		}
+	}
+	res, err := s.collection().UpdateOne(ctx, bson.M{"_id": oid}, bson.M{"$set": set})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrGenerationLogNotFound
+	}
+	return nil
+}
+
+// GetGenerationLogs 获取生成日志详情
+func (s *GenerationLogService) GetGenerationLogs(ctx context.Context, id string) (models.GenerationLog, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.GenerationLog{}, errors.New("invalid id")
+	}
+
+	var entry models.GenerationLog
+	if err := s.collection().FindOne(ctx, bson.M{"_id": oid}).Decode(&entry); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return models.GenerationLog{}, ErrGenerationLogNotFound
+		}
+		return models.GenerationLog{}, err
+	}
+	return entry, nil
+}
+
+// ListGenerationLogs 分页查询生成日志，非管理员仅能查看自己的日志；回收站中小说的日志不列出
+func (s *GenerationLogService) ListGenerationLogs(ctx context.Context, userID, role string, f GenerationLogFilter, page, pageSize int64, sortExpr string) (PagedGenerationLogs, error) {
+	filter := bson.M{"deleted_at": bson.M{"$exists": false}}
+	if role != models.RoleAdmin {
+		filter["user_id"] = userID
+	} else if f.UserID != "" {
+		filter["user_id"] = f.UserID
+	}
+	for field, value := range map[string]string{
+		"novel_id":      f.NovelID,
+		"template_type": f.TemplateType,
+		"template_id":   f.TemplateID,
+		"llm_model_id":  f.LLMModelID,
+		"request_id":    f.RequestID,
+		"status":        f.Status,
+	} {
+		if value != "" {
+			filter[field] = value
+		}
+	}
+	ctime := bson.M{}
+	if f.From > 0 {
+		ctime["$gte"] = f.From
+	}
+	if f.To > 0 {
+		ctime["$lte"] = f.To
+	}
+	if len(ctime) > 0 {
+		filter["ctime"] = ctime
+	}
+
+	sort := common.BuildSort(sortExpr)
+	if len(sort) == 0 {
+		sort = bson.D{{Key: "ctime", Value: -1}}
+	}
+	// 列表不返回Prompt、原始输出与解析结果
+	opts := common.BuildFindOptions(page, pageSize, sort, bson.M{"messages": 0, "response": 0, "result": 0})
+
+	items, total, err := common.FindWithPagination[models.GenerationLog](ctx, s.collection(), filter, opts)
+	if err != nil {
+		return PagedGenerationLogs{}, err
+	}
+
+	totalPages := total / common.NormalizePageSize(pageSize)
+	if total%common.NormalizePageSize(pageSize) != 0 {
+		totalPages++
+	}
+
+	return PagedGenerationLogs{
+		Items: items,
+		Pagination: common.Pagination{
+			Page:      common.NormalizePage(page),
+			PageSize:  common.NormalizePageSize(pageSize),
+			Total:     total,
+			TotalPage: totalPages,
+		},
+	}, nil
+}
+
+// EnsureGenerationLogIndexes 创建查询索引，并按 retention 设置 created_at 上的过期索引：
+// 已存在且保留时间不同时通过 collMod 修改，retention 为0时删除过期索引、永久保留日志
+func (s *GenerationLogService) EnsureGenerationLogIndexes(ctx context.Context, retention time.Duration) error {
+	coll := s.collection()
+	if _, err := coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "request_id", Value: 1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "ctime", Value: -1}}},
+		{Keys: bson.D{{Key: "novel_id", Value: 1}, {Key: "ctime", Value: -1}}},
+	}); err != nil {
+		return err
+	}
+
+	cursor, err := coll.Indexes().List(ctx)
+	if err != nil {
+		return err
+	}
+	var indexes []struct {
+		Name               string `bson:"name"`
+		ExpireAfterSeconds *int64 `bson:"expireAfterSeconds"`
+	}
+	if err := cursor.All(ctx, &indexes); err != nil {
+		return err
+	}
+	var current *int64
+	exists := false
+	for _, index := range indexes {
+		if index.Name == generationLogTTLIndex {
+			exists = true
+			current = index.ExpireAfterSeconds
+		}
+	}
+
+	seconds := int64(retention / time.Second)
+	switch {
+	case seconds <= 0:
+		if exists {
+			_, err = coll.Indexes().DropOne(ctx, generationLogTTLIndex)
+		}
+		return err
+	case !exists:
+		_, err = coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "created_at", Value: 1}},
+			Options: options.Index().SetName(generationLogTTLIndex).SetExpireAfterSeconds(int32(seconds)),
+		})
+		return err
+	case current == nil || *current != seconds:
+		return s.client.Database(s.dbName).RunCommand(ctx, bson.D{
+			{Key: "collMod", Value: coll.Name()},
+			{Key: "index", Value: bson.D{
+				{Key: "name", Value: generationLogTTLIndex},
+				{Key: "expireAfterSeconds", Value: seconds},
+			}},
+		}).Err()
+	}
+	return nil
+}
+
+// newGenerationLog 按生成请求创建日志，请求ID取自上下文
+func newGenerationLog(ctx context.Context, req models.GenerationRequest) *models.GenerationLog {
+	return &models.GenerationLog{
+		RequestID:    common.RequestIDFromContext(ctx),
+		UserID:       req.UserID,
+		NovelID:      req.NovelID,
+		TemplateType: req.TemplateType,
+		LLMModelID:   req.LLMModelID,
+		Stream:       req.Stream,
+	}
+}
+
+// setGenerationLogMessages 记录发送给模型的消息
+func setGenerationLogMessages(entry *models.GenerationLog, messages []llm.Message) {
+	entry.Messages = make([]models.GenerationLogMessage, 0, len(messages))
+	for _, message := range messages {
+		entry.Messages = append(entry.Messages, models.GenerationLogMessage{Role: message.Role, Content: message.Content})
+	}
+}
+
+// recordGenerationLog 补全用量、耗时与状态后写入生成日志，失败只记日志不影响生成，返回日志ID
+func recordGenerationLog(ctx context.Context, client *mongo.Client, dbName string, entry *models.GenerationLog, usage *llm.Usage, start time.Time, genErr error) string {
+	entry.LatencyMs = time.Since(start).Milliseconds()
+	entry.Status = models.GenerationLogStatusSuccess
+	if genErr != nil {
+		entry.Status = models.GenerationLogStatusFailed
+		entry.Error = genErr.Error()
+	}
+	if usage != nil {
+		entry.PromptTokens = usage.PromptTokens
+		entry.CompletionTokens = usage.CompletionTokens
+		entry.TotalTokens = usage.TotalTokens
+		if entry.TotalTokens == 0 {
+			entry.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+		}
+	}
+
+	// 请求结束后上下文可能已取消，日志仍需写入
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	id, err := NewGenerationLogService(client, dbName).PostGenerationLogs(ctx, *entry)
+	if err != nil {
+		log.Printf("Failed to record generation log: %v", err)
+	}
+	return id
+}
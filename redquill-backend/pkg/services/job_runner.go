@@ -0,0 +1,275 @@
+// Package services
+/*
+/@Author: urmsone urmsone@163.com
+/@Date: 2025/10/20 20:44
+/@Name: job_runner.go
+/@Description: Bounded worker pool executing generation jobs
+/*/
+
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"redquill-backend/pkg/common"
+	"redquill-backend/pkg/models"
+)
+
+// errJobCancelled 用户取消任务时作为任务上下文的取消原因
+var errJobCancelled = errors.New("job cancelled")
+
+// JobRunner 进程内生成任务执行器，固定数量的worker从队列中取任务执行
+type JobRunner struct {
+	client  *mongo.Client
+	dbName  string
+	workers int
+	queue   chan string
+	quotas  DefaultQuotas
+
+	mu      sync.Mutex
+	cancels map[string]context.CancelCauseFunc
+
+	ctx  context.Context
+	stop context.CancelFunc
+	wg   sync.WaitGroup
+}
+
+// NewJobRunner 创建任务执行器，workers 为并发数，queueSize 为排队上限，
+// quotas 为任务内多次调用之间复查配额时使用的默认配额
+func NewJobRunner(client *mongo.Client, dbName string, workers, queueSize int, quotas DefaultQuotas) *JobRunner {
+	if workers <= 0 {
+		workers = 1
+	}
+	if queueSize <= 0 {
+		queueSize = 100
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	return &JobRunner{
+		client:  client,
+		dbName:  dbName,
+		workers: workers,
+		queue:   make(chan string, queueSize),
+		quotas:  quotas,
+		cancels: make(map[string]context.CancelCauseFunc),
+		ctx:     ctx,
+		stop:    stop,
+	}
+}
+
+// Start 启动worker，并恢复上次退出时未完成的任务
+func (r *JobRunner) Start() {
+	for i := 0; i < r.workers; i++ {
+		r.wg.Add(1)
+		go r.work()
+	}
+
+	ids, err := NewJobService(r.client, r.dbName).RecoverJobs(r.ctx)
+	if err != nil {
+		log.Printf("Failed to recover generation jobs: %v", err)
+		return
+	}
+	if len(ids) == 0 {
+		return
+	}
+	log.Printf("Recovered %d generation jobs", len(ids))
+	go func() {
+		for _, id := range ids {
+			select {
+			case r.queue <- id:
+			case <-r.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止执行器；执行中的任务被中断并在下次启动时重新排队
+func (r *JobRunner) Stop() {
+	r.stop()
+	r.wg.Wait()
+}
+
+// Submit 创建任务并加入队列
+func (r *JobRunner) Submit(ctx context.Context, job models.GenerationJob) (models.GenerationJob, error) {
+	jobService := NewJobService(r.client, r.dbName)
+	if job.RequestID == "" {
+		job.RequestID = common.RequestIDFromContext(ctx)
+	}
+	job, err := jobService.PostJobs(ctx, job)
+	if err != nil {
+		return models.GenerationJob{}, err
+	}
+
+	select {
+	case r.queue <- job.ID:
+		return job, nil
+	default:
+		_, _ = jobService.transitJob(ctx, job.ID, models.JobStatusQueued, models.JobStatusFailed, bson.M{
+			"error":       ErrJobQueueFull.Error(),
+			"finished_at": time.Now().Unix(),
+		})
+		return models.GenerationJob{}, ErrJobQueueFull
+	}
+}
+
+// Cancel 取消任务：排队中的任务直接标记取消，执行中的任务通过上下文中断
+func (r *JobRunner) Cancel(ctx context.Context, id string) (models.GenerationJob, error) {
+	jobService := NewJobService(r.client, r.dbName)
+
+	r.mu.Lock()
+	cancel, running := r.cancels[id]
+	r.mu.Unlock()
+	if running {
+		cancel(errJobCancelled)
+	}
+
+	if _, err := jobService.transitJob(ctx, id, models.JobStatusQueued, models.JobStatusCancelled, bson.M{
+		"error":       errJobCancelled.Error(),
+		"finished_at": time.Now().Unix(),
+	}); err != nil {
+		return models.GenerationJob{}, err
+	}
+
+	job, err := jobService.GetJobs(ctx, id)
+	if err != nil {
+		return models.GenerationJob{}, err
+	}
+	if !running && job.IsFinished() && job.Status != models.JobStatusCancelled {
+		return job, ErrJobFinished
+	}
+	return job, nil
+}
+
+// work worker主循环
+func (r *JobRunner) work() {
+	defer r.wg.Done()
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case id := <-r.queue:
+			r.run(id)
+		}
+	}
+}
+
+// run 执行单个任务并持久化结果
+func (r *JobRunner) run(id string) {
+	jobService := NewJobService(r.client, r.dbName)
+
+	ctx, cancel := context.WithCancelCause(r.ctx)
+	r.mu.Lock()
+	r.cancels[id] = cancel
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		delete(r.cancels, id)
+		r.mu.Unlock()
+		cancel(nil)
+	}()
+
+	// 仅排队中的任务会被执行，已取消的任务直接跳过
+	started, err := jobService.transitJob(ctx, id, models.JobStatusQueued, models.JobStatusRunning, bson.M{
+		"started_at": time.Now().Unix(),
+	})
+	if err != nil || !started {
+		return
+	}
+	job, err := jobService.GetJobs(ctx, id)
+	if err != nil {
+		log.Printf("Failed to load generation job %s: %v", id, err)
+		return
+	}
+
+	result, runErr := r.execute(ctx, job)
+
+	// 任务上下文可能已取消，使用独立上下文写回结果
+	saveCtx, saveCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer saveCancel()
+
+	status := models.JobStatusSucceeded
+	set := bson.M{"finished_at": time.Now().Unix()}
+	switch {
+	case runErr == nil:
+		set["result"] = result
+	case errors.Is(context.Cause(ctx), errJobCancelled):
+		status = models.JobStatusCancelled
+		set["error"] = errJobCancelled.Error()
+	case r.ctx.Err() != nil:
+		// 服务关闭导致中断，重新排队等待下次启动执行
+		status = models.JobStatusQueued
+		set = bson.M{"started_at": 0}
+	default:
+		status = models.JobStatusFailed
+		set["error"] = runErr.Error()
+	}
+
+	if _, err := jobService.transitJob(saveCtx, id, models.JobStatusRunning, status, set); err != nil {
+		log.Printf("Failed to save generation job %s: %v", id, err)
+	}
+}
+
+// execute 按任务类型调用对应的生成服务，生成结果与同步接口一样保存到各自集合
+func (r *JobRunner) execute(ctx context.Context, job models.GenerationJob) (result interface{}, err error) {
+	// 解析生成结果时的panic不应终止worker
+	defer func() {
+		if p := recover(); p != nil {
+			result, err = nil, fmt.Errorf("%w: %v", ErrGenerationParse, p)
+		}
+	}()
+
+	ctx = common.WithRequestID(ctx, job.RequestID)
+	opts := job.Options
+	opts.UserID = job.UserID
+	generationService := NewNovelGenerationService(r.client, r.dbName)
+
+	switch job.Type {
+	case models.JobTypeStoryCore:
+		return generationService.GenerateStoryCore(ctx, job.NovelID, job.LLMModelID, job.InputData, opts)
+	case models.JobTypeWorldview:
+		return generationService.GenerateWorldview(ctx, job.NovelID, job.LLMModelID, job.InputData, opts)
+	case models.JobTypeCharacter:
+		return generationService.GenerateCharacter(ctx, job.NovelID, job.LLMModelID, job.InputData, opts)
+	case models.JobTypeCharactersFromOutline:
+		return generationService.GenerateCharactersFromOutline(ctx, job.NovelID, job.LLMModelID,
+			generationService.getString(job.InputData, "outline_id"),
+			generationService.getString(job.InputData, "user_requirements"), opts)
+	case models.JobTypeOutline:
+		return generationService.GenerateOutline(ctx, job.NovelID, job.LLMModelID, job.InputData, opts)
+	case models.JobTypeChapter:
+		return generationService.GenerateChapter(ctx, job.NovelID, job.LLMModelID, job.InputData, opts)
+	case models.JobTypeLLM:
+		response, err := NewPromptTemplateService(r.client, r.dbName).GenerateWithLLM(ctx, models.GenerationRequest{
+			NovelID:           job.NovelID,
+			LLMModelID:        job.LLMModelID,
+			InputData:         job.InputData,
+			TemplateType:      job.TemplateType,
+			GenerationOptions: opts,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if !response.Success {
+			return nil, errors.New(response.Error)
+		}
+		return response, nil
+	case models.JobTypeChapterAnalysis:
+		// 逐章调用模型，每章之前复查用户与模型的每日token与每月费用
+		checkQuota := func(ctx context.Context) error {
+			return NewQuotaService(r.client, r.dbName).CheckUsageQuota(ctx, job.UserID,
+				append([]string{job.LLMModelID}, opts.FallbackModelIDs...), r.quotas)
+		}
+		return NewNovelImportService(r.client, r.dbName).AnalyzeChapters(ctx, job.NovelID,
+			stringList(job.InputData["chapter_ids"]), job.LLMModelID, opts, checkQuota)
+	default:
+		return nil, fmt.Errorf("unsupported job type: %s", job.Type)
+	}
+}
@@ -0,0 +1,179 @@
+// Package services
+/*
+/@Author: urmsone urmsone@163.com
+/@Date: 2025/10/20 20:44
+/@Name: job_service.go
+/@Description: Asynchronous generation job service implementation
+/*/
+
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"redquill-backend/pkg/common"
+	"redquill-backend/pkg/models"
+)
+
+var (
+	ErrJobNotFound  = errors.New("job not found")
+	ErrJobFinished  = errors.New("job already finished")
+	ErrJobQueueFull = errors.New("job queue is full")
+)
+
+// JobService 生成任务服务
+type JobService struct {
+	client *mongo.Client
+	dbName string
+}
+
+// NewJobService 创建生成任务服务
+func NewJobService(client *mongo.Client, dbName string) *JobService {
+	return &JobService{
+		client: client,
+		dbName: dbName,
+	}
+}
+
+// collection 任务结果为任意文档，按 bson.M 解码以便直接输出JSON
+func (s *JobService) collection() *mongo.Collection {
+	opts := options.Collection().SetBSONOptions(&options.BSONOptions{DefaultDocumentM: true})
+	return s.client.Database(s.dbName).Collection("generation_jobs", opts)
+}
+
+// PostJobs 创建排队中的任务
+func (s *JobService) PostJobs(ctx context.Context, job models.GenerationJob) (models.GenerationJob, error) {
+	now := time.Now().Unix()
+	job.ID = ""
+	job.Status = models.JobStatusQueued
+	job.Result = nil
+	job.Error = ""
+	job.Ctime = now
+	job.Mtime = now
+
+	res, err := s.collection().InsertOne(ctx, job)
+	if err != nil {
+		return models.GenerationJob{}, err
+	}
+	if oid, ok := res.InsertedID.(primitive.ObjectID); ok {
+		job.ID = oid.Hex()
+	}
+	return job, nil
+}
+
+// GetJobs 获取任务详情
+func (s *JobService) GetJobs(ctx context.Context, id string) (models.GenerationJob, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.GenerationJob{}, errors.New("invalid id")
+	}
+
+	var job models.GenerationJob
+	if err := s.collection().FindOne(ctx, bson.M{"_id": oid}).Decode(&job); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return models.GenerationJob{}, ErrJobNotFound
+		}
+		return models.GenerationJob{}, err
+	}
+	return job, nil
+}
+
+// ListJobs 分页查询任务，非管理员仅能查看自己的任务；回收站中小说的任务不列出
+func (s *JobService) ListJobs(ctx context.Context, userID, role, novelID, status string, page, pageSize int64, sortExpr string) (PagedJobs, error) {
+	filter := bson.M{"deleted_at": bson.M{"$exists": false}}
+	if role != models.RoleAdmin {
+		filter["user_id"] = userID
+	}
+	if novelID != "" {
+		filter["novel_id"] = novelID
+	}
+	if status != "" {
+		filter["status"] = status
+	}
+
+	sort := common.BuildSort(sortExpr)
+	if len(sort) == 0 {
+		sort = bson.D{{Key: "ctime", Value: -1}}
+	}
+	// 列表不返回生成结果
+	opts := common.BuildFindOptions(page, pageSize, sort, bson.M{"result": 0})
+
+	items, total, err := common.FindWithPagination[models.GenerationJob](ctx, s.collection(), filter, opts)
+	if err != nil {
+		return PagedJobs{}, err
+	}
+
+	totalPages := total / common.NormalizePageSize(pageSize)
+	if total%common.NormalizePageSize(pageSize) != 0 {
+		totalPages++
+	}
+
+	return PagedJobs{
+		Items: items,
+		Pagination: common.Pagination{
+			Page:      common.NormalizePage(page),
+			PageSize:  common.NormalizePageSize(pageSize),
+			Total:     total,
+			TotalPage: totalPages,
+		},
+	}, nil
+}
+
+// PagedJobs 分页任务结果
+type PagedJobs struct {
+	Items      []models.GenerationJob `json:"items"`
+	Pagination common.Pagination      `json:"pagination"`
+}
+
+// transitJob 仅当任务处于 from 状态时更新为 to，返回是否更新成功
+func (s *JobService) transitJob(ctx context.Context, id, from, to string, set bson.M) (bool, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, errors.New("invalid id")
+	}
+
+	update := bson.M{"status": to, "mtime": time.Now().Unix()}
+	for k, v := range set {
+		update[k] = v
+	}
+	res, err := s.collection().UpdateOne(ctx, bson.M{"_id": oid, "status": from}, bson.M{"$set": update})
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount > 0, nil
+}
+
+// RecoverJobs 将上次进程退出时未完成的任务重新排队，返回按创建时间排序的排队任务ID
+func (s *JobService) RecoverJobs(ctx context.Context) ([]string, error) {
+	coll := s.collection()
+	if _, err := coll.UpdateMany(ctx,
+		bson.M{"status": models.JobStatusRunning},
+		bson.M{"$set": bson.M{"status": models.JobStatusQueued, "mtime": time.Now().Unix()}},
+	); err != nil {
+		return nil, err
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "ctime", Value: 1}}).SetProjection(bson.M{"_id": 1})
+	cursor, err := coll.Find(ctx, bson.M{"status": models.JobStatusQueued}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var ids []string
+	for cursor.Next(ctx) {
+		var job models.GenerationJob
+		if err := cursor.Decode(&job); err != nil {
+			return nil, err
+		}
+		ids = append(ids, job.ID)
+	}
+	return ids, cursor.Err()
+}
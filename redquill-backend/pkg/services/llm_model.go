@@ -11,6 +11,8 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -38,7 +40,7 @@ func NewLLMModelService(client *mongo.Client, dbName string) *LLMModelService {
 }
 
 // PostLLMModels 创建LLM模型
-func (s *LLMModelService) PostLLMModels(ctx context.Context, name, modelID, displayName, description string, capabilities []string, temperatureRange []float64, costPerToken float64, status string, config models.LLMModelConfig, creatorID, creator string) (models.LLMModel, error) {
+func (s *LLMModelService) PostLLMModels(ctx context.Context, name, modelID, displayName, description string, capabilities []string, temperatureRange []float64, costPerToken float64, status string, config models.LLMModelConfig, quota *models.Quota, creatorID, creator string) (models.LLMModel, error) {
 	coll := s.client.Database(s.dbName).Collection("llm_models")
 
 	// 检查模型名称是否已存在
@@ -47,6 +49,20 @@ func (s *LLMModelService) PostLLMModels(ctx context.Context, name, modelID, disp
 		return models.LLMModel{}, errors.New("model name already exists")
 	}
 
+	// 加密API Key后再落库
+	if err := sealAPIKey(&config); err != nil {
+		return models.LLMModel{}, err
+	}
+
+	// 新建模型未指定重试次数时使用默认值，-1 表示关闭重试
+	if config.MaxRetries == 0 {
+		config.MaxRetries = DefaultLLMMaxRetries
+	}
+
+	if quota != nil && quota.IsZero() {
+		quota = nil
+	}
+
 	now := time.Now()
 	llmModel := models.LLMModel{
 		Name:             name,
@@ -58,6 +74,7 @@ func (s *LLMModelService) PostLLMModels(ctx context.Context, name, modelID, disp
 		CostPerToken:     costPerToken,
 		Status:           status,
 		Config:           config,
+		Quota:            quota,
 		UsageCount:       0,
 		Ctime:            now.Unix(),
 		Mtime:            now.Unix(),
@@ -94,7 +111,7 @@ func (s *LLMModelService) GetLLMModels(ctx context.Context, id string) (models.L
 }
 
 // PutLLMModels 更新LLM模型
-func (s *LLMModelService) PutLLMModels(ctx context.Context, id string, name *string, modelID *string, displayName *string, description *string, capabilities *[]string, temperatureRange *[]float64, costPerToken *float64, status *string, config *models.LLMModelConfig) (models.LLMModel, error) {
+func (s *LLMModelService) PutLLMModels(ctx context.Context, id string, name *string, modelID *string, displayName *string, description *string, capabilities *[]string, temperatureRange *[]float64, costPerToken *float64, status *string, config *models.LLMModelConfig, quota *models.Quota) (models.LLMModel, error) {
 	coll := s.client.Database(s.dbName).Collection("llm_models")
 	oid, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
@@ -129,16 +146,43 @@ func (s *LLMModelService) PutLLMModels(ctx context.Context, id string, name *str
 		set["status"] = *status
 	}
 	if config != nil {
-		set["config"] = *config
+		cfg := *config
+		var existing models.LLMModel
+		if err := coll.FindOne(ctx, bson.M{"_id": oid}).Decode(&existing); err != nil {
+			return models.LLMModel{}, err
+		}
+		// 未提交新Key（空值或脱敏值）时沿用原有密文
+		if cfg.APIKey == "" || cfg.APIKey == existing.Config.APIKeyMask {
+			cfg.APIKey = existing.Config.APIKey
+			cfg.APIKeyCipher = existing.Config.APIKeyCipher
+			cfg.APIKeyWrappedKey = existing.Config.APIKeyWrappedKey
+			cfg.APIKeyMask = existing.Config.APIKeyMask
+		}
+		if cfg.APIKey != "" {
+			if err := sealAPIKey(&cfg); err != nil {
+				return models.LLMModel{}, err
+			}
+		}
+		set["config"] = cfg
 	}
 
 	for k, v := range set {
 		update[k] = v
 	}
 
+	// 空配额表示移除模型配额，改用默认配额
+	change := bson.M{"$set": update}
+	if quota != nil {
+		if quota.IsZero() {
+			change["$unset"] = bson.M{"quota": ""}
+		} else {
+			update["quota"] = *quota
+		}
+	}
+
 	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
 	var out models.LLMModel
-	if err := coll.FindOneAndUpdate(ctx, bson.M{"_id": oid}, bson.M{"$set": update}, opts).Decode(&out); err != nil {
+	if err := coll.FindOneAndUpdate(ctx, bson.M{"_id": oid}, change, opts).Decode(&out); err != nil {
 		return models.LLMModel{}, err
 	}
 
@@ -229,13 +273,22 @@ func (s *LLMModelService) TestLLMModel(ctx context.Context, id string, req model
 	}
 
 	// 创建LLM客户端
+	apiKey, err := DecryptAPIKey(llmModel.Config)
+	if err != nil {
+		return models.LLMModelTestResponse{
+			Success: false,
+			Message: "Failed to decrypt API key",
+			Error:   err.Error(),
+		}, nil
+	}
 	llmConfig := llm.LLMConfig{
 		Provider: llmModel.Config.Provider,
 		BaseURL:  llmModel.Config.BaseURL,
-		APIKey:   llmModel.Config.APIKey,
+		APIKey:   apiKey,
 		Model:    llmModel.Config.ModelName,
 		Timeout:  time.Duration(llmModel.Config.Timeout) * time.Second,
 	}
+	applyRetryConfig(&llmConfig, llmModel.Config)
 
 	client, err := llm.NewClient(llmConfig)
 	if err != nil {
@@ -265,7 +318,13 @@ func (s *LLMModelService) TestLLMModel(ctx context.Context, id string, req model
 	}
 
 	// 同步测试
+	start := time.Now()
 	resp, err := client.Chat(ctx, chatReq)
+	var usage *llm.Usage
+	if resp != nil {
+		usage = resp.Usage
+	}
+	recordLLMUsage(ctx, s.client, s.dbName, models.UsageRecord{UserID: req.UserID, Source: models.UsageSourceTest}, llmModel, usage, start, err)
 	if err != nil {
 		return models.LLMModelTestResponse{
 			Success: false,
@@ -308,13 +367,22 @@ func (s *LLMModelService) ServiceLLMModel(ctx context.Context, id string, req mo
 	}
 
 	// 创建LLM客户端
+	apiKey, err := DecryptAPIKey(llmModel.Config)
+	if err != nil {
+		return models.LLMModelServiceResponse{
+			Success: false,
+			Message: "Failed to decrypt API key",
+			Error:   err.Error(),
+		}, nil
+	}
 	llmConfig := llm.LLMConfig{
 		Provider: llmModel.Config.Provider,
 		BaseURL:  llmModel.Config.BaseURL,
-		APIKey:   llmModel.Config.APIKey,
+		APIKey:   apiKey,
 		Model:    llmModel.Config.ModelName,
 		Timeout:  time.Duration(llmModel.Config.Timeout) * time.Second,
 	}
+	applyRetryConfig(&llmConfig, llmModel.Config)
 
 	client, err := llm.NewClient(llmConfig)
 	if err != nil {
@@ -346,10 +414,19 @@ func (s *LLMModelService) ServiceLLMModel(ctx context.Context, id string, req mo
 	var response string
 	var tokenCount int64
 
+	// 记录用量
+	start := time.Now()
+	usage := &llm.Usage{}
+	var callErr error
+	defer func() {
+		recordLLMUsage(ctx, s.client, s.dbName, models.UsageRecord{UserID: req.UserID, Source: models.UsageSourceService}, llmModel, usage, start, callErr)
+	}()
+
 	if req.Stream {
 		// 流式服务调用
 		stream, err := client.ChatStream(ctx, chatReq)
 		if err != nil {
+			callErr = err
 			return models.LLMModelServiceResponse{
 				Success: false,
 				Message: "Stream service failed",
@@ -359,6 +436,7 @@ func (s *LLMModelService) ServiceLLMModel(ctx context.Context, id string, req mo
 
 		for chunk := range stream {
 			if chunk.Error != nil {
+				callErr = chunk.Error
 				return models.LLMModelServiceResponse{
 					Success: false,
 					Message: "Stream service error",
@@ -375,12 +453,16 @@ func (s *LLMModelService) ServiceLLMModel(ctx context.Context, id string, req mo
 			// 统计token使用量
 			if chunk.Usage != nil {
 				tokenCount += chunk.Usage.TotalTokens
+				addUsage(usage, chunk.Usage)
 			}
 		}
+		estimateStreamUsage(usage, messages, response)
+		tokenCount = usage.TotalTokens
 	} else {
 		// 同步服务调用
 		resp, err := client.Chat(ctx, chatReq)
 		if err != nil {
+			callErr = err
 			return models.LLMModelServiceResponse{
 				Success: false,
 				Message: "Chat service failed",
@@ -395,6 +477,7 @@ func (s *LLMModelService) ServiceLLMModel(ctx context.Context, id string, req mo
 		// 统计token使用量
 		if resp.Usage != nil {
 			tokenCount = resp.Usage.TotalTokens
+			addUsage(usage, resp.Usage)
 		}
 	}
 
@@ -440,13 +523,21 @@ func (s *LLMModelService) TestLLMModelStream(ctx context.Context, id string, req
 	}
 
 	// 创建LLM客户端
+	apiKey, err := DecryptAPIKey(llmModel.Config)
+	if err != nil {
+		ch := make(chan models.StreamChunk, 1)
+		ch <- models.StreamChunk{Error: err}
+		close(ch)
+		return ch, nil
+	}
 	llmConfig := llm.LLMConfig{
 		Provider: llmModel.Config.Provider,
 		BaseURL:  llmModel.Config.BaseURL,
-		APIKey:   llmModel.Config.APIKey,
+		APIKey:   apiKey,
 		Model:    llmModel.Config.ModelName,
 		Timeout:  time.Duration(llmModel.Config.Timeout) * time.Second,
 	}
+	applyRetryConfig(&llmConfig, llmModel.Config)
 
 	client, err := llm.NewClient(llmConfig)
 	if err != nil {
@@ -487,11 +578,27 @@ func (s *LLMModelService) TestLLMModelStream(ctx context.Context, id string, req
 	go func() {
 		defer close(result)
 
+		start := time.Now()
+		usage := &llm.Usage{}
+		var callErr error
+		var response strings.Builder
+		defer func() {
+			estimateStreamUsage(usage, messages, response.String())
+			recordLLMUsage(ctx, s.client, s.dbName, models.UsageRecord{UserID: req.UserID, Source: models.UsageSourceTest}, llmModel, usage, start, callErr)
+		}()
+
+		// 完成原因之后厂商仍会发送仅含用量的数据块，需读到流关闭
+		finished := false
+
 		for chunk := range stream {
 			if chunk.Error != nil {
+				callErr = chunk.Error
 				result <- models.StreamChunk{Error: chunk.Error}
 				return
 			}
+			if chunk.Usage != nil {
+				addUsage(usage, chunk.Usage)
+			}
 
 			// 提取内容
 			content := ""
@@ -502,6 +609,7 @@ func (s *LLMModelService) TestLLMModelStream(ctx context.Context, id string, req
 			}
 
 			if content != "" {
+				response.WriteString(content)
 				result <- models.StreamChunk{
 					Content: content,
 					Done:    false,
@@ -510,14 +618,151 @@ func (s *LLMModelService) TestLLMModelStream(ctx context.Context, id string, req
 
 			// 检查是否完成
 			if len(chunk.Choices) > 0 && chunk.Choices[0].FinishReason != "" {
-				result <- models.StreamChunk{
-					Content: "",
-					Done:    true,
-				}
-				return
+				finished = true
+			}
+		}
+		if finished {
+			result <- models.StreamChunk{
+				Content: "",
+				Done:    true,
 			}
 		}
 	}()
 
 	return result, nil
 }
+
+// DefaultLLMMaxRetries 创建模型时未指定重试次数的默认值
+const DefaultLLMMaxRetries = 2
+
+// applyRetryConfig 将模型的重试配置写入LLM客户端配置；
+// 重试次数为0（含启用重试前创建的模型）或-1时不重试
+func applyRetryConfig(llmConfig *llm.LLMConfig, config models.LLMModelConfig) {
+	llmConfig.MaxRetries = 0
+	if config.MaxRetries > 0 {
+		llmConfig.MaxRetries = config.MaxRetries
+	}
+	if config.RetryDelayMs > 0 {
+		llmConfig.RetryDelay = time.Duration(config.RetryDelayMs) * time.Millisecond
+	}
+	llmConfig.Backoff = llm.BackoffType(config.Backoff)
+}
+
+// sealAPIKey 对配置中的明文API Key做信封加密，并清空明文
+func sealAPIKey(config *models.LLMModelConfig) error {
+	if config.APIKey == "" {
+		return nil
+	}
+
+	ciphertext, wrappedKey, err := common.SealSecret(common.MasterKey(), config.APIKey)
+	if err != nil {
+		return err
+	}
+
+	config.APIKeyCipher = ciphertext
+	config.APIKeyWrappedKey = wrappedKey
+	config.APIKeyMask = common.MaskSecret(config.APIKey)
+	config.APIKey = ""
+	return nil
+}
+
+// DecryptAPIKey 解密API Key，仅在创建LLM客户端时调用；兼容尚未加密的历史数据
+func DecryptAPIKey(config models.LLMModelConfig) (string, error) {
+	if config.APIKeyCipher == "" {
+		return config.APIKey, nil
+	}
+	return common.OpenSecret(common.MasterKey(), config.APIKeyCipher, config.APIKeyWrappedKey)
+}
+
+// RedactLLMModel 将API Key替换为脱敏值，用于接口返回
+func RedactLLMModel(llmModel *models.LLMModel) {
+	if llmModel.Config.APIKeyMask != "" {
+		llmModel.Config.APIKey = llmModel.Config.APIKeyMask
+	} else {
+		llmModel.Config.APIKey = common.MaskSecret(llmModel.Config.APIKey)
+	}
+}
+
+// EncryptLegacyAPIKeys 加密历史遗留的明文API Key，返回处理的文档数
+func (s *LLMModelService) EncryptLegacyAPIKeys(ctx context.Context) (int64, error) {
+	coll := s.client.Database(s.dbName).Collection("llm_models")
+
+	filter := bson.M{
+		"config.api_key":        bson.M{"$nin": bson.A{"", nil}},
+		"config.api_key_cipher": bson.M{"$in": bson.A{"", nil}},
+	}
+	cursor, err := coll.Find(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var count int64
+	for cursor.Next(ctx) {
+		var llmModel models.LLMModel
+		if err := cursor.Decode(&llmModel); err != nil {
+			return count, err
+		}
+
+		cfg := llmModel.Config
+		if err := sealAPIKey(&cfg); err != nil {
+			return count, err
+		}
+
+		oid, _ := primitive.ObjectIDFromHex(llmModel.ID)
+		_, err := coll.UpdateOne(ctx, bson.M{"_id": oid}, bson.M{
+			"$set": bson.M{
+				"config.api_key_cipher":      cfg.APIKeyCipher,
+				"config.api_key_wrapped_key": cfg.APIKeyWrappedKey,
+				"config.api_key_mask":        cfg.APIKeyMask,
+			},
+			"$unset": bson.M{"config.api_key": ""},
+		})
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, cursor.Err()
+}
+
+// RotateMasterKey 用新主密钥重新包裹所有模型的数据密钥，返回轮换的文档数。
+// 已由新主密钥包裹的文档会被跳过，中途失败后可直接重新执行
+func (s *LLMModelService) RotateMasterKey(ctx context.Context, oldKey, newKey []byte) (int64, error) {
+	coll := s.client.Database(s.dbName).Collection("llm_models")
+
+	cursor, err := coll.Find(ctx, bson.M{"config.api_key_wrapped_key": bson.M{"$nin": bson.A{"", nil}}})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var count int64
+	for cursor.Next(ctx) {
+		var llmModel models.LLMModel
+		if err := cursor.Decode(&llmModel); err != nil {
+			return count, err
+		}
+
+		if common.IsWrappedWith(newKey, llmModel.Config.APIKeyWrappedKey) {
+			continue
+		}
+		wrappedKey, err := common.RewrapKey(llmModel.Config.APIKeyWrappedKey, oldKey, newKey)
+		if err != nil {
+			return count, fmt.Errorf("model %s: %w", llmModel.ID, err)
+		}
+
+		// 仅在数据密钥未被并发修改时写入
+		oid, _ := primitive.ObjectIDFromHex(llmModel.ID)
+		res, err := coll.UpdateOne(ctx, bson.M{"_id": oid, "config.api_key_wrapped_key": llmModel.Config.APIKeyWrappedKey}, bson.M{
+			"$set": bson.M{"config.api_key_wrapped_key": wrappedKey, "mtime": time.Now().Unix()},
+		})
+		if err != nil {
+			return count, err
+		}
+		count += res.ModifiedCount
+	}
+
+	return count, cursor.Err()
+}
@@ -0,0 +1,64 @@
+// Package services
+/*
+/@Author: urmsone urmsone@163.com
+/@Date: 2025/10/20 20:44
+/@Name: migration.go
+/@Description: Startup migrator recording applied migrations in schema_migrations
+/*/
+
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"redquill-backend/pkg/models"
+)
+
+// Migration 启动时执行一次的迁移。Up 应当是幂等的：多实例同时启动时可能重复执行
+type Migration struct {
+	ID          string
+	Description string
+	Up          func(ctx context.Context, db *mongo.Database) (string, error) // 返回执行结果说明
+}
+
+// RunMigrations 按顺序执行 schema_migrations 中尚未记录的迁移，返回本次执行的迁移数。
+// 迁移失败时立即返回，已执行的迁移仍会记录
+func RunMigrations(ctx context.Context, client *mongo.Client, dbName string, migrations []Migration) (int, error) {
+	db := client.Database(dbName)
+	coll := db.Collection("schema_migrations")
+
+	applied := 0
+	for _, migration := range migrations {
+		err := coll.FindOne(ctx, bson.M{"_id": migration.ID}).Err()
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			return applied, err
+		}
+
+		result, err := migration.Up(ctx, db)
+		if err != nil {
+			return applied, err
+		}
+		_, err = coll.InsertOne(ctx, models.SchemaMigration{
+			ID:          migration.ID,
+			Description: migration.Description,
+			Result:      result,
+			AppliedAt:   time.Now().Unix(),
+		})
+		// 其他实例已记录同一迁移
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
+			return applied, err
+		}
+		log.Printf("Applied migration %s: %s", migration.ID, result)
+		applied++
+	}
+	return applied, nil
+}
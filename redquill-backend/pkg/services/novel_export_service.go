@@ -0,0 +1,457 @@
+// Package services
+/*
+/@Author: urmsone urmsone@163.com
+/@Date: 2025/10/20 20:44
+/@Name: novel_export_service.go
+/@Description: Novel export service implementation
+/*/
+
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"redquill-backend/pkg/models"
+	"redquill-backend/pkg/utils/docx"
+	"redquill-backend/pkg/utils/epub"
+)
+
+// 导出格式
+const (
+	ExportFormatEPUB     = "epub"
+	ExportFormatMarkdown = "md"
+	ExportFormatTXT      = "txt"
+	ExportFormatDOCX     = "docx"
+)
+
+// ExportZipChapterThreshold 章节数超过该值时按章节打包为 zip 流式导出（EPUB 除外）
+const ExportZipChapterThreshold = 200
+
+var (
+	// ErrNoChaptersToExport 小说（或指定范围内）还没有章节
+	ErrNoChaptersToExport = errors.New("novel has no chapters to export")
+	// ErrUnsupportedExportFormat 不支持的导出格式
+	ErrUnsupportedExportFormat = errors.New("unsupported export format")
+)
+
+// txtBOM UTF-8 BOM，便于 Windows 记事本及各网文平台正确识别编码
+const txtBOM = "\uFEFF"
+
+// ExportOptions 导出选项
+type ExportOptions struct {
+	Format      string // 导出格式，默认 epub
+	FromChapter int    // 起始章节序号（含），0 表示从第一章开始
+	ToChapter   int    // 结束章节序号（含），0 表示到最后一章
+	Summaries   bool   // 在每章正文前附上章节概要
+	Appendix    bool   // 附录：人物表与世界观（仅 EPUB）
+}
+
+// IsExportFormat 是否为支持的导出格式
+func IsExportFormat(format string) bool {
+	switch format {
+	case ExportFormatEPUB, ExportFormatMarkdown, ExportFormatTXT, ExportFormatDOCX:
+		return true
+	}
+	return false
+}
+
+// NovelExportService 小说导出服务
+type NovelExportService struct {
+	client *mongo.Client
+	dbName string
+}
+
+// NewNovelExportService 创建小说导出服务
+func NewNovelExportService(client *mongo.Client, dbName string) *NovelExportService {
+	return &NovelExportService{
+		client: client,
+		dbName: dbName,
+	}
+}
+
+// exportMeta 导出文件的书籍信息
+type exportMeta struct {
+	novel       models.Novel
+	author      string
+	description string
+	modified    time.Time
+}
+
+// CountExportChapters 统计导出范围内的章节数
+func (s *NovelExportService) CountExportChapters(ctx context.Context, novelID string, opts ExportOptions) (int64, error) {
+	return s.client.Database(s.dbName).Collection("chapters").CountDocuments(ctx, exportChapterFilter(novelID, opts))
+}
+
+// Export 按章节序号将小说导出为单个文件，书名与作者取自小说及其作者
+func (s *NovelExportService) Export(ctx context.Context, novelID string, opts ExportOptions, w io.Writer) error {
+	if opts.Format == "" {
+		opts.Format = ExportFormatEPUB
+	}
+	if !IsExportFormat(opts.Format) {
+		return ErrUnsupportedExportFormat
+	}
+	meta, err := s.loadMeta(ctx, novelID)
+	if err != nil {
+		return err
+	}
+
+	switch opts.Format {
+	case ExportFormatEPUB:
+		book, err := s.buildBook(ctx, meta, opts)
+		if err != nil {
+			return err
+		}
+		return book.Write(w)
+	case ExportFormatDOCX:
+		doc := docx.New(meta.novel.Title, meta.author)
+		doc.Modified = meta.modified
+		doc.TitleParagraph(meta.novel.Title)
+		if meta.author != "" {
+			doc.Subtitle(meta.author)
+		}
+		if err := s.eachChapter(ctx, novelID, opts, func(chapter models.Chapter) error {
+			writeDocxChapter(doc, chapter, opts.Summaries)
+			return nil
+		}); err != nil {
+			return err
+		}
+		return doc.Write(w)
+	case ExportFormatMarkdown:
+		header := "# " + meta.novel.Title + "\n\n"
+		if meta.author != "" {
+			header += "作者：" + meta.author + "\n\n"
+		}
+		if meta.description != "" {
+			header += "> " + meta.description + "\n\n"
+		}
+		return s.exportText(ctx, novelID, opts, w, header, func(chapter models.Chapter) string {
+			return markdownChapter(chapter, "##", opts.Summaries)
+		})
+	default:
+		header := txtBOM + meta.novel.Title + "\r\n"
+		if meta.author != "" {
+			header += "作者：" + meta.author + "\r\n"
+		}
+		if meta.description != "" {
+			header += "简介：" + meta.description + "\r\n"
+		}
+		return s.exportText(ctx, novelID, opts, w, header+"\r\n", func(chapter models.Chapter) string {
+			return txtChapter(chapter, opts.Summaries) + "\r\n"
+		})
+	}
+}
+
+// ExportZip 将导出范围内的每一章写为 zip 中的一个文件，按游标逐章流式写出，适合大部头作品
+func (s *NovelExportService) ExportZip(ctx context.Context, novelID string, opts ExportOptions, w io.Writer) error {
+	if opts.Format == "" || opts.Format == ExportFormatEPUB || !IsExportFormat(opts.Format) {
+		return ErrUnsupportedExportFormat
+	}
+	meta, err := s.loadMeta(ctx, novelID)
+	if err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+	if err := s.eachChapter(ctx, novelID, opts, func(chapter models.Chapter) error {
+		header := &zip.FileHeader{
+			Name:     fmt.Sprintf("%04d %s.%s", chapter.ChapterNumber, sanitizeFileName(chapterDisplayTitle(chapter)), opts.Format),
+			Method:   zip.Deflate,
+			Modified: meta.modified,
+		}
+		if opts.Format == ExportFormatDOCX {
+			// docx 本身已压缩
+			header.Method = zip.Store
+		}
+		fw, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+
+		switch opts.Format {
+		case ExportFormatDOCX:
+			doc := docx.New(chapterDisplayTitle(chapter), meta.author)
+			doc.Modified = meta.modified
+			writeDocxChapter(doc, chapter, opts.Summaries)
+			return doc.Write(fw)
+		case ExportFormatMarkdown:
+			_, err = io.WriteString(fw, markdownChapter(chapter, "#", opts.Summaries))
+		default:
+			_, err = io.WriteString(fw, txtBOM+txtChapter(chapter, opts.Summaries))
+		}
+		return err
+	}); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// loadMeta 读取书名、作者、简介与修改时间
+func (s *NovelExportService) loadMeta(ctx context.Context, novelID string) (exportMeta, error) {
+	novelService := NewNovelService(s.client, s.dbName)
+	novel, err := novelService.GetNovels(ctx, novelID)
+	if err != nil {
+		return exportMeta{}, err
+	}
+
+	meta := exportMeta{
+		novel:       novel,
+		description: novel.ProjectBlueprint.CoreConflict,
+		modified:    time.Unix(novel.Mtime, 0),
+	}
+	if novel.Mtime == 0 {
+		meta.modified = time.Unix(novel.Ctime, 0)
+	}
+	if author, err := NewUserService(s.client, s.dbName).GetUsers(ctx, novel.AuthorID); err == nil {
+		meta.author = author.Name
+	}
+	if storyCore, err := novelService.GetSelectedStoryCore(ctx, novelID); err == nil && storyCore.CoreConflict != "" {
+		meta.description = storyCore.CoreConflict
+	}
+	return meta, nil
+}
+
+// eachChapter 按章节序号依次读取导出范围内的章节，范围内没有章节时返回 ErrNoChaptersToExport
+func (s *NovelExportService) eachChapter(ctx context.Context, novelID string, opts ExportOptions, fn func(models.Chapter) error) error {
+	coll := s.client.Database(s.dbName).Collection("chapters")
+	cursor, err := coll.Find(ctx, exportChapterFilter(novelID, opts), options.Find().SetSort(bson.M{"chapter_number": 1}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	count := 0
+	for cursor.Next(ctx) {
+		var chapter models.Chapter
+		if err := cursor.Decode(&chapter); err != nil {
+			return err
+		}
+		if err := fn(chapter); err != nil {
+			return err
+		}
+		count++
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrNoChaptersToExport
+	}
+	return nil
+}
+
+// exportText 依次写出头部和每一章的文本
+func (s *NovelExportService) exportText(ctx context.Context, novelID string, opts ExportOptions, w io.Writer, header string, render func(models.Chapter) string) error {
+	if _, err := io.WriteString(w, header); err != nil {
+		return err
+	}
+	return s.eachChapter(ctx, novelID, opts, func(chapter models.Chapter) error {
+		_, err := io.WriteString(w, render(chapter))
+		return err
+	})
+}
+
+// buildBook 组装电子书内容
+func (s *NovelExportService) buildBook(ctx context.Context, meta exportMeta, opts ExportOptions) (*epub.Book, error) {
+	novelID := meta.novel.ID
+	book := &epub.Book{
+		Identifier:  "urn:redquill:novel:" + novelID,
+		Title:       meta.novel.Title,
+		Author:      meta.author,
+		Description: meta.description,
+		Modified:    meta.modified,
+	}
+
+	if err := s.eachChapter(ctx, novelID, opts, func(chapter models.Chapter) error {
+		title := chapterDisplayTitle(chapter)
+		body := epub.Heading(1, title)
+		if summary := strings.TrimSpace(chapter.Summary); opts.Summaries && summary != "" {
+			body += `<p class="summary">本章概要：` + epub.Escape(summary) + "</p>\n"
+		}
+		book.Sections = append(book.Sections, epub.Section{
+			Title: title,
+			Body:  body + epub.Paragraphs(chapter.Content),
+		})
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if opts.Appendix {
+		novelService := NewNovelService(s.client, s.dbName)
+		if characters, err := novelService.GetCharacters(ctx, novelID); err == nil && len(characters) > 0 {
+			book.Sections = append(book.Sections, epub.Section{Title: "附录：人物表", Body: charactersAppendix(characters)})
+		}
+		if worldview, err := novelService.GetWorldviews(ctx, novelID); err == nil {
+			book.Sections = append(book.Sections, epub.Section{Title: "附录：世界观", Body: worldviewAppendix(worldview)})
+		}
+	}
+
+	return book, nil
+}
+
+// exportChapterFilter 导出范围内章节的查询条件
+func exportChapterFilter(novelID string, opts ExportOptions) bson.M {
+	filter := bson.M{"novel_id": novelID}
+	numberFilter := bson.M{}
+	if opts.FromChapter > 0 {
+		numberFilter["$gte"] = opts.FromChapter
+	}
+	if opts.ToChapter > 0 {
+		numberFilter["$lte"] = opts.ToChapter
+	}
+	if len(numberFilter) > 0 {
+		filter["chapter_number"] = numberFilter
+	}
+	return filter
+}
+
+// markdownChapter 一章的 Markdown 文本，level 为章节标题的 # 前缀
+func markdownChapter(chapter models.Chapter, level string, summaries bool) string {
+	var sb strings.Builder
+	sb.WriteString(level + " " + chapterDisplayTitle(chapter) + "\n\n")
+	if summary := strings.TrimSpace(chapter.Summary); summaries && summary != "" {
+		sb.WriteString("> 本章概要：" + summary + "\n\n")
+	}
+	for _, line := range contentLines(chapter.Content) {
+		sb.WriteString(line + "\n\n")
+	}
+	return sb.String()
+}
+
+// txtChapter 一章的纯文本：“第N章 标题”单独一行，段首缩进两个全角空格，CRLF 换行
+func txtChapter(chapter models.Chapter, summaries bool) string {
+	var sb strings.Builder
+	sb.WriteString(chapterDisplayTitle(chapter) + "\r\n\r\n")
+	if summary := strings.TrimSpace(chapter.Summary); summaries && summary != "" {
+		sb.WriteString("【本章概要】" + summary + "\r\n\r\n")
+	}
+	for _, line := range contentLines(chapter.Content) {
+		sb.WriteString("\u3000\u3000" + line + "\r\n")
+	}
+	return sb.String()
+}
+
+// writeDocxChapter 将一章写入 Word 文档，每章另起一页
+func writeDocxChapter(doc *docx.Document, chapter models.Chapter, summaries bool) {
+	doc.Heading(chapterDisplayTitle(chapter), true)
+	if summary := strings.TrimSpace(chapter.Summary); summaries && summary != "" {
+		doc.Note("本章概要：" + summary)
+	}
+	doc.Paragraphs(chapter.Content)
+}
+
+// contentLines 正文的非空段落，去掉原有的缩进
+func contentLines(content string) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// sanitizeFileName 替换文件名中不允许的字符
+func sanitizeFileName(name string) string {
+	return strings.NewReplacer("/", "_", "\\", "_", ":", "_", "*", "_", "?", "_", "\"", "_", "<", "_", ">", "_", "|", "_").Replace(name)
+}
+
+// chapterDisplayTitle 章节显示标题，标题本身已带“第N章”时不再重复
+func chapterDisplayTitle(chapter models.Chapter) string {
+	title := strings.TrimSpace(chapter.Title)
+	if strings.HasPrefix(title, "第") && strings.Contains(title, "章") {
+		return title
+	}
+	if title == "" {
+		return fmt.Sprintf("第%d章", chapter.ChapterNumber)
+	}
+	return fmt.Sprintf("第%d章 %s", chapter.ChapterNumber, title)
+}
+
+// charactersAppendix 人物表
+func charactersAppendix(characters []models.Character) string {
+	var sb strings.Builder
+	sb.WriteString(epub.Heading(1, "人物表"))
+	for _, character := range characters {
+		sb.WriteString(epub.Heading(2, character.Name))
+		sb.WriteString("<dl>\n")
+		writeDefinition(&sb, "身份", characterTypeLabel(character.Type))
+		writeDefinition(&sb, "境界", character.CoreAttributes.CultivationLevel)
+		writeDefinition(&sb, "性格", strings.Join(character.SoulProfile.Personality.CoreTraits, "、"))
+		writeDefinition(&sb, "出身", character.SoulProfile.Background.Origin)
+		writeDefinition(&sb, "能力", strings.Join(character.CoreAttributes.Abilities, "、"))
+		writeDefinition(&sb, "目标", character.SoulProfile.Motivations.LongTermGoal)
+		writeDefinition(&sb, "核心驱动", character.SoulProfile.Motivations.CoreDrive)
+		sb.WriteString("</dl>\n")
+	}
+	return sb.String()
+}
+
+// worldviewAppendix 世界观
+func worldviewAppendix(worldview models.Worldview) string {
+	var sb strings.Builder
+	sb.WriteString(epub.Heading(1, "世界观"))
+
+	sb.WriteString(epub.Heading(2, "力量体系"))
+	sb.WriteString("<dl>\n")
+	writeDefinition(&sb, "名称", worldview.PowerSystem.Name)
+	writeDefinition(&sb, "等级", strings.Join(worldview.PowerSystem.Levels, " → "))
+	writeDefinition(&sb, "修炼方式", worldview.PowerSystem.CultivationMethod)
+	writeDefinition(&sb, "限制", worldview.PowerSystem.Limitations)
+	sb.WriteString("</dl>\n")
+
+	sb.WriteString(epub.Heading(2, "社会结构"))
+	sb.WriteString("<dl>\n")
+	writeDefinition(&sb, "等级制度", worldview.SocietyStructure.Hierarchy)
+	for _, faction := range worldview.SocietyStructure.MajorFactions {
+		writeDefinition(&sb, faction.Name, strings.Trim(faction.Type+"，"+faction.Influence, "，"))
+	}
+	writeDefinition(&sb, "经济体系", worldview.SocietyStructure.EconomicSystem)
+	sb.WriteString("</dl>\n")
+
+	sb.WriteString(epub.Heading(2, "地理"))
+	sb.WriteString("<dl>\n")
+	writeDefinition(&sb, "主要区域", strings.Join(worldview.Geography.MajorRegions, "、"))
+	writeDefinition(&sb, "特殊地点", strings.Join(worldview.Geography.SpecialLocations, "、"))
+	sb.WriteString("</dl>\n")
+
+	if len(worldview.SpecialRules) > 0 {
+		sb.WriteString(epub.Heading(2, "特殊规则"))
+		sb.WriteString(epub.Paragraphs(strings.Join(worldview.SpecialRules, "\n")))
+	}
+	return sb.String()
+}
+
+// writeDefinition 写入一条定义，内容为空时跳过
+func writeDefinition(sb *strings.Builder, term, description string) {
+	if strings.TrimSpace(description) == "" {
+		return
+	}
+	sb.WriteString("<dt>" + epub.Escape(term) + "</dt><dd>" + epub.Escape(description) + "</dd>\n")
+}
+
+// characterTypeLabel 角色类型的中文名称
+func characterTypeLabel(charType string) string {
+	switch charType {
+	case "protagonist":
+		return "主角"
+	case "antagonist":
+		return "反派"
+	case "supporting":
+		return "配角"
+	case "love_interest":
+		return "感情线角色"
+	default:
+		return charType
+	}
+}
@@ -13,7 +13,10 @@ import (
 	"errors"
 	"fmt"
 	"go.mongodb.org/mongo-driver/mongo"
+	"log"
+	"redquill-backend/pkg/common"
 	"redquill-backend/pkg/models"
+	"sort"
 	"strings"
 )
 
@@ -32,14 +35,15 @@ func NewNovelGenerationService(client *mongo.Client, dbName string) *NovelGenera
 }
 
 // GenerateStoryCore 生成故事核心
-func (s *NovelGenerationService) GenerateStoryCore(ctx context.Context, novelID, llmModelID string, inputData map[string]interface{}) (models.StoryCore, error) {
+func (s *NovelGenerationService) GenerateStoryCore(ctx context.Context, novelID, llmModelID string, inputData map[string]interface{}, opts models.GenerationOptions) (models.StoryCore, error) {
 	// 构建输入数据
 	generationReq := models.GenerationRequest{
-		NovelID:      novelID,
-		LLMModelID:   llmModelID,
-		InputData:    inputData,
-		TemplateType: "story_core",
-		Stream:       false,
+		NovelID:           novelID,
+		LLMModelID:        llmModelID,
+		InputData:         inputData,
+		TemplateType:      "story_core",
+		Stream:            false,
+		GenerationOptions: opts,
 	}
 
 	// 调用LLM生成
@@ -53,6 +57,11 @@ func (s *NovelGenerationService) GenerateStoryCore(ctx context.Context, novelID,
 		return models.StoryCore{}, errors.New(response.Error)
 	}
 
+	return s.saveStoryCore(ctx, novelID, inputData, response)
+}
+
+// saveStoryCore 解析生成结果并保存故事核心
+func (s *NovelGenerationService) saveStoryCore(ctx context.Context, novelID string, inputData map[string]interface{}, response models.GenerationResponse) (models.StoryCore, error) {
 	// 解析响应数据
 	concepts, ok := response.Data["concepts"].([]interface{})
 	if !ok || len(concepts) == 0 {
@@ -60,49 +69,50 @@ func (s *NovelGenerationService) GenerateStoryCore(ctx context.Context, novelID,
 	}
 
 	// 取第一个概念作为故事核心
-	concept := concepts[0].(map[string]interface{})
-
-	storyCore := models.StoryCore{
-		NovelID:             novelID,
-		Title:               s.getString(concept, "title"),
-		CoreConflict:        s.getString(concept, "core_conflict"),
-		Theme:               s.getString(concept, "theme"),
-		Innovation:          s.getString(concept, "innovation"),
-		CommercialPotential: s.getString(concept, "commercial_potential"),
-		TargetAudience:      s.getString(concept, "target_audience"),
+	var storyCore models.StoryCore
+	missing, err := common.DecodeLenient(concepts[0], &storyCore, serverFields...)
+	if err != nil {
+		return models.StoryCore{}, fmt.Errorf("%w: concepts[0]: %v", ErrGenerationParse, err)
 	}
+	s.reportMissingFields(novelID, "story_core", missing)
 
 	// 保存到数据库
 	novelService := NewNovelService(s.client, s.dbName)
-	storyCore, err = novelService.PostStoryCores(ctx, storyCore.NovelID, storyCore.Title, storyCore.CoreConflict, storyCore.Theme, storyCore.Innovation, storyCore.CommercialPotential, storyCore.TargetAudience)
+	storyCore, err = novelService.PostStoryCores(ctx, novelID, storyCore.Title, storyCore.CoreConflict, storyCore.Theme, storyCore.Innovation, storyCore.CommercialPotential, storyCore.TargetAudience)
 	if err != nil {
 		return models.StoryCore{}, err
 	}
 
 	// 保存ExtraInfo
 	extraInfo := map[string]interface{}{
-		"generation_time": response.Data["generation_time"],
-		"token_count":     response.TokenCount,
-		"usage_count":     response.UsageCount,
-		"raw_response":    response.Data,
+		"generation_time":     response.Data["generation_time"],
+		"token_count":         response.TokenCount,
+		"usage_count":         response.UsageCount,
+		"served_llm_model_id": response.ServedModelID,
+		"raw_response":        response.Data,
+	}
+	if len(missing) > 0 {
+		extraInfo["missing_fields"] = missing
 	}
 	if err := novelService.UpdateNovelExtraInfo(ctx, novelID, "story_core", extraInfo); err != nil {
 		// 记录错误但不影响主流程
 		// log.Printf("Failed to update extra info: %v", err)
 	}
 
+	storyCore.MissingFields = missing
 	return storyCore, nil
 }
 
 // GenerateWorldview 生成世界观
-func (s *NovelGenerationService) GenerateWorldview(ctx context.Context, novelID, llmModelID string, inputData map[string]interface{}) (models.Worldview, error) {
+func (s *NovelGenerationService) GenerateWorldview(ctx context.Context, novelID, llmModelID string, inputData map[string]interface{}, opts models.GenerationOptions) (models.Worldview, error) {
 	// 构建输入数据
 	generationReq := models.GenerationRequest{
-		NovelID:      novelID,
-		LLMModelID:   llmModelID,
-		InputData:    inputData,
-		TemplateType: "worldview",
-		Stream:       false,
+		NovelID:           novelID,
+		LLMModelID:        llmModelID,
+		InputData:         inputData,
+		TemplateType:      "worldview",
+		Stream:            false,
+		GenerationOptions: opts,
 	}
 
 	// 调用LLM生成
@@ -116,60 +126,56 @@ func (s *NovelGenerationService) GenerateWorldview(ctx context.Context, novelID,
 		return models.Worldview{}, errors.New(response.Error)
 	}
 
-	// 解析响应数据
-	worldviewData := response.Data
-
-	powerSystem := models.PowerSystem{
-		Name:              s.getString(worldviewData, "power_system.name"),
-		Levels:            s.getStringArray(worldviewData, "power_system.levels"),
-		CultivationMethod: s.getString(worldviewData, "power_system.cultivation_method"),
-		Limitations:       s.getString(worldviewData, "power_system.limitations"),
-	}
-
-	societyStructure := models.SocietyStructure{
-		Hierarchy:      s.getString(worldviewData, "society_structure.hierarchy"),
-		EconomicSystem: s.getString(worldviewData, "society_structure.economic_system"),
-		MajorFactions:  s.parseFactions(worldviewData),
-	}
+	return s.saveWorldview(ctx, novelID, inputData, response)
+}
 
-	geography := models.Geography{
-		MajorRegions:     s.getStringArray(worldviewData, "geography.major_regions"),
-		SpecialLocations: s.getStringArray(worldviewData, "geography.special_locations"),
+// saveWorldview 解析生成结果并保存世界观
+func (s *NovelGenerationService) saveWorldview(ctx context.Context, novelID string, inputData map[string]interface{}, response models.GenerationResponse) (models.Worldview, error) {
+	// 解析响应数据
+	var parsed models.Worldview
+	missing, err := common.DecodeLenient(response.Data, &parsed, serverFields...)
+	if err != nil {
+		return models.Worldview{}, fmt.Errorf("%w: %v", ErrGenerationParse, err)
 	}
-
-	specialRules := s.getStringArray(worldviewData, "special_rules")
+	s.reportMissingFields(novelID, "worldview", missing)
 
 	// 保存到数据库
 	novelService := NewNovelService(s.client, s.dbName)
-	worldview, err := novelService.PostWorldviews(ctx, novelID, powerSystem, societyStructure, geography, specialRules)
+	worldview, err := novelService.PostWorldviews(ctx, novelID, parsed.PowerSystem, parsed.SocietyStructure, parsed.Geography, parsed.SpecialRules)
 	if err != nil {
 		return models.Worldview{}, err
 	}
 
 	// 保存ExtraInfo
 	extraInfo := map[string]interface{}{
-		"generation_time": response.Data["generation_time"],
-		"token_count":     response.TokenCount,
-		"usage_count":     response.UsageCount,
-		"raw_response":    response.Data,
+		"generation_time":     response.Data["generation_time"],
+		"token_count":         response.TokenCount,
+		"usage_count":         response.UsageCount,
+		"served_llm_model_id": response.ServedModelID,
+		"raw_response":        response.Data,
+	}
+	if len(missing) > 0 {
+		extraInfo["missing_fields"] = missing
 	}
 	if err := novelService.UpdateNovelExtraInfo(ctx, novelID, "worldview", extraInfo); err != nil {
 		// 记录错误但不影响主流程
 		// log.Printf("Failed to update extra info: %v", err)
 	}
 
+	worldview.MissingFields = missing
 	return worldview, nil
 }
 
 // GenerateCharacter 生成角色
-func (s *NovelGenerationService) GenerateCharacter(ctx context.Context, novelID, llmModelID string, inputData map[string]interface{}) (models.Character, error) {
+func (s *NovelGenerationService) GenerateCharacter(ctx context.Context, novelID, llmModelID string, inputData map[string]interface{}, opts models.GenerationOptions) (models.Character, error) {
 	// 构建输入数据
 	generationReq := models.GenerationRequest{
-		NovelID:      novelID,
-		LLMModelID:   llmModelID,
-		InputData:    inputData,
-		TemplateType: "character",
-		Stream:       false,
+		NovelID:           novelID,
+		LLMModelID:        llmModelID,
+		InputData:         inputData,
+		TemplateType:      "character",
+		Stream:            false,
+		GenerationOptions: opts,
 	}
 
 	// 调用LLM生成
@@ -183,72 +189,61 @@ func (s *NovelGenerationService) GenerateCharacter(ctx context.Context, novelID,
 		return models.Character{}, errors.New(response.Error)
 	}
 
-	// 解析响应数据
-	characterData := response.Data
-
-	// 解析灵魂档案
-	soulProfile := models.SoulProfile{
-		Personality: models.Personality{
-			CoreTraits:        s.getStringArray(characterData, "soul_profile.personality.core_traits"),
-			MoralCompass:      s.getString(characterData, "soul_profile.personality.moral_compass"),
-			InternalConflicts: s.getStringArray(characterData, "soul_profile.personality.internal_conflicts"),
-			Fears:             s.getStringArray(characterData, "soul_profile.personality.fears"),
-			Desires:           s.getStringArray(characterData, "soul_profile.personality.desires"),
-		},
-		Background: models.Background{
-			Origin:         s.getString(characterData, "soul_profile.background.origin"),
-			DefiningEvents: s.getStringArray(characterData, "soul_profile.background.defining_events"),
-			HiddenSecrets:  s.getStringArray(characterData, "soul_profile.background.hidden_secrets"),
-		},
-		Motivations: models.Motivations{
-			ImmediateGoal: s.getString(characterData, "soul_profile.motivations.immediate_goal"),
-			LongTermGoal:  s.getString(characterData, "soul_profile.motivations.long_term_goal"),
-			CoreDrive:     s.getString(characterData, "soul_profile.motivations.core_drive"),
-		},
-	}
+	return s.saveCharacter(ctx, novelID, inputData, response)
+}
 
-	// 解析核心属性
-	coreAttributes := models.CoreAttributes{
-		CultivationLevel: s.getString(characterData, "core_attributes.cultivation_level"),
-		CurrentItems:     s.getStringArray(characterData, "core_attributes.current_items"),
-		Abilities:        s.getStringArray(characterData, "core_attributes.abilities"),
-		Relationships:    s.parseRelationships(characterData),
+// saveCharacter 解析生成结果并保存角色
+func (s *NovelGenerationService) saveCharacter(ctx context.Context, novelID string, inputData map[string]interface{}, response models.GenerationResponse) (models.Character, error) {
+	// 解析响应数据，角色类型由请求指定
+	var parsed models.Character
+	missing, err := common.DecodeLenient(response.Data, &parsed, append(serverFields, "type", "growth_track", "updated_at")...)
+	if err != nil {
+		return models.Character{}, fmt.Errorf("%w: %v", ErrGenerationParse, err)
 	}
+	s.reportMissingFields(novelID, "character", missing)
 
 	// 保存到数据库
 	novelService := NewNovelService(s.client, s.dbName)
-	character, err := novelService.PostCharacters(ctx, novelID, s.getString(characterData, "name"), s.getString(inputData, "character_type"), coreAttributes, soulProfile)
+	character, err := novelService.PostCharacters(ctx, novelID, parsed.Name, s.getString(inputData, "character_type"), parsed.CoreAttributes, parsed.SoulProfile)
 	if err != nil {
 		return models.Character{}, err
 	}
 
 	// 保存ExtraInfo
 	extraInfo := map[string]interface{}{
-		"generation_time": response.Data["generation_time"],
-		"token_count":     response.TokenCount,
-		"usage_count":     response.UsageCount,
-		"raw_response":    response.Data,
+		"generation_time":     response.Data["generation_time"],
+		"token_count":         response.TokenCount,
+		"usage_count":         response.UsageCount,
+		"served_llm_model_id": response.ServedModelID,
+		"raw_response":        response.Data,
+	}
+	if len(missing) > 0 {
+		extraInfo["missing_fields"] = missing
 	}
 	if err := novelService.UpdateNovelExtraInfo(ctx, novelID, "character", extraInfo); err != nil {
 		// 记录错误但不影响主流程
 		// log.Printf("Failed to update extra info: %v", err)
 	}
 
+	character.MissingFields = missing
 	return character, nil
 }
 
 // GenerateCharactersFromOutline 根据大纲批量生成角色
-func (s *NovelGenerationService) GenerateCharactersFromOutline(ctx context.Context, novelID, llmModelID, outlineID string, userRequirements string) ([]models.Character, error) {
+func (s *NovelGenerationService) GenerateCharactersFromOutline(ctx context.Context, novelID, llmModelID, outlineID string, userRequirements string, opts models.GenerationOptions) ([]models.Character, error) {
 	// 1. 获取大纲数据
 	novelService := NewNovelService(s.client, s.dbName)
 	outline, err := novelService.GetOutline(ctx, outlineID)
 	if err != nil {
 		return nil, err
 	}
+	if outline.NovelID != novelID {
+		return nil, ErrNovelForbidden
+	}
 
 	// 2. 获取故事核心和世界观
-	storyCores, err := novelService.GetStoryCores(ctx, novelID)
-	if err != nil || len(storyCores) == 0 {
+	storyCore, err := novelService.GetSelectedStoryCore(ctx, novelID)
+	if err != nil {
 		return nil, errors.New("no story core found")
 	}
 
@@ -263,12 +258,13 @@ func (s *NovelGenerationService) GenerateCharactersFromOutline(ctx context.Conte
 		LLMModelID: llmModelID,
 		InputData: map[string]interface{}{
 			"outline_content":   s.buildOutlineContent(outline),
-			"story_core":        s.buildStoryCoreContent(storyCores[0]),
+			"story_core":        s.buildStoryCoreContent(storyCore),
 			"worldview":         s.buildWorldviewContent(worldview),
 			"user_requirements": userRequirements,
 		},
 		TemplateType: "batch_character",
 		Stream:       false,
+		GenerationOptions: opts,
 	}
 
 	// 4. 调用LLM生成
@@ -282,7 +278,13 @@ func (s *NovelGenerationService) GenerateCharactersFromOutline(ctx context.Conte
 		return nil, errors.New(response.Error)
 	}
 
+	return s.saveCharactersFromOutline(ctx, novelID, outlineID, response)
+}
+
+// saveCharactersFromOutline 解析生成结果并保存批量角色
+func (s *NovelGenerationService) saveCharactersFromOutline(ctx context.Context, novelID string, outlineID string, response models.GenerationResponse) ([]models.Character, error) {
 	// 5. 解析响应数据
+	novelService := NewNovelService(s.client, s.dbName)
 	charactersData, ok := response.Data["characters"].([]interface{})
 	if !ok {
 		return nil, errors.New("invalid characters data format")
@@ -290,14 +292,15 @@ func (s *NovelGenerationService) GenerateCharactersFromOutline(ctx context.Conte
 
 	// 6. 解析并保存角色
 	var characters []models.Character
-	for _, charData := range charactersData {
-		charMap, ok := charData.(map[string]interface{})
-		if !ok {
+	missingFields := make(map[string][]string)
+	for i, charData := range charactersData {
+		var character models.Character
+		missing, err := common.DecodeLenient(charData, &character, append(serverFields, "growth_track", "updated_at")...)
+		if err != nil {
+			log.Printf("Skipping generated character %d for novel %s: %v", i, novelID, err)
 			continue
 		}
-
-		character := s.parseCharacterFromMap(charMap)
-		character.NovelID = novelID
+		s.reportMissingFields(novelID, "batch_character", missing)
 
 		// 保存到数据库
 		savedChar, err := novelService.PostCharacters(
@@ -312,17 +315,25 @@ func (s *NovelGenerationService) GenerateCharactersFromOutline(ctx context.Conte
 			// 记录错误但继续处理其他角色
 			continue
 		}
+		if len(missing) > 0 {
+			savedChar.MissingFields = missing
+			missingFields[savedChar.Name] = missing
+		}
 		characters = append(characters, savedChar)
 	}
 
 	// 7. 保存ExtraInfo
 	extraInfo := map[string]interface{}{
-		"generation_time": response.Data["generation_time"],
-		"token_count":     response.TokenCount,
-		"usage_count":     response.UsageCount,
-		"raw_response":    response.Data,
-		"outline_id":      outlineID,
-		"character_count": len(characters),
+		"generation_time":     response.Data["generation_time"],
+		"token_count":         response.TokenCount,
+		"usage_count":         response.UsageCount,
+		"served_llm_model_id": response.ServedModelID,
+		"raw_response":        response.Data,
+		"outline_id":          outlineID,
+		"character_count":     len(characters),
+	}
+	if len(missingFields) > 0 {
+		extraInfo["missing_fields"] = missingFields
 	}
 	if err := novelService.UpdateNovelExtraInfo(ctx, novelID, "batch_character", extraInfo); err != nil {
 		// 记录错误但不影响主流程
@@ -407,66 +418,14 @@ func (s *NovelGenerationService) buildCurrentArcContent(arc models.StoryArc) str
 		arc.Name, arc.Description, arc.StartChapter, arc.EndChapter, arc.Theme)
 }
 
-// parseCharacterFromMap 从map解析角色数据
-func (s *NovelGenerationService) parseCharacterFromMap(charMap map[string]interface{}) models.Character {
-	// 解析灵魂档案
-	soulProfileData := charMap["soul_profile"].(map[string]interface{})
-	personalityData := soulProfileData["personality"].(map[string]interface{})
-	backgroundData := soulProfileData["background"].(map[string]interface{})
-	motivationsData := soulProfileData["motivations"].(map[string]interface{})
-
-	soulProfile := models.SoulProfile{
-		Personality: models.Personality{
-			CoreTraits:        s.getStringArray(personalityData, "core_traits"),
-			MoralCompass:      s.getString(personalityData, "moral_compass"),
-			InternalConflicts: s.getStringArray(personalityData, "internal_conflicts"),
-			Fears:             s.getStringArray(personalityData, "fears"),
-			Desires:           s.getStringArray(personalityData, "desires"),
-		},
-		Background: models.Background{
-			Origin:         s.getString(backgroundData, "origin"),
-			DefiningEvents: s.getStringArray(backgroundData, "defining_events"),
-			HiddenSecrets:  s.getStringArray(backgroundData, "hidden_secrets"),
-		},
-		Motivations: models.Motivations{
-			ImmediateGoal: s.getString(motivationsData, "immediate_goal"),
-			LongTermGoal:  s.getString(motivationsData, "long_term_goal"),
-			CoreDrive:     s.getString(motivationsData, "core_drive"),
-		},
-	}
-
-	// 解析核心属性
-	coreAttributesData := charMap["core_attributes"].(map[string]interface{})
-	relationshipsData := coreAttributesData["relationships"].(map[string]interface{})
-
-	coreAttributes := models.CoreAttributes{
-		CultivationLevel: s.getString(coreAttributesData, "cultivation_level"),
-		CurrentItems:     s.getStringArray(coreAttributesData, "current_items"),
-		Abilities:        s.getStringArray(coreAttributesData, "abilities"),
-		Relationships: map[string][]string{
-			"enemies": s.getStringArray(relationshipsData, "enemies"),
-			"allies":  s.getStringArray(relationshipsData, "allies"),
-			"mentors": s.getStringArray(relationshipsData, "mentors"),
-		},
-	}
-
-	return models.Character{
-		Name:           s.getString(charMap, "name"),
-		Type:           s.getString(charMap, "type"),
-		SoulProfile:    soulProfile,
-		CoreAttributes: coreAttributes,
-	}
-}
-
 // PrepareChapterInputData 准备章节生成的输入数据（用于流式和非流式调用）
 func (s *NovelGenerationService) PrepareChapterInputData(ctx context.Context, novelID string, inputData map[string]interface{}) map[string]interface{} {
 	novelService := NewNovelService(s.client, s.dbName)
 	llmInputData := make(map[string]interface{})
 
 	// 获取故事核心
-	storyCores, err := novelService.GetStoryCores(ctx, novelID)
-	if err == nil && len(storyCores) > 0 {
-		storyCore := storyCores[0]
+	storyCore, err := novelService.GetSelectedStoryCore(ctx, novelID)
+	if err == nil {
 		llmInputData["novel_title"] = storyCore.Title
 		llmInputData["story_core"] = s.buildStoryCoreContent(storyCore)
 	} else {
@@ -500,6 +459,9 @@ func (s *NovelGenerationService) PrepareChapterInputData(ctx context.Context, no
 
 	if previousSummary, ok := inputData["previous_summary"]; ok {
 		llmInputData["previous_summary"] = previousSummary
+	} else if previous, err := novelService.GetPreviousChapter(ctx, novelID, s.getInt(inputData, "chapter_number")); err == nil {
+		// 未提供前情时使用上一章保存的概要、关键事件与角色变化（生成或导入分析时写入）
+		llmInputData["previous_summary"] = s.buildPreviousChapterContent(previous)
 	} else {
 		llmInputData["previous_summary"] = ""
 	}
@@ -525,7 +487,8 @@ func (s *NovelGenerationService) PrepareChapterInputData(ctx context.Context, no
 	// 处理当前故事弧线（如果有大纲）
 	if outlineID, ok := inputData["outline_id"].(string); ok && outlineID != "" {
 		outline, err := novelService.GetOutline(ctx, outlineID)
-		if err == nil {
+		// 仅使用属于当前小说的大纲，避免跨作品读取
+		if err == nil && outline.NovelID == novelID {
 			chapterNumber := s.getInt(inputData, "chapter_number")
 			currentArc := s.findCurrentArc(outline.StoryArcs, chapterNumber)
 			if currentArc != nil {
@@ -543,6 +506,31 @@ func (s *NovelGenerationService) PrepareChapterInputData(ctx context.Context, no
 	return llmInputData
 }
 
+// buildPreviousChapterContent 构建上一章的前情文本，章节没有概要时为空
+func (s *NovelGenerationService) buildPreviousChapterContent(chapter models.Chapter) string {
+	if strings.TrimSpace(chapter.Summary) == "" {
+		return ""
+	}
+
+	content := fmt.Sprintf("%s：%s\n", chapterDisplayTitle(chapter), chapter.Summary)
+	if len(chapter.Outline.KeyEvents) > 0 {
+		content += fmt.Sprintf("关键事件：%s\n", strings.Join(chapter.Outline.KeyEvents, "、"))
+	}
+	if len(chapter.CharacterDevelopment) > 0 {
+		names := make([]string, 0, len(chapter.CharacterDevelopment))
+		for name := range chapter.CharacterDevelopment {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		changes := make([]string, 0, len(names))
+		for _, name := range names {
+			changes = append(changes, name+"："+chapter.CharacterDevelopment[name])
+		}
+		content += fmt.Sprintf("角色变化：%s\n", strings.Join(changes, "；"))
+	}
+	return content
+}
+
 // buildChapterOutlineContent 构建章节大纲内容文本
 func (s *NovelGenerationService) buildChapterOutlineContent(charactersOutlineData interface{}) string {
 	if charactersOutlineData == nil {
@@ -706,17 +694,18 @@ func (s *NovelGenerationService) findCurrentArc(arcs []models.StoryArc, chapterN
 }
 
 // GenerateChapter 生成章节
-func (s *NovelGenerationService) GenerateChapter(ctx context.Context, novelID, llmModelID string, inputData map[string]interface{}) (models.Chapter, error) {
+func (s *NovelGenerationService) GenerateChapter(ctx context.Context, novelID, llmModelID string, inputData map[string]interface{}, opts models.GenerationOptions) (models.Chapter, error) {
 	// 处理章节大纲信息（characters_outline）
 	llmInputData := s.PrepareChapterInputData(ctx, novelID, inputData)
 	
 	// 构建输入数据
 	generationReq := models.GenerationRequest{
-		NovelID:      novelID,
-		LLMModelID:   llmModelID,
-		InputData:    llmInputData,
-		TemplateType: "chapter",
-		Stream:       false,
+		NovelID:           novelID,
+		LLMModelID:        llmModelID,
+		InputData:         llmInputData,
+		TemplateType:      "chapter",
+		Stream:            false,
+		GenerationOptions: opts,
 	}
 
 	// 调用LLM生成
@@ -730,48 +719,58 @@ func (s *NovelGenerationService) GenerateChapter(ctx context.Context, novelID, l
 		return models.Chapter{}, errors.New(response.Error)
 	}
 
-	// 解析响应数据
-	chapterData := response.Data
-
-	// 解析章节大纲
-	outline := models.ChapterOutline{
-		Goal:           s.getString(chapterData, "outline.goal"),
-		KeyEvents:      s.getStringArray(chapterData, "outline.key_events"),
-		DramaticPoints: s.getInt(chapterData, "outline.dramatic_points"),
+	chapter, err := s.saveChapter(ctx, novelID, opts.UserID, inputData, response)
+	if err != nil {
+		return models.Chapter{}, err
 	}
 
-	// 解析质量指标
-	qualityMetrics := models.QualityMetrics{
-		Score:            s.getInt(chapterData, "quality_metrics.score"),
-		Strengths:        s.getStringArray(chapterData, "quality_metrics.strengths"),
-		ImprovementAreas: s.getStringArray(chapterData, "quality_metrics.improvement_areas"),
+	// 自动审核，审核失败不影响已保存的章节
+	if opts.AutoReview {
+		review, err := NewChapterReviewService(s.client, s.dbName).AutoReviewChapter(ctx, chapter.ID, llmModelID, opts)
+		if err != nil {
+			log.Printf("Failed to review chapter %s: %v", chapter.ID, err)
+		} else {
+			chapter.QualityMetrics = qualityMetricsFromReview(review)
+		}
 	}
 
-	// 解析角色发展
-	characterDevelopment := s.parseCharacterDevelopment(chapterData)
+	return chapter, nil
+}
 
-	// 获取章节内容
-	content := s.getString(chapterData, "content")
+// saveChapter 解析生成结果并保存章节
+func (s *NovelGenerationService) saveChapter(ctx context.Context, novelID, userID string, inputData map[string]interface{}, response models.GenerationResponse) (models.Chapter, error) {
+	// 解析响应数据，章节序号由请求指定，字数由正文计算
+	var parsed models.Chapter
+	missing, err := common.DecodeLenient(response.Data, &parsed, append(serverFields, "chapter_number", "word_count", "active_version")...)
+	if err != nil {
+		return models.Chapter{}, fmt.Errorf("%w: %v", ErrGenerationParse, err)
+	}
+	s.reportMissingFields(novelID, "chapter", missing)
 
 	// 保存到数据库
 	novelService := NewNovelService(s.client, s.dbName)
-	chapter, err := novelService.PostChapters(ctx, novelID, s.getInt(inputData, "chapter_number"), s.getString(chapterData, "title"), content, s.getString(chapterData, "summary"), outline, qualityMetrics, characterDevelopment)
+	chapter, err := novelService.PostChapters(ctx, novelID, s.getInt(inputData, "chapter_number"), parsed.Title, parsed.Content, parsed.Summary, parsed.Outline, parsed.QualityMetrics, parsed.CharacterDevelopment, models.ChapterVersionSourceGeneration, userID)
 	if err != nil {
 		return models.Chapter{}, err
 	}
 
 	// 保存ExtraInfo
 	extraInfo := map[string]interface{}{
-		"generation_time": response.Data["generation_time"],
-		"token_count":     response.TokenCount,
-		"usage_count":     response.UsageCount,
-		"raw_response":    response.Data,
+		"generation_time":     response.Data["generation_time"],
+		"token_count":         response.TokenCount,
+		"usage_count":         response.UsageCount,
+		"served_llm_model_id": response.ServedModelID,
+		"raw_response":        response.Data,
+	}
+	if len(missing) > 0 {
+		extraInfo["missing_fields"] = missing
 	}
 	if err := novelService.UpdateNovelExtraInfo(ctx, novelID, "chapter", extraInfo); err != nil {
 		// 记录错误但不影响主流程
 		// log.Printf("Failed to update extra info: %v", err)
 	}
 
+	chapter.MissingFields = missing
 	return chapter, nil
 }
 
@@ -809,68 +808,26 @@ func (s *NovelGenerationService) getInt(data map[string]interface{}, key string)
 	return 0
 }
 
-func (s *NovelGenerationService) parseFactions(data map[string]interface{}) []models.Faction {
-	factions := []models.Faction{}
-	if factionsData, ok := data["society_structure.major_factions"]; ok {
-		if arr, ok := factionsData.([]interface{}); ok {
-			for _, v := range arr {
-				if factionMap, ok := v.(map[string]interface{}); ok {
-					faction := models.Faction{
-						Name:      s.getString(factionMap, "name"),
-						Type:      s.getString(factionMap, "type"),
-						Influence: s.getString(factionMap, "influence"),
-					}
-					factions = append(factions, faction)
-				}
-			}
-		}
-	}
-	return factions
-}
+// serverFields 由服务端填写的字段，不要求模型输出
+var serverFields = []string{"id", "novel_id", "selected", "version", "ctime", "mtime"}
 
-func (s *NovelGenerationService) parseRelationships(data map[string]interface{}) map[string][]string {
-	relationships := make(map[string][]string)
-	if relData, ok := data["core_attributes.relationships"]; ok {
-		if relMap, ok := relData.(map[string]interface{}); ok {
-			for key, val := range relMap {
-				if arr, ok := val.([]interface{}); ok {
-					result := make([]string, len(arr))
-					for i, v := range arr {
-						if str, ok := v.(string); ok {
-							result[i] = str
-						}
-					}
-					relationships[key] = result
-				}
-			}
-		}
+// reportMissingFields 记录生成结果中模型未输出的字段
+func (s *NovelGenerationService) reportMissingFields(novelID, templateType string, missing []string) {
+	if len(missing) > 0 {
+		log.Printf("Generated %s for novel %s is missing fields: %s", templateType, novelID, strings.Join(missing, ", "))
 	}
-	return relationships
-}
-
-func (s *NovelGenerationService) parseCharacterDevelopment(data map[string]interface{}) map[string]string {
-	development := make(map[string]string)
-	if devData, ok := data["character_development"]; ok {
-		if devMap, ok := devData.(map[string]interface{}); ok {
-			for key, val := range devMap {
-				if str, ok := val.(string); ok {
-					development[key] = str
-				}
-			}
-		}
-	}
-	return development
 }
 
 // GenerateOutline 生成大纲
-func (s *NovelGenerationService) GenerateOutline(ctx context.Context, novelID, llmModelID string, inputData map[string]interface{}) (models.Outline, error) {
+func (s *NovelGenerationService) GenerateOutline(ctx context.Context, novelID, llmModelID string, inputData map[string]interface{}, opts models.GenerationOptions) (models.Outline, error) {
 	// 构建输入数据
 	generationReq := models.GenerationRequest{
-		NovelID:      novelID,
-		LLMModelID:   llmModelID,
-		InputData:    inputData,
-		TemplateType: "outline",
-		Stream:       false,
+		NovelID:           novelID,
+		LLMModelID:        llmModelID,
+		InputData:         inputData,
+		TemplateType:      "outline",
+		Stream:            false,
+		GenerationOptions: opts,
 	}
 
 	// 调用LLM生成
@@ -884,27 +841,19 @@ func (s *NovelGenerationService) GenerateOutline(ctx context.Context, novelID, l
 		return models.Outline{}, errors.New(response.Error)
 	}
 
-	// 解析响应数据
-	outlineData := response.Data
-
-	// 解析章节信息
-	chapters := s.parseChapters(outlineData)
-
-	// 解析故事弧线
-	storyArcs := s.parseStoryArcs(outlineData)
-
-	// 解析关键主题
-	keyThemes := s.getStringArray(outlineData, "key_themes")
+	return s.saveOutline(ctx, novelID, inputData, response)
+}
 
-	// 构建大纲对象
-	outline := models.Outline{
-		NovelID:   novelID,
-		Title:     s.getString(outlineData, "title"),
-		Summary:   s.getString(outlineData, "summary"),
-		Chapters:  chapters,
-		StoryArcs: storyArcs,
-		KeyThemes: keyThemes,
+// saveOutline 解析生成结果并保存大纲
+func (s *NovelGenerationService) saveOutline(ctx context.Context, novelID string, inputData map[string]interface{}, response models.GenerationResponse) (models.Outline, error) {
+	// 解析响应数据
+	var outline models.Outline
+	missing, err := common.DecodeLenient(response.Data, &outline, serverFields...)
+	if err != nil {
+		return models.Outline{}, fmt.Errorf("%w: %v", ErrGenerationParse, err)
 	}
+	s.reportMissingFields(novelID, "outline", missing)
+	outline.NovelID = novelID
 
 	// 保存到数据库
 	novelService := NewNovelService(s.client, s.dbName)
@@ -915,67 +864,83 @@ func (s *NovelGenerationService) GenerateOutline(ctx context.Context, novelID, l
 
 	// 保存ExtraInfo
 	extraInfo := map[string]interface{}{
-		"generation_time": response.Data["generation_time"],
-		"token_count":     response.TokenCount,
-		"usage_count":     response.UsageCount,
-		"raw_response":    response.Data,
+		"generation_time":     response.Data["generation_time"],
+		"token_count":         response.TokenCount,
+		"usage_count":         response.UsageCount,
+		"served_llm_model_id": response.ServedModelID,
+		"raw_response":        response.Data,
+	}
+	if len(missing) > 0 {
+		extraInfo["missing_fields"] = missing
 	}
 	if err := novelService.UpdateNovelExtraInfo(ctx, novelID, "outline", extraInfo); err != nil {
 		// 记录错误但不影响主流程
 		// log.Printf("Failed to update extra info: %v", err)
 	}
 
+	outline.MissingFields = missing
 	return outline, nil
 }
 
-// parseChapters 解析章节信息
-func (s *NovelGenerationService) parseChapters(data map[string]interface{}) []models.ChapterInfo {
-	chapters := []models.ChapterInfo{}
-	if chaptersData, ok := data["chapters"]; ok {
-		if arr, ok := chaptersData.([]interface{}); ok {
-			for _, v := range arr {
-				if chapterMap, ok := v.(map[string]interface{}); ok {
-					chapter := models.ChapterInfo{
-						ChapterNumber: s.getInt(chapterMap, "chapter_number"),
-						Title:         s.getString(chapterMap, "title"),
-						Summary:       s.getString(chapterMap, "summary"),
-						KeyEvents:     s.getStringArray(chapterMap, "key_events"),
-						Characters:    s.getStringArray(chapterMap, "characters"),
-						Location:      s.getString(chapterMap, "location"),
-						POV:           s.getString(chapterMap, "pov"),
-						WordCount:     s.getInt(chapterMap, "word_count"),
-						Outline: models.ChapterOutline{
-							Goal:           s.getString(chapterMap, "outline.goal"),
-							KeyEvents:      s.getStringArray(chapterMap, "outline.key_events"),
-							DramaticPoints: s.getInt(chapterMap, "outline.dramatic_points"),
-						},
-					}
-					chapters = append(chapters, chapter)
-				}
-			}
-		}
-	}
-	return chapters
+// ErrGenerationParse 生成结果无法解析为目标数据结构
+var ErrGenerationParse = errors.New("failed to parse generation output")
+
+// StreamResult 流式生成完成后保存的文档
+type StreamResult struct {
+	TemplateType string      `json:"template_type"`
+	ID           string      `json:"id"`
+	IDs          []string    `json:"ids,omitempty"` // 批量生成角色时的全部ID
+	Document     interface{} `json:"document"`
 }
 
-// parseStoryArcs 解析故事弧线
-func (s *NovelGenerationService) parseStoryArcs(data map[string]interface{}) []models.StoryArc {
-	arcs := []models.StoryArc{}
-	if arcsData, ok := data["story_arcs"]; ok {
-		if arr, ok := arcsData.([]interface{}); ok {
-			for _, v := range arr {
-				if arcMap, ok := v.(map[string]interface{}); ok {
-					arc := models.StoryArc{
-						Name:         s.getString(arcMap, "name"),
-						Description:  s.getString(arcMap, "description"),
-						StartChapter: s.getInt(arcMap, "start_chapter"),
-						EndChapter:   s.getInt(arcMap, "end_chapter"),
-						Theme:        s.getString(arcMap, "theme"),
-					}
-					arcs = append(arcs, arc)
-				}
-			}
+// SaveStreamedGeneration 按同步生成相同的逻辑保存流式生成的结构化结果（由 PromptTemplateService.ParseGenerationOutput 解析）。
+// inputData 为调用方的原始输入（如章节的 chapter_number），userID 为发起生成的用户。
+func (s *NovelGenerationService) SaveStreamedGeneration(ctx context.Context, templateType, novelID, userID string, inputData, data map[string]interface{}, servedModelID string) (result StreamResult, err error) {
+	response := models.GenerationResponse{
+		Success:       true,
+		Data:          data,
+		ServedModelID: servedModelID,
+	}
+
+	result = StreamResult{TemplateType: templateType}
+	switch templateType {
+	case "story_core":
+		var doc models.StoryCore
+		doc, err = s.saveStoryCore(ctx, novelID, inputData, response)
+		result.ID, result.Document = doc.ID, doc
+	case "worldview":
+		var doc models.Worldview
+		doc, err = s.saveWorldview(ctx, novelID, inputData, response)
+		result.ID, result.Document = doc.ID, doc
+	case "character":
+		var doc models.Character
+		doc, err = s.saveCharacter(ctx, novelID, inputData, response)
+		result.ID, result.Document = doc.ID, doc
+	case "batch_character":
+		var docs []models.Character
+		docs, err = s.saveCharactersFromOutline(ctx, novelID, s.getString(inputData, "outline_id"), response)
+		if err == nil && len(docs) == 0 {
+			err = fmt.Errorf("%w: no characters generated", ErrGenerationParse)
+		}
+		for _, doc := range docs {
+			result.IDs = append(result.IDs, doc.ID)
 		}
+		if len(docs) > 0 {
+			result.ID, result.Document = docs[0].ID, docs
+		}
+	case "outline":
+		var doc models.Outline
+		doc, err = s.saveOutline(ctx, novelID, inputData, response)
+		result.ID, result.Document = doc.ID, doc
+	case "chapter":
+		var doc models.Chapter
+		doc, err = s.saveChapter(ctx, novelID, userID, inputData, response)
+		result.ID, result.Document = doc.ID, doc
+	default:
+		err = fmt.Errorf("unsupported template type: %s", templateType)
+	}
+	if err != nil {
+		return StreamResult{}, err
 	}
-	return arcs
+	return result, nil
 }
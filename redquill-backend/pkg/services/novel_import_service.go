@@ -0,0 +1,284 @@
+// Package services
+/*
+/@Author: urmsone urmsone@163.com
+/@Date: 2025/10/20 20:44
+/@Name: novel_import_service.go
+/@Description: Manuscript import and chapter analysis service implementation
+/*/
+
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"redquill-backend/pkg/common"
+	"redquill-backend/pkg/models"
+	"redquill-backend/pkg/utils/manuscript"
+)
+
+// ErrInvalidManuscript 稿件格式不支持、文件损坏或没有识别出章节
+var ErrInvalidManuscript = errors.New("invalid manuscript")
+
+// ImportOptions 稿件导入选项
+type ImportOptions struct {
+	Format       string // txt|md|epub
+	StartChapter int    // 第一章使用的章节序号，0 表示接在已有章节之后；序号已存在的章节会被覆盖为新版本
+}
+
+// NovelImportService 稿件导入服务
+type NovelImportService struct {
+	client *mongo.Client
+	dbName string
+}
+
+// NewNovelImportService 创建稿件导入服务
+func NewNovelImportService(client *mongo.Client, dbName string) *NovelImportService {
+	return &NovelImportService{
+		client: client,
+		dbName: dbName,
+	}
+}
+
+// ImportManuscript 将稿件拆分为章节并按顺序保存，每章记录为来源为 import 的版本
+func (s *NovelImportService) ImportManuscript(ctx context.Context, novelID, userID string, data []byte, opts ImportOptions) (models.ImportResult, error) {
+	parsed, err := manuscript.Parse(opts.Format, data)
+	if err != nil {
+		return models.ImportResult{}, fmt.Errorf("%w: %v", ErrInvalidManuscript, err)
+	}
+
+	start := opts.StartChapter
+	if start <= 0 {
+		last, err := s.lastChapterNumber(ctx, novelID)
+		if err != nil {
+			return models.ImportResult{}, err
+		}
+		start = last + 1
+	}
+
+	novelService := NewNovelService(s.client, s.dbName)
+	result := models.ImportResult{
+		NovelID:  novelID,
+		Format:   opts.Format,
+		Chapters: make([]models.ImportedChapter, 0, len(parsed)),
+	}
+	for i, item := range parsed {
+		chapter, err := novelService.PostChapters(ctx, novelID, start+i, item.Title, item.Content, item.Summary,
+			models.ChapterOutline{}, models.QualityMetrics{}, nil, models.ChapterVersionSourceImport, userID)
+		if err != nil {
+			return result, fmt.Errorf("import chapter %d: %w", start+i, err)
+		}
+		result.Chapters = append(result.Chapters, models.ImportedChapter{
+			ID:            chapter.ID,
+			ChapterNumber: chapter.ChapterNumber,
+			Title:         chapter.Title,
+			WordCount:     chapter.WordCount,
+			HasSummary:    chapter.Summary != "",
+		})
+	}
+
+	return result, nil
+}
+
+// lastChapterNumber 小说当前最大的章节序号，没有章节时为0
+func (s *NovelImportService) lastChapterNumber(ctx context.Context, novelID string) (int, error) {
+	coll := s.client.Database(s.dbName).Collection("chapters")
+	var chapter models.Chapter
+	err := coll.FindOne(ctx, bson.M{"novel_id": novelID}, options.FindOne().
+		SetSort(bson.M{"chapter_number": -1}).
+		SetProjection(bson.M{"chapter_number": 1})).Decode(&chapter)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, nil
+	}
+	return chapter.ChapterNumber, err
+}
+
+// AnalyzeChapters 按章节序号依次用 chapter_analysis 模板分析章节，填充概要、关键事件与角色变化，
+// 使续写时 PrepareChapterInputData 能取得前文上下文。chapterIDs 为空时分析小说中所有还没有概要的章节。
+// 单章失败不影响其他章节，全部失败时返回第一个错误；checkQuota 不为空时每章之前调用，返回错误则停止分析
+func (s *NovelImportService) AnalyzeChapters(ctx context.Context, novelID string, chapterIDs []string, llmModelID string, opts models.GenerationOptions, checkQuota func(context.Context) error) (models.ChapterAnalysisResult, error) {
+	coll := s.client.Database(s.dbName).Collection("chapters")
+
+	filter := bson.M{"novel_id": novelID}
+	if len(chapterIDs) > 0 {
+		oids := make([]primitive.ObjectID, 0, len(chapterIDs))
+		for _, id := range chapterIDs {
+			oid, err := primitive.ObjectIDFromHex(id)
+			if err != nil {
+				return models.ChapterAnalysisResult{}, errors.New("invalid id")
+			}
+			oids = append(oids, oid)
+		}
+		filter["_id"] = bson.M{"$in": oids}
+	} else {
+		filter["summary"] = bson.M{"$in": bson.A{"", nil}}
+	}
+	cursor, err := coll.Find(ctx, filter, options.Find().
+		SetSort(bson.M{"chapter_number": 1}).
+		SetProjection(bson.M{"_id": 1, "chapter_number": 1}))
+	if err != nil {
+		return models.ChapterAnalysisResult{}, err
+	}
+	var targets []models.Chapter
+	if err := cursor.All(ctx, &targets); err != nil {
+		return models.ChapterAnalysisResult{}, err
+	}
+
+	// 全书共用的上下文
+	novelService := NewNovelService(s.client, s.dbName)
+	baseInput := map[string]interface{}{"novel_title": "", "story_core": ""}
+	if novel, err := novelService.GetNovels(ctx, novelID); err == nil {
+		baseInput["novel_title"] = novel.Title
+	}
+	if storyCore, err := novelService.GetSelectedStoryCore(ctx, novelID); err == nil {
+		baseInput["story_core"] = NewNovelGenerationService(s.client, s.dbName).buildStoryCoreContent(storyCore)
+	}
+
+	var result models.ChapterAnalysisResult
+	var firstErr error
+	for i, target := range targets {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		// 配额用尽时停止，剩余章节记为失败
+		if checkQuota != nil {
+			if err := checkQuota(ctx); err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				for _, rest := range targets[i:] {
+					result.Failed = append(result.Failed, models.ChapterAnalysisFailure{
+						ChapterID:     rest.ID,
+						ChapterNumber: rest.ChapterNumber,
+						Error:         err.Error(),
+					})
+				}
+				break
+			}
+		}
+		// 逐章重新读取，前一章刚写入的概要可作为本章的上下文
+		if err := s.analyzeChapter(ctx, target.ID, baseInput, llmModelID, opts); err != nil {
+			if ctx.Err() != nil {
+				return result, ctx.Err()
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+			result.Failed = append(result.Failed, models.ChapterAnalysisFailure{
+				ChapterID:     target.ID,
+				ChapterNumber: target.ChapterNumber,
+				Error:         err.Error(),
+			})
+			continue
+		}
+		result.Analyzed++
+	}
+
+	if result.Analyzed == 0 && firstErr != nil {
+		return result, firstErr
+	}
+	return result, nil
+}
+
+// analyzeChapter 分析一章并保存结果；概要同时写入当前版本，回滚到该版本时保持一致
+func (s *NovelImportService) analyzeChapter(ctx context.Context, chapterID string, baseInput map[string]interface{}, llmModelID string, opts models.GenerationOptions) error {
+	novelService := NewNovelService(s.client, s.dbName)
+	chapter, err := novelService.GetChapter(ctx, chapterID)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(chapter.Content) == "" {
+		return errors.New("chapter has no content to analyze")
+	}
+
+	inputData := make(map[string]interface{}, len(baseInput)+3)
+	for k, v := range baseInput {
+		inputData[k] = v
+	}
+	inputData["chapter_title"] = chapterDisplayTitle(chapter)
+	inputData["chapter_content"] = chapter.Content
+	inputData["previous_summary"] = ""
+	if previous, err := novelService.GetPreviousChapter(ctx, chapter.NovelID, chapter.ChapterNumber); err == nil {
+		inputData["previous_summary"] = previous.Summary
+	}
+
+	response, err := NewPromptTemplateService(s.client, s.dbName).GenerateWithLLM(ctx, models.GenerationRequest{
+		NovelID:           chapter.NovelID,
+		LLMModelID:        llmModelID,
+		InputData:         inputData,
+		TemplateType:      "chapter_analysis",
+		GenerationOptions: opts,
+	})
+	if err != nil {
+		return err
+	}
+	if !response.Success {
+		return errors.New(response.Error)
+	}
+
+	var analysis models.ChapterAnalysis
+	if _, err := common.DecodeLenient(response.Data, &analysis); err != nil {
+		return fmt.Errorf("%w: %v", ErrGenerationParse, err)
+	}
+	if strings.TrimSpace(analysis.Summary) == "" {
+		return fmt.Errorf("%w: summary is empty", ErrGenerationParse)
+	}
+	if analysis.KeyEvents == nil {
+		analysis.KeyEvents = []string{}
+	}
+	if analysis.CharacterDevelopment == nil {
+		analysis.CharacterDevelopment = map[string]string{}
+	}
+
+	set := bson.M{
+		"summary":               strings.TrimSpace(analysis.Summary),
+		"outline.key_events":    analysis.KeyEvents,
+		"character_development": analysis.CharacterDevelopment,
+		"mtime":                 time.Now().Unix(),
+	}
+	if goal := strings.TrimSpace(analysis.Goal); goal != "" {
+		set["outline.goal"] = goal
+	}
+	oid, err := primitive.ObjectIDFromHex(chapter.ID)
+	if err != nil {
+		return errors.New("invalid id")
+	}
+	db := s.client.Database(s.dbName)
+	if _, err := db.Collection("chapters").UpdateOne(ctx, bson.M{"_id": oid}, bson.M{"$set": set}); err != nil {
+		return err
+	}
+	_, err = db.Collection("chapter_versions").UpdateOne(ctx,
+		bson.M{"chapter_id": chapter.ID, "version": chapter.ActiveVersion},
+		bson.M{"$set": bson.M{"summary": set["summary"]}})
+	return err
+}
+
+// stringList 将任务输入中的数组（JSON 或 BSON 解码结果）转换为字符串切片
+func stringList(value interface{}) []string {
+	var items []interface{}
+	switch v := value.(type) {
+	case []string:
+		return v
+	case []interface{}:
+		items = v
+	case primitive.A:
+		items = v
+	default:
+		return nil
+	}
+	result := make([]string, 0, len(items))
+	for _, item := range items {
+		if str, ok := item.(string); ok && str != "" {
+			result = append(result, str)
+		}
+	}
+	return result
+}
@@ -22,6 +22,17 @@ import (
 	"redquill-backend/pkg/models"
 )
 
+var (
+	// ErrNovelNotFound 小说不存在
+	ErrNovelNotFound = errors.New("novel not found")
+	// ErrNovelForbidden 无权访问该小说
+	ErrNovelForbidden = errors.New("no permission to access this novel")
+	// ErrVersionConflict 文档已被其他请求修改（If-Match 版本不一致）
+	ErrVersionConflict = errors.New("document has been modified, reload and retry")
+	// ErrChapterNumberTaken 小说中已存在该章节序号
+	ErrChapterNumberTaken = errors.New("chapter number already exists in this novel")
+)
+
 // NovelService 小说服务
 type NovelService struct {
 	client *mongo.Client
@@ -37,7 +48,7 @@ func NewNovelService(client *mongo.Client, dbName string) *NovelService {
 }
 
 // PostNovels 创建小说
-func (s *NovelService) PostNovels(ctx context.Context, title, authorID, status, currentPhase string, blueprint models.ProjectBlueprint, aiContext models.AIContext) (models.Novel, error) {
+func (s *NovelService) PostNovels(ctx context.Context, title, authorID, status, currentPhase string, shared bool, blueprint models.ProjectBlueprint, aiContext models.AIContext) (models.Novel, error) {
 	coll := s.client.Database(s.dbName).Collection("novels")
 
 	now := time.Now()
@@ -46,6 +57,7 @@ func (s *NovelService) PostNovels(ctx context.Context, title, authorID, status,
 		AuthorID:         authorID,
 		Status:           status,
 		CurrentPhase:     currentPhase,
+		Shared:           shared,
 		Ctime:            now.Unix(),
 		Mtime:            now.Unix(),
 		ProjectBlueprint: blueprint,
@@ -81,8 +93,83 @@ func (s *NovelService) GetNovels(ctx context.Context, id string) (models.Novel,
 	return novel, nil
 }
 
+// AuthorizeNovel 校验用户对小说的访问权限，返回小说详情
+// 管理员可访问全部作品；作者可读写自己的作品；共享作品对其他用户只读
+func (s *NovelService) AuthorizeNovel(ctx context.Context, novelID, userID, role string, write bool) (models.Novel, error) {
+	if _, err := primitive.ObjectIDFromHex(novelID); err != nil {
+		return models.Novel{}, ErrNovelNotFound
+	}
+
+	novel, err := s.GetNovels(ctx, novelID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return models.Novel{}, ErrNovelNotFound
+		}
+		return models.Novel{}, err
+	}
+
+	// 回收站中的小说及其内容不可访问，只能恢复
+	if novel.DeletedAt != 0 {
+		return models.Novel{}, ErrNovelNotFound
+	}
+	if err := checkNovelAccess(novel, userID, role, write); err != nil {
+		return models.Novel{}, err
+	}
+
+	return novel, nil
+}
+
+// checkNovelAccess 管理员可访问全部作品；作者可读写自己的作品；共享作品对其他用户只读
+func checkNovelAccess(novel models.Novel, userID, role string, write bool) error {
+	switch {
+	case role == models.RoleAdmin:
+	case novel.AuthorID == userID && role != models.RoleViewer:
+	case novel.Shared && !write:
+	default:
+		return ErrNovelForbidden
+	}
+	return nil
+}
+
+// novelAccessFilter 构建用户可见小说的过滤条件（不含回收站中的小说）
+func novelAccessFilter(userID, role string) bson.M {
+	notDeleted := bson.M{"$exists": false}
+	switch role {
+	case models.RoleAdmin:
+		return bson.M{"deleted_at": notDeleted}
+	case models.RoleViewer:
+		return bson.M{"shared": true, "deleted_at": notDeleted}
+	default:
+		return bson.M{"author_id": userID, "deleted_at": notDeleted}
+	}
+}
+
+// listAccessibleNovelIDs 获取用户可见的全部小说ID
+func (s *NovelService) listAccessibleNovelIDs(ctx context.Context, userID, role string) ([]string, error) {
+	coll := s.client.Database(s.dbName).Collection("novels")
+
+	cursor, err := coll.Find(ctx, novelAccessFilter(userID, role), options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	ids := []string{}
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		ids = append(ids, doc.ID.Hex())
+	}
+
+	return ids, cursor.Err()
+}
+
 // PutNovels 更新小说
-func (s *NovelService) PutNovels(ctx context.Context, id string, title *string, status *string, currentPhase *string, blueprint *models.ProjectBlueprint, aiContext *models.AIContext, extraInfo *map[string]interface{}) (models.Novel, error) {
+func (s *NovelService) PutNovels(ctx context.Context, id string, title *string, status *string, currentPhase *string, shared *bool, blueprint *models.ProjectBlueprint, aiContext *models.AIContext, extraInfo *map[string]interface{}) (models.Novel, error) {
 	coll := s.client.Database(s.dbName).Collection("novels")
 	oid, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
@@ -101,6 +188,9 @@ func (s *NovelService) PutNovels(ctx context.Context, id string, title *string,
 	if currentPhase != nil {
 		set["current_phase"] = *currentPhase
 	}
+	if shared != nil {
+		set["shared"] = *shared
+	}
 	if blueprint != nil {
 		set["project_blueprint"] = *blueprint
 	}
@@ -164,25 +254,14 @@ func (s *NovelService) GetNovelExtraInfo(ctx context.Context, id string, phase s
 	return novel.ExtraInfo[phase], nil
 }
 
-// DeleteNovels 删除小说
-func (s *NovelService) DeleteNovels(ctx context.Context, id string) error {
-	coll := s.client.Database(s.dbName).Collection("novels")
-	oid, err := primitive.ObjectIDFromHex(id)
-	if err != nil {
-		return errors.New("invalid id")
-	}
-
-	_, err = coll.DeleteOne(ctx, bson.M{"_id": oid})
-	return err
-}
 
-// ListNovels 分页查询小说列表
-func (s *NovelService) ListNovels(ctx context.Context, page, pageSize int64, sortExpr, keyword string) (PagedNovels, error) {
+// ListNovels 分页查询用户可见的小说列表
+func (s *NovelService) ListNovels(ctx context.Context, userID, role string, page, pageSize int64, sortExpr, keyword string) (PagedNovels, error) {
 	coll := s.client.Database(s.dbName).Collection("novels")
 
 	// 构建过滤条件
 	kwFilter := common.BuildKeywordFilter(keyword, []string{"title", "project_blueprint.genre", "project_blueprint.core_conflict"})
-	filter := common.MergeFilters(bson.M{}, kwFilter)
+	filter := common.MergeFilters(novelAccessFilter(userID, role), kwFilter)
 
 	// 构建选项
 	sort := common.BuildSort(sortExpr)
@@ -247,7 +326,7 @@ func (s *NovelService) PostStoryCores(ctx context.Context, novelID, title, coreC
 func (s *NovelService) GetStoryCores(ctx context.Context, novelID string) ([]models.StoryCore, error) {
 	coll := s.client.Database(s.dbName).Collection("story_cores")
 
-	cursor, err := coll.Find(ctx, bson.M{"novel_id": novelID})
+	cursor, err := coll.Find(ctx, bson.M{"novel_id": novelID}, options.Find().SetSort(bson.D{{Key: "ctime", Value: 1}, {Key: "_id", Value: 1}}))
 	if err != nil {
 		return nil, err
 	}
@@ -287,18 +366,34 @@ func (s *NovelService) PostWorldviews(ctx context.Context, novelID string, power
 	return worldview, nil
 }
 
-// GetWorldviews 获取世界观
+// GetWorldviews 获取小说采用的世界观，未选择时返回最早创建的世界观
 func (s *NovelService) GetWorldviews(ctx context.Context, novelID string) (models.Worldview, error) {
-	coll := s.client.Database(s.dbName).Collection("worldviews")
-
 	var worldview models.Worldview
-	if err := coll.FindOne(ctx, bson.M{"novel_id": novelID}).Decode(&worldview); err != nil {
+	if err := s.findSelected(ctx, "worldviews", novelID, &worldview); err != nil {
 		return models.Worldview{}, err
 	}
 
 	return worldview, nil
 }
 
+// ListWorldviews 获取小说的全部世界观
+func (s *NovelService) ListWorldviews(ctx context.Context, novelID string) ([]models.Worldview, error) {
+	coll := s.client.Database(s.dbName).Collection("worldviews")
+
+	cursor, err := coll.Find(ctx, bson.M{"novel_id": novelID}, options.Find().SetSort(bson.D{{Key: "ctime", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	worldviews := []models.Worldview{}
+	if err := cursor.All(ctx, &worldviews); err != nil {
+		return nil, err
+	}
+
+	return worldviews, nil
+}
+
 // PostCharacters 创建角色
 func (s *NovelService) PostCharacters(ctx context.Context, novelID, name, charType string, coreAttributes models.CoreAttributes, soulProfile models.SoulProfile) (models.Character, error) {
 	coll := s.client.Database(s.dbName).Collection("characters")
@@ -344,8 +439,292 @@ func (s *NovelService) GetCharacters(ctx context.Context, novelID string) ([]mod
 	return characters, nil
 }
 
-// PostChapters 创建章节
-func (s *NovelService) PostChapters(ctx context.Context, novelID string, chapterNumber int, title, content, summary string, outline models.ChapterOutline, qualityMetrics models.QualityMetrics, characterDevelopment map[string]string) (models.Chapter, error) {
+// GetSelectedStoryCore 获取小说采用的故事核心，未选择时返回最早创建的故事核心
+func (s *NovelService) GetSelectedStoryCore(ctx context.Context, novelID string) (models.StoryCore, error) {
+	var storyCore models.StoryCore
+	if err := s.findSelected(ctx, "story_cores", novelID, &storyCore); err != nil {
+		return models.StoryCore{}, err
+	}
+
+	return storyCore, nil
+}
+
+// findSelected 查找小说中 selected 为 true 的文档，没有时取最早创建的文档
+func (s *NovelService) findSelected(ctx context.Context, collection, novelID string, out interface{}) error {
+	coll := s.client.Database(s.dbName).Collection(collection)
+
+	opts := options.FindOne().SetSort(bson.D{{Key: "selected", Value: -1}, {Key: "ctime", Value: 1}, {Key: "_id", Value: 1}})
+	return coll.FindOne(ctx, bson.M{"novel_id": novelID}, opts).Decode(out)
+}
+
+// GetStoryCore 获取单个故事核心
+func (s *NovelService) GetStoryCore(ctx context.Context, id string) (models.StoryCore, error) {
+	var storyCore models.StoryCore
+	if err := s.findByID(ctx, "story_cores", id, &storyCore); err != nil {
+		return models.StoryCore{}, err
+	}
+	return storyCore, nil
+}
+
+// PutStoryCores 更新故事核心
+func (s *NovelService) PutStoryCores(ctx context.Context, id string, expectedVersion *int, title, coreConflict, theme, innovation, commercialPotential, targetAudience *string) (models.StoryCore, error) {
+	set := bson.M{}
+	if title != nil {
+		set["title"] = *title
+	}
+	if coreConflict != nil {
+		set["core_conflict"] = *coreConflict
+	}
+	if theme != nil {
+		set["theme"] = *theme
+	}
+	if innovation != nil {
+		set["innovation"] = *innovation
+	}
+	if commercialPotential != nil {
+		set["commercial_potential"] = *commercialPotential
+	}
+	if targetAudience != nil {
+		set["target_audience"] = *targetAudience
+	}
+
+	var out models.StoryCore
+	if err := s.updateVersioned(ctx, "story_cores", id, expectedVersion, set, nil, &out); err != nil {
+		return models.StoryCore{}, err
+	}
+	return out, nil
+}
+
+// PatchStoryCores 按 JSON Merge Patch 局部更新故事核心
+func (s *NovelService) PatchStoryCores(ctx context.Context, id string, expectedVersion *int, patch map[string]interface{}) (models.StoryCore, error) {
+	set, unset, err := common.MergePatchUpdate(patch, models.StoryCore{}, readOnlyFields...)
+	if err != nil {
+		return models.StoryCore{}, err
+	}
+
+	var out models.StoryCore
+	if err := s.updateVersioned(ctx, "story_cores", id, expectedVersion, set, unset, &out); err != nil {
+		return models.StoryCore{}, err
+	}
+	return out, nil
+}
+
+// DeleteStoryCores 删除故事核心
+func (s *NovelService) DeleteStoryCores(ctx context.Context, id string) error {
+	return s.deleteByID(ctx, "story_cores", id)
+}
+
+// SelectStoryCore 将故事核心设为小说采用的故事核心，同一小说的其他故事核心取消选择
+func (s *NovelService) SelectStoryCore(ctx context.Context, id string) (models.StoryCore, error) {
+	var out models.StoryCore
+	if err := s.selectDocument(ctx, "story_cores", id, &out); err != nil {
+		return models.StoryCore{}, err
+	}
+	return out, nil
+}
+
+// GetWorldview 获取单个世界观
+func (s *NovelService) GetWorldview(ctx context.Context, id string) (models.Worldview, error) {
+	var worldview models.Worldview
+	if err := s.findByID(ctx, "worldviews", id, &worldview); err != nil {
+		return models.Worldview{}, err
+	}
+	return worldview, nil
+}
+
+// PutWorldviews 更新世界观
+func (s *NovelService) PutWorldviews(ctx context.Context, id string, expectedVersion *int, powerSystem *models.PowerSystem, societyStructure *models.SocietyStructure, geography *models.Geography, specialRules *[]string) (models.Worldview, error) {
+	set := bson.M{}
+	if powerSystem != nil {
+		set["power_system"] = *powerSystem
+	}
+	if societyStructure != nil {
+		set["society_structure"] = *societyStructure
+	}
+	if geography != nil {
+		set["geography"] = *geography
+	}
+	if specialRules != nil {
+		set["special_rules"] = *specialRules
+	}
+
+	var out models.Worldview
+	if err := s.updateVersioned(ctx, "worldviews", id, expectedVersion, set, nil, &out); err != nil {
+		return models.Worldview{}, err
+	}
+	return out, nil
+}
+
+// PatchWorldviews 按 JSON Merge Patch 局部更新世界观
+func (s *NovelService) PatchWorldviews(ctx context.Context, id string, expectedVersion *int, patch map[string]interface{}) (models.Worldview, error) {
+	set, unset, err := common.MergePatchUpdate(patch, models.Worldview{}, readOnlyFields...)
+	if err != nil {
+		return models.Worldview{}, err
+	}
+
+	var out models.Worldview
+	if err := s.updateVersioned(ctx, "worldviews", id, expectedVersion, set, unset, &out); err != nil {
+		return models.Worldview{}, err
+	}
+	return out, nil
+}
+
+// DeleteWorldviews 删除世界观
+func (s *NovelService) DeleteWorldviews(ctx context.Context, id string) error {
+	return s.deleteByID(ctx, "worldviews", id)
+}
+
+// SelectWorldview 将世界观设为小说采用的世界观，同一小说的其他世界观取消选择
+func (s *NovelService) SelectWorldview(ctx context.Context, id string) (models.Worldview, error) {
+	var out models.Worldview
+	if err := s.selectDocument(ctx, "worldviews", id, &out); err != nil {
+		return models.Worldview{}, err
+	}
+	return out, nil
+}
+
+// GetCharacter 获取单个角色
+func (s *NovelService) GetCharacter(ctx context.Context, id string) (models.Character, error) {
+	var character models.Character
+	if err := s.findByID(ctx, "characters", id, &character); err != nil {
+		return models.Character{}, err
+	}
+	return character, nil
+}
+
+// PutCharacters 更新角色
+func (s *NovelService) PutCharacters(ctx context.Context, id string, expectedVersion *int, name, charType *string, coreAttributes *models.CoreAttributes, soulProfile *models.SoulProfile, growthTrack *[]models.GrowthEvent) (models.Character, error) {
+	set := bson.M{}
+	if name != nil {
+		set["name"] = *name
+	}
+	if charType != nil {
+		set["type"] = *charType
+	}
+	if coreAttributes != nil {
+		set["core_attributes"] = *coreAttributes
+	}
+	if soulProfile != nil {
+		set["soul_profile"] = *soulProfile
+	}
+	if growthTrack != nil {
+		set["growth_track"] = *growthTrack
+	}
+
+	var out models.Character
+	if err := s.updateVersioned(ctx, "characters", id, expectedVersion, set, nil, &out); err != nil {
+		return models.Character{}, err
+	}
+	return out, nil
+}
+
+// PatchCharacters 按 JSON Merge Patch 局部更新角色，例如只修改 soul_profile.background.origin
+func (s *NovelService) PatchCharacters(ctx context.Context, id string, expectedVersion *int, patch map[string]interface{}) (models.Character, error) {
+	set, unset, err := common.MergePatchUpdate(patch, models.Character{}, append(readOnlyFields, "updated_at")...)
+	if err != nil {
+		return models.Character{}, err
+	}
+
+	var out models.Character
+	if err := s.updateVersioned(ctx, "characters", id, expectedVersion, set, unset, &out); err != nil {
+		return models.Character{}, err
+	}
+	return out, nil
+}
+
+// DeleteCharacters 删除角色
+func (s *NovelService) DeleteCharacters(ctx context.Context, id string) error {
+	return s.deleteByID(ctx, "characters", id)
+}
+
+// readOnlyFields 不允许通过 PATCH 修改的字段
+var readOnlyFields = []string{"id", "novel_id", "selected", "version", "ctime", "mtime"}
+
+// findByID 按ID查找小说下的文档
+func (s *NovelService) findByID(ctx context.Context, collection, id string, out interface{}) error {
+	coll := s.client.Database(s.dbName).Collection(collection)
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.New("invalid id")
+	}
+
+	return coll.FindOne(ctx, bson.M{"_id": oid}).Decode(out)
+}
+
+// deleteByID 按ID删除小说下的文档
+func (s *NovelService) deleteByID(ctx context.Context, collection, id string) error {
+	coll := s.client.Database(s.dbName).Collection(collection)
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.New("invalid id")
+	}
+
+	_, err = coll.DeleteOne(ctx, bson.M{"_id": oid})
+	return err
+}
+
+// updateVersioned 更新文档并递增 version；expectedVersion 不为空时只在版本一致时更新，否则返回 ErrVersionConflict
+func (s *NovelService) updateVersioned(ctx context.Context, collection, id string, expectedVersion *int, set, unset bson.M, out interface{}) error {
+	coll := s.client.Database(s.dbName).Collection(collection)
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.New("invalid id")
+	}
+
+	filter := bson.M{"_id": oid}
+	if expectedVersion != nil {
+		if *expectedVersion == 0 {
+			// 启用版本号之前创建的文档没有 version 字段
+			filter["version"] = bson.M{"$in": bson.A{0, nil}}
+		} else {
+			filter["version"] = *expectedVersion
+		}
+	}
+
+	if set == nil {
+		set = bson.M{}
+	}
+	set["mtime"] = time.Now().Unix()
+	update := bson.M{"$set": set, "$inc": bson.M{"version": 1}}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err = coll.FindOneAndUpdate(ctx, filter, update, opts).Decode(out)
+	if errors.Is(err, mongo.ErrNoDocuments) && expectedVersion != nil {
+		if n, countErr := coll.CountDocuments(ctx, bson.M{"_id": oid}); countErr == nil && n > 0 {
+			return ErrVersionConflict
+		}
+	}
+	return err
+}
+
+// selectDocument 选中文档并取消同一小说其他文档的选中状态
+func (s *NovelService) selectDocument(ctx context.Context, collection, id string, out interface{}) error {
+	coll := s.client.Database(s.dbName).Collection(collection)
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.New("invalid id")
+	}
+
+	var doc struct {
+		NovelID string `bson:"novel_id"`
+	}
+	if err := coll.FindOne(ctx, bson.M{"_id": oid}).Decode(&doc); err != nil {
+		return err
+	}
+	if _, err := coll.UpdateMany(ctx, bson.M{"novel_id": doc.NovelID, "_id": bson.M{"$ne": oid}, "selected": true}, bson.M{"$set": bson.M{"selected": false}}); err != nil {
+		return err
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	return coll.FindOneAndUpdate(ctx, bson.M{"_id": oid}, bson.M{"$set": bson.M{"selected": true}}, opts).Decode(out)
+}
+
+// PostChapters 保存章节：每部小说的每个章节序号只对应一个章节，
+// 已存在时保留原内容为历史版本，新内容作为新版本并设为当前版本
+func (s *NovelService) PostChapters(ctx context.Context, novelID string, chapterNumber int, title, content, summary string, outline models.ChapterOutline, qualityMetrics models.QualityMetrics, characterDevelopment map[string]string, source, creatorID string) (models.Chapter, error) {
 	coll := s.client.Database(s.dbName).Collection("chapters")
 
 	now := time.Now()
@@ -360,10 +739,27 @@ func (s *NovelService) PostChapters(ctx context.Context, novelID string, chapter
 		QualityMetrics:       qualityMetrics,
 		CharacterDevelopment: characterDevelopment,
 		Ctime:                now.Unix(),
+		Mtime:                now.Unix(),
 	}
 
+	var existing models.Chapter
+	err := coll.FindOne(ctx, bson.M{"novel_id": novelID, "chapter_number": chapterNumber}).Decode(&existing)
+	if err == nil {
+		return s.replaceChapter(ctx, existing, chapter, source, creatorID)
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return models.Chapter{}, err
+	}
+
+	chapter.ActiveVersion = 1
 	res, err := coll.InsertOne(ctx, chapter)
 	if err != nil {
+		// 并发创建同一章节时由唯一索引拦截，改为更新已创建的章节
+		if mongo.IsDuplicateKeyError(err) {
+			if err := coll.FindOne(ctx, bson.M{"novel_id": novelID, "chapter_number": chapterNumber}).Decode(&existing); err == nil {
+				return s.replaceChapter(ctx, existing, chapter, source, creatorID)
+			}
+		}
 		return models.Chapter{}, err
 	}
 
@@ -371,9 +767,162 @@ func (s *NovelService) PostChapters(ctx context.Context, novelID string, chapter
 		chapter.ID = oid.Hex()
 	}
 
+	if _, err := NewChapterVersionService(s.client, s.dbName).postChapterVersions(ctx, chapter, source, "", creatorID); err != nil {
+		return models.Chapter{}, err
+	}
+
 	return chapter, nil
 }
 
+// replaceChapter 用新内容覆盖已有章节，并记录为新版本
+func (s *NovelService) replaceChapter(ctx context.Context, existing, chapter models.Chapter, source, creatorID string) (models.Chapter, error) {
+	coll := s.client.Database(s.dbName).Collection("chapters")
+	oid, err := primitive.ObjectIDFromHex(existing.ID)
+	if err != nil {
+		return models.Chapter{}, errors.New("invalid id")
+	}
+
+	versionService := NewChapterVersionService(s.client, s.dbName)
+	if err := versionService.ensureOriginalVersion(ctx, existing, creatorID); err != nil {
+		return models.Chapter{}, err
+	}
+	chapter.ID = existing.ID
+	chapter.Ctime = existing.Ctime
+	version, err := versionService.postChapterVersions(ctx, chapter, source, "", creatorID)
+	if err != nil {
+		return models.Chapter{}, err
+	}
+	chapter.ActiveVersion = version.Version
+
+	res, err := coll.UpdateOne(ctx, chapterSnapshotFilter(oid, existing.ActiveVersion), bson.M{"$set": bson.M{
+		"title":                 chapter.Title,
+		"content":               chapter.Content,
+		"word_count":            chapter.WordCount,
+		"summary":               chapter.Summary,
+		"outline":               chapter.Outline,
+		"quality_metrics":       chapter.QualityMetrics,
+		"character_development": chapter.CharacterDevelopment,
+		"active_version":        chapter.ActiveVersion,
+		"mtime":                 chapter.Mtime,
+	}})
+	if err != nil {
+		return models.Chapter{}, err
+	}
+	if res.MatchedCount == 0 {
+		versionService.discardChapterVersion(ctx, version)
+		return models.Chapter{}, ErrChapterConflict
+	}
+
+	return chapter, nil
+}
+
+// PutChapters 更新章节；标题、正文或概要变化时保存为新版本
+func (s *NovelService) PutChapters(ctx context.Context, id, creatorID string, chapterNumber *int, title, content, summary *string, outline *models.ChapterOutline, qualityMetrics *models.QualityMetrics, characterDevelopment *map[string]string) (models.Chapter, error) {
+	coll := s.client.Database(s.dbName).Collection("chapters")
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.Chapter{}, errors.New("invalid id")
+	}
+
+	existing, err := s.GetChapter(ctx, id)
+	if err != nil {
+		return models.Chapter{}, err
+	}
+
+	set := bson.M{"mtime": time.Now().Unix()}
+	if chapterNumber != nil && *chapterNumber != existing.ChapterNumber {
+		n, err := coll.CountDocuments(ctx, bson.M{"novel_id": existing.NovelID, "chapter_number": *chapterNumber, "_id": bson.M{"$ne": oid}})
+		if err != nil {
+			return models.Chapter{}, err
+		}
+		if n > 0 {
+			return models.Chapter{}, ErrChapterNumberTaken
+		}
+		set["chapter_number"] = *chapterNumber
+	}
+	if outline != nil {
+		set["outline"] = *outline
+	}
+	if qualityMetrics != nil {
+		set["quality_metrics"] = *qualityMetrics
+	}
+	if characterDevelopment != nil {
+		set["character_development"] = *characterDevelopment
+	}
+
+	chapter := existing
+	if title != nil {
+		chapter.Title = *title
+	}
+	if content != nil {
+		chapter.Content = *content
+		chapter.WordCount = len([]rune(*content))
+	}
+	if summary != nil {
+		chapter.Summary = *summary
+	}
+	// 保存新版本时，仅在章节未被并发修改的情况下更新
+	filter := bson.M{"_id": oid}
+	versionService := NewChapterVersionService(s.client, s.dbName)
+	var version *models.ChapterVersion
+	if chapter.Title != existing.Title || chapter.Content != existing.Content || chapter.Summary != existing.Summary {
+		if err := versionService.ensureOriginalVersion(ctx, existing, creatorID); err != nil {
+			return models.Chapter{}, err
+		}
+		posted, err := versionService.postChapterVersions(ctx, chapter, models.ChapterVersionSourceEdit, "", creatorID)
+		if err != nil {
+			return models.Chapter{}, err
+		}
+		version = &posted
+		filter = chapterSnapshotFilter(oid, existing.ActiveVersion)
+		set["title"] = chapter.Title
+		set["content"] = chapter.Content
+		set["word_count"] = chapter.WordCount
+		set["summary"] = chapter.Summary
+		set["active_version"] = version.Version
+		// 原有质量指标针对旧内容，未同时提交时清空
+		if chapter.Content != existing.Content && qualityMetrics == nil {
+			set["quality_metrics"] = models.QualityMetrics{}
+		}
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var out models.Chapter
+	if err := coll.FindOneAndUpdate(ctx, filter, bson.M{"$set": set}, opts).Decode(&out); err != nil {
+		if version != nil {
+			versionService.discardChapterVersion(ctx, *version)
+		}
+		if mongo.IsDuplicateKeyError(err) {
+			return models.Chapter{}, ErrChapterNumberTaken
+		}
+		if version != nil && errors.Is(err, mongo.ErrNoDocuments) {
+			return models.Chapter{}, ErrChapterConflict
+		}
+		return models.Chapter{}, err
+	}
+
+	return out, nil
+}
+
+// DeleteChapters 删除章节及其版本、审核报告和修订记录
+func (s *NovelService) DeleteChapters(ctx context.Context, id string) error {
+	db := s.client.Database(s.dbName)
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.New("invalid id")
+	}
+
+	if _, err := db.Collection("chapters").DeleteOne(ctx, bson.M{"_id": oid}); err != nil {
+		return err
+	}
+	for _, name := range []string{"chapter_versions", "chapter_reviews", "chapter_revisions"} {
+		if _, err := db.Collection(name).DeleteMany(ctx, bson.M{"chapter_id": id}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // GetChapters 获取章节列表
 func (s *NovelService) GetChapters(ctx context.Context, novelID string) ([]models.Chapter, error) {
 	coll := s.client.Database(s.dbName).Collection("chapters")
@@ -392,6 +941,21 @@ func (s *NovelService) GetChapters(ctx context.Context, novelID string) ([]model
 	return chapters, nil
 }
 
+// GetPreviousChapter 获取序号小于 chapterNumber 的最后一章；chapterNumber 不大于0时获取最新一章
+func (s *NovelService) GetPreviousChapter(ctx context.Context, novelID string, chapterNumber int) (models.Chapter, error) {
+	coll := s.client.Database(s.dbName).Collection("chapters")
+
+	filter := bson.M{"novel_id": novelID}
+	if chapterNumber > 0 {
+		filter["chapter_number"] = bson.M{"$lt": chapterNumber}
+	}
+	var chapter models.Chapter
+	err := coll.FindOne(ctx, filter, options.FindOne().
+		SetSort(bson.M{"chapter_number": -1}).
+		SetProjection(bson.M{"content": 0})).Decode(&chapter)
+	return chapter, err
+}
+
 // GetChapter 获取单个章节
 func (s *NovelService) GetChapter(ctx context.Context, id string) (models.Chapter, error) {
 	coll := s.client.Database(s.dbName).Collection("chapters")
@@ -550,13 +1114,18 @@ func (s *NovelService) DeleteOutlines(ctx context.Context, id string) error {
 	return err
 }
 
-// ListOutlines 分页查询大纲列表
-func (s *NovelService) ListOutlines(ctx context.Context, page, pageSize int64, sortExpr, keyword string) (PagedOutlines, error) {
+// ListOutlines 分页查询用户可见小说的大纲列表
+func (s *NovelService) ListOutlines(ctx context.Context, userID, role string, page, pageSize int64, sortExpr, keyword string) (PagedOutlines, error) {
 	coll := s.client.Database(s.dbName).Collection("outlines")
 
+	novelIDs, err := s.listAccessibleNovelIDs(ctx, userID, role)
+	if err != nil {
+		return PagedOutlines{}, err
+	}
+
 	// 构建过滤条件
 	kwFilter := common.BuildKeywordFilter(keyword, []string{"title", "summary"})
-	filter := common.MergeFilters(bson.M{}, kwFilter)
+	filter := common.MergeFilters(bson.M{"novel_id": bson.M{"$in": novelIDs}}, kwFilter)
 
 	// 构建选项
 	sort := common.BuildSort(sortExpr)
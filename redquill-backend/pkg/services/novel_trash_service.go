@@ -0,0 +1,270 @@
+// Package services
+/*
+/@Author: urmsone urmsone@163.com
+/@Date: 2025/10/20 20:44
+/@Name: novel_trash_service.go
+/@Description: Novel soft delete, restore and trash purge
+/*/
+
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"redquill-backend/pkg/common"
+	"redquill-backend/pkg/models"
+)
+
+// DefaultTrashRetention 回收站默认保留期限
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
+// ErrNovelNotInTrash 小说不在回收站中
+var ErrNovelNotInTrash = errors.New("novel is not in trash")
+
+// novelChildCollections 按 novel_id 归属于小说的集合，随小说一起移入回收站、恢复和彻底删除。
+// usage_records 同样带有 novel_id，但作为计费与配额依据保留，不随小说删除
+var novelChildCollections = []string{
+	"story_cores",
+	"worldviews",
+	"characters",
+	"outlines",
+	"chapters",
+	"chapter_versions",
+	"chapter_reviews",
+	"chapter_revisions",
+	"writing_sessions",
+	"generation_jobs",
+	"generation_logs",
+}
+
+// DeleteNovels 将小说及其全部内容移入回收站，保留 retention 后由 TrashPurger 彻底删除
+func (s *NovelService) DeleteNovels(ctx context.Context, id string, retention time.Duration) (models.Novel, error) {
+	db := s.client.Database(s.dbName)
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.Novel{}, errors.New("invalid id")
+	}
+	if retention <= 0 {
+		retention = DefaultTrashRetention
+	}
+
+	// 先标记小说：小说不可访问后，其内容也无法再通过接口访问
+	now := time.Now()
+	trash := bson.M{"deleted_at": now.Unix(), "purge_at": now.Add(retention).Unix()}
+	res, err := db.Collection("novels").UpdateOne(ctx, bson.M{"_id": oid, "deleted_at": bson.M{"$exists": false}}, bson.M{"$set": trash})
+	if err != nil {
+		return models.Novel{}, err
+	}
+	if res.MatchedCount == 0 {
+		return models.Novel{}, ErrNovelNotFound
+	}
+
+	for _, name := range novelChildCollections {
+		if _, err := db.Collection(name).UpdateMany(ctx, bson.M{"novel_id": id}, bson.M{"$set": bson.M{"deleted_at": now.Unix()}}); err != nil {
+			return models.Novel{}, err
+		}
+	}
+
+	return s.GetNovels(ctx, id)
+}
+
+// RestoreNovels 从回收站恢复小说及其全部内容
+func (s *NovelService) RestoreNovels(ctx context.Context, id, userID, role string) (models.Novel, error) {
+	db := s.client.Database(s.dbName)
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.Novel{}, ErrNovelNotFound
+	}
+
+	novel, err := s.GetNovels(ctx, id)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return models.Novel{}, ErrNovelNotFound
+		}
+		return models.Novel{}, err
+	}
+	if err := checkNovelAccess(novel, userID, role, true); err != nil {
+		return models.Novel{}, err
+	}
+	if novel.DeletedAt == 0 {
+		return models.Novel{}, ErrNovelNotInTrash
+	}
+
+	// 先恢复内容再恢复小说，中途失败时小说仍在回收站中，可重试
+	for _, name := range novelChildCollections {
+		if _, err := db.Collection(name).UpdateMany(ctx, bson.M{"novel_id": id}, bson.M{"$unset": bson.M{"deleted_at": ""}}); err != nil {
+			return models.Novel{}, err
+		}
+	}
+	if _, err := db.Collection("novels").UpdateOne(ctx, bson.M{"_id": oid}, bson.M{
+		"$unset": bson.M{"deleted_at": "", "purge_at": ""},
+		"$set":   bson.M{"mtime": time.Now().Unix()},
+	}); err != nil {
+		return models.Novel{}, err
+	}
+
+	return s.GetNovels(ctx, id)
+}
+
+// ListTrashedNovels 分页查询回收站中的小说：管理员可见全部，其他用户只能看到自己的作品
+func (s *NovelService) ListTrashedNovels(ctx context.Context, userID, role string, page, pageSize int64, sortExpr, keyword string) (PagedNovels, error) {
+	coll := s.client.Database(s.dbName).Collection("novels")
+
+	trashFilter := bson.M{"deleted_at": bson.M{"$exists": true}}
+	if role != models.RoleAdmin {
+		trashFilter["author_id"] = userID
+	}
+	kwFilter := common.BuildKeywordFilter(keyword, []string{"title"})
+	filter := common.MergeFilters(trashFilter, kwFilter)
+
+	if sortExpr == "" {
+		sortExpr = "-deleted_at"
+	}
+	opts := common.BuildFindOptions(page, pageSize, common.BuildSort(sortExpr), bson.M{})
+
+	items, total, err := common.FindWithPagination[models.Novel](ctx, coll, filter, opts)
+	if err != nil {
+		return PagedNovels{}, err
+	}
+
+	totalPages := total / common.NormalizePageSize(pageSize)
+	if total%common.NormalizePageSize(pageSize) != 0 {
+		totalPages++
+	}
+
+	return PagedNovels{
+		Items: items,
+		Pagination: common.Pagination{
+			Page:      common.NormalizePage(page),
+			PageSize:  common.NormalizePageSize(pageSize),
+			Total:     total,
+			TotalPage: totalPages,
+		},
+	}, nil
+}
+
+// PurgeExpiredNovels 彻底删除回收站中已过保留期限的小说。每部小说及其内容在一个事务中删除，
+// 需要 MongoDB 副本集；返回删除的小说数
+func (s *NovelService) PurgeExpiredNovels(ctx context.Context, now time.Time) (int, error) {
+	db := s.client.Database(s.dbName)
+
+	cursor, err := db.Collection("novels").Find(ctx, bson.M{"deleted_at": bson.M{"$exists": true}, "purge_at": bson.M{"$lte": now.Unix()}})
+	if err != nil {
+		return 0, err
+	}
+	var novels []models.Novel
+	if err := cursor.All(ctx, &novels); err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, novel := range novels {
+		deleted, err := s.purgeNovel(ctx, novel.ID, now)
+		if err != nil {
+			return purged, err
+		}
+		if deleted {
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// purgeNovel 在事务中删除小说及其内容；小说已被恢复时不删除
+func (s *NovelService) purgeNovel(ctx context.Context, id string, now time.Time) (bool, error) {
+	db := s.client.Database(s.dbName)
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, errors.New("invalid id")
+	}
+
+	session, err := s.client.StartSession()
+	if err != nil {
+		return false, err
+	}
+	defer session.EndSession(ctx)
+
+	result, err := session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		res, err := db.Collection("novels").DeleteOne(sc, bson.M{
+			"_id":        oid,
+			"deleted_at": bson.M{"$exists": true},
+			"purge_at":   bson.M{"$lte": now.Unix()},
+		})
+		if err != nil || res.DeletedCount == 0 {
+			return false, err
+		}
+		for _, name := range novelChildCollections {
+			if _, err := db.Collection(name).DeleteMany(sc, bson.M{"novel_id": id}); err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return result.(bool), nil
+}
+
+// TrashPurger 定期彻底删除回收站中过期的小说
+type TrashPurger struct {
+	client   *mongo.Client
+	dbName   string
+	interval time.Duration
+	ctx      context.Context
+	stop     context.CancelFunc
+}
+
+// NewTrashPurger 创建回收站清理器
+func NewTrashPurger(client *mongo.Client, dbName string, interval time.Duration) *TrashPurger {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	return &TrashPurger{
+		client:   client,
+		dbName:   dbName,
+		interval: interval,
+		ctx:      ctx,
+		stop:     stop,
+	}
+}
+
+// Start 启动清理，启动时立即执行一次
+func (p *TrashPurger) Start() {
+	go func() {
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		for {
+			p.purge()
+			select {
+			case <-p.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop 停止清理
+func (p *TrashPurger) Stop() {
+	p.stop()
+}
+
+func (p *TrashPurger) purge() {
+	n, err := NewNovelService(p.client, p.dbName).PurgeExpiredNovels(p.ctx, time.Now())
+	if err != nil && p.ctx.Err() == nil {
+		log.Printf("Failed to purge expired novels: %v", err)
+	}
+	if n > 0 {
+		log.Printf("Purged %d expired novels from trash", n)
+	}
+}
@@ -0,0 +1,110 @@
+// Package services
+/*
+/@Author: urmsone urmsone@163.com
+/@Date: 2025/10/20 20:44
+/@Name: prompt_preview_service.go
+/@Description: Render generation prompts without calling a provider
+/*/
+
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"redquill-backend/pkg/models"
+	"redquill-backend/pkg/utils/llm"
+	"redquill-backend/pkg/utils/prompt"
+)
+
+// PromptPreview 生成请求将发送给模型的内容
+type PromptPreview struct {
+	TemplateType    string                 `json:"template_type"`
+	TemplateID      string                 `json:"template_id"`
+	TemplateName    string                 `json:"template_name"`
+	TemplateKey     string                 `json:"template_key,omitempty"`
+	TemplateVersion int                    `json:"template_version,omitempty"`
+	LLMModelID      string                 `json:"llm_model_id,omitempty"`
+	Model           string                 `json:"model,omitempty"` // 厂商的模型名称
+	Temperature     float64                `json:"temperature,omitempty"`
+	MaxTokens       int                    `json:"max_tokens,omitempty"`
+	ResponseFormat  *llm.ResponseFormat    `json:"response_format,omitempty"`
+	Messages        []llm.Message          `json:"messages"`
+	InputData       map[string]interface{} `json:"input_data"`       // 实际用于渲染的输入，章节生成时包含自动组装的上下文
+	Sections        []PromptSection        `json:"sections"`         // 模板文字与各变量，按模板声明的变量顺序
+	EstimatedTokens int                    `json:"estimated_tokens"` // 全部消息的预估token数
+}
+
+// PromptSection Prompt中一部分内容的预估token数
+type PromptSection struct {
+	Name            string `json:"name"` // 变量名，template 为模板自身的文字
+	Characters      int    `json:"characters"`
+	EstimatedTokens int    `json:"estimated_tokens"`
+}
+
+// promptTemplateSection 模板自身文字（不含变量值）的分段名称
+const promptTemplateSection = "template"
+
+// PreviewPrompt 按生成时相同的方式选择模板、组装输入并渲染Prompt，不调用模型。
+// 章节生成与 /generate/chapter 一样先经过 PrepareChapterInputData；llm_model_id 为空时不返回模型参数
+func (s *PromptTemplateService) PreviewPrompt(ctx context.Context, req models.GenerationRequest) (PromptPreview, error) {
+	if req.TemplateType == "chapter" {
+		req.InputData = NewNovelGenerationService(s.client, s.dbName).PrepareChapterInputData(ctx, req.NovelID, req.InputData)
+	}
+	if req.InputData == nil {
+		req.InputData = map[string]interface{}{}
+	}
+
+	template, err := s.resolvePromptTemplate(ctx, req)
+	if err != nil {
+		return PromptPreview{}, err
+	}
+	fullPrompt, err := s.buildPrompt(template, req.InputData)
+	if err != nil {
+		return PromptPreview{}, err
+	}
+
+	preview := PromptPreview{
+		TemplateType:    req.TemplateType,
+		TemplateID:      template.ID,
+		TemplateName:    template.Name,
+		TemplateKey:     template.Key,
+		TemplateVersion: template.Version,
+		ResponseFormat:  responseFormatFor(template),
+		Messages:        []llm.Message{{Role: "user", Content: fullPrompt}},
+		InputData:       req.InputData,
+	}
+	if req.LLMModelID != "" {
+		llmModel, err := s.getLLMModel(ctx, req.LLMModelID)
+		if err != nil {
+			return PromptPreview{}, fmt.Errorf("llm model: %w", err)
+		}
+		preview.LLMModelID = llmModel.ID
+		preview.Model = llmModel.Config.ModelName
+		preview.Temperature = llmModel.Config.Temperature
+		preview.MaxTokens = llmModel.Config.MaxTokens
+	}
+
+	// 模板文字：所有变量取空值时的渲染结果
+	empty := make(map[string]interface{}, len(template.Variables))
+	for _, name := range template.Variables {
+		empty[name] = ""
+	}
+	if text, err := prompt.Render(template.Content, template.Variables, empty); err == nil {
+		preview.Sections = append(preview.Sections, newPromptSection(promptTemplateSection, text))
+	}
+	for _, name := range template.Variables {
+		preview.Sections = append(preview.Sections, newPromptSection(name, prompt.FormatValue(req.InputData[name])))
+	}
+	preview.EstimatedTokens = llm.EstimateMessagesTokens(preview.Messages)
+
+	return preview, nil
+}
+
+func newPromptSection(name, text string) PromptSection {
+	return PromptSection{
+		Name:            name,
+		Characters:      len([]rune(text)),
+		EstimatedTokens: llm.EstimateTokens(text),
+	}
+}
@@ -3,39 +3,37 @@
 /@Author: urmsone urmsone@163.com
 /@Date: 2025/10/20 20:44
 /@Name: prompt_template_init.go
-/@Description: Initialize prompt templates based on novels.md
+/@Description: Built-in prompt templates and their versioned migrations
 /*/
 
 package services
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"redquill-backend/pkg/models"
+	"redquill-backend/pkg/utils/prompt"
 )
 
-// InitializePromptTemplates 初始化Prompt模板
-func InitializePromptTemplates(client *mongo.Client, dbName string) error {
-	ctx := context.Background()
-	coll := client.Database(dbName).Collection("prompt_templates")
-
-	// 检查是否已经初始化
-	count, err := coll.CountDocuments(ctx, bson.M{})
-	if err != nil {
-		return err
-	}
-	if count > 0 {
-		return nil // 已经初始化过了
-	}
-
-	templates := []models.PromptTemplate{
+// builtinPromptTemplates 内置Prompt模板的当前版本。修改某个模板的内容时需同时增加其 Version，
+// 启动时由 PromptTemplateMigrations 升级已部署的模板
+func builtinPromptTemplates() []models.PromptTemplate {
+	return []models.PromptTemplate{
 		{
 			Name:        "故事核心生成",
 			Type:        "story_core",
+			Key:         "system.story_core",
+			Version:     2,
 			Phase:       "story_core",
 			Description: "基于用户选择的题材和初步想法，生成具有爆款潜力的故事核心方案",
 			Content: `【角色】
@@ -46,10 +44,10 @@ func InitializePromptTemplates(client *mongo.Client, dbName string) error {
 
 【输入数据】
 {
-  "genre": "{genre}",
-  "sub_genre": "{sub_genre}", 
-  "user_ideas": "{user_ideas}",
-  "target_audience": "{target_audience}"
+  "genre": {{json .genre}},
+  "sub_genre": {{json .sub_genre}}, 
+  "user_ideas": {{json .user_ideas}},
+  "target_audience": {{json .target_audience}}
 }
 
 【输出要求】
@@ -76,6 +74,8 @@ func InitializePromptTemplates(client *mongo.Client, dbName string) error {
 		{
 			Name:        "世界观构建",
 			Type:        "worldview",
+			Key:         "system.worldview",
+			Version:     2,
 			Phase:       "worldview",
 			Description: "为选定的故事核心构建完整的世界观体系",
 			Content: `【角色】
@@ -86,14 +86,14 @@ func InitializePromptTemplates(client *mongo.Client, dbName string) error {
 
 【输入数据】
 {
-  "genre": "{genre}",
-  "title" : "{title}",
-  "core_conflict" : "{core_conflict}",
-  "theme" : "{theme}",
-  "innovation" : "{innovation}",
-  "commercial_potential" : "{commercial_potential}",
-  "target_audience" : "{target_audience}",
-  "user_ideas": "{user_ideas}",
+  "genre": {{json .genre}},
+  "title" : {{json .title}},
+  "core_conflict" : {{json .core_conflict}},
+  "theme" : {{json .theme}},
+  "innovation" : {{json .innovation}},
+  "commercial_potential" : {{json .commercial_potential}},
+  "target_audience" : {{json .target_audience}},
+  "user_ideas": {{json .user_ideas}},
 }
 
 【输出要求】
@@ -122,7 +122,7 @@ func InitializePromptTemplates(client *mongo.Client, dbName string) error {
   },
   "special_rules": ["特殊规则1", "特殊规则2"]
 }`,
-			Variables:  []string{"title", "core_conflict", "theme", "innovation", "commercial_potential", "target_audience", "genre"},
+			Variables:  []string{"title", "core_conflict", "theme", "innovation", "commercial_potential", "target_audience", "genre", "user_ideas"},
 			UsageCount: 0,
 			CreatorID:  "system",
 			Creator:    "system",
@@ -132,6 +132,8 @@ func InitializePromptTemplates(client *mongo.Client, dbName string) error {
 		{
 			Name:        "角色灵魂塑造",
 			Type:        "character",
+			Key:         "system.character",
+			Version:     2,
 			Phase:       "characters",
 			Description: "基于故事核心和世界观，深度塑造主要角色的内在灵魂",
 			Content: `【角色】
@@ -142,10 +144,10 @@ func InitializePromptTemplates(client *mongo.Client, dbName string) error {
 
 【输入数据】
 {
-  "story_core": "{story_core}",
-  "worldview": "{worldview}",
-  "character_type": "{character_type}",
-  "role_requirements": "{role_requirements}"
+  "story_core": {{json .story_core}},
+  "worldview": {{json .worldview}},
+  "character_type": {{json .character_type}},
+  "role_requirements": {{json .role_requirements}}
 }
 
 【输出要求】
@@ -192,6 +194,8 @@ func InitializePromptTemplates(client *mongo.Client, dbName string) error {
 		{
 			Name:        "批量角色生成",
 			Type:        "batch_character",
+			Key:         "system.batch_character",
+			Version:     2,
 			Phase:       "characters",
 			Description: "根据大纲批量生成所有角色，直接返回符合数据库模型的数据结构",
 			Content: `【角色】
@@ -201,10 +205,10 @@ func InitializePromptTemplates(client *mongo.Client, dbName string) error {
 分析大纲内容，识别所有重要角色，并批量生成完整的角色档案。直接返回符合数据库模型的数据结构。
 
 【输入数据】
-- 大纲内容：{outline_content}
-- 故事核心：{story_core}
-- 世界观：{worldview}
-- 用户要求：{user_requirements}
+- 大纲内容：{{.outline_content}}
+- 故事核心：{{.story_core}}
+- 世界观：{{.worldview}}
+- 用户要求：{{.user_requirements}}
 
 【输出要求】
 请严格按照以下JSON格式输出角色数据，确保数据结构完全符合数据库模型：
@@ -266,35 +270,33 @@ func InitializePromptTemplates(client *mongo.Client, dbName string) error {
 		{
 			Name:        "章节内容生成",
 			Type:        "chapter",
+			Key:         "system.chapter",
+			Version:     3,
 			Phase:       "writing",
 			Description: "根据章节大纲和目标，生成具体章节内容",
 			Content: `【角色】
-你是{novel_title}的御用写手，完全沉浸在故事的世界中。
+你是{{.novel_title}}的御用写手，完全沉浸在故事的世界中。
 
 【任务】
 根据章节大纲和目标，生成具体章节内容。
 
 【输入数据】
-- 小说标题：{novel_title}
-- 故事核心：{story_core}
-- 世界观：{worldview}
-- 当前故事弧线：{current_arc}
-- 章节目标：{chapter_goal}
-- 参与角色：{characters_involved}
-- 章节大纲信息：{characters_outline}
-- 前情提要：{previous_summary}
-- 情节模板：{plot_templates}
+- 小说标题：{{.novel_title}}
+- 故事核心：{{.story_core}}
+- 世界观：{{.worldview}}
+- 当前故事弧线：{{.current_arc}}
+- 章节目标：{{.chapter_goal}}
+- 参与角色：{{.characters_involved}}
+- 章节大纲信息：{{.characters_outline}}
+- 前情提要：{{.previous_summary}}
+- 情节模板：{{.plot_templates}}
 
 【输出要求】
-请先输出章节元数据JSON，然后输出正文内容：
+请严格按照以下JSON格式输出，章节正文放在 content 字段中：
 {
   "title": "章节标题",
+  "content": "章节正文（2000字左右，段落之间用换行分隔）",
   "summary": "本章内容摘要",
-  "plot_advancements": ["剧情推进点1", "剧情推进点2"],
-  "character_development": {
-    "角色名": "在本章中的成长变化"
-  },
-  "next_chapter_hook": "为下一章埋下的钩子",
   "outline": {
     "goal": "本章核心目标",
     "key_events": ["关键事件1", "关键事件2"],
@@ -311,10 +313,7 @@ func InitializePromptTemplates(client *mongo.Client, dbName string) error {
   },
   "plot_advancements": ["剧情推进点1", "剧情推进点2"],
   "next_chapter_hook": "为下一章埋下的钩子"
-}
-
-【正文开始】
-（此处生成2000字左右的章节正文内容，人物）`,
+}`,
 			Variables:  []string{"novel_title", "story_core", "worldview", "current_arc", "chapter_goal", "characters_involved", "characters_outline", "previous_summary", "plot_templates"},
 			UsageCount: 0,
 			CreatorID:  "system",
@@ -325,6 +324,8 @@ func InitializePromptTemplates(client *mongo.Client, dbName string) error {
 		{
 			Name:        "小说大纲生成",
 			Type:        "outline",
+			Key:         "system.outline",
+			Version:     2,
 			Phase:       "outlining",
 			Description: "根据故事核心和世界观生成完整的小说大纲",
 			Content: `【角色】
@@ -334,12 +335,12 @@ func InitializePromptTemplates(client *mongo.Client, dbName string) error {
 基于故事核心、世界观和总章节数，生成完整的小说大纲，包含章节信息、故事弧线和关键主题。
 
 【输入数据】
-- 故事核心：{story_core}
-- 世界观：{worldview}
-- 总章节数：{total_chapters}
-- 小说类型：{genre}
-- 目标读者：{target_audience}
-- 用户要求：{user_ideas},
+- 故事核心：{{.story_core}}
+- 世界观：{{.worldview}}
+- 总章节数：{{.total_chapters}}
+- 小说类型：{{.genre}}
+- 目标读者：{{.target_audience}}
+- 用户要求：{{.user_ideas}},
 
 【输出要求】
 请生成一个完整的JSON格式大纲，包含以下结构：
@@ -396,6 +397,8 @@ func InitializePromptTemplates(client *mongo.Client, dbName string) error {
 		{
 			Name:        "内容质量审核",
 			Type:        "quality_review",
+			Key:         "system.quality_review",
+			Version:     2,
 			Phase:       "writing",
 			Description: "对生成的章节内容进行全面的质量评估",
 			Content: `【角色】
@@ -406,17 +409,17 @@ func InitializePromptTemplates(client *mongo.Client, dbName string) error {
 
 【输入数据】
 {
-  "chapter_content": "{chapter_content}",
-  "chapter_metadata": "{chapter_metadata}",
+  "chapter_content": {{json .chapter_content}},
+  "chapter_metadata": {{json .chapter_metadata}},
   "novel_context": {
-    "story_core": "{story_core}",
-    "worldview": "{worldview}"
+    "story_core": {{json .story_core}},
+    "worldview": {{json .worldview}}
   },
-  "quality_standards": {
+  "quality_standards": {{if .quality_standards}}{{json .quality_standards}}{{else}}{
     "role_consistency": "角色一致性要求",
     "plot_advancement": "剧情推进要求", 
     "emotional_impact": "情感冲击要求"
-  }
+  }{{end}}
 }
 
 【输出要求】
@@ -441,15 +444,334 @@ func InitializePromptTemplates(client *mongo.Client, dbName string) error {
 			Ctime:      time.Now().Unix(),
 			Mtime:      time.Now().Unix(),
 		},
+		{
+			Name:        "章节修订",
+			Type:        "chapter_revision",
+			Key:         "system.chapter_revision",
+			Version:     2,
+			Phase:       "writing",
+			Description: "根据质量审核报告中的问题修订章节内容",
+			Content: `【角色】
+你是{{.novel_title}}的御用写手，擅长根据编辑的审稿意见精修章节。
+
+【任务】
+根据审核发现的问题修订下面的章节。保留原有的剧情走向、人物设定和文风，只针对问题做必要的修改。
+
+【输入数据】
+- 小说标题：{{.novel_title}}
+- 故事核心：{{.story_core}}
+- 世界观：{{.worldview}}
+- 章节标题：{{.chapter_title}}
+- 原章节正文：
+{{.chapter_content}}
+
+【需要解决的问题】
+{{.issues}}
+
+【优化建议】
+{{.suggestions}}
+
+【作者要求】
+{{.user_instructions}}
+
+【输出要求】
+请严格按照以下JSON格式输出修订后的完整章节，正文放在 content 字段中：
+{
+  "title": "章节标题",
+  "content": "修订后的完整章节正文",
+  "summary": "本章内容摘要",
+  "revision_notes": ["修改说明1", "修改说明2"]
+}`,
+			Variables:  []string{"novel_title", "story_core", "worldview", "chapter_title", "chapter_content", "issues", "suggestions", "user_instructions"},
+			UsageCount: 0,
+			CreatorID:  "system",
+			Creator:    "system",
+			Ctime:      time.Now().Unix(),
+			Mtime:      time.Now().Unix(),
+		},
+		{
+			Name:        "章节分析",
+			Type:        "chapter_analysis",
+			Key:         "system.chapter_analysis",
+			Version:     2,
+			Phase:       "writing",
+			Description: "分析导入的已有章节，提炼概要、关键事件与角色变化，供后续续写参考",
+			Content: `【角色】
+你是{{.novel_title}}的责任编辑，熟悉全书的人物与剧情。
+
+【任务】
+阅读下面这一章，提炼后续续写所需的上下文信息。只根据正文内容总结，不要编造正文中没有的情节。
+
+【输入数据】
+- 小说标题：{{.novel_title}}
+- 故事核心：{{.story_core}}
+- 前一章概要：{{.previous_summary}}
+- 章节标题：{{.chapter_title}}
+- 章节正文：
+{{.chapter_content}}
+
+【输出要求】
+请严格按照以下JSON格式输出：
+{
+  "summary": "本章内容概要（200字以内）",
+  "goal": "本章在全书中承担的作用",
+  "key_events": ["关键事件1", "关键事件2"],
+  "character_development": {
+    "角色名": "该角色在本章中的变化或成长"
+  }
+}`,
+			Variables:  []string{"novel_title", "story_core", "previous_summary", "chapter_title", "chapter_content"},
+			UsageCount: 0,
+			CreatorID:  "system",
+			Creator:    "system",
+			Ctime:      time.Now().Unix(),
+			Mtime:      time.Now().Unix(),
+		},
+	}
+}
+
+// legacyPromptTemplateChecksums 以前发布过的内置模板内容的校验和，用于判断启用版本管理前写入的模板是否被修改过
+var legacyPromptTemplateChecksums = map[string][]string{
+	"system.story_core":       {"b18c2e7d5b2adc4fcb4218cf1649c86b0c467896b52cf433d08fbc8e574516f1"},
+	"system.worldview":        {"05e66a5beaa76c5d8db5faa5fb99a7bfa5d072c02caa4ff76d31736e46cc1bb7"},
+	"system.character":        {"185b53e542bbf839db4962a991e6c371b4fe756e5f0f0779efb30fb885fe8833"},
+	"system.batch_character":  {"5e5ef7905e7333a4b5aa9818ebb06ae17173550bf338b1116393a471dd06666c"},
+	"system.chapter":          {"2eadb159cc16306422438a21da0e98c7c81e4b9420b153aa28c0c551da029717", "6c8c2195480632e3f7bd1b7569d29f6da3db2d8f7b0bfeddd0b15d831a1f7571"},
+	"system.outline":          {"a8d37315f99fa0dee70a947c5b7af56ad62a17f17db3c0f3105c36232eab53d2"},
+	"system.quality_review":   {"c83307f254d0e54524d8b6c9672830cebaa298c62f26e70d3a419dd814ca0b98"},
+	"system.chapter_revision": {"d8df300f56715d399e68d8fbe64edc3d53fba02bee02f143e1189fca9c71c2ec"},
+	"system.chapter_analysis": {"b4da83e57bf34e9a1492b5882534c48ba8c061c60d1d5560aa882c6cc5662ef8"},
+}
+
+// InitializePromptTemplates 执行内置Prompt模板的迁移，并为缺少输出Schema的模板补充默认Schema
+func InitializePromptTemplates(client *mongo.Client, dbName string) error {
+	ctx := context.Background()
+	coll := client.Database(dbName).Collection("prompt_templates")
+
+	if _, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "key", Value: 1}},
+		Options: options.Index().SetUnique(true).SetSparse(true),
+	}); err != nil {
+		return err
+	}
+	// 每种类型最多一个默认模板
+	if _, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "type", Value: 1}},
+		Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"is_default": true}),
+	}); err != nil {
+		return err
+	}
+	if _, err := RunMigrations(ctx, client, dbName, PromptTemplateMigrations()); err != nil {
+		return err
+	}
+	return ensureOutputSchemas(ctx, coll)
+}
+
+// PromptTemplateMigrations 每个内置模板的当前版本对应一个迁移，例如 prompt_template:system.chapter@v2，
+// 之后将仍为 {name} 占位符写法的模板转换为模板语法，最后为还没有默认模板的类型选定默认模板
+func PromptTemplateMigrations() []Migration {
+	templates := builtinPromptTemplates()
+	migrations := make([]Migration, 0, len(templates)+2)
+	for _, template := range templates {
+		template := template
+		migrations = append(migrations, Migration{
+			ID:          fmt.Sprintf("prompt_template:%s@v%d", template.Key, template.Version),
+			Description: fmt.Sprintf("upgrade built-in prompt template %s to version %d", template.Key, template.Version),
+			Up: func(ctx context.Context, db *mongo.Database) (string, error) {
+				return upgradePromptTemplate(ctx, db.Collection("prompt_templates"), template)
+			},
+		})
+	}
+	migrations = append(migrations, Migration{
+		ID:          "prompt_templates:template_syntax",
+		Description: "convert {name} placeholders of customized and user-created templates to template syntax",
+		Up: func(ctx context.Context, db *mongo.Database) (string, error) {
+			return convertLegacyPromptTemplates(ctx, db.Collection("prompt_templates"), templates)
+		},
+	})
+	migrations = append(migrations, Migration{
+		ID:          "prompt_templates:default_per_type",
+		Description: "mark the template each type was generated with as its default",
+		Up: func(ctx context.Context, db *mongo.Database) (string, error) {
+			return markDefaultPromptTemplates(ctx, db.Collection("prompt_templates"))
+		},
+	})
+	return migrations
+}
+
+// convertLegacyPromptTemplates 将 {name} 占位符写法的模板转换为模板语法。内容与当前内置模板一致的已是新写法，跳过
+func convertLegacyPromptTemplates(ctx context.Context, coll *mongo.Collection, builtins []models.PromptTemplate) (string, error) {
+	current := make(map[string]string, len(builtins))
+	for _, template := range builtins {
+		current[template.Key] = template.Content
+	}
+
+	cursor, err := coll.Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{"key": 1, "content": 1, "variables": 1}))
+	if err != nil {
+		return "", err
+	}
+	var templates []models.PromptTemplate
+	if err := cursor.All(ctx, &templates); err != nil {
+		return "", err
 	}
 
-	// 插入模板
+	converted := 0
 	for _, template := range templates {
-		_, err := coll.InsertOne(ctx, template)
+		if template.Key != "" && template.Content == current[template.Key] {
+			continue
+		}
+		content := prompt.ConvertLegacy(template.Content, template.Variables)
+		if content == template.Content {
+			continue
+		}
+		oid, err := primitive.ObjectIDFromHex(template.ID)
 		if err != nil {
-			return err
+			return "", errors.New("invalid id")
 		}
+		if _, err := coll.UpdateOne(ctx, bson.M{"_id": oid}, bson.M{"$set": bson.M{"content": content}}); err != nil {
+			return "", err
+		}
+		converted++
 	}
+	return fmt.Sprintf("converted %d templates", converted), nil
+}
 
+// markDefaultPromptTemplates 为没有默认模板的类型选定默认模板：沿用此前的选择规则，用户创建的模板优先于内置模板，其次按创建时间
+func markDefaultPromptTemplates(ctx context.Context, coll *mongo.Collection) (string, error) {
+	types, err := coll.Distinct(ctx, "type", bson.M{})
+	if err != nil {
+		return "", err
+	}
+	marked := 0
+	for _, value := range types {
+		templateType, ok := value.(string)
+		if !ok {
+			continue
+		}
+		err := coll.FindOne(ctx, bson.M{"type": templateType, "is_default": true}).Err()
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			return "", err
+		}
+		var template models.PromptTemplate
+		if err := coll.FindOne(ctx, bson.M{"type": templateType}, options.FindOne().
+			SetSort(bson.D{{Key: "key", Value: 1}, {Key: "ctime", Value: 1}})).Decode(&template); err != nil {
+			return "", err
+		}
+		oid, err := primitive.ObjectIDFromHex(template.ID)
+		if err != nil {
+			return "", errors.New("invalid id")
+		}
+		if _, err := coll.UpdateOne(ctx, bson.M{"_id": oid}, bson.M{"$set": bson.M{"is_default": true}}); err != nil {
+			return "", err
+		}
+		marked++
+	}
+	return fmt.Sprintf("marked %d default templates", marked), nil
+}
+
+// upgradePromptTemplate 将内置模板写入或升级到 template 的版本。
+// 内容已被修改过的模板保持不变，只标记其 key，使之后的版本同样不会覆盖
+func upgradePromptTemplate(ctx context.Context, coll *mongo.Collection, template models.PromptTemplate) (string, error) {
+	now := time.Now().Unix()
+	checksum := contentChecksum(template.Content)
+
+	var existing models.PromptTemplate
+	err := coll.FindOne(ctx, bson.M{"key": template.Key}).Decode(&existing)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// 启用版本管理前写入的内置模板
+		err = coll.FindOne(ctx, bson.M{
+			"type":       template.Type,
+			"creator_id": "system",
+			"key":        bson.M{"$exists": false},
+		}, options.FindOne().SetSort(bson.M{"ctime": 1})).Decode(&existing)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			// 新增类型的内置模板在该类型没有默认模板时作为默认模板
+			err := coll.FindOne(ctx, bson.M{"type": template.Type, "is_default": true}).Err()
+			if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+				return "", err
+			}
+			template.IsDefault = errors.Is(err, mongo.ErrNoDocuments)
+			template.OutputSchema = DefaultOutputSchema(template.Type)
+			template.SeedChecksum = checksum
+			template.Ctime = now
+			template.Mtime = now
+			if _, err := coll.InsertOne(ctx, template); err != nil {
+				return "", err
+			}
+			return "inserted", nil
+		}
+		if err != nil {
+			return "", err
+		}
+		existing.SeedChecksum = legacySeedChecksum(template.Key, existing.Content, checksum)
+	} else if err != nil {
+		return "", err
+	}
+
+	oid, err := primitive.ObjectIDFromHex(existing.ID)
+	if err != nil {
+		return "", errors.New("invalid id")
+	}
+	if existing.Version >= template.Version {
+		return fmt.Sprintf("%s is already at version %d", existing.ID, existing.Version), nil
+	}
+	if existing.SeedChecksum == "" || contentChecksum(existing.Content) != existing.SeedChecksum {
+		if _, err := coll.UpdateOne(ctx, bson.M{"_id": oid}, bson.M{"$set": bson.M{"key": template.Key}}); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("kept customized %s (based on version %d)", existing.ID, existing.Version), nil
+	}
+
+	if _, err := coll.UpdateOne(ctx, bson.M{"_id": oid}, bson.M{"$set": bson.M{
+		"key":           template.Key,
+		"version":       template.Version,
+		"seed_checksum": checksum,
+		"name":          template.Name,
+		"phase":         template.Phase,
+		"description":   template.Description,
+		"content":       template.Content,
+		"variables":     template.Variables,
+		"output_schema": DefaultOutputSchema(template.Type),
+		"mtime":         now,
+	}}); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("updated %s from version %d", existing.ID, existing.Version), nil
+}
+
+// legacySeedChecksum 旧部署中内置模板的内容与当前或历史内置内容一致时返回其校验和，否则视为已修改，返回空
+func legacySeedChecksum(key, content, current string) string {
+	sum := contentChecksum(content)
+	if sum == current {
+		return sum
+	}
+	for _, legacy := range legacyPromptTemplateChecksums[key] {
+		if sum == legacy {
+			return sum
+		}
+	}
+	return ""
+}
+
+// contentChecksum 模板内容的 SHA-256 校验和
+func contentChecksum(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// ensureOutputSchemas 为已存在但缺少输出Schema的内置类型模板补充默认Schema
+func ensureOutputSchemas(ctx context.Context, coll *mongo.Collection) error {
+	for templateType := range defaultOutputSchemas {
+		_, err := coll.UpdateMany(ctx, bson.M{
+			"type":          templateType,
+			"output_schema": bson.M{"$exists": false},
+		}, bson.M{"$set": bson.M{"output_schema": DefaultOutputSchema(templateType)}})
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
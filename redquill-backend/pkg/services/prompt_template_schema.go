@@ -0,0 +1,220 @@
+// Package services
+/*
+/@Author: urmsone urmsone@163.com
+/@Date: 2025/10/20 20:44
+/@Name: prompt_template_schema.go
+/@Description: Default output JSON schemas of built-in prompt templates
+/*/
+
+package services
+
+import (
+	"encoding/json"
+)
+
+// 数值字段同时接受字符串，模型常把评分等输出为 "8"
+const (
+	characterSchema = `{
+  "type": "object",
+  "required": ["name", "soul_profile", "core_attributes"],
+  "properties": {
+    "name": {"type": "string", "minLength": 1},
+    "type": {"type": "string"},
+    "soul_profile": {
+      "type": "object",
+      "required": ["personality", "background", "motivations"],
+      "properties": {
+        "personality": {"type": "object"},
+        "background": {"type": "object"},
+        "motivations": {"type": "object"}
+      }
+    },
+    "core_attributes": {"type": "object"}
+  }
+}`
+
+	storyCoreSchema = `{
+  "type": "object",
+  "required": ["concepts"],
+  "properties": {
+    "concepts": {
+      "type": "array",
+      "minItems": 1,
+      "items": {
+        "type": "object",
+        "required": ["title", "core_conflict", "theme"],
+        "properties": {
+          "title": {"type": "string", "minLength": 1},
+          "core_conflict": {"type": "string"},
+          "theme": {"type": "string"},
+          "innovation": {"type": "string"},
+          "commercial_potential": {"type": "string"},
+          "target_audience": {"type": "string"}
+        }
+      }
+    }
+  }
+}`
+
+	worldviewSchema = `{
+  "type": "object",
+  "required": ["power_system", "society_structure", "geography"],
+  "properties": {
+    "power_system": {
+      "type": "object",
+      "properties": {
+        "name": {"type": "string"},
+        "levels": {"type": "array", "items": {"type": "string"}}
+      }
+    },
+    "society_structure": {
+      "type": "object",
+      "properties": {
+        "major_factions": {"type": "array", "items": {"type": "object"}}
+      }
+    },
+    "geography": {"type": "object"},
+    "special_rules": {"type": "array", "items": {"type": "string"}}
+  }
+}`
+
+	batchCharacterSchema = `{
+  "type": "object",
+  "required": ["characters"],
+  "properties": {
+    "characters": {"type": "array", "minItems": 1, "items": ` + characterSchema + `}
+  }
+}`
+
+	chapterSchema = `{
+  "type": "object",
+  "required": ["title", "content"],
+  "properties": {
+    "title": {"type": "string", "minLength": 1},
+    "content": {"type": "string", "minLength": 1},
+    "summary": {"type": "string"},
+    "plot_advancements": {"type": "array", "items": {"type": "string"}},
+    "character_development": {"type": "object"},
+    "next_chapter_hook": {"type": "string"},
+    "outline": {
+      "type": "object",
+      "properties": {
+        "goal": {"type": "string"},
+        "key_events": {"type": "array", "items": {"type": "string"}},
+        "dramatic_points": {"type": ["integer", "string"]}
+      }
+    },
+    "quality_metrics": {
+      "type": "object",
+      "properties": {
+        "score": {"type": ["number", "string"]}
+      }
+    }
+  }
+}`
+
+	outlineSchema = `{
+  "type": "object",
+  "required": ["title", "chapters"],
+  "properties": {
+    "title": {"type": "string"},
+    "summary": {"type": "string"},
+    "key_themes": {"type": "array", "items": {"type": "string"}},
+    "story_arcs": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "required": ["name"],
+        "properties": {
+          "start_chapter": {"type": ["integer", "string"]},
+          "end_chapter": {"type": ["integer", "string"]}
+        }
+      }
+    },
+    "chapters": {
+      "type": "array",
+      "minItems": 1,
+      "items": {
+        "type": "object",
+        "required": ["chapter_number", "title"],
+        "properties": {
+          "chapter_number": {"type": ["integer", "string"]},
+          "title": {"type": "string"},
+          "word_count": {"type": ["integer", "string"]}
+        }
+      }
+    }
+  }
+}`
+
+	chapterRevisionSchema = `{
+  "type": "object",
+  "required": ["title", "content"],
+  "properties": {
+    "title": {"type": "string", "minLength": 1},
+    "content": {"type": "string", "minLength": 1},
+    "summary": {"type": "string"},
+    "revision_notes": {"type": "array", "items": {"type": "string"}}
+  }
+}`
+
+	qualityReviewSchema = `{
+  "type": "object",
+  "required": ["overall_score", "issues"],
+  "properties": {
+    "overall_score": {"type": ["number", "string"]},
+    "strengths": {"type": "array", "items": {"type": "string"}},
+    "issues": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "required": ["type", "description"],
+        "properties": {
+          "type": {"type": "string"},
+          "location": {"type": "string"},
+          "description": {"type": "string"},
+          "suggestion": {"type": "string"}
+        }
+      }
+    },
+    "optimization_suggestions": {"type": "array", "items": {"type": "string"}}
+  }
+}`
+
+	chapterAnalysisSchema = `{
+  "type": "object",
+  "required": ["summary", "key_events"],
+  "properties": {
+    "summary": {"type": "string", "minLength": 1},
+    "goal": {"type": "string"},
+    "key_events": {"type": "array", "items": {"type": "string"}},
+    "character_development": {"type": "object"}
+  }
+}`
+)
+
+// defaultOutputSchemas 内置模板类型的输出Schema
+var defaultOutputSchemas = map[string]string{
+	"story_core":       storyCoreSchema,
+	"worldview":        worldviewSchema,
+	"character":        characterSchema,
+	"batch_character":  batchCharacterSchema,
+	"chapter":          chapterSchema,
+	"outline":          outlineSchema,
+	"quality_review":   qualityReviewSchema,
+	"chapter_revision": chapterRevisionSchema,
+	"chapter_analysis": chapterAnalysisSchema,
+}
+
+// DefaultOutputSchema 返回模板类型的默认输出Schema，未知类型返回nil
+func DefaultOutputSchema(templateType string) map[string]interface{} {
+	raw, ok := defaultOutputSchemas[templateType]
+	if !ok {
+		return nil
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &schema); err != nil {
+		panic("invalid default output schema for " + templateType + ": " + err.Error())
+	}
+	return schema
+}
@@ -19,8 +19,11 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"redquill-backend/pkg/common"
 	"redquill-backend/pkg/models"
 	"redquill-backend/pkg/utils/llm"
+	"redquill-backend/pkg/utils/prompt"
 )
 
 // PromptTemplateService Prompt模板服务
@@ -37,23 +40,39 @@ func NewPromptTemplateService(client *mongo.Client, dbName string) *PromptTempla
 	}
 }
 
-// PostPromptTemplates 创建Prompt模板
-func (s *PromptTemplateService) PostPromptTemplates(ctx context.Context, name, templateType, phase, content string, variables []string, description, creatorID, creator string) (models.PromptTemplate, error) {
+// ErrPromptTemplateNotFound Prompt模板不存在
+var ErrPromptTemplateNotFound = errors.New("prompt template not found")
+
+// ErrPromptTemplateTypeMismatch 指定的Prompt模板与生成类型不一致
+var ErrPromptTemplateTypeMismatch = errors.New("prompt template type mismatch")
+
+// ErrPromptTemplateProtected 内置模板与默认模板不可删除
+var ErrPromptTemplateProtected = errors.New("prompt template cannot be deleted")
+
+// PostPromptTemplates 创建Prompt模板，内容与声明的变量须一致；outputSchema 为 nil 时使用该类型的默认Schema
+func (s *PromptTemplateService) PostPromptTemplates(ctx context.Context, name, templateType, phase, content string, variables []string, description string, outputSchema map[string]interface{}, creatorID, creator string) (models.PromptTemplate, error) {
 	coll := s.client.Database(s.dbName).Collection("prompt_templates")
 
+	if err := prompt.Validate(content, variables); err != nil {
+		return models.PromptTemplate{}, err
+	}
+	if outputSchema == nil {
+		outputSchema = DefaultOutputSchema(templateType)
+	}
 	now := time.Now()
 	template := models.PromptTemplate{
-		Name:        name,
-		Type:        templateType,
-		Phase:       phase,
-		Content:     content,
-		Variables:   variables,
-		Description: description,
-		UsageCount:  0,
-		CreatorID:   creatorID,
-		Creator:     creator,
-		Ctime:       now.Unix(),
-		Mtime:       now.Unix(),
+		Name:         name,
+		Type:         templateType,
+		Phase:        phase,
+		Content:      content,
+		Variables:    variables,
+		Description:  description,
+		OutputSchema: outputSchema,
+		UsageCount:   0,
+		CreatorID:    creatorID,
+		Creator:      creator,
+		Ctime:        now.Unix(),
+		Mtime:        now.Unix(),
 	}
 
 	res, err := coll.InsertOne(ctx, template)
@@ -68,6 +87,118 @@ func (s *PromptTemplateService) PostPromptTemplates(ctx context.Context, name, t
 	return template, nil
 }
 
+// DeletePromptTemplates 删除Prompt模板，并移除各小说中指向该模板的覆盖。内置模板与默认模板不可删除
+func (s *PromptTemplateService) DeletePromptTemplates(ctx context.Context, id string) error {
+	template, err := s.GetPromptTemplate(ctx, id)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return ErrPromptTemplateNotFound
+		}
+		return err
+	}
+	if template.Key != "" {
+		return fmt.Errorf("%w: %s is a built-in template", ErrPromptTemplateProtected, id)
+	}
+	if template.IsDefault {
+		return fmt.Errorf("%w: %s is the default %s template, set another default first", ErrPromptTemplateProtected, id, template.Type)
+	}
+
+	db := s.client.Database(s.dbName)
+	oid, _ := primitive.ObjectIDFromHex(id)
+	if _, err := db.Collection("prompt_templates").DeleteOne(ctx, bson.M{"_id": oid}); err != nil {
+		return err
+	}
+	field := "prompt_templates." + template.Type
+	_, err = db.Collection("novels").UpdateMany(ctx, bson.M{field: id}, bson.M{"$unset": bson.M{field: ""}})
+	return err
+}
+
+// SetDefaultPromptTemplate 将模板设为其类型的默认模板，同一类型原来的默认模板取消默认
+func (s *PromptTemplateService) SetDefaultPromptTemplate(ctx context.Context, id string) (models.PromptTemplate, error) {
+	template, err := s.GetPromptTemplate(ctx, id)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return models.PromptTemplate{}, ErrPromptTemplateNotFound
+		}
+		return models.PromptTemplate{}, err
+	}
+	if template.IsDefault {
+		return template, nil
+	}
+
+	coll := s.client.Database(s.dbName).Collection("prompt_templates")
+	oid, _ := primitive.ObjectIDFromHex(id)
+	now := time.Now().Unix()
+	// 先取消原默认模板：(type, is_default=true) 上有唯一索引
+	if _, err := coll.UpdateMany(ctx, bson.M{"type": template.Type, "is_default": true, "_id": bson.M{"$ne": oid}},
+		bson.M{"$set": bson.M{"is_default": false, "mtime": now}}); err != nil {
+		return models.PromptTemplate{}, err
+	}
+	if _, err := coll.UpdateOne(ctx, bson.M{"_id": oid}, bson.M{"$set": bson.M{"is_default": true, "mtime": now}}); err != nil {
+		return models.PromptTemplate{}, err
+	}
+	template.IsDefault = true
+	template.Mtime = now
+	return template, nil
+}
+
+// PutNovelPromptTemplates 替换小说的模板覆盖，键为模板类型、值为模板ID，值为空的项被忽略
+func (s *PromptTemplateService) PutNovelPromptTemplates(ctx context.Context, novelID string, overrides map[string]string) (map[string]string, error) {
+	oid, err := primitive.ObjectIDFromHex(novelID)
+	if err != nil {
+		return nil, errors.New("invalid id")
+	}
+
+	result := make(map[string]string, len(overrides))
+	for templateType, templateID := range overrides {
+		if templateID == "" {
+			continue
+		}
+		if _, err := s.GetPromptTemplateOfType(ctx, templateID, templateType); err != nil {
+			return nil, err
+		}
+		result[templateType] = templateID
+	}
+
+	update := bson.M{"$set": bson.M{"prompt_templates": result, "mtime": time.Now().Unix()}}
+	if len(result) == 0 {
+		update = bson.M{"$unset": bson.M{"prompt_templates": ""}, "$set": bson.M{"mtime": time.Now().Unix()}}
+	}
+	res, err := s.client.Database(s.dbName).Collection("novels").UpdateOne(ctx, bson.M{"_id": oid}, update)
+	if err != nil {
+		return nil, err
+	}
+	if res.MatchedCount == 0 {
+		return nil, ErrNovelNotFound
+	}
+	return result, nil
+}
+
+// GetPromptTemplateOfType 获取指定的Prompt模板并检查其类型
+func (s *PromptTemplateService) GetPromptTemplateOfType(ctx context.Context, id, templateType string) (models.PromptTemplate, error) {
+	template, err := s.GetPromptTemplate(ctx, id)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return models.PromptTemplate{}, fmt.Errorf("%w: %s", ErrPromptTemplateNotFound, id)
+		}
+		return models.PromptTemplate{}, err
+	}
+	if template.Type != templateType {
+		return models.PromptTemplate{}, fmt.Errorf("%w: %s is a %s template, expected %s", ErrPromptTemplateTypeMismatch, id, template.Type, templateType)
+	}
+	return template, nil
+}
+
+// IsPromptTemplateType 是否为支持的模板类型
+func IsPromptTemplateType(templateType string) bool {
+	for _, template := range builtinPromptTemplates() {
+		if template.Type == templateType {
+			return true
+		}
+	}
+	return false
+}
+
 // GetPromptTemplates 获取Prompt模板
 func (s *PromptTemplateService) GetPromptTemplates(ctx context.Context, templateType string) ([]models.PromptTemplate, error) {
 	coll := s.client.Database(s.dbName).Collection("prompt_templates")
@@ -86,8 +217,140 @@ func (s *PromptTemplateService) GetPromptTemplates(ctx context.Context, template
 	return templates, nil
 }
 
+// GetPromptTemplate 获取Prompt模板详情
+func (s *PromptTemplateService) GetPromptTemplate(ctx context.Context, id string) (models.PromptTemplate, error) {
+	coll := s.client.Database(s.dbName).Collection("prompt_templates")
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.PromptTemplate{}, errors.New("invalid id")
+	}
+
+	var template models.PromptTemplate
+	if err := coll.FindOne(ctx, bson.M{"_id": oid}).Decode(&template); err != nil {
+		return models.PromptTemplate{}, err
+	}
+
+	return template, nil
+}
+
+// PutPromptTemplates 更新Prompt模板
+func (s *PromptTemplateService) PutPromptTemplates(ctx context.Context, id string, name *string, phase *string, content *string, variables *[]string, description *string, outputSchema *map[string]interface{}) (models.PromptTemplate, error) {
+	coll := s.client.Database(s.dbName).Collection("prompt_templates")
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.PromptTemplate{}, errors.New("invalid id")
+	}
+
+	// 内容或变量变化时校验两者是否一致
+	if content != nil || variables != nil {
+		existing, err := s.GetPromptTemplate(ctx, id)
+		if err != nil {
+			return models.PromptTemplate{}, err
+		}
+		if content != nil {
+			existing.Content = *content
+		}
+		if variables != nil {
+			existing.Variables = *variables
+		}
+		if err := prompt.Validate(existing.Content, existing.Variables); err != nil {
+			return models.PromptTemplate{}, err
+		}
+	}
+
+	update := bson.M{"mtime": time.Now().Unix()}
+	set := bson.M{}
+
+	if name != nil {
+		set["name"] = *name
+	}
+	if phase != nil {
+		set["phase"] = *phase
+	}
+	if content != nil {
+		set["content"] = *content
+	}
+	if variables != nil {
+		set["variables"] = *variables
+	}
+	if description != nil {
+		set["description"] = *description
+	}
+	if outputSchema != nil {
+		set["output_schema"] = *outputSchema
+	}
+
+	for k, v := range set {
+		update[k] = v
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var out models.PromptTemplate
+	if err := coll.FindOneAndUpdate(ctx, bson.M{"_id": oid}, bson.M{"$set": update}, opts).Decode(&out); err != nil {
+		return models.PromptTemplate{}, err
+	}
+
+	return out, nil
+}
+
+// ListPromptTemplates 分页查询Prompt模板列表，templateType为空时返回全部类型
+func (s *PromptTemplateService) ListPromptTemplates(ctx context.Context, templateType string, page, pageSize int64, sortExpr, keyword string) (PagedPromptTemplates, error) {
+	coll := s.client.Database(s.dbName).Collection("prompt_templates")
+
+	// 构建过滤条件
+	typeFilter := bson.M{}
+	if templateType != "" {
+		typeFilter["type"] = templateType
+	}
+	kwFilter := common.BuildKeywordFilter(keyword, []string{"name", "description", "content"})
+	filter := common.MergeFilters(typeFilter, kwFilter)
+
+	// 构建选项
+	sort := common.BuildSort(sortExpr)
+	opts := common.BuildFindOptions(page, pageSize, sort, bson.M{})
+
+	items, total, err := common.FindWithPagination[models.PromptTemplate](ctx, coll, filter, opts)
+	if err != nil {
+		return PagedPromptTemplates{}, err
+	}
+
+	totalPages := total / common.NormalizePageSize(pageSize)
+	if total%common.NormalizePageSize(pageSize) != 0 {
+		totalPages++
+	}
+
+	return PagedPromptTemplates{
+		Items: items,
+		Pagination: common.Pagination{
+			Page:      common.NormalizePage(page),
+			PageSize:  common.NormalizePageSize(pageSize),
+			Total:     total,
+			TotalPage: totalPages,
+		},
+	}, nil
+}
+
+// PagedPromptTemplates 分页Prompt模板结果
+type PagedPromptTemplates struct {
+	Items      []models.PromptTemplate `json:"items"`
+	Pagination common.Pagination       `json:"pagination"`
+}
+
 // GenerateWithLLM 使用LLM生成内容
-func (s *PromptTemplateService) GenerateWithLLM(ctx context.Context, req models.GenerationRequest) (models.GenerationResponse, error) {
+func (s *PromptTemplateService) GenerateWithLLM(ctx context.Context, req models.GenerationRequest) (result models.GenerationResponse, err error) {
+	// 记录生成日志：Prompt、原始输出、解析结果与用量
+	start := time.Now()
+	usage := &llm.Usage{}
+	entry := newGenerationLog(ctx, req)
+	defer func() {
+		var genErr error
+		if !result.Success {
+			genErr = errors.New(result.Error)
+		}
+		entry.Result = result.Data
+		recordGenerationLog(ctx, s.client, s.dbName, entry, usage, start, genErr)
+	}()
+
 	// 获取LLM模型配置
 	llmModel, err := s.getLLMModel(ctx, req.LLMModelID)
 	if err != nil {
@@ -99,7 +362,7 @@ func (s *PromptTemplateService) GenerateWithLLM(ctx context.Context, req models.
 	}
 
 	// 获取Prompt模板
-	template, err := s.getPromptTemplate(ctx, req.TemplateType)
+	template, err := s.resolvePromptTemplate(ctx, req)
 	if err != nil {
 		return models.GenerationResponse{
 			Success: false,
@@ -107,9 +370,11 @@ func (s *PromptTemplateService) GenerateWithLLM(ctx context.Context, req models.
 			Error:   err.Error(),
 		}, nil
 	}
+	entry.TemplateID = template.ID
+	entry.TemplateVersion = template.Version
 
 	// 构建完整的Prompt
-	fullPrompt, err := s.buildPrompt(template.Content, req.InputData)
+	fullPrompt, err := s.buildPrompt(template, req.InputData)
 	if err != nil {
 		return models.GenerationResponse{
 			Success: false,
@@ -118,20 +383,8 @@ func (s *PromptTemplateService) GenerateWithLLM(ctx context.Context, req models.
 		}, nil
 	}
 
-	// 创建LLM客户端
-	timeout := llmModel.Config.Timeout
-	if timeout <= 0 {
-		timeout = 300 // 默认5分钟超时
-	}
-	llmConfig := llm.LLMConfig{
-		Provider: llmModel.Config.Provider,
-		BaseURL:  llmModel.Config.BaseURL,
-		APIKey:   llmModel.Config.APIKey,
-		Model:    llmModel.Config.ModelName,
-		Timeout:  time.Duration(timeout) * time.Second,
-	}
-
-	client, err := llm.NewClient(llmConfig)
+	// 创建LLM客户端（首选模型 + 回退模型）
+	client, err := s.newGenerationClient(ctx, llmModel, req.FallbackModelIDs)
 	if err != nil {
 		return models.GenerationResponse{
 			Success: false,
@@ -152,18 +405,28 @@ func (s *PromptTemplateService) GenerateWithLLM(ctx context.Context, req models.
 	chatReq := llm.ChatRequest{
 		Model:       llmModel.Config.ModelName,
 		Messages:    messages,
-		Stream:      req.Stream,
-		Temperature: llmModel.Config.Temperature,
-		MaxTokens:   llmModel.Config.MaxTokens,
+		Stream:         req.Stream,
+		Temperature:    llmModel.Config.Temperature,
+		MaxTokens:      llmModel.Config.MaxTokens,
+		ResponseFormat: responseFormatFor(template),
 	}
+	entry.Model = llmModel.Config.ModelName
+	setGenerationLogMessages(entry, messages)
 
 	var response string
 	var tokenCount int64
 
+	// 记录用量
+	var callErr error
+	defer func() {
+		s.recordUsage(ctx, req, llmModel, client.ServedBy(), usage, start, callErr)
+	}()
+
 	if req.Stream {
 		// 流式生成
 		stream, err := client.ChatStream(ctx, chatReq)
 		if err != nil {
+			callErr = err
 			return models.GenerationResponse{
 				Success: false,
 				Message: "Stream generation failed",
@@ -173,6 +436,7 @@ func (s *PromptTemplateService) GenerateWithLLM(ctx context.Context, req models.
 
 		for chunk := range stream {
 			if chunk.Error != nil {
+				callErr = chunk.Error
 				return models.GenerationResponse{
 					Success: false,
 					Message: "Stream generation error",
@@ -189,12 +453,15 @@ func (s *PromptTemplateService) GenerateWithLLM(ctx context.Context, req models.
 			// 统计token使用量
 			if chunk.Usage != nil {
 				tokenCount += chunk.Usage.TotalTokens
+				addUsage(usage, chunk.Usage)
 			}
 		}
+		estimateStreamUsage(usage, messages, response)
 	} else {
 		// 同步生成
 		resp, err := client.Chat(ctx, chatReq)
 		if err != nil {
+			callErr = err
 			return models.GenerationResponse{
 				Success: false,
 				Message: "Chat generation failed",
@@ -209,11 +476,16 @@ func (s *PromptTemplateService) GenerateWithLLM(ctx context.Context, req models.
 		// 统计token使用量
 		if resp.Usage != nil {
 			tokenCount = resp.Usage.TotalTokens
+			addUsage(usage, resp.Usage)
 		}
 	}
 
-	// 解析响应为结构化数据
-	structuredData, err := s.parseResponse(response, req.TemplateType)
+	entry.Response = response
+	entry.ServedModelID = client.ServedBy()
+
+	// 解析响应为结构化数据，不符合输出Schema时请求模型修复
+	structuredData, err := s.parseWithRepair(ctx, client, chatReq, response, template, usage)
+	tokenCount = usage.TotalTokens
 	if err != nil {
 		return models.GenerationResponse{
 			Success: false,
@@ -222,18 +494,21 @@ func (s *PromptTemplateService) GenerateWithLLM(ctx context.Context, req models.
 		}, nil
 	}
 
-	// 更新模型使用次数
-	s.updateLLMModelUsage(ctx, req.LLMModelID)
+	// 更新实际提供服务的模型使用次数
+	servedModelID := client.ServedBy()
+	s.updateLLMModelUsage(ctx, servedModelID)
+	s.recordServedModel(ctx, req, servedModelID)
 
 	// 更新模板使用次数
-	s.updateTemplateUsage(ctx, req.TemplateType)
+	s.updateTemplateUsage(ctx, template.ID)
 
 	return models.GenerationResponse{
-		Success:    true,
-		Message:    "Generation successful",
-		Data:       structuredData,
-		UsageCount: llmModel.UsageCount + 1,
-		TokenCount: tokenCount,
+		Success:       true,
+		Message:       "Generation successful",
+		Data:          structuredData,
+		UsageCount:    llmModel.UsageCount + 1,
+		TokenCount:    tokenCount,
+		ServedModelID: servedModelID,
 	}, nil
 }
 
@@ -253,67 +528,212 @@ func (s *PromptTemplateService) getLLMModel(ctx context.Context, modelID string)
 	return llmModel, nil
 }
 
-// getPromptTemplate 获取Prompt模板
-func (s *PromptTemplateService) getPromptTemplate(ctx context.Context, templateType string) (models.PromptTemplate, error) {
-	coll := s.client.Database(s.dbName).Collection("prompt_templates")
+// resolvePromptTemplate 确定生成使用的Prompt模板：请求指定的模板 > 小说的模板覆盖 > 该类型的默认模板。
+// 小说覆盖的模板已被删除时忽略；没有默认模板时用户创建的模板优先于内置模板（没有 key 的排在前面）
+func (s *PromptTemplateService) resolvePromptTemplate(ctx context.Context, req models.GenerationRequest) (models.PromptTemplate, error) {
+	if req.TemplateID != "" {
+		return s.GetPromptTemplateOfType(ctx, req.TemplateID, req.TemplateType)
+	}
+
+	db := s.client.Database(s.dbName)
+	if oid, err := primitive.ObjectIDFromHex(req.NovelID); err == nil {
+		var novel models.Novel
+		err := db.Collection("novels").FindOne(ctx, bson.M{"_id": oid},
+			options.FindOne().SetProjection(bson.M{"prompt_templates": 1})).Decode(&novel)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return models.PromptTemplate{}, err
+		}
+		if templateID := novel.PromptTemplates[req.TemplateType]; templateID != "" {
+			template, err := s.GetPromptTemplateOfType(ctx, templateID, req.TemplateType)
+			if err == nil {
+				return template, nil
+			}
+			if !errors.Is(err, ErrPromptTemplateNotFound) && !errors.Is(err, ErrPromptTemplateTypeMismatch) {
+				return models.PromptTemplate{}, err
+			}
+		}
+	}
 
+	coll := db.Collection("prompt_templates")
 	var template models.PromptTemplate
-	if err := coll.FindOne(ctx, bson.M{"type": templateType}).Decode(&template); err != nil {
+	opts := options.FindOne().SetSort(bson.D{{Key: "is_default", Value: -1}, {Key: "key", Value: 1}, {Key: "ctime", Value: 1}})
+	if err := coll.FindOne(ctx, bson.M{"type": req.TemplateType}, opts).Decode(&template); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return models.PromptTemplate{}, fmt.Errorf("%w: no %s template", ErrPromptTemplateNotFound, req.TemplateType)
+		}
 		return models.PromptTemplate{}, err
 	}
 
 	return template, nil
 }
 
-// buildPrompt 构建完整Prompt
-func (s *PromptTemplateService) buildPrompt(templateContent string, inputData map[string]interface{}) (string, error) {
-	prompt := templateContent
+// buildPrompt 构建完整Prompt：输入数据须包含模板声明的全部变量
+func (s *PromptTemplateService) buildPrompt(template models.PromptTemplate, inputData map[string]interface{}) (string, error) {
+	return prompt.Render(template.Content, template.Variables, inputData)
+}
 
-	// 替换变量
-	for key, value := range inputData {
-		placeholder := fmt.Sprintf("{%s}", key)
-		valueStr := fmt.Sprintf("%v", value)
-		prompt = strings.ReplaceAll(prompt, placeholder, valueStr)
-	}
+// maxRepairAttempts 输出不符合Schema时请求模型修复的次数
+const maxRepairAttempts = 1
+
+// OutputValidationError 模型输出不符合模板的输出Schema
+type OutputValidationError struct {
+	Errors []string
+}
 
-	return prompt, nil
+func (e *OutputValidationError) Error() string {
+	return "output does not match schema: " + strings.Join(e.Errors, "; ")
 }
 
-// parseResponse 解析响应为结构化数据
-func (s *PromptTemplateService) parseResponse(response, templateType string) (map[string]interface{}, error) {
-	// 根据模板类型解析不同的JSON结构
+// parseResponse 解析响应为结构化数据：提取JSON（兼容代码块和前后说明文字）并按模板的输出Schema校验
+func (s *PromptTemplateService) parseResponse(response string, template models.PromptTemplate) (map[string]interface{}, error) {
+	// 未声明输出Schema的模板允许非JSON输出，返回原始响应
+	rawResult := map[string]interface{}{
+		"raw_response":  response,
+		"template_type": template.Type,
+	}
+
+	jsonText, rest, err := common.ExtractJSON(response)
+	if err != nil {
+		if len(template.OutputSchema) == 0 {
+			return rawResult, nil
+		}
+		return nil, err
+	}
 	var result map[string]interface{}
-	
-	// 尝试解析JSON响应
-	if err := json.Unmarshal([]byte(response), &result); err != nil {
-		// 如果不是JSON，返回原始响应
-		return map[string]interface{}{
-			"raw_response":  response,
-			"template_type": templateType,
-		}, nil
+	if err := json.Unmarshal([]byte(jsonText), &result); err != nil {
+		if len(template.OutputSchema) == 0 {
+			return rawResult, nil
+		}
+		return nil, fmt.Errorf("output must be a JSON object: %w", err)
+	}
+
+	// 兼容先输出元数据JSON、再输出正文的旧版章节模板
+	if _, ok := result["content"]; !ok && rest != "" && schemaHasProperty(template.OutputSchema, "content") {
+		result["content"] = rest
+	}
+
+	if errs := common.ValidateJSONSchema(template.OutputSchema, result); len(errs) > 0 {
+		return nil, &OutputValidationError{Errors: errs}
 	}
-	
+
 	// 根据模板类型返回相应的数据结构
-	switch templateType {
-	case "story_core":
+	if template.Type == "story_core" {
 		if concepts, ok := result["concepts"]; ok {
 			return map[string]interface{}{
 				"concepts": concepts,
 			}, nil
 		}
-	case "worldview":
-		return result, nil
-	case "character":
-		return result, nil
-	case "chapter":
-		return result, nil
-	default:
-		return result, nil
 	}
-	
 	return result, nil
 }
 
+// schemaHasProperty Schema 顶层是否声明了指定字段
+func schemaHasProperty(schema map[string]interface{}, key string) bool {
+	props, ok := schema["properties"].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	_, ok = props[key]
+	return ok
+}
+
+// parseWithRepair 解析响应，失败时把错误和Schema发回实际提供服务的模型要求修正，修复调用的用量累加到 usage
+func (s *PromptTemplateService) parseWithRepair(ctx context.Context, client *llm.FallbackClient, chatReq llm.ChatRequest, response string, template models.PromptTemplate, usage *llm.Usage) (map[string]interface{}, error) {
+	data, err := s.parseResponse(response, template)
+	for attempt := 0; err != nil && attempt < maxRepairAttempts; attempt++ {
+		repairReq := chatReq
+		repairReq.Stream = false
+		repairReq.Messages = append(append([]llm.Message{}, chatReq.Messages...),
+			llm.Message{Role: "assistant", Content: response},
+			llm.Message{Role: "user", Content: buildRepairPrompt(err, template.OutputSchema)},
+		)
+
+		resp, repairErr := client.Pinned().Chat(ctx, repairReq)
+		if repairErr != nil {
+			return nil, fmt.Errorf("%v (repair failed: %v)", err, repairErr)
+		}
+		if resp.Usage != nil {
+			addUsage(usage, resp.Usage)
+		}
+		if len(resp.Choices) == 0 {
+			return nil, fmt.Errorf("%v (repair returned no output)", err)
+		}
+		response = resp.Choices[0].Message.Content
+		data, err = s.parseResponse(response, template)
+	}
+	return data, err
+}
+
+// buildRepairPrompt 构建修复输出的提示
+func buildRepairPrompt(parseErr error, schema map[string]interface{}) string {
+	var b strings.Builder
+	b.WriteString("你上一次的输出无法解析为符合要求的JSON，问题如下：\n")
+	var validationErr *OutputValidationError
+	if errors.As(parseErr, &validationErr) {
+		for _, e := range validationErr.Errors {
+			b.WriteString("- " + e + "\n")
+		}
+	} else {
+		b.WriteString("- " + parseErr.Error() + "\n")
+	}
+	b.WriteString("\n请修正后重新输出完整结果：只输出一个JSON对象，不要使用代码块，不要包含任何解释文字。")
+	if schemaJSON, err := json.MarshalIndent(schema, "", "  "); err == nil && len(schema) > 0 {
+		b.WriteString("输出必须符合以下JSON Schema：\n")
+		b.Write(schemaJSON)
+	}
+	return b.String()
+}
+
+// ParseGenerationOutput 解析流式生成累积的完整输出，不符合Schema时请求实际提供服务的模型修复一次
+func (s *PromptTemplateService) ParseGenerationOutput(ctx context.Context, req models.GenerationRequest, servedModelID, response string) (map[string]interface{}, error) {
+	template, err := s.resolvePromptTemplate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	data, err := s.parseResponse(response, template)
+	if err == nil {
+		return data, nil
+	}
+
+	// 修复使用实际提供服务的模型
+	if servedModelID == "" {
+		servedModelID = req.LLMModelID
+	}
+	llmModel, modelErr := s.getLLMModel(ctx, servedModelID)
+	if modelErr != nil {
+		return nil, err
+	}
+	client, clientErr := s.newGenerationClient(ctx, llmModel, nil)
+	if clientErr != nil {
+		return nil, err
+	}
+	fullPrompt, promptErr := s.buildPrompt(template, req.InputData)
+	if promptErr != nil {
+		return nil, err
+	}
+	chatReq := llm.ChatRequest{
+		Model:          llmModel.Config.ModelName,
+		Messages:       []llm.Message{{Role: "user", Content: fullPrompt}},
+		Temperature:    llmModel.Config.Temperature,
+		MaxTokens:      llmModel.Config.MaxTokens,
+		ResponseFormat: responseFormatFor(template),
+	}
+
+	start := time.Now()
+	usage := &llm.Usage{}
+	data, err = s.parseWithRepair(ctx, client, chatReq, response, template, usage)
+	s.recordUsage(ctx, req, llmModel, client.ServedBy(), usage, start, nil)
+	return data, err
+}
+
+// responseFormatFor 声明了输出Schema的模板请求原生JSON模式（不支持的提供商会忽略）
+func responseFormatFor(template models.PromptTemplate) *llm.ResponseFormat {
+	if len(template.OutputSchema) == 0 {
+		return nil
+	}
+	return llm.JSONObjectFormat
+}
+
 // updateLLMModelUsage 更新LLM模型使用次数
 func (s *PromptTemplateService) updateLLMModelUsage(ctx context.Context, modelID string) {
 	coll := s.client.Database(s.dbName).Collection("llm_models")
@@ -324,10 +744,113 @@ func (s *PromptTemplateService) updateLLMModelUsage(ctx context.Context, modelID
 	})
 }
 
+// newGenerationClient 创建生成用的客户端：首选模型在前，回退模型按请求顺序排列
+func (s *PromptTemplateService) newGenerationClient(ctx context.Context, primary models.LLMModel, fallbackModelIDs []string) (*llm.FallbackClient, error) {
+	target, err := newFallbackTarget(primary)
+	if err != nil {
+		return nil, err
+	}
+	targets := []llm.FallbackTarget{target}
+
+	seen := map[string]bool{primary.ID: true}
+	for _, id := range fallbackModelIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		fallbackModel, err := s.getLLMModel(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("fallback model %s: %w", id, err)
+		}
+		if fallbackModel.Status != "active" {
+			continue
+		}
+		target, err := newFallbackTarget(fallbackModel)
+		if err != nil {
+			return nil, fmt.Errorf("fallback model %s: %w", id, err)
+		}
+		targets = append(targets, target)
+	}
+
+	return llm.NewFallbackClient(targets...)
+}
+
+// newFallbackTarget 根据模型配置创建回退链节点
+func newFallbackTarget(llmModel models.LLMModel) (llm.FallbackTarget, error) {
+	apiKey, err := DecryptAPIKey(llmModel.Config)
+	if err != nil {
+		return llm.FallbackTarget{}, err
+	}
+
+	timeout := llmModel.Config.Timeout
+	if timeout <= 0 {
+		timeout = 300 // 默认5分钟超时
+	}
+	llmConfig := llm.LLMConfig{
+		Provider: llmModel.Config.Provider,
+		BaseURL:  llmModel.Config.BaseURL,
+		APIKey:   apiKey,
+		Model:    llmModel.Config.ModelName,
+		Timeout:  time.Duration(timeout) * time.Second,
+	}
+	applyRetryConfig(&llmConfig, llmModel.Config)
+
+	client, err := llm.NewClient(llmConfig)
+	if err != nil {
+		return llm.FallbackTarget{}, err
+	}
+
+	return llm.FallbackTarget{
+		ID:          llmModel.ID,
+		Client:      client,
+		Model:       llmModel.Config.ModelName,
+		Temperature: llmModel.Config.Temperature,
+		MaxTokens:   llmModel.Config.MaxTokens,
+		JSONMode:    llm.SupportsJSONMode(llmModel.Config.Provider),
+	}, nil
+}
+
+// recordUsage 记录生成调用的用量，费用按实际提供服务的模型计算
+func (s *PromptTemplateService) recordUsage(ctx context.Context, req models.GenerationRequest, primary models.LLMModel, servedModelID string, usage *llm.Usage, start time.Time, callErr error) {
+	served := primary
+	if servedModelID != "" && servedModelID != primary.ID {
+		if fallbackModel, err := s.getLLMModel(context.WithoutCancel(ctx), servedModelID); err == nil {
+			served = fallbackModel
+		}
+	}
+
+	record := models.UsageRecord{
+		UserID:       req.UserID,
+		NovelID:      req.NovelID,
+		TemplateType: req.TemplateType,
+		Source:       models.UsageSourceGeneration,
+	}
+	recordLLMUsage(ctx, s.client, s.dbName, record, served, usage, start, callErr)
+}
+
+// recordServedModel 在小说ExtraInfo中记录实际提供服务的模型
+func (s *PromptTemplateService) recordServedModel(ctx context.Context, req models.GenerationRequest, servedModelID string) {
+	if req.NovelID == "" || servedModelID == "" {
+		return
+	}
+	info := map[string]interface{}{
+		"llm_model_id":           servedModelID,
+		"requested_llm_model_id": req.LLMModelID,
+		"fallback":               servedModelID != req.LLMModelID,
+		"time":                   time.Now().Unix(),
+	}
+	_ = NewNovelService(s.client, s.dbName).UpdateNovelExtraInfo(ctx, req.NovelID, "served_models."+req.TemplateType, info)
+}
+
 // updateTemplateUsage 更新模板使用次数
-func (s *PromptTemplateService) updateTemplateUsage(ctx context.Context, templateType string) {
+func (s *PromptTemplateService) updateTemplateUsage(ctx context.Context, templateID string) {
 	coll := s.client.Database(s.dbName).Collection("prompt_templates")
-	coll.UpdateOne(ctx, bson.M{"type": templateType}, bson.M{
+	oid, err := primitive.ObjectIDFromHex(templateID)
+	if err != nil {
+		return
+	}
+	coll.UpdateOne(ctx, bson.M{"_id": oid}, bson.M{
 		"$inc": bson.M{"usage_count": 1},
 		"$set": bson.M{"mtime": time.Now().Unix()},
 	})
@@ -338,56 +861,48 @@ type StreamChunk struct {
 	Content string `json:"content"`
 	Done    bool   `json:"done"`
 	Error   error  `json:"error,omitempty"`
+	ModelID string `json:"model_id,omitempty"` // 实际提供服务的模型ID，仅在完成时返回
+	LogID   string `json:"log_id,omitempty"`   // 生成日志ID，仅在完成时返回，用于写入解析结果
 }
 
 // GenerateWithLLMStream 流式LLM生成
 func (s *PromptTemplateService) GenerateWithLLMStream(ctx context.Context, req models.GenerationRequest) (<-chan StreamChunk, error) {
-	// 获取LLM模型配置
-	llmModel, err := s.getLLMModel(ctx, req.LLMModelID)
-	if err != nil {
+	start := time.Now()
+	entry := newGenerationLog(ctx, req)
+	entry.Stream = true
+	// fail 记录失败的生成日志，并返回只包含该错误的数据块
+	fail := func(err error) (<-chan StreamChunk, error) {
+		recordGenerationLog(ctx, s.client, s.dbName, entry, nil, start, err)
 		ch := make(chan StreamChunk, 1)
 		ch <- StreamChunk{Error: err}
 		close(ch)
 		return ch, nil
 	}
 
-	// 获取Prompt模板
-	template, err := s.getPromptTemplate(ctx, req.TemplateType)
+	// 获取LLM模型配置
+	llmModel, err := s.getLLMModel(ctx, req.LLMModelID)
 	if err != nil {
-		ch := make(chan StreamChunk, 1)
-		ch <- StreamChunk{Error: err}
-		close(ch)
-		return ch, nil
+		return fail(err)
 	}
 
-	// 构建完整的Prompt
-	fullPrompt, err := s.buildPrompt(template.Content, req.InputData)
+	// 获取Prompt模板
+	template, err := s.resolvePromptTemplate(ctx, req)
 	if err != nil {
-		ch := make(chan StreamChunk, 1)
-		ch <- StreamChunk{Error: err}
-		close(ch)
-		return ch, nil
+		return fail(err)
 	}
+	entry.TemplateID = template.ID
+	entry.TemplateVersion = template.Version
 
-	// 创建LLM客户端
-	timeout := llmModel.Config.Timeout
-	if timeout <= 0 {
-		timeout = 300 // 默认5分钟超时
-	}
-	llmConfig := llm.LLMConfig{
-		Provider: llmModel.Config.Provider,
-		BaseURL:  llmModel.Config.BaseURL,
-		APIKey:   llmModel.Config.APIKey,
-		Model:    llmModel.Config.ModelName,
-		Timeout:  time.Duration(timeout) * time.Second,
+	// 构建完整的Prompt
+	fullPrompt, err := s.buildPrompt(template, req.InputData)
+	if err != nil {
+		return fail(err)
 	}
 
-	client, err := llm.NewClient(llmConfig)
+	// 创建LLM客户端（首选模型 + 回退模型）
+	client, err := s.newGenerationClient(ctx, llmModel, req.FallbackModelIDs)
 	if err != nil {
-		ch := make(chan StreamChunk, 1)
-		ch <- StreamChunk{Error: err}
-		close(ch)
-		return ch, nil
+		return fail(err)
 	}
 
 	// 构建消息
@@ -402,56 +917,93 @@ func (s *PromptTemplateService) GenerateWithLLMStream(ctx context.Context, req m
 	chatReq := llm.ChatRequest{
 		Model:       llmModel.Config.ModelName,
 		Messages:    messages,
-		Stream:      true,
-		Temperature: llmModel.Config.Temperature,
-		MaxTokens:   llmModel.Config.MaxTokens,
+		Stream:         true,
+		Temperature:    llmModel.Config.Temperature,
+		MaxTokens:      llmModel.Config.MaxTokens,
+		ResponseFormat: responseFormatFor(template),
 	}
+	entry.Model = llmModel.Config.ModelName
+	setGenerationLogMessages(entry, messages)
 
 	stream, err := client.ChatStream(ctx, chatReq)
 	if err != nil {
-		ch := make(chan StreamChunk, 1)
-		ch <- StreamChunk{Error: err}
-		close(ch)
-		return ch, nil
+		return fail(err)
 	}
 
 	// 转换流式响应
 	result := make(chan StreamChunk, 100)
 	go func() {
 		defer close(result)
-		defer s.updateLLMModelUsage(ctx, req.LLMModelID)
-		defer s.updateTemplateUsage(ctx, req.TemplateType)
+		defer s.updateTemplateUsage(ctx, template.ID)
+
+		usage := &llm.Usage{}
+		var callErr error
+		var content strings.Builder
+		logged := false
+		defer func() {
+			estimateStreamUsage(usage, messages, content.String())
+			servedModelID := client.ServedBy()
+			s.updateLLMModelUsage(ctx, servedModelID)
+			s.recordServedModel(ctx, req, servedModelID)
+			s.recordUsage(ctx, req, llmModel, servedModelID, usage, start, callErr)
+			if !logged {
+				entry.Response = content.String()
+				entry.ServedModelID = servedModelID
+				recordGenerationLog(ctx, s.client, s.dbName, entry, usage, start, callErr)
+			}
+		}()
+
+		// 完成原因之后厂商仍会发送仅含用量的数据块，需读到流关闭
+		finished := false
 
 		for chunk := range stream {
 			if chunk.Error != nil {
+				callErr = chunk.Error
 				result <- StreamChunk{Error: chunk.Error}
 				return
 			}
+			if chunk.Usage != nil {
+				addUsage(usage, chunk.Usage)
+			}
 
 			// 提取内容
-			content := ""
+			delta := ""
 			for _, choice := range chunk.Choices {
 				if choice.Delta.Content != "" {
-					content += choice.Delta.Content
+					delta += choice.Delta.Content
 				}
 			}
 
-			if content != "" {
+			if delta != "" {
+				content.WriteString(delta)
 				result <- StreamChunk{
-					Content: content,
+					Content: delta,
 					Done:    false,
 				}
 			}
 
 			// 检查是否完成
 			if len(chunk.Choices) > 0 && chunk.Choices[0].FinishReason != "" {
-				result <- StreamChunk{
-					Content: "",
-					Done:    true,
-				}
-				return
+				finished = true
 			}
 		}
+		if !finished {
+			return
+		}
+
+		// 先写入日志，调用方收到完成块后即可写入解析结果
+		estimateStreamUsage(usage, messages, content.String())
+		entry.Response = content.String()
+		entry.ServedModelID = client.ServedBy()
+		logID := recordGenerationLog(ctx, s.client, s.dbName, entry, usage, start, nil)
+		logged = true
+
+		result <- StreamChunk{
+			Content: "",
+			Done:    true,
+			ModelID: client.ServedBy(),
+			LogID:   logID,
+		}
 	}()
 
 	return result, nil
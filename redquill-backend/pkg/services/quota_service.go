@@ -0,0 +1,276 @@
+// Package services
+/*
+/@Author: urmsone urmsone@163.com
+/@Date: 2025/10/20 20:44
+/@Name: quota_service.go
+/@Description: Quota checking service implementation
+/*/
+
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"redquill-backend/pkg/common"
+	"redquill-backend/pkg/models"
+)
+
+// 配额主体
+const (
+	QuotaScopeUser  = "user"
+	QuotaScopeModel = "model"
+)
+
+// quotaScopeFields 配额主体对应的用量记录字段
+var quotaScopeFields = map[string]string{
+	QuotaScopeUser:  "user_id",
+	QuotaScopeModel: "llm_model_id",
+}
+
+// QuotaExceededError 配额超限
+type QuotaExceededError struct {
+	Scope   string    `json:"scope"`    // user|model
+	Subject string    `json:"subject"`  // 用户ID或模型ID
+	Quota   string    `json:"quota"`    // requests_per_minute|tokens_per_day|cost_per_month
+	Limit   float64   `json:"limit"`    // 配额上限
+	Used    float64   `json:"used"`     // 当前已用量
+	ResetAt time.Time `json:"reset_at"` // 配额重置时间
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("%s quota exceeded: %s (%g/%g)", e.Scope, e.Quota, e.Used, e.Limit)
+}
+
+// QuotaService 配额服务
+type QuotaService struct {
+	client *mongo.Client
+	dbName string
+}
+
+// NewQuotaService 创建配额服务
+func NewQuotaService(client *mongo.Client, dbName string) *QuotaService {
+	return &QuotaService{
+		client: client,
+		dbName: dbName,
+	}
+}
+
+// CheckQuota 检查主体是否超出配额，超限时返回 *QuotaExceededError。
+// limiter 不为空时每分钟请求数使用进程内计数（单实例），检查通过即占用一次请求名额，
+// 调用方后续的检查未通过时调用 release 归还；limiter 为空时从用量记录统计，release 不做任何事。
+// 每日token与每月费用始终从用量记录统计。
+func (s *QuotaService) CheckQuota(ctx context.Context, scope, subjectID string, quota models.Quota, limiter *common.SlidingWindowLimiter) (release func(), err error) {
+	release = func() {}
+	field, ok := quotaScopeFields[scope]
+	if !ok || subjectID == "" || quota.IsZero() {
+		return release, nil
+	}
+	now := time.Now()
+
+	// 每分钟请求数
+	if quota.RequestsPerMinute > 0 {
+		if limiter != nil {
+			key := scope + ":" + subjectID
+			ok, resetAt := limiter.Allow(key, quota.RequestsPerMinute, now)
+			if !ok {
+				return release, &QuotaExceededError{
+					Scope:   scope,
+					Subject: subjectID,
+					Quota:   "requests_per_minute",
+					Limit:   float64(quota.RequestsPerMinute),
+					Used:    float64(quota.RequestsPerMinute),
+					ResetAt: resetAt,
+				}
+			}
+			release = func() { limiter.Release(key, now) }
+		} else {
+			count, resetAt, err := s.countRecentCalls(ctx, field, subjectID, now.Add(-time.Minute), quota.RequestsPerMinute)
+			if err != nil {
+				return release, err
+			}
+			if count >= int64(quota.RequestsPerMinute) {
+				return release, &QuotaExceededError{
+					Scope:   scope,
+					Subject: subjectID,
+					Quota:   "requests_per_minute",
+					Limit:   float64(quota.RequestsPerMinute),
+					Used:    float64(count),
+					ResetAt: resetAt,
+				}
+			}
+		}
+	}
+
+	if quota.TokensPerDay <= 0 && quota.CostPerMonth <= 0 {
+		return release, nil
+	}
+
+	// 每日token与每月费用
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	tokensToday, costThisMonth, err := s.sumUsage(ctx, field, subjectID, dayStart, monthStart)
+	if err != nil {
+		// 统计失败时调用方不阻塞请求，保留占用的名额
+		return release, err
+	}
+
+	// 超限时归还本次占用的请求名额
+	if quota.TokensPerDay > 0 && tokensToday >= quota.TokensPerDay {
+		release()
+		return func() {}, &QuotaExceededError{
+			Scope:   scope,
+			Subject: subjectID,
+			Quota:   "tokens_per_day",
+			Limit:   float64(quota.TokensPerDay),
+			Used:    float64(tokensToday),
+			ResetAt: dayStart.AddDate(0, 0, 1),
+		}
+	}
+	if quota.CostPerMonth > 0 && costThisMonth >= quota.CostPerMonth {
+		release()
+		return func() {}, &QuotaExceededError{
+			Scope:   scope,
+			Subject: subjectID,
+			Quota:   "cost_per_month",
+			Limit:   quota.CostPerMonth,
+			Used:    costThisMonth,
+			ResetAt: monthStart.AddDate(0, 1, 0),
+		}
+	}
+
+	return release, nil
+}
+
+// DefaultQuotas 用户或模型没有单独配置配额时使用的默认配额
+type DefaultQuotas struct {
+	User  models.Quota
+	Model models.Quota
+}
+
+// CheckGenerationQuota 检查用户及本次可能调用的各模型的配额，单独配置的配额优先于默认配额。
+// 全部通过时每分钟请求名额保持占用，返回的 release 可归还；任一超限时已占用的名额先归还，
+// 再返回 *QuotaExceededError。统计失败只记日志，不阻塞请求
+func (s *QuotaService) CheckGenerationQuota(ctx context.Context, userID string, modelIDs []string, defaults DefaultQuotas, limiter *common.SlidingWindowLimiter) (func(), error) {
+	return s.checkGenerationQuota(ctx, userID, modelIDs, defaults, limiter, true)
+}
+
+// CheckUsageQuota 只检查用户及各模型的每日token与每月费用，不计每分钟请求数；
+// 用于一个任务内的多次调用之间（如逐章分析）
+func (s *QuotaService) CheckUsageQuota(ctx context.Context, userID string, modelIDs []string, defaults DefaultQuotas) error {
+	_, err := s.checkGenerationQuota(ctx, userID, modelIDs, defaults, nil, false)
+	return err
+}
+
+func (s *QuotaService) checkGenerationQuota(ctx context.Context, userID string, modelIDs []string, defaults DefaultQuotas, limiter *common.SlidingWindowLimiter, rate bool) (func(), error) {
+	type quotaCheck struct {
+		scope, id string
+		quota     models.Quota
+	}
+
+	// 用户配额
+	userQuota := defaults.User
+	if user, err := NewUserService(s.client, s.dbName).GetUsers(ctx, userID); err == nil && user.Quota != nil {
+		userQuota = *user.Quota
+	}
+	checks := []quotaCheck{{QuotaScopeUser, userID, userQuota}}
+
+	// 模型配额，同一模型只检查一次
+	seen := map[string]bool{}
+	for _, modelID := range modelIDs {
+		if modelID == "" || seen[modelID] {
+			continue
+		}
+		seen[modelID] = true
+		modelQuota := defaults.Model
+		if llmModel, err := NewLLMModelService(s.client, s.dbName).GetLLMModels(ctx, modelID); err == nil && llmModel.Quota != nil {
+			modelQuota = *llmModel.Quota
+		}
+		checks = append(checks, quotaCheck{QuotaScopeModel, modelID, modelQuota})
+	}
+
+	var releases []func()
+	releaseAll := func() {
+		for _, release := range releases {
+			release()
+		}
+	}
+	for _, check := range checks {
+		quota := check.quota
+		if !rate {
+			quota.RequestsPerMinute = 0
+		}
+		release, err := s.CheckQuota(ctx, check.scope, check.id, quota, limiter)
+		releases = append(releases, release)
+		if err == nil {
+			continue
+		}
+		var exceeded *QuotaExceededError
+		if errors.As(err, &exceeded) {
+			releaseAll()
+			return func() {}, err
+		}
+		log.Printf("quota check failed: %v", err)
+	}
+	return releaseAll, nil
+}
+
+// countRecentCalls 统计 since 之后的调用次数，并估算窗口内最早的记录过期时间
+func (s *QuotaService) countRecentCalls(ctx context.Context, field, subjectID string, since time.Time, limit int) (int64, time.Time, error) {
+	coll := s.client.Database(s.dbName).Collection("usage_records")
+	filter := bson.M{field: subjectID, "ctime": bson.M{"$gt": since.Unix()}}
+
+	count, err := coll.CountDocuments(ctx, filter)
+	if err != nil || count < int64(limit) {
+		return count, time.Time{}, err
+	}
+
+	// 第 count-limit+1 条记录过期后即可再次请求
+	opts := options.FindOne().SetSort(bson.D{{Key: "ctime", Value: 1}}).SetSkip(count - int64(limit))
+	var record models.UsageRecord
+	if err := coll.FindOne(ctx, filter, opts).Decode(&record); err != nil {
+		return count, time.Now().Add(time.Minute), nil
+	}
+	return count, time.Unix(record.Ctime, 0).Add(time.Minute), nil
+}
+
+// sumUsage 统计当日token与当月费用
+func (s *QuotaService) sumUsage(ctx context.Context, field, subjectID string, dayStart, monthStart time.Time) (int64, float64, error) {
+	coll := s.client.Database(s.dbName).Collection("usage_records")
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{field: subjectID, "ctime": bson.M{"$gte": monthStart.Unix()}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id": nil,
+			"tokens_today": bson.M{"$sum": bson.M{"$cond": bson.A{
+				bson.M{"$gte": bson.A{"$ctime", dayStart.Unix()}}, "$total_tokens", 0,
+			}}},
+			"cost": bson.M{"$sum": "$cost"},
+		}}},
+	}
+
+	cursor, err := coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var result []struct {
+		TokensToday int64   `bson:"tokens_today"`
+		Cost        float64 `bson:"cost"`
+	}
+	if err := cursor.All(ctx, &result); err != nil {
+		return 0, 0, err
+	}
+	if len(result) == 0 {
+		return 0, 0, nil
+	}
+	return result[0].TokensToday, result[0].Cost, nil
+}
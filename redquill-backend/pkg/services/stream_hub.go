@@ -0,0 +1,256 @@
+// Package services
+/*
+/@Author: urmsone urmsone@163.com
+/@Date: 2025/10/20 20:44
+/@Name: stream_hub.go
+/@Description: Buffered, resumable streaming generation sessions
+/*/
+
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"redquill-backend/pkg/common"
+	"redquill-backend/pkg/models"
+)
+
+// DefaultStreamTTL 流式生成结束后缓冲保留的时间，过期后无法再续传
+const DefaultStreamTTL = 10 * time.Minute
+
+// StreamEvent 流式生成中的一个SSE事件，ID 在同一次生成内单调递增
+type StreamEvent struct {
+	ID    int64       `json:"id"`
+	Event string      `json:"event"`
+	Data  interface{} `json:"data"`
+}
+
+// StreamSession 一次流式生成，缓冲全部事件以便断线重连后补发
+type StreamSession struct {
+	ID      string
+	UserID  string
+	NovelID string
+
+	mu         sync.Mutex
+	events     []StreamEvent
+	done       bool
+	updated    chan struct{}
+	finishedAt time.Time
+}
+
+// Publish 追加事件并通知等待中的订阅者
+func (s *StreamSession) Publish(event string, data interface{}) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := int64(len(s.events)) + 1
+	s.events = append(s.events, StreamEvent{ID: id, Event: event, Data: data})
+	close(s.updated)
+	s.updated = make(chan struct{})
+	return id
+}
+
+// Finish 标记生成结束
+func (s *StreamSession) Finish() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.done {
+		return
+	}
+	s.done = true
+	s.finishedAt = time.Now()
+	close(s.updated)
+	s.updated = make(chan struct{})
+}
+
+// Since 返回 lastID 之后的事件、下次有新事件时关闭的通道，以及生成是否已结束
+func (s *StreamSession) Since(lastID int64) ([]StreamEvent, <-chan struct{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if lastID < 0 {
+		lastID = 0
+	}
+	var events []StreamEvent
+	if lastID < int64(len(s.events)) {
+		events = append(events, s.events[lastID:]...)
+	}
+	return events, s.updated, s.done
+}
+
+// expired 生成结束且超过保留时间
+func (s *StreamSession) expired(now time.Time, ttl time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.done && now.Sub(s.finishedAt) > ttl
+}
+
+// StreamHub 管理进程内的流式生成会话。
+// 生成在后台执行，不随HTTP连接断开而中止，客户端可凭 generation_id 重连续传。
+type StreamHub struct {
+	client *mongo.Client
+	dbName string
+	ttl    time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]*StreamSession
+
+	ctx  context.Context
+	stop context.CancelFunc
+}
+
+// NewStreamHub 创建流式生成会话管理器，ttl 为结束后缓冲保留时间
+func NewStreamHub(client *mongo.Client, dbName string, ttl time.Duration) *StreamHub {
+	if ttl <= 0 {
+		ttl = DefaultStreamTTL
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	h := &StreamHub{
+		client:   client,
+		dbName:   dbName,
+		ttl:      ttl,
+		sessions: make(map[string]*StreamSession),
+		ctx:      ctx,
+		stop:     stop,
+	}
+	go h.cleanup()
+	return h
+}
+
+// Stop 中止所有进行中的生成
+func (h *StreamHub) Stop() {
+	h.stop()
+}
+
+// Get 获取会话
+func (h *StreamHub) Get(id string) (*StreamSession, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	session, ok := h.sessions[id]
+	return session, ok
+}
+
+// open 创建会话，首个事件为 generation，携带 generation_id
+func (h *StreamHub) open(userID, novelID string) *StreamSession {
+	session := &StreamSession{
+		ID:      primitive.NewObjectID().Hex(),
+		UserID:  userID,
+		NovelID: novelID,
+		updated: make(chan struct{}),
+	}
+	session.Publish("generation", map[string]interface{}{"generation_id": session.ID})
+
+	h.mu.Lock()
+	h.sessions[session.ID] = session
+	h.mu.Unlock()
+	return session
+}
+
+// StartGeneration 在后台启动流式生成，并将数据块写入会话缓冲。
+// 生成完成后按同步接口相同的逻辑解析并保存，最后发送 result（保存的文档ID）或 parse_error（原始文本）事件；
+// inputData 为调用方的原始输入，用于保存（如章节的 chapter_number）。
+// ctx 为发起请求的上下文，只用于取得请求ID：生成不随请求结束而中断
+func (h *StreamHub) StartGeneration(ctx context.Context, req models.GenerationRequest, inputData map[string]interface{}) *StreamSession {
+	session := h.open(req.UserID, req.NovelID)
+	genCtx := common.WithRequestID(h.ctx, common.RequestIDFromContext(ctx))
+
+	go func() {
+		defer session.Finish()
+
+		response, err := NewPromptTemplateService(h.client, h.dbName).GenerateWithLLMStream(genCtx, req)
+		if err != nil {
+			session.Publish("error", map[string]interface{}{"error": err.Error()})
+			return
+		}
+
+		var content strings.Builder
+		servedModelID, logID := "", ""
+		for chunk := range response {
+			if chunk.Error != nil {
+				session.Publish("error", map[string]interface{}{"error": chunk.Error.Error()})
+				return
+			}
+
+			content.WriteString(chunk.Content)
+			session.Publish("data", map[string]interface{}{
+				"content": chunk.Content,
+				"done":    chunk.Done,
+			})
+
+			if chunk.Done {
+				servedModelID, logID = chunk.ModelID, chunk.LogID
+				break
+			}
+		}
+		if genCtx.Err() != nil {
+			return
+		}
+
+		// 解析（必要时修复）并保存生成结果
+		data, err := NewPromptTemplateService(h.client, h.dbName).ParseGenerationOutput(genCtx, req, servedModelID, content.String())
+		if logID != "" {
+			if logErr := NewGenerationLogService(h.client, h.dbName).SetGenerationLogResult(genCtx, logID, data, err); logErr != nil {
+				log.Printf("Failed to update generation log %s: %v", logID, logErr)
+			}
+		}
+		if err != nil {
+			session.Publish("parse_error", map[string]interface{}{
+				"error": fmt.Errorf("%w: %v", ErrGenerationParse, err).Error(),
+				"raw":   content.String(),
+			})
+			return
+		}
+		result, err := NewNovelGenerationService(h.client, h.dbName).SaveStreamedGeneration(
+			genCtx, req.TemplateType, req.NovelID, req.UserID, inputData, data, servedModelID)
+		if err != nil {
+			session.Publish("parse_error", map[string]interface{}{
+				"error": err.Error(),
+				"raw":   content.String(),
+			})
+			return
+		}
+		session.Publish("result", result)
+
+		// 章节自动审核
+		if req.TemplateType == "chapter" && req.AutoReview {
+			review, err := NewChapterReviewService(h.client, h.dbName).AutoReviewChapter(genCtx, result.ID, req.LLMModelID, req.GenerationOptions)
+			if err != nil {
+				session.Publish("review_error", map[string]interface{}{"error": err.Error()})
+				return
+			}
+			session.Publish("review", review)
+		}
+	}()
+
+	return session
+}
+
+// cleanup 定期清理已过期的会话
+func (h *StreamHub) cleanup() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-h.ctx.Done():
+			return
+		case now := <-ticker.C:
+			h.mu.Lock()
+			for id, session := range h.sessions {
+				if session.expired(now, h.ttl) {
+					delete(h.sessions, id)
+				}
+			}
+			h.mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// Package services
+/*
+/@Author: urmsone urmsone@163.com
+/@Date: 2025/10/20 20:44
+/@Name: usage_service.go
+/@Description: LLM usage accounting service implementation
+/*/
+
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"redquill-backend/pkg/models"
+	"redquill-backend/pkg/utils/llm"
+)
+
+// UsageService 用量统计服务
+type UsageService struct {
+	client *mongo.Client
+	dbName string
+}
+
+// NewUsageService 创建用量统计服务
+func NewUsageService(client *mongo.Client, dbName string) *UsageService {
+	return &UsageService{
+		client: client,
+		dbName: dbName,
+	}
+}
+
+// usageGroupFields 支持的分组维度
+var usageGroupFields = map[string]string{
+	"user":  "$user_id",
+	"model": "$llm_model_id",
+	"novel": "$novel_id",
+}
+
+// PostUsageRecords 写入一条用量记录
+func (s *UsageService) PostUsageRecords(ctx context.Context, record models.UsageRecord) error {
+	coll := s.client.Database(s.dbName).Collection("usage_records")
+	if record.Ctime == 0 {
+		record.Ctime = time.Now().Unix()
+	}
+	_, err := coll.InsertOne(ctx, record)
+	return err
+}
+
+// EnsureUsageIndexes 创建用量聚合与配额统计使用的索引
+func (s *UsageService) EnsureUsageIndexes(ctx context.Context) error {
+	coll := s.client.Database(s.dbName).Collection("usage_records")
+	_, err := coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "ctime", Value: -1}}},
+		{Keys: bson.D{{Key: "llm_model_id", Value: 1}, {Key: "ctime", Value: -1}}},
+	})
+	return err
+}
+
+// AggregateUsage 按 user/model/novel 分组聚合用量，from/to 为unix秒（0 表示不限），userID 非空时只统计该用户
+func (s *UsageService) AggregateUsage(ctx context.Context, groupBy string, from, to int64, userID string) ([]models.UsageSummary, error) {
+	coll := s.client.Database(s.dbName).Collection("usage_records")
+
+	groupField, ok := usageGroupFields[groupBy]
+	if !ok {
+		return nil, errors.New("invalid group_by, expected user|model|novel")
+	}
+
+	match := bson.M{}
+	if userID != "" {
+		match["user_id"] = userID
+	}
+	ctime := bson.M{}
+	if from > 0 {
+		ctime["$gte"] = from
+	}
+	if to > 0 {
+		ctime["$lte"] = to
+	}
+	if len(ctime) > 0 {
+		match["ctime"] = ctime
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{
+			"_id":               groupField,
+			"calls":             bson.M{"$sum": 1},
+			"success_calls":     bson.M{"$sum": bson.M{"$cond": bson.A{"$success", 1, 0}}},
+			"prompt_tokens":     bson.M{"$sum": "$prompt_tokens"},
+			"completion_tokens": bson.M{"$sum": "$completion_tokens"},
+			"total_tokens":      bson.M{"$sum": "$total_tokens"},
+			"cost":              bson.M{"$sum": "$cost"},
+			"avg_latency_ms":    bson.M{"$avg": "$latency_ms"},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "cost", Value: -1}, {Key: "total_tokens", Value: -1}}}},
+	}
+
+	cursor, err := coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	items := make([]models.UsageSummary, 0)
+	if err := cursor.All(ctx, &items); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// recordLLMUsage 记录一次LLM调用的用量，失败只记日志不影响主流程
+func recordLLMUsage(ctx context.Context, client *mongo.Client, dbName string, record models.UsageRecord, llmModel models.LLMModel, usage *llm.Usage, start time.Time, callErr error) {
+	record.LLMModelID = llmModel.ID
+	record.ModelName = llmModel.Config.ModelName
+	record.LatencyMs = time.Since(start).Milliseconds()
+	record.Success = callErr == nil
+	if callErr != nil {
+		record.Error = callErr.Error()
+	}
+	if usage != nil {
+		record.PromptTokens = usage.PromptTokens
+		record.CompletionTokens = usage.CompletionTokens
+		record.TotalTokens = usage.TotalTokens
+		if record.TotalTokens == 0 {
+			record.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+		}
+	}
+	record.Cost = float64(record.TotalTokens) * llmModel.CostPerToken
+
+	// 请求结束后上下文可能已取消，用量记录仍需写入
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := NewUsageService(client, dbName).PostUsageRecords(ctx, record); err != nil {
+		log.Printf("Failed to record LLM usage: %v", err)
+	}
+}
+
+// addUsage 累加token用量
+func addUsage(total *llm.Usage, usage *llm.Usage) {
+	if usage == nil {
+		return
+	}
+	total.PromptTokens += usage.PromptTokens
+	total.CompletionTokens += usage.CompletionTokens
+	total.TotalTokens += usage.TotalTokens
+}
+
+// estimateStreamUsage 流式响应未返回用量时（厂商不支持 stream_options），按Prompt与输出估算token数
+func estimateStreamUsage(usage *llm.Usage, messages []llm.Message, response string) {
+	if usage.PromptTokens > 0 || usage.CompletionTokens > 0 || usage.TotalTokens > 0 {
+		return
+	}
+	usage.PromptTokens = int64(llm.EstimateMessagesTokens(messages))
+	usage.CompletionTokens = int64(llm.EstimateTokens(response))
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+}
@@ -3,6 +3,8 @@ package services
 import (
 	"context"
 	"errors"
+	"regexp"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -61,6 +63,9 @@ func (s *UserService) ListPaged(ctx context.Context, page, pageSize int64, sortE
 	if err != nil {
 		return PagedUsers{}, err
 	}
+	for i := range items {
+		items[i].Role = models.NormalizeRole(items[i].Role)
+	}
 
 	totalPages := total / common.NormalizePageSize(pageSize)
 	if total%common.NormalizePageSize(pageSize) != 0 {
@@ -91,6 +96,7 @@ func (s *UserService) PostUsers(ctx context.Context, name, email, password strin
 		Name:     name,
 		Email:    email,
 		Password: common.HashPassword(password),
+		Role:     models.RoleWriter,
 		Ctime:    now.Unix(),
 		Mtime:    now.Unix(),
 	}
@@ -117,11 +123,13 @@ func (s *UserService) GetUsers(ctx context.Context, id string) (models.User, err
 		return models.User{}, err
 	}
 	u.Password = ""
+	u.Role = models.NormalizeRole(u.Role)
 	return u, nil
 }
 
-// PutUsers updates allowed fields; nil pointer means no change
-func (s *UserService) PutUsers(ctx context.Context, id string, name *string, email *string, password *string) (models.User, error) {
+// PutUsers updates allowed fields; nil pointer means no change.
+// An empty quota removes the user override so the default quota applies.
+func (s *UserService) PutUsers(ctx context.Context, id string, name *string, email *string, password *string, role *string, quota *models.Quota) (models.User, error) {
 	coll := s.client.Database(s.dbName).Collection("users")
 	oid, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
@@ -138,15 +146,30 @@ func (s *UserService) PutUsers(ctx context.Context, id string, name *string, ema
 	if password != nil && *password != "" {
 		set["password"] = common.HashPassword(*password)
 	}
+	if role != nil {
+		if !models.IsValidRole(*role) {
+			return models.User{}, errors.New("invalid role")
+		}
+		set["role"] = *role
+	}
 	for k, v := range set {
 		update[k] = v
 	}
+	change := bson.M{"$set": update}
+	if quota != nil {
+		if quota.IsZero() {
+			change["$unset"] = bson.M{"quota": ""}
+		} else {
+			update["quota"] = *quota
+		}
+	}
 	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
 	var out models.User
-	if err := coll.FindOneAndUpdate(ctx, bson.M{"_id": oid}, bson.M{"$set": update}, opts).Decode(&out); err != nil {
+	if err := coll.FindOneAndUpdate(ctx, bson.M{"_id": oid}, change, opts).Decode(&out); err != nil {
 		return models.User{}, err
 	}
 	out.Password = ""
+	out.Role = models.NormalizeRole(out.Role)
 	return out, nil
 }
 
@@ -172,5 +195,22 @@ func (s *UserService) Authenticate(ctx context.Context, email, password string)
 		return models.User{}, errors.New("invalid email or password")
 	}
 	u.Password = ""
+	u.Role = models.NormalizeRole(u.Role)
 	return u, nil
 }
+
+// PromoteAdmins grants the admin role to users with the given emails (case-insensitive)
+func (s *UserService) PromoteAdmins(ctx context.Context, emails []string) error {
+	if len(emails) == 0 {
+		return nil
+	}
+	patterns := make(bson.A, 0, len(emails))
+	for _, email := range emails {
+		patterns = append(patterns, primitive.Regex{Pattern: "^" + regexp.QuoteMeta(strings.TrimSpace(email)) + "$", Options: "i"})
+	}
+	coll := s.client.Database(s.dbName).Collection("users")
+	_, err := coll.UpdateMany(ctx, bson.M{"email": bson.M{"$in": patterns}}, bson.M{
+		"$set": bson.M{"role": models.RoleAdmin, "mtime": time.Now().Unix()},
+	})
+	return err
+}
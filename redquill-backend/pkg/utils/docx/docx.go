@@ -0,0 +1,165 @@
+// Package docx
+/*
+/@Author: urmsone urmsone@163.com
+/@Date: 2025/10/20 20:44
+/@Name: docx.go
+/@Description: Minimal Office Open XML (DOCX) text document writer in pure Go
+/*/
+
+package docx
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"io"
+	"strings"
+	"time"
+)
+
+// Document 一个只包含标题与段落的 Word 文档
+type Document struct {
+	Title    string    // 文档属性中的标题
+	Author   string    // 文档属性中的作者
+	Modified time.Time // 文档属性中的修改时间，默认当前时间
+
+	body strings.Builder
+}
+
+// New 创建文档
+func New(title, author string) *Document {
+	return &Document{Title: title, Author: author}
+}
+
+// TitleParagraph 添加文档标题（Title 样式）
+func (d *Document) TitleParagraph(text string) {
+	d.paragraph("Title", false, false, text)
+}
+
+// Subtitle 添加副标题，例如作者
+func (d *Document) Subtitle(text string) {
+	d.paragraph("Subtitle", false, false, text)
+}
+
+// Heading 添加一级标题（Heading1 样式，出现在导航窗格中）；pageBreak 为 true 时另起一页
+func (d *Document) Heading(text string, pageBreak bool) {
+	d.paragraph("Heading1", pageBreak, false, text)
+}
+
+// Note 添加斜体说明段落，例如章节概要
+func (d *Document) Note(text string) {
+	d.paragraph("Note", false, true, text)
+}
+
+// Paragraphs 将纯文本按行添加为正文段落，忽略空行
+func (d *Document) Paragraphs(text string) {
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			d.paragraph("", false, false, line)
+		}
+	}
+}
+
+func (d *Document) paragraph(style string, pageBreak, italic bool, text string) {
+	d.body.WriteString("<w:p>")
+	if style != "" || pageBreak {
+		d.body.WriteString("<w:pPr>")
+		if style != "" {
+			d.body.WriteString(`<w:pStyle w:val="` + style + `"/>`)
+		}
+		if pageBreak {
+			d.body.WriteString("<w:pageBreakBefore/>")
+		}
+		d.body.WriteString("</w:pPr>")
+	}
+	d.body.WriteString("<w:r>")
+	if italic {
+		d.body.WriteString("<w:rPr><w:i/></w:rPr>")
+	}
+	d.body.WriteString(`<w:t xml:space="preserve">` + escape(text) + "</w:t></w:r></w:p>")
+}
+
+// Write 写出 .docx 包
+func (d *Document) Write(w io.Writer) error {
+	modified := d.Modified
+	if modified.IsZero() {
+		modified = time.Now()
+	}
+
+	files := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", contentTypesXML},
+		{"_rels/.rels", rootRelsXML},
+		{"docProps/core.xml", d.corePropertiesXML(modified)},
+		{"word/_rels/document.xml.rels", documentRelsXML},
+		{"word/styles.xml", stylesXML},
+		{"word/document.xml", xml.Header + `<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>` +
+			d.body.String() +
+			`<w:sectPr><w:pgSz w:w="11906" w:h="16838"/><w:pgMar w:top="1440" w:right="1800" w:bottom="1440" w:left="1800" w:header="851" w:footer="992" w:gutter="0"/></w:sectPr>` +
+			"</w:body></w:document>"},
+	}
+
+	zw := zip.NewWriter(w)
+	for _, f := range files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: modified})
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(fw, f.content); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func (d *Document) corePropertiesXML(modified time.Time) string {
+	ts := modified.UTC().Format("2006-01-02T15:04:05Z")
+	return xml.Header + `<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:dcterms="http://purl.org/dc/terms/" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">` +
+		"<dc:title>" + escape(d.Title) + "</dc:title>" +
+		"<dc:creator>" + escape(d.Author) + "</dc:creator>" +
+		`<dcterms:created xsi:type="dcterms:W3CDTF">` + ts + "</dcterms:created>" +
+		`<dcterms:modified xsi:type="dcterms:W3CDTF">` + ts + "</dcterms:modified>" +
+		"</cp:coreProperties>"
+}
+
+// escape 转义 XML 特殊字符，并去掉 XML 1.0 不允许的控制字符
+func escape(text string) string {
+	text = strings.Map(func(r rune) rune {
+		if r == '\t' || r == '\n' || r == '\r' || (r >= 0x20 && r != 0xFFFE && r != 0xFFFF) {
+			return r
+		}
+		return -1
+	}, strings.ToValidUTF8(text, ""))
+	var sb strings.Builder
+	_ = xml.EscapeText(&sb, []byte(text))
+	return sb.String()
+}
+
+const contentTypesXML = xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>` +
+	`<Override PartName="/word/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.styles+xml"/>` +
+	`<Override PartName="/docProps/core.xml" ContentType="application/vnd.openxmlformats-package.core-properties+xml"/>` +
+	`</Types>`
+
+const rootRelsXML = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/>` +
+	`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/package/2006/relationships/metadata/core-properties" Target="docProps/core.xml"/>` +
+	`</Relationships>`
+
+const documentRelsXML = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+	`</Relationships>`
+
+// stylesXML 正文首行缩进两个字符，中文字体使用宋体/黑体
+const stylesXML = xml.Header + `<w:styles xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">` +
+	`<w:docDefaults><w:rPrDefault><w:rPr><w:rFonts w:ascii="Times New Roman" w:hAnsi="Times New Roman" w:eastAsia="宋体"/><w:sz w:val="24"/><w:lang w:val="en-US" w:eastAsia="zh-CN"/></w:rPr></w:rPrDefault>` +
+	`<w:pPrDefault><w:pPr><w:spacing w:after="120" w:line="360" w:lineRule="auto"/></w:pPr></w:pPrDefault></w:docDefaults>` +
+	`<w:style w:type="paragraph" w:default="1" w:styleId="Normal"><w:name w:val="Normal"/><w:qFormat/><w:pPr><w:ind w:firstLineChars="200" w:firstLine="480"/><w:jc w:val="both"/></w:pPr></w:style>` +
+	`<w:style w:type="paragraph" w:styleId="Title"><w:name w:val="Title"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/><w:pPr><w:spacing w:before="2400" w:after="480"/><w:ind w:firstLineChars="0" w:firstLine="0"/><w:jc w:val="center"/></w:pPr><w:rPr><w:rFonts w:eastAsia="黑体"/><w:b/><w:sz w:val="48"/></w:rPr></w:style>` +
+	`<w:style w:type="paragraph" w:styleId="Subtitle"><w:name w:val="Subtitle"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/><w:pPr><w:ind w:firstLineChars="0" w:firstLine="0"/><w:jc w:val="center"/></w:pPr><w:rPr><w:sz w:val="28"/></w:rPr></w:style>` +
+	`<w:style w:type="paragraph" w:styleId="Heading1"><w:name w:val="heading 1"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/><w:pPr><w:keepNext/><w:spacing w:before="480" w:after="360"/><w:ind w:firstLineChars="0" w:firstLine="0"/><w:jc w:val="center"/><w:outlineLvl w:val="0"/></w:pPr><w:rPr><w:rFonts w:eastAsia="黑体"/><w:b/><w:sz w:val="32"/></w:rPr></w:style>` +
+	`<w:style w:type="paragraph" w:styleId="Note"><w:name w:val="Note"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:pPr><w:ind w:firstLineChars="0" w:firstLine="0"/></w:pPr><w:rPr><w:color w:val="666666"/></w:rPr></w:style>` +
+	`</w:styles>`
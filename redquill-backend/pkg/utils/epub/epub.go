@@ -0,0 +1,251 @@
+// Package epub
+/*
+/@Author: urmsone urmsone@163.com
+/@Date: 2025/10/20 20:44
+/@Name: epub.go
+/@Description: Minimal EPUB 3 writer (with EPUB 2 NCX fallback) in pure Go
+/*/
+
+package epub
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Book 一本电子书
+type Book struct {
+	Identifier  string    // 唯一标识，例如 urn:redquill:novel:<id>
+	Title       string    // 书名
+	Author      string    // 作者
+	Language    string    // BCP 47 语言代码，默认 zh-CN
+	Description string    // 简介，可为空
+	Modified    time.Time // 最后修改时间，默认当前时间
+	Sections    []Section // 正文与附录，按阅读顺序排列
+}
+
+// Section 书中的一个章节（一个 XHTML 文件）
+type Section struct {
+	Title string // 目录中显示的标题
+	Body  string // XHTML 片段（<body> 内部），可由 Heading、Paragraphs 等生成
+}
+
+// Write 将电子书写为 EPUB 3 包：mimetype（不压缩，位于首位）、META-INF/container.xml、
+// OEBPS 下的 content.opf、nav.xhtml、toc.ncx、样式表、扉页和各章节
+func (b *Book) Write(w io.Writer) error {
+	if strings.TrimSpace(b.Title) == "" {
+		return errors.New("epub: title is required")
+	}
+	if strings.TrimSpace(b.Identifier) == "" {
+		return errors.New("epub: identifier is required")
+	}
+	if b.Language == "" {
+		b.Language = "zh-CN"
+	}
+	if b.Modified.IsZero() {
+		b.Modified = time.Now()
+	}
+
+	zw := zip.NewWriter(w)
+
+	// mimetype 必须是第一个文件且不压缩
+	mw, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(mw, "application/epub+zip"); err != nil {
+		return err
+	}
+
+	files := []struct {
+		name    string
+		content string
+	}{
+		{"META-INF/container.xml", containerXML},
+		{"OEBPS/content.opf", b.packageDocument()},
+		{"OEBPS/nav.xhtml", b.navDocument()},
+		{"OEBPS/toc.ncx", b.ncxDocument()},
+		{"OEBPS/style.css", styleCSS},
+		{"OEBPS/title.xhtml", b.titlePage()},
+	}
+	for i, section := range b.Sections {
+		files = append(files, struct {
+			name    string
+			content string
+		}{"OEBPS/" + sectionFile(i), xhtmlDocument(b.Language, section.Title, section.Body)})
+	}
+
+	for _, f := range files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: b.Modified})
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(fw, f.content); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+// Heading 生成标题元素
+func Heading(level int, text string) string {
+	return fmt.Sprintf("<h%d>%s</h%d>\n", level, Escape(text), level)
+}
+
+// Paragraphs 将纯文本按行转换为段落，忽略空行
+func Paragraphs(text string) string {
+	var sb strings.Builder
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			sb.WriteString("<p>" + Escape(line) + "</p>\n")
+		}
+	}
+	return sb.String()
+}
+
+// Escape 转义 XML 特殊字符，并去掉 XML 1.0 不允许的控制字符
+func Escape(text string) string {
+	if !utf8.ValidString(text) {
+		text = strings.ToValidUTF8(text, "")
+	}
+	text = strings.Map(func(r rune) rune {
+		if r == '\t' || r == '\n' || r == '\r' || (r >= 0x20 && r != 0xFFFE && r != 0xFFFF) {
+			return r
+		}
+		return -1
+	}, text)
+	return html.EscapeString(text)
+}
+
+func sectionFile(i int) string {
+	return fmt.Sprintf("section-%04d.xhtml", i+1)
+}
+
+func (b *Book) packageDocument() string {
+	var sb strings.Builder
+	sb.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	sb.WriteString(`<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id" xml:lang="` + Escape(b.Language) + `">` + "\n")
+	sb.WriteString(`  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">` + "\n")
+	sb.WriteString(`    <dc:identifier id="book-id">` + Escape(b.Identifier) + `</dc:identifier>` + "\n")
+	sb.WriteString(`    <dc:title>` + Escape(b.Title) + `</dc:title>` + "\n")
+	sb.WriteString(`    <dc:language>` + Escape(b.Language) + `</dc:language>` + "\n")
+	if b.Author != "" {
+		sb.WriteString(`    <dc:creator id="creator">` + Escape(b.Author) + `</dc:creator>` + "\n")
+		sb.WriteString(`    <meta refines="#creator" property="role" scheme="marc:relators">aut</meta>` + "\n")
+	}
+	if b.Description != "" {
+		sb.WriteString(`    <dc:description>` + Escape(b.Description) + `</dc:description>` + "\n")
+	}
+	sb.WriteString(`    <meta property="dcterms:modified">` + b.Modified.UTC().Format("2006-01-02T15:04:05Z") + `</meta>` + "\n")
+	sb.WriteString("  </metadata>\n")
+
+	sb.WriteString("  <manifest>\n")
+	sb.WriteString(`    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>` + "\n")
+	sb.WriteString(`    <item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>` + "\n")
+	sb.WriteString(`    <item id="css" href="style.css" media-type="text/css"/>` + "\n")
+	sb.WriteString(`    <item id="title" href="title.xhtml" media-type="application/xhtml+xml"/>` + "\n")
+	for i := range b.Sections {
+		sb.WriteString(fmt.Sprintf(`    <item id="section-%d" href="%s" media-type="application/xhtml+xml"/>`+"\n", i+1, sectionFile(i)))
+	}
+	sb.WriteString("  </manifest>\n")
+
+	sb.WriteString(`  <spine toc="ncx">` + "\n")
+	sb.WriteString(`    <itemref idref="title"/>` + "\n")
+	sb.WriteString(`    <itemref idref="nav"/>` + "\n")
+	for i := range b.Sections {
+		sb.WriteString(fmt.Sprintf(`    <itemref idref="section-%d"/>`+"\n", i+1))
+	}
+	sb.WriteString("  </spine>\n")
+	sb.WriteString("</package>\n")
+	return sb.String()
+}
+
+func (b *Book) navDocument() string {
+	var body strings.Builder
+	body.WriteString(`<nav epub:type="toc" id="toc">` + "\n")
+	body.WriteString(Heading(1, "目录"))
+	body.WriteString("<ol>\n")
+	for i, section := range b.Sections {
+		body.WriteString(fmt.Sprintf(`<li><a href="%s">%s</a></li>`+"\n", sectionFile(i), Escape(section.Title)))
+	}
+	body.WriteString("</ol>\n</nav>\n")
+	return xhtmlDocument(b.Language, "目录", body.String())
+}
+
+func (b *Book) ncxDocument() string {
+	var sb strings.Builder
+	sb.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	sb.WriteString(`<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1">` + "\n")
+	sb.WriteString("  <head>\n")
+	sb.WriteString(`    <meta name="dtb:uid" content="` + Escape(b.Identifier) + `"/>` + "\n")
+	sb.WriteString(`    <meta name="dtb:depth" content="1"/>` + "\n")
+	sb.WriteString(`    <meta name="dtb:totalPageCount" content="0"/>` + "\n")
+	sb.WriteString(`    <meta name="dtb:maxPageNumber" content="0"/>` + "\n")
+	sb.WriteString("  </head>\n")
+	sb.WriteString("  <docTitle><text>" + Escape(b.Title) + "</text></docTitle>\n")
+	sb.WriteString("  <navMap>\n")
+	for i, section := range b.Sections {
+		sb.WriteString(fmt.Sprintf(`    <navPoint id="nav-%d" playOrder="%d"><navLabel><text>%s</text></navLabel><content src="%s"/></navPoint>`+"\n",
+			i+1, i+1, Escape(section.Title), sectionFile(i)))
+	}
+	sb.WriteString("  </navMap>\n")
+	sb.WriteString("</ncx>\n")
+	return sb.String()
+}
+
+func (b *Book) titlePage() string {
+	var body strings.Builder
+	body.WriteString(`<section class="title-page">` + "\n")
+	body.WriteString(Heading(1, b.Title))
+	if b.Author != "" {
+		body.WriteString(`<p class="author">` + Escape(b.Author) + "</p>\n")
+	}
+	if b.Description != "" {
+		body.WriteString(`<p class="description">` + Escape(b.Description) + "</p>\n")
+	}
+	body.WriteString("</section>\n")
+	return xhtmlDocument(b.Language, b.Title, body.String())
+}
+
+func xhtmlDocument(lang, title, body string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="` + Escape(lang) + `" lang="` + Escape(lang) + `">
+<head>
+<meta charset="UTF-8"/>
+<title>` + Escape(title) + `</title>
+<link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body>
+` + body + `</body>
+</html>
+`
+}
+
+const containerXML = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`
+
+const styleCSS = `body { font-family: serif; line-height: 1.8; margin: 0 5%; }
+h1 { text-align: center; margin: 2em 0 1em; }
+h2 { margin: 1.5em 0 0.5em; }
+p { text-indent: 2em; margin: 0.3em 0; }
+.title-page { text-align: center; margin-top: 30%; }
+.title-page p { text-indent: 0; }
+.author { font-size: 1.2em; margin-top: 2em; }
+.summary { text-indent: 0; color: #666; font-style: italic; }
+dl dt { font-weight: bold; margin-top: 0.8em; }
+dl dd { margin-left: 2em; }
+nav ol { list-style: none; padding: 0; }
+`
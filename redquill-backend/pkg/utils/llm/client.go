@@ -26,7 +26,7 @@ type Client struct {
 func NewClient(config LLMConfig) (*Client, error) {
 	// 创建HTTP客户端
 	client := &http.Client{
-		Timeout: time.Duration(config.Timeout) * time.Second,
+		Timeout: config.Timeout,
 		Transport: &http.Transport{
 			MaxIdleConns:        100,
 			MaxIdleConnsPerHost: 10,
@@ -132,6 +132,15 @@ func NewClient(config LLMConfig) (*Client, error) {
 		}
 	}
 
+	// 配置了重试次数时使用重试装饰器
+	if config.MaxRetries > 0 {
+		provider = NewRetryProvider(provider, RetryConfig{
+			MaxRetries: config.MaxRetries,
+			RetryDelay: config.RetryDelay,
+			Backoff:    config.Backoff,
+		})
+	}
+
 	return &Client{
 		provider: provider,
 	}, nil
@@ -166,6 +175,7 @@ func (c *Client) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, erro
 		TopP:             req.TopP,
 		FrequencyPenalty: req.FrequencyPenalty,
 		PresencePenalty:  req.PresencePenalty,
+		ResponseFormat:   convertResponseFormat(req.ResponseFormat),
 	}
 
 	resp, err := c.provider.Chat(ctx, providerReq)
@@ -195,6 +205,7 @@ func (c *Client) ChatStream(ctx context.Context, req ChatRequest) (<-chan Stream
 		TopP:             req.TopP,
 		FrequencyPenalty: req.FrequencyPenalty,
 		PresencePenalty:  req.PresencePenalty,
+		ResponseFormat:   convertResponseFormat(req.ResponseFormat),
 	}
 
 	stream, err := c.provider.ChatStream(ctx, providerReq)
@@ -279,6 +290,13 @@ func convertMessages(messages []Message) []providers.Message {
 	return result
 }
 
+func convertResponseFormat(format *ResponseFormat) *providers.ResponseFormat {
+	if format == nil {
+		return nil
+	}
+	return &providers.ResponseFormat{Type: format.Type}
+}
+
 func convertChoices(choices []providers.Choice) []Choice {
 	result := make([]Choice, len(choices))
 	for i, choice := range choices {
@@ -321,9 +339,11 @@ func convertError(err *providers.LLMError) *LLMError {
 		return nil
 	}
 	return &LLMError{
-		Type:    err.Type,
-		Message: err.Message,
-		Code:    err.Code,
-		Details: err.Details,
+		Type:       err.Type,
+		Message:    err.Message,
+		Code:       err.Code,
+		Details:    err.Details,
+		StatusCode: err.StatusCode,
+		RetryAfter: err.RetryAfter,
 	}
 }
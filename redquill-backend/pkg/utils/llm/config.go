@@ -25,6 +25,7 @@ type LLMConfig struct {
 	Timeout    time.Duration     `json:"timeout"`
 	MaxRetries int               `json:"max_retries"`
 	RetryDelay time.Duration     `json:"retry_delay"`
+	Backoff    BackoffType       `json:"backoff,omitempty"` // linear, exponential（默认）
 }
 
 // MultiProviderConfig 多厂商配置
@@ -9,8 +9,12 @@
 package llm
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"time"
+
+	"redquill-backend/pkg/utils/llm/providers"
 )
 
 // Metrics 监控指标
@@ -21,17 +25,41 @@ type Metrics struct {
 	TokenCount      int64 `json:"token_count"`
 }
 
-// IsRetryableError 判断错误是否可重试
+// IsRetryableError 判断错误是否可重试（兼容 providers 层错误）
 func IsRetryableError(err error) bool {
-	if llmErr, ok := err.(*LLMError); ok {
-		switch llmErr.Type {
-		case string(ErrorTypeRateLimit), string(ErrorTypeNetwork), string(ErrorTypeServer):
-			return true
-		}
+	switch errorType(err) {
+	case string(ErrorTypeRateLimit), string(ErrorTypeNetwork), string(ErrorTypeServer):
+		return true
 	}
 	return false
 }
 
+// RetryAfterFromError 获取上游 Retry-After 建议的等待时间
+func RetryAfterFromError(err error) time.Duration {
+	var llmErr *LLMError
+	if errors.As(err, &llmErr) {
+		return llmErr.RetryAfter
+	}
+	var providerErr *providers.LLMError
+	if errors.As(err, &providerErr) {
+		return providerErr.RetryAfter
+	}
+	return 0
+}
+
+// errorType 获取错误类型
+func errorType(err error) string {
+	var llmErr *LLMError
+	if errors.As(err, &llmErr) {
+		return llmErr.Type
+	}
+	var providerErr *providers.LLMError
+	if errors.As(err, &providerErr) {
+		return providerErr.Type
+	}
+	return ""
+}
+
 // GetHTTPStatusFromError 从错误获取HTTP状态码
 func GetHTTPStatusFromError(err error) int {
 	if llmErr, ok := err.(*LLMError); ok {
@@ -0,0 +1,196 @@
+// Package llm
+/*
+/@Author: urmsone urmsone@163.com
+/@Date: 2025/10/20 20:44
+/@Name: fallback.go
+/@Description: Fallback client over an ordered list of models
+/*/
+
+package llm
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// FallbackTarget 回退链中的一个模型
+type FallbackTarget struct {
+	ID          string    // 模型标识（如 llm_model_id），用于记录实际提供服务的模型
+	Client      LLMClient // 模型对应的客户端
+	Model       string    // 覆盖请求中的模型名
+	Temperature float64   // 覆盖请求温度，0 表示沿用请求值
+	MaxTokens   int       // 覆盖最大token数，0 表示沿用请求值
+	JSONMode    bool      // 是否支持原生JSON模式，不支持时去掉请求中的 response_format
+}
+
+// FallbackClient 按顺序尝试多个模型，遇到可重试错误时切换到下一个模型
+type FallbackClient struct {
+	targets []FallbackTarget
+
+	mu       sync.Mutex
+	servedBy string
+}
+
+// NewFallbackClient 创建回退客户端
+func NewFallbackClient(targets ...FallbackTarget) (*FallbackClient, error) {
+	if len(targets) == 0 {
+		return nil, NewInvalidRequestError("fallback client requires at least one target")
+	}
+	return &FallbackClient{targets: targets}, nil
+}
+
+// ServedBy 返回最近一次实际提供服务的模型标识
+func (c *FallbackClient) ServedBy() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.servedBy
+}
+
+func (c *FallbackClient) setServedBy(id string) {
+	c.mu.Lock()
+	c.servedBy = id
+	c.mu.Unlock()
+}
+
+// Chat 同步聊天
+func (c *FallbackClient) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	var lastErr error
+	for i, target := range c.targets {
+		resp, err := target.Client.Chat(ctx, target.apply(req))
+		if err == nil {
+			c.setServedBy(target.ID)
+			return resp, nil
+		}
+		lastErr = err
+		if !c.canFailover(ctx, err, i) {
+			break
+		}
+	}
+	return nil, lastErr
+}
+
+// ChatStream 流式聊天。打开流失败或在输出任何token前出错时切换到下一个模型
+func (c *FallbackClient) ChatStream(ctx context.Context, req ChatRequest) (<-chan StreamChunk, error) {
+	stream, index, err := c.openStream(ctx, req, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(chan StreamChunk, 100)
+	go func() {
+		defer close(result)
+
+		emitted := false
+		for {
+			var failoverErr error
+			for chunk := range stream {
+				if failoverErr != nil {
+					continue // 丢弃切换前的剩余数据
+				}
+				if chunk.Error != nil && !emitted && c.canFailover(ctx, chunk.Error, index) {
+					failoverErr = chunk.Error
+					continue
+				}
+				if chunkHasContent(chunk) {
+					emitted = true
+				}
+				select {
+				case result <- chunk:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			if failoverErr == nil {
+				return
+			}
+
+			stream, index, err = c.openStream(ctx, req, index+1)
+			if err != nil {
+				var llmErr *LLMError
+				if !errors.As(err, &llmErr) {
+					llmErr = WrapError(err, "fallback stream failed")
+				}
+				select {
+				case result <- StreamChunk{Error: llmErr}:
+				case <-ctx.Done():
+				}
+				return
+			}
+		}
+	}()
+
+	return result, nil
+}
+
+// Pinned 返回仅包含最近一次实际提供服务模型的客户端，用于在同一模型上继续对话（如修复输出）
+func (c *FallbackClient) Pinned() *FallbackClient {
+	servedBy := c.ServedBy()
+	for _, target := range c.targets {
+		if target.ID == servedBy {
+			return &FallbackClient{targets: []FallbackTarget{target}, servedBy: servedBy}
+		}
+	}
+	return &FallbackClient{targets: c.targets[:1], servedBy: c.targets[0].ID}
+}
+
+// Health 健康检查（检查首选模型）
+func (c *FallbackClient) Health(ctx context.Context) error {
+	return c.targets[0].Client.Health(ctx)
+}
+
+// Models 获取模型列表（首选模型）
+func (c *FallbackClient) Models(ctx context.Context) ([]Model, error) {
+	return c.targets[0].Client.Models(ctx)
+}
+
+// openStream 从第 start 个模型开始依次尝试打开流
+func (c *FallbackClient) openStream(ctx context.Context, req ChatRequest, start int) (<-chan StreamChunk, int, error) {
+	var lastErr error
+	for i := start; i < len(c.targets); i++ {
+		target := c.targets[i]
+		stream, err := target.Client.ChatStream(ctx, target.apply(req))
+		if err == nil {
+			c.setServedBy(target.ID)
+			return stream, i, nil
+		}
+		lastErr = err
+		if !c.canFailover(ctx, err, i) {
+			break
+		}
+	}
+	return nil, start, lastErr
+}
+
+// canFailover 判断第 index 个模型失败后是否可以切换到下一个模型
+func (c *FallbackClient) canFailover(ctx context.Context, err error, index int) bool {
+	return ctx.Err() == nil && index+1 < len(c.targets) && IsRetryableError(err)
+}
+
+// apply 将模型配置应用到请求上
+func (t FallbackTarget) apply(req ChatRequest) ChatRequest {
+	if t.Model != "" {
+		req.Model = t.Model
+	}
+	if t.Temperature != 0 {
+		req.Temperature = t.Temperature
+	}
+	if t.MaxTokens != 0 {
+		req.MaxTokens = t.MaxTokens
+	}
+	if !t.JSONMode {
+		req.ResponseFormat = nil
+	}
+	return req
+}
+
+// chunkHasContent 判断流式块中是否包含已输出的token
+func chunkHasContent(chunk StreamChunk) bool {
+	for _, choice := range chunk.Choices {
+		if choice.Delta.Content != "" || choice.Message.Content != "" {
+			return true
+		}
+	}
+	return false
+}
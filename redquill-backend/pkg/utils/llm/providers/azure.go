@@ -83,16 +83,7 @@ func (p *AzureProvider) Chat(ctx context.Context, req ChatRequest) (*ChatRespons
 	}
 	
 	if resp.StatusCode != http.StatusOK {
-		var errorResp struct {
-			Error LLMError `json:"error"`
-		}
-		if err := json.Unmarshal(body, &errorResp); err == nil {
-			return nil, &errorResp.Error
-		}
-		return nil, &LLMError{
-			Type:    string(ErrorTypeServer),
-			Message: fmt.Sprintf("HTTP %d: %s", resp.StatusCode, string(body)),
-		}
+		return nil, NewHTTPError(resp, body)
 	}
 	
 	var chatResp ChatResponse
@@ -109,6 +100,7 @@ func (p *AzureProvider) Chat(ctx context.Context, req ChatRequest) (*ChatRespons
 // ChatStream 流式聊天
 func (p *AzureProvider) ChatStream(ctx context.Context, req ChatRequest) (<-chan StreamChunk, error) {
 	req.Stream = true
+	req.StreamOptions = &StreamOptions{IncludeUsage: true}
 	
 	reqBody, err := json.Marshal(req)
 	if err != nil {
@@ -150,10 +142,7 @@ func (p *AzureProvider) ChatStream(ctx context.Context, req ChatRequest) (<-chan
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		resp.Body.Close()
-		return nil, &LLMError{
-			Type:    string(ErrorTypeServer),
-			Message: fmt.Sprintf("HTTP %d: %s", resp.StatusCode, string(body)),
-		}
+		return nil, NewHTTPError(resp, body)
 	}
 	
 	return p.stream.ProcessSSEStream(ctx, resp)
@@ -80,16 +80,7 @@ func (p *DeepSeekProvider) Chat(ctx context.Context, req ChatRequest) (*ChatResp
 	}
 	
 	if resp.StatusCode != http.StatusOK {
-		var errorResp struct {
-			Error LLMError `json:"error"`
-		}
-		if err := json.Unmarshal(body, &errorResp); err == nil {
-			return nil, &errorResp.Error
-		}
-		return nil, &LLMError{
-			Type:    string(ErrorTypeServer),
-			Message: fmt.Sprintf("HTTP %d: %s", resp.StatusCode, string(body)),
-		}
+		return nil, NewHTTPError(resp, body)
 	}
 	
 	var chatResp ChatResponse
@@ -106,6 +97,7 @@ func (p *DeepSeekProvider) Chat(ctx context.Context, req ChatRequest) (*ChatResp
 // ChatStream 流式聊天
 func (p *DeepSeekProvider) ChatStream(ctx context.Context, req ChatRequest) (<-chan StreamChunk, error) {
 	req.Stream = true
+	req.StreamOptions = &StreamOptions{IncludeUsage: true}
 	
 	reqBody, err := json.Marshal(req)
 	if err != nil {
@@ -145,10 +137,7 @@ func (p *DeepSeekProvider) ChatStream(ctx context.Context, req ChatRequest) (<-c
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		resp.Body.Close()
-		return nil, &LLMError{
-			Type:    string(ErrorTypeServer),
-			Message: fmt.Sprintf("HTTP %d: %s", resp.StatusCode, string(body)),
-		}
+		return nil, NewHTTPError(resp, body)
 	}
 	
 	return p.stream.ProcessSSEStream(ctx, resp)
@@ -82,16 +82,7 @@ func (p *DoubaoProvider) Chat(ctx context.Context, req ChatRequest) (*ChatRespon
 	}
 	
 	if resp.StatusCode != http.StatusOK {
-		var errorResp struct {
-			Error LLMError `json:"error"`
-		}
-		if err := json.Unmarshal(body, &errorResp); err == nil {
-			return nil, &errorResp.Error
-		}
-		return nil, &LLMError{
-			Type:    string(ErrorTypeServer),
-			Message: fmt.Sprintf("HTTP %d: %s", resp.StatusCode, string(body)),
-		}
+		return nil, NewHTTPError(resp, body)
 	}
 	
 	// 豆包API响应格式转换
@@ -111,6 +102,7 @@ func (p *DoubaoProvider) Chat(ctx context.Context, req ChatRequest) (*ChatRespon
 // ChatStream 流式聊天
 func (p *DoubaoProvider) ChatStream(ctx context.Context, req ChatRequest) (<-chan StreamChunk, error) {
 	req.Stream = true
+	req.StreamOptions = &StreamOptions{IncludeUsage: true}
 	
 	// 豆包API需要转换请求格式
 	doubaoReq := p.convertToDoubaoRequest(req)
@@ -152,10 +144,7 @@ func (p *DoubaoProvider) ChatStream(ctx context.Context, req ChatRequest) (<-cha
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		resp.Body.Close()
-		return nil, &LLMError{
-			Type:    string(ErrorTypeServer),
-			Message: fmt.Sprintf("HTTP %d: %s", resp.StatusCode, string(body)),
-		}
+		return nil, NewHTTPError(resp, body)
 	}
 	
 	return p.stream.ProcessSSEStream(ctx, resp)
@@ -220,12 +209,13 @@ func (p *DoubaoProvider) Models(ctx context.Context) ([]Model, error) {
 
 // DoubaoRequest 豆包请求格式
 type DoubaoRequest struct {
-	Model       string    `json:"model"`
-	Messages    []Message `json:"messages"`
-	Stream      bool      `json:"stream,omitempty"`
-	Temperature float64   `json:"temperature,omitempty"`
-	MaxTokens   int       `json:"max_tokens,omitempty"`
-	TopP        float64   `json:"top_p,omitempty"`
+	Model         string         `json:"model"`
+	Messages      []Message      `json:"messages"`
+	Stream        bool           `json:"stream,omitempty"`
+	Temperature   float64        `json:"temperature,omitempty"`
+	MaxTokens     int            `json:"max_tokens,omitempty"`
+	TopP          float64        `json:"top_p,omitempty"`
+	StreamOptions *StreamOptions `json:"stream_options,omitempty"`
 }
 
 // DoubaoResponse 豆包响应格式
@@ -247,12 +237,13 @@ type DoubaoChoice struct {
 // convertToDoubaoRequest 转换为豆包请求格式
 func (p *DoubaoProvider) convertToDoubaoRequest(req ChatRequest) DoubaoRequest {
 	return DoubaoRequest{
-		Model:       req.Model,
-		Messages:    req.Messages,
-		Stream:      req.Stream,
-		Temperature: req.Temperature,
-		MaxTokens:   req.MaxTokens,
-		TopP:        req.TopP,
+		Model:         req.Model,
+		Messages:      req.Messages,
+		Stream:        req.Stream,
+		Temperature:   req.Temperature,
+		MaxTokens:     req.MaxTokens,
+		TopP:          req.TopP,
+		StreamOptions: req.StreamOptions,
 	}
 }
 
@@ -0,0 +1,82 @@
+// Package providers
+/*
+/@Author: urmsone urmsone@163.com
+/@Date: 2025/10/20 20:44
+/@Name: errors.go
+/@Description: HTTP error classification for providers
+/*/
+
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// NewHTTPError 根据上游HTTP响应构造错误，按状态码归类错误类型并解析 Retry-After
+func NewHTTPError(resp *http.Response, body []byte) *LLMError {
+	llmErr := &LLMError{
+		Type:       string(ClassifyStatus(resp.StatusCode)),
+		Message:    fmt.Sprintf("HTTP %d: %s", resp.StatusCode, string(body)),
+		StatusCode: resp.StatusCode,
+		RetryAfter: ParseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+
+	// 尽量保留上游返回的错误信息
+	var errorResp struct {
+		Error struct {
+			Message string      `json:"message"`
+			Code    interface{} `json:"code"`
+			Type    string      `json:"type"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &errorResp); err == nil && errorResp.Error.Message != "" {
+		llmErr.Message = fmt.Sprintf("HTTP %d: %s", resp.StatusCode, errorResp.Error.Message)
+		llmErr.Details = errorResp.Error.Type
+		if errorResp.Error.Code != nil {
+			llmErr.Code = fmt.Sprint(errorResp.Error.Code)
+		}
+	}
+
+	return llmErr
+}
+
+// ClassifyStatus 将HTTP状态码映射为错误类型
+func ClassifyStatus(status int) ErrorType {
+	switch {
+	case status == http.StatusTooManyRequests:
+		return ErrorTypeRateLimit
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ErrorTypeAuth
+	case status == http.StatusRequestTimeout:
+		return ErrorTypeNetwork
+	case status >= 500:
+		return ErrorTypeServer
+	case status >= 400:
+		return ErrorTypeInvalidRequest
+	default:
+		return ErrorTypeServer
+	}
+}
+
+// ParseRetryAfter 解析 Retry-After 头，支持秒数与HTTP日期两种格式
+func ParseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
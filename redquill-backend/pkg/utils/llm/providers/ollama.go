@@ -79,16 +79,7 @@ func (p *OllamaProvider) Chat(ctx context.Context, req ChatRequest) (*ChatRespon
 	}
 	
 	if resp.StatusCode != http.StatusOK {
-		var errorResp struct {
-			Error LLMError `json:"error"`
-		}
-		if err := json.Unmarshal(body, &errorResp); err == nil {
-			return nil, &errorResp.Error
-		}
-		return nil, &LLMError{
-			Type:    string(ErrorTypeServer),
-			Message: fmt.Sprintf("HTTP %d: %s", resp.StatusCode, string(body)),
-		}
+		return nil, NewHTTPError(resp, body)
 	}
 	
 	var chatResp ChatResponse
@@ -143,10 +134,7 @@ func (p *OllamaProvider) ChatStream(ctx context.Context, req ChatRequest) (<-cha
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		resp.Body.Close()
-		return nil, &LLMError{
-			Type:    string(ErrorTypeServer),
-			Message: fmt.Sprintf("HTTP %d: %s", resp.StatusCode, string(body)),
-		}
+		return nil, NewHTTPError(resp, body)
 	}
 	
 	return p.stream.ProcessSSEStream(ctx, resp)
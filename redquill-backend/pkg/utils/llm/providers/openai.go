@@ -80,16 +80,7 @@ func (p *OpenAIProvider) Chat(ctx context.Context, req ChatRequest) (*ChatRespon
 	}
 	
 	if resp.StatusCode != http.StatusOK {
-		var errorResp struct {
-			Error LLMError `json:"error"`
-		}
-		if err := json.Unmarshal(body, &errorResp); err == nil {
-			return nil, &errorResp.Error
-		}
-		return nil, &LLMError{
-			Type:    string(ErrorTypeServer),
-			Message: fmt.Sprintf("HTTP %d: %s", resp.StatusCode, string(body)),
-		}
+		return nil, NewHTTPError(resp, body)
 	}
 	
 	var chatResp ChatResponse
@@ -106,6 +97,7 @@ func (p *OpenAIProvider) Chat(ctx context.Context, req ChatRequest) (*ChatRespon
 // ChatStream 流式聊天
 func (p *OpenAIProvider) ChatStream(ctx context.Context, req ChatRequest) (<-chan StreamChunk, error) {
 	req.Stream = true
+	req.StreamOptions = &StreamOptions{IncludeUsage: true}
 	
 	reqBody, err := json.Marshal(req)
 	if err != nil {
@@ -145,10 +137,7 @@ func (p *OpenAIProvider) ChatStream(ctx context.Context, req ChatRequest) (<-cha
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		resp.Body.Close()
-		return nil, &LLMError{
-			Type:    string(ErrorTypeServer),
-			Message: fmt.Sprintf("HTTP %d: %s", resp.StatusCode, string(body)),
-		}
+		return nil, NewHTTPError(resp, body)
 	}
 	
 	return p.stream.ProcessSSEStream(ctx, resp)
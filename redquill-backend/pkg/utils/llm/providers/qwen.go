@@ -82,16 +82,7 @@ func (p *QwenProvider) Chat(ctx context.Context, req ChatRequest) (*ChatResponse
 	}
 	
 	if resp.StatusCode != http.StatusOK {
-		var errorResp struct {
-			Error LLMError `json:"error"`
-		}
-		if err := json.Unmarshal(body, &errorResp); err == nil {
-			return nil, &errorResp.Error
-		}
-		return nil, &LLMError{
-			Type:    string(ErrorTypeServer),
-			Message: fmt.Sprintf("HTTP %d: %s", resp.StatusCode, string(body)),
-		}
+		return nil, NewHTTPError(resp, body)
 	}
 	
 	// 千问API响应格式转换
@@ -111,6 +102,7 @@ func (p *QwenProvider) Chat(ctx context.Context, req ChatRequest) (*ChatResponse
 // ChatStream 流式聊天
 func (p *QwenProvider) ChatStream(ctx context.Context, req ChatRequest) (<-chan StreamChunk, error) {
 	req.Stream = true
+	req.StreamOptions = &StreamOptions{IncludeUsage: true}
 	
 	// 千问API需要转换请求格式
 	qwenReq := p.convertToQwenRequest(req)
@@ -152,10 +144,7 @@ func (p *QwenProvider) ChatStream(ctx context.Context, req ChatRequest) (<-chan
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		resp.Body.Close()
-		return nil, &LLMError{
-			Type:    string(ErrorTypeServer),
-			Message: fmt.Sprintf("HTTP %d: %s", resp.StatusCode, string(body)),
-		}
+		return nil, NewHTTPError(resp, body)
 	}
 	
 	return p.stream.ProcessSSEStream(ctx, resp)
@@ -220,12 +209,13 @@ func (p *QwenProvider) Models(ctx context.Context) ([]Model, error) {
 
 // QwenRequest 千问请求格式
 type QwenRequest struct {
-	Model       string    `json:"model"`
-	Messages    []Message `json:"messages"`
-	Stream      bool      `json:"stream,omitempty"`
-	Temperature float64   `json:"temperature,omitempty"`
-	MaxTokens   int       `json:"max_tokens,omitempty"`
-	TopP        float64   `json:"top_p,omitempty"`
+	Model         string         `json:"model"`
+	Messages      []Message      `json:"messages"`
+	Stream        bool           `json:"stream,omitempty"`
+	Temperature   float64        `json:"temperature,omitempty"`
+	MaxTokens     int            `json:"max_tokens,omitempty"`
+	TopP          float64        `json:"top_p,omitempty"`
+	StreamOptions *StreamOptions `json:"stream_options,omitempty"`
 }
 
 // QwenResponse 千问响应格式
@@ -247,12 +237,13 @@ type QwenChoice struct {
 // convertToQwenRequest 转换为千问请求格式
 func (p *QwenProvider) convertToQwenRequest(req ChatRequest) QwenRequest {
 	return QwenRequest{
-		Model:       req.Model,
-		Messages:    req.Messages,
-		Stream:      req.Stream,
-		Temperature: req.Temperature,
-		MaxTokens:   req.MaxTokens,
-		TopP:        req.TopP,
+		Model:         req.Model,
+		Messages:      req.Messages,
+		Stream:        req.Stream,
+		Temperature:   req.Temperature,
+		MaxTokens:     req.MaxTokens,
+		TopP:          req.TopP,
+		StreamOptions: req.StreamOptions,
 	}
 }
 
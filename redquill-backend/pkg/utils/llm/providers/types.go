@@ -11,6 +11,7 @@ package providers
 import (
 	"context"
 	"net/http"
+	"time"
 )
 
 // LLMConfig LLM配置
@@ -27,14 +28,26 @@ type LLMConfig struct {
 
 // ChatRequest 聊天请求
 type ChatRequest struct {
-	Model            string    `json:"model"`
-	Messages         []Message `json:"messages"`
-	Stream           bool      `json:"stream,omitempty"`
-	Temperature      float64   `json:"temperature,omitempty"`
-	MaxTokens        int       `json:"max_tokens,omitempty"`
-	TopP             float64   `json:"top_p,omitempty"`
-	FrequencyPenalty float64   `json:"frequency_penalty,omitempty"`
-	PresencePenalty  float64   `json:"presence_penalty,omitempty"`
+	Model            string          `json:"model"`
+	Messages         []Message       `json:"messages"`
+	Stream           bool            `json:"stream,omitempty"`
+	Temperature      float64         `json:"temperature,omitempty"`
+	MaxTokens        int             `json:"max_tokens,omitempty"`
+	TopP             float64         `json:"top_p,omitempty"`
+	FrequencyPenalty float64         `json:"frequency_penalty,omitempty"`
+	PresencePenalty  float64         `json:"presence_penalty,omitempty"`
+	ResponseFormat   *ResponseFormat `json:"response_format,omitempty"`
+	StreamOptions    *StreamOptions  `json:"stream_options,omitempty"`
+}
+
+// StreamOptions 流式选项（OpenAI兼容的 stream_options），IncludeUsage 要求在流末尾返回用量
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// ResponseFormat 输出格式（OpenAI兼容的 response_format）
+type ResponseFormat struct {
+	Type string `json:"type"`
 }
 
 // Message 消息类型
@@ -87,10 +100,12 @@ type Model struct {
 
 // LLMError LLM错误
 type LLMError struct {
-	Type    string `json:"type"`
-	Message string `json:"message"`
-	Code    string `json:"code,omitempty"`
-	Details string `json:"details,omitempty"`
+	Type       string        `json:"type"`
+	Message    string        `json:"message"`
+	Code       string        `json:"code,omitempty"`
+	Details    string        `json:"details,omitempty"`
+	StatusCode int           `json:"-"` // 上游HTTP状态码
+	RetryAfter time.Duration `json:"-"` // 上游 Retry-After 建议的等待时间
 }
 
 func (e *LLMError) Error() string {
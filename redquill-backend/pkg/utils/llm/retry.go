@@ -0,0 +1,208 @@
+// Package llm
+/*
+/@Author: urmsone urmsone@163.com
+/@Date: 2025/10/20 20:44
+/@Name: retry.go
+/@Description: Retry decorator for providers with jittered backoff
+/*/
+
+package llm
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"redquill-backend/pkg/utils/llm/providers"
+)
+
+const (
+	// DefaultRetryDelay 默认重试基础间隔
+	DefaultRetryDelay = time.Second
+	// MaxRetryDelay 单次退避的最大等待时间，Retry-After 超过该值时不再重试
+	MaxRetryDelay = 30 * time.Second
+)
+
+// RetryProvider 重试装饰器，包装 providers.Provider 并按错误类型重试
+type RetryProvider struct {
+	next   providers.Provider
+	config RetryConfig
+}
+
+// NewRetryProvider 创建重试装饰器
+func NewRetryProvider(next providers.Provider, config RetryConfig) *RetryProvider {
+	if config.RetryDelay <= 0 {
+		config.RetryDelay = DefaultRetryDelay
+	}
+	if config.Backoff == "" {
+		config.Backoff = BackoffExponential
+	}
+	return &RetryProvider{
+		next:   next,
+		config: config,
+	}
+}
+
+// Chat 同步聊天，可重试错误按退避策略重试
+func (p *RetryProvider) Chat(ctx context.Context, req providers.ChatRequest) (*providers.ChatResponse, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := p.next.Chat(ctx, req)
+		if err == nil {
+			return resp, nil
+		}
+		if !p.shouldRetry(ctx, err, attempt) {
+			return nil, err
+		}
+		if waitErr := p.wait(ctx, err, attempt); waitErr != nil {
+			return nil, err
+		}
+	}
+}
+
+// ChatStream 流式聊天。建立连接失败时按策略重试；
+// 流中途出错时，仅在尚未输出任何token的情况下重新发起请求，避免内容重复。
+func (p *RetryProvider) ChatStream(ctx context.Context, req providers.ChatRequest) (<-chan providers.StreamChunk, error) {
+	stream, attempt, err := p.openStream(ctx, req, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(chan providers.StreamChunk, 100)
+	go func() {
+		defer close(result)
+
+		emitted := false
+		for {
+			var retryErr error
+			for chunk := range stream {
+				if retryErr != nil {
+					continue // 丢弃重试前的剩余数据
+				}
+				if chunk.Error != nil && !emitted && p.shouldRetry(ctx, chunk.Error, attempt) {
+					retryErr = chunk.Error
+					continue
+				}
+				if hasContent(chunk) {
+					emitted = true
+				}
+				select {
+				case result <- chunk:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			if retryErr == nil {
+				return
+			}
+			if err := p.wait(ctx, retryErr, attempt); err != nil {
+				return
+			}
+
+			stream, attempt, err = p.openStream(ctx, req, attempt+1)
+			if err != nil {
+				select {
+				case result <- providers.StreamChunk{Error: toProviderError(err)}:
+				case <-ctx.Done():
+				}
+				return
+			}
+		}
+	}()
+
+	return result, nil
+}
+
+// Health 健康检查
+func (p *RetryProvider) Health(ctx context.Context) error {
+	return p.next.Health(ctx)
+}
+
+// Models 获取模型列表
+func (p *RetryProvider) Models(ctx context.Context) ([]providers.Model, error) {
+	return p.next.Models(ctx)
+}
+
+// openStream 建立流式连接，失败时按策略重试，返回最终使用的重试次数
+func (p *RetryProvider) openStream(ctx context.Context, req providers.ChatRequest, attempt int) (<-chan providers.StreamChunk, int, error) {
+	for ; ; attempt++ {
+		stream, err := p.next.ChatStream(ctx, req)
+		if err == nil {
+			return stream, attempt, nil
+		}
+		if !p.shouldRetry(ctx, err, attempt) {
+			return nil, attempt, err
+		}
+		if waitErr := p.wait(ctx, err, attempt); waitErr != nil {
+			return nil, attempt, err
+		}
+	}
+}
+
+// shouldRetry 判断是否还需要重试；厂商要求的等待时间超过 MaxRetryDelay 时直接放弃，交由回退模型处理
+func (p *RetryProvider) shouldRetry(ctx context.Context, err error, attempt int) bool {
+	if ctx.Err() != nil || attempt >= p.config.MaxRetries {
+		return false
+	}
+	if RetryAfterFromError(err) > MaxRetryDelay {
+		return false
+	}
+	return IsRetryableError(err)
+}
+
+// wait 等待退避时间，上下文取消时提前返回
+func (p *RetryProvider) wait(ctx context.Context, err error, attempt int) error {
+	timer := time.NewTimer(p.backoff(err, attempt))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// backoff 计算第 attempt 次重试前的等待时间：
+// 限流错误优先使用 Retry-After（不超过 MaxRetryDelay），否则按线性/指数退避并加入随机抖动
+func (p *RetryProvider) backoff(err error, attempt int) time.Duration {
+	if d := RetryAfterFromError(err); d > 0 {
+		return min(d, MaxRetryDelay)
+	}
+
+	var delay time.Duration
+	switch p.config.Backoff {
+	case BackoffLinear:
+		delay = p.config.RetryDelay * time.Duration(attempt+1)
+	default:
+		delay = p.config.RetryDelay << uint(attempt)
+	}
+	if delay <= 0 || delay > MaxRetryDelay {
+		delay = MaxRetryDelay
+	}
+
+	// 抖动：在 [delay/2, delay) 区间内随机
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// hasContent 判断流式块中是否包含已输出的token
+func hasContent(chunk providers.StreamChunk) bool {
+	for _, choice := range chunk.Choices {
+		if choice.Delta.Content != "" || choice.Message.Content != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// toProviderError 将错误转换为 providers.LLMError 以便放入流式块
+func toProviderError(err error) *providers.LLMError {
+	if llmErr, ok := err.(*providers.LLMError); ok {
+		return llmErr
+	}
+	return &providers.LLMError{
+		Type:    string(ErrorTypeServer),
+		Message: err.Error(),
+	}
+}
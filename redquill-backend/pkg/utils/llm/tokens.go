@@ -0,0 +1,40 @@
+// Package llm
+/*
+/@Author: urmsone urmsone@163.com
+/@Date: 2025/10/20 20:44
+/@Name: tokens.go
+/@Description: Rough token count estimation without a provider tokenizer
+/*/
+
+package llm
+
+import "unicode"
+
+// messageOverheadTokens 每条消息的角色与分隔符大约占用的token数
+const messageOverheadTokens = 4
+
+// EstimateTokens 粗略估算文本的token数：中日韩字符约每字1个token，其他字符约每4个1个token。
+// 各厂商分词器不同，结果仅用于预估上下文长度
+func EstimateTokens(text string) int {
+	cjk, other := 0, 0
+	for _, r := range text {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			cjk++
+		case unicode.IsSpace(r):
+			// 空白通常并入相邻的token
+		default:
+			other++
+		}
+	}
+	return cjk + (other+3)/4
+}
+
+// EstimateMessagesTokens 估算一组消息作为输入时的token数
+func EstimateMessagesTokens(messages []Message) int {
+	total := 0
+	for _, message := range messages {
+		total += messageOverheadTokens + EstimateTokens(message.Content)
+	}
+	return total
+}
@@ -8,7 +8,10 @@
 
 package llm
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // LLMClient 统一客户端接口
 type LLMClient interface {
@@ -35,6 +38,25 @@ type ChatRequest struct {
 	TopP             float64   `json:"top_p,omitempty"`
 	FrequencyPenalty float64   `json:"frequency_penalty,omitempty"`
 	PresencePenalty  float64   `json:"presence_penalty,omitempty"`
+	// ResponseFormat 原生JSON模式，仅部分厂商支持（见 SupportsJSONMode）
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+}
+
+// ResponseFormat 输出格式
+type ResponseFormat struct {
+	Type string `json:"type"` // text|json_object
+}
+
+// JSONObjectFormat 要求模型输出JSON对象
+var JSONObjectFormat = &ResponseFormat{Type: "json_object"}
+
+// SupportsJSONMode 厂商是否支持 response_format 原生JSON模式
+func SupportsJSONMode(provider string) bool {
+	switch provider {
+	case "openai", "deepseek":
+		return true
+	}
+	return false
 }
 
 // Message 消息类型
@@ -87,10 +109,12 @@ type Model struct {
 
 // LLMError LLM错误
 type LLMError struct {
-	Type    string `json:"type"`
-	Message string `json:"message"`
-	Code    string `json:"code,omitempty"`
-	Details string `json:"details,omitempty"`
+	Type       string        `json:"type"`
+	Message    string        `json:"message"`
+	Code       string        `json:"code,omitempty"`
+	Details    string        `json:"details,omitempty"`
+	StatusCode int           `json:"-"` // 上游HTTP状态码
+	RetryAfter time.Duration `json:"-"` // 上游 Retry-After 建议的等待时间
 }
 
 func (e *LLMError) Error() string {
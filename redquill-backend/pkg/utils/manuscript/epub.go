@@ -0,0 +1,342 @@
+// Package manuscript
+/*
+/@Author: urmsone urmsone@163.com
+/@Date: 2025/10/20 20:44
+/@Name: epub.go
+/@Description: Read EPUB 2/3 books chapter by chapter following the spine
+/*/
+
+package manuscript
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// maxEPUBEntrySize EPUB 中单个文件解压后的最大字节数，防止压缩炸弹
+const maxEPUBEntrySize = 16 << 20
+
+type epubContainer struct {
+	Rootfiles []struct {
+		FullPath string `xml:"full-path,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+type epubPackage struct {
+	Manifest []struct {
+		ID         string `xml:"id,attr"`
+		Href       string `xml:"href,attr"`
+		MediaType  string `xml:"media-type,attr"`
+		Properties string `xml:"properties,attr"`
+	} `xml:"manifest>item"`
+	Spine struct {
+		Toc      string `xml:"toc,attr"`
+		ItemRefs []struct {
+			IDRef  string `xml:"idref,attr"`
+			Linear string `xml:"linear,attr"`
+		} `xml:"itemref"`
+	} `xml:"spine"`
+}
+
+type ncxNavPoint struct {
+	Label   string `xml:"navLabel>text"`
+	Content struct {
+		Src string `xml:"src,attr"`
+	} `xml:"content"`
+	Points []ncxNavPoint `xml:"navPoint"`
+}
+
+type ncxDocument struct {
+	Points []ncxNavPoint `xml:"navMap>navPoint"`
+}
+
+// parseEPUB 按 spine 顺序读取各文档。书中有目录（nav.xhtml 或 toc.ncx）时只导入目录中列出的文档，
+// 并以目录中的名称作为章节标题，从而跳过封面、扉页等
+func parseEPUB(data []byte) ([]Chapter, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("manuscript: invalid epub: %w", err)
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	var container epubContainer
+	if err := decodeEPUBXML(files, "META-INF/container.xml", &container); err != nil {
+		return nil, err
+	}
+	if len(container.Rootfiles) == 0 {
+		return nil, errors.New("manuscript: invalid epub: no rootfile")
+	}
+	opfPath := container.Rootfiles[0].FullPath
+	var pkg epubPackage
+	if err := decodeEPUBXML(files, opfPath, &pkg); err != nil {
+		return nil, err
+	}
+
+	hrefs := make(map[string]string, len(pkg.Manifest))
+	navPath, ncxPath := "", ""
+	for _, item := range pkg.Manifest {
+		p := resolveHref(opfPath, item.Href)
+		hrefs[item.ID] = p
+		if strings.Contains(" "+item.Properties+" ", " nav ") {
+			navPath = p
+		}
+		if item.ID == pkg.Spine.Toc || item.MediaType == "application/x-dtbncx+xml" {
+			ncxPath = p
+		}
+	}
+
+	toc := readEPUBToc(files, navPath, ncxPath)
+
+	var chapters []Chapter
+	for _, ref := range pkg.Spine.ItemRefs {
+		p, ok := hrefs[ref.IDRef]
+		if !ok || ref.Linear == "no" || p == navPath {
+			continue
+		}
+		title, listed := toc[p]
+		if len(toc) > 0 && !listed {
+			continue
+		}
+		raw, err := readEPUBFile(files, p)
+		if err != nil {
+			return nil, err
+		}
+		blocks := extractBlocks(raw)
+
+		lines := make([]string, 0, len(blocks))
+		for i, block := range blocks {
+			// 文档开头的标题即章节标题，不重复写入正文
+			if i == 0 && block.heading {
+				if title == "" {
+					title = block.text
+				}
+				continue
+			}
+			lines = append(lines, block.text)
+		}
+		chapters = append(chapters, Chapter{Title: StripChapterNumber(title), Content: joinParagraphs(lines)})
+	}
+	return chapters, nil
+}
+
+// readEPUBToc 读取目录，返回文档路径到目录名称的映射；同一文档以第一个目录项为准
+func readEPUBToc(files map[string]*zip.File, navPath, ncxPath string) map[string]string {
+	toc := map[string]string{}
+	add := func(base, href, label string) {
+		href, _, _ = strings.Cut(href, "#")
+		if href == "" {
+			return
+		}
+		p := resolveHref(base, href)
+		if _, ok := toc[p]; !ok {
+			toc[p] = strings.TrimSpace(label)
+		}
+	}
+
+	if navPath != "" {
+		if raw, err := readEPUBFile(files, navPath); err == nil {
+			for _, link := range extractTocLinks(raw) {
+				add(navPath, link[0], link[1])
+			}
+		}
+	}
+	if len(toc) == 0 && ncxPath != "" {
+		var ncx ncxDocument
+		if err := decodeEPUBXML(files, ncxPath, &ncx); err == nil {
+			var walk func(points []ncxNavPoint)
+			walk = func(points []ncxNavPoint) {
+				for _, point := range points {
+					add(ncxPath, point.Content.Src, point.Label)
+					walk(point.Points)
+				}
+			}
+			walk(ncx.Points)
+		}
+	}
+	return toc
+}
+
+// resolveHref 将相对于 base 文件的链接解析为包内路径
+func resolveHref(base, href string) string {
+	if unescaped, err := url.PathUnescape(href); err == nil {
+		href = unescaped
+	}
+	return path.Join(path.Dir(base), href)
+}
+
+func readEPUBFile(files map[string]*zip.File, name string) ([]byte, error) {
+	f, ok := files[name]
+	if !ok {
+		return nil, fmt.Errorf("manuscript: invalid epub: missing %s", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, maxEPUBEntrySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxEPUBEntrySize {
+		return nil, fmt.Errorf("manuscript: %s is too large", name)
+	}
+	return data, nil
+}
+
+func decodeEPUBXML(files map[string]*zip.File, name string, v interface{}) error {
+	data, err := readEPUBFile(files, name)
+	if err != nil {
+		return err
+	}
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.CharsetReader = passThroughCharset
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("manuscript: invalid epub: %s: %w", name, err)
+	}
+	return nil
+}
+
+// newHTMLDecoder 宽松的 XML 解码器，容忍 HTML 实体与未闭合标签
+func newHTMLDecoder(data []byte) *xml.Decoder {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.Strict = false
+	dec.AutoClose = xml.HTMLAutoClose
+	dec.Entity = xml.HTMLEntity
+	dec.CharsetReader = passThroughCharset
+	return dec
+}
+
+// passThroughCharset EPUB 文档按规范应为 UTF-8/UTF-16，声明其他编码时按原样读取
+func passThroughCharset(_ string, input io.Reader) (io.Reader, error) {
+	return input, nil
+}
+
+// textBlock 文档中的一个段落或标题
+type textBlock struct {
+	text    string
+	heading bool
+}
+
+// blockElements 结束当前段落的元素
+var blockElements = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "section": true, "article": true,
+	"blockquote": true, "tr": true, "dt": true, "dd": true, "pre": true, "hr": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+}
+
+// extractBlocks 提取 XHTML 正文中的段落与标题
+func extractBlocks(data []byte) []textBlock {
+	dec := newHTMLDecoder(data)
+	var blocks []textBlock
+	var sb strings.Builder
+	heading, skip := false, 0
+
+	flush := func() {
+		if text := strings.Join(strings.Fields(sb.String()), " "); text != "" {
+			blocks = append(blocks, textBlock{text: text, heading: heading})
+		}
+		sb.Reset()
+		heading = false
+	}
+
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			name := strings.ToLower(t.Name.Local)
+			switch {
+			case name == "head" || name == "script" || name == "style":
+				skip++
+			case blockElements[name]:
+				flush()
+				heading = len(name) == 2 && name[0] == 'h' && name[1] >= '1' && name[1] <= '6'
+			}
+		case xml.EndElement:
+			name := strings.ToLower(t.Name.Local)
+			switch {
+			case name == "head" || name == "script" || name == "style":
+				if skip > 0 {
+					skip--
+				}
+			case blockElements[name]:
+				flush()
+			}
+		case xml.CharData:
+			if skip == 0 {
+				sb.Write(t)
+			}
+		}
+	}
+	flush()
+	return blocks
+}
+
+// extractTocLinks 提取 nav 文档中 epub:type="toc" 导航里的链接，返回 [href, 文字] 列表
+func extractTocLinks(data []byte) [][2]string {
+	dec := newHTMLDecoder(data)
+	var links [][2]string
+	navDepth, tocDepth := 0, 0
+	href, inLink := "", false
+	var sb strings.Builder
+
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch strings.ToLower(t.Name.Local) {
+			case "nav":
+				navDepth++
+				for _, attr := range t.Attr {
+					if attr.Name.Local == "type" && strings.Contains(" "+attr.Value+" ", " toc ") && tocDepth == 0 {
+						tocDepth = navDepth
+					}
+				}
+			case "a":
+				if tocDepth > 0 {
+					href, inLink = "", true
+					sb.Reset()
+					for _, attr := range t.Attr {
+						if attr.Name.Local == "href" {
+							href = attr.Value
+						}
+					}
+				}
+			}
+		case xml.EndElement:
+			switch strings.ToLower(t.Name.Local) {
+			case "nav":
+				if navDepth == tocDepth {
+					tocDepth = 0
+				}
+				navDepth--
+			case "a":
+				if inLink {
+					links = append(links, [2]string{href, strings.Join(strings.Fields(sb.String()), " ")})
+					inLink = false
+				}
+			}
+		case xml.CharData:
+			if inLink {
+				sb.Write(t)
+			}
+		}
+	}
+	return links
+}
@@ -0,0 +1,315 @@
+// Package manuscript
+/*
+/@Author: urmsone urmsone@163.com
+/@Date: 2025/10/20 20:44
+/@Name: manuscript.go
+/@Description: Split TXT, Markdown and EPUB manuscripts into chapters
+/*/
+
+package manuscript
+
+import (
+	"bytes"
+	"errors"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// 支持的稿件格式
+const (
+	FormatTXT      = "txt"
+	FormatMarkdown = "md"
+	FormatEPUB     = "epub"
+)
+
+// ErrUnsupportedFormat 不支持的稿件格式
+var ErrUnsupportedFormat = errors.New("manuscript: unsupported format")
+
+// ErrNoChapters 稿件中没有识别出任何有正文的章节
+var ErrNoChapters = errors.New("manuscript: no chapters found")
+
+// minPreambleRunes 第一个章节标题之前的文字超过该长度时作为“序章”导入，否则视为书名、简介等信息丢弃
+const minPreambleRunes = 200
+
+// summaryPrefixes 导出时写在正文前的章节概要前缀，导入时还原为章节概要
+var summaryPrefixes = []string{"本章概要：", "【本章概要】"}
+
+var (
+	// chapterHeading 第N章/回/节 标题，N 可为阿拉伯数字、全角数字或中文数字
+	chapterHeading = regexp.MustCompile(`^第\s*[0-9０-９零〇一二两三四五六七八九十百千万]+\s*[章回节]`)
+	// volumeHeading 第N卷/部/集 分卷标题，不作为章节
+	volumeHeading = regexp.MustCompile(`^第\s*[0-9０-９零〇一二两三四五六七八九十百千万]+\s*[卷部集]`)
+	// specialHeading 序章、楔子等没有序号的章节
+	specialHeading = regexp.MustCompile(`^(序章|序言|序|楔子|引子|前言|尾声|后记|终章|番外)([\s:：、.．\-—]|$)`)
+	// headingSeparator 章节序号与标题之间的分隔符
+	headingSeparator = regexp.MustCompile(`^[\s:：、.．\-—]+`)
+	// markdownHeading Markdown ATX 标题
+	markdownHeading = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+)
+
+// maxHeadingRunes 标题行的最大长度，更长的行视为正文
+const maxHeadingRunes = 40
+
+// Chapter 从稿件中拆分出的一章
+type Chapter struct {
+	Title   string // 去掉“第N章”序号后的标题，没有标题时为空
+	Content string // 正文，段落之间以换行分隔
+	Summary string // 正文前附带的章节概要（如本系统导出的文件），没有时为空
+}
+
+// Parse 按格式解析稿件并拆分为章节，不包含没有正文的章节
+func Parse(format string, data []byte) ([]Chapter, error) {
+	var chapters []Chapter
+	var err error
+	switch format {
+	case FormatTXT:
+		chapters = splitText(DecodeText(data))
+	case FormatMarkdown:
+		chapters = splitMarkdown(DecodeText(data))
+	case FormatEPUB:
+		chapters, err = parseEPUB(data)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Chapter, 0, len(chapters))
+	for _, chapter := range chapters {
+		chapter = extractSummary(chapter)
+		if strings.TrimSpace(chapter.Content) != "" {
+			result = append(result, chapter)
+		}
+	}
+	if len(result) == 0 {
+		return nil, ErrNoChapters
+	}
+	return result, nil
+}
+
+// DetectFormat 根据文件扩展名判断稿件格式，无法判断时返回空
+func DetectFormat(filename string) string {
+	name := strings.ToLower(filename)
+	switch {
+	case strings.HasSuffix(name, ".txt"):
+		return FormatTXT
+	case strings.HasSuffix(name, ".md"), strings.HasSuffix(name, ".markdown"):
+		return FormatMarkdown
+	case strings.HasSuffix(name, ".epub"):
+		return FormatEPUB
+	}
+	return ""
+}
+
+// DecodeText 将文本文件解码为 UTF-8：识别 UTF-8/UTF-16 BOM，非 UTF-8 内容按 GB18030（兼容 GBK、GB2312）解码
+func DecodeText(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		data = data[3:]
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}), bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		decoded, err := unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM).NewDecoder().Bytes(data)
+		if err == nil {
+			return normalizeNewlines(string(decoded))
+		}
+	}
+	if !utf8.Valid(data) {
+		if decoded, err := simplifiedchinese.GB18030.NewDecoder().Bytes(data); err == nil {
+			data = decoded
+		}
+	}
+	return normalizeNewlines(strings.ToValidUTF8(string(data), ""))
+}
+
+func normalizeNewlines(text string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\r", "\n")
+}
+
+// splitText 按“第N章”等标题行拆分纯文本
+func splitText(text string) []Chapter {
+	return splitLines(strings.Split(text, "\n"), func(line string) (string, bool, bool) {
+		line = strings.TrimSpace(line)
+		if line == "" || utf8.RuneCountInString(line) > maxHeadingRunes {
+			return "", false, false
+		}
+		if volumeHeading.MatchString(line) {
+			return "", false, true
+		}
+		if chapterHeading.MatchString(line) || specialHeading.MatchString(line) {
+			return line, true, false
+		}
+		return "", false, false
+	})
+}
+
+// splitMarkdown 按 Markdown 标题拆分：优先使用含“第N章”的标题所在级别，否则使用出现多次的最高级别。
+// 更高级别的标题（书名、分卷）丢弃，更低级别的标题作为正文保留；没有 Markdown 标题时按纯文本拆分
+func splitMarkdown(text string) []Chapter {
+	lines := strings.Split(text, "\n")
+
+	counts := map[int]int{}
+	chapterLevel, topLevel := 0, 0
+	inFence := false
+	for _, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+			continue
+		}
+		m := markdownHeading.FindStringSubmatch(line)
+		if inFence || m == nil {
+			continue
+		}
+		level := len(m[1])
+		counts[level]++
+		if chapterHeading.MatchString(m[2]) && (chapterLevel == 0 || level < chapterLevel) {
+			chapterLevel = level
+		}
+	}
+	if len(counts) == 0 {
+		return splitText(stripMarkdown(text))
+	}
+	if chapterLevel == 0 {
+		for level := 1; level <= 6; level++ {
+			if counts[level] >= 2 {
+				chapterLevel = level
+				break
+			}
+			if counts[level] > 0 && topLevel == 0 {
+				topLevel = level
+			}
+		}
+		if chapterLevel == 0 {
+			chapterLevel = topLevel
+		}
+	}
+
+	inFence = false
+	return splitLines(lines, func(line string) (string, bool, bool) {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+			return "", false, true
+		}
+		m := markdownHeading.FindStringSubmatch(line)
+		if inFence || m == nil {
+			return "", false, false
+		}
+		switch level := len(m[1]); {
+		case level == chapterLevel:
+			return stripMarkdown(m[2]), true, false
+		case level < chapterLevel:
+			return "", false, true
+		}
+		return "", false, false
+	}, stripMarkdownLine)
+}
+
+// splitLines 逐行拆分章节。classify 返回标题文字、是否为章节标题、是否丢弃该行；
+// transform 可选，用于在写入正文前处理每一行
+func splitLines(lines []string, classify func(line string) (title string, heading, skip bool), transform ...func(string) string) []Chapter {
+	var chapters []Chapter
+	var preamble []string
+	var current *Chapter
+	var body []string
+
+	flush := func() {
+		if current != nil {
+			current.Content = joinParagraphs(body)
+			chapters = append(chapters, *current)
+		}
+		body = nil
+	}
+
+	for _, line := range lines {
+		title, heading, skip := classify(line)
+		if skip {
+			continue
+		}
+		if heading {
+			flush()
+			current = &Chapter{Title: StripChapterNumber(title)}
+			continue
+		}
+		for _, fn := range transform {
+			line = fn(line)
+		}
+		if current == nil {
+			preamble = append(preamble, line)
+		} else {
+			body = append(body, line)
+		}
+	}
+	flush()
+
+	// 没有任何标题时整篇作为一章
+	if len(chapters) == 0 {
+		return []Chapter{{Content: joinParagraphs(preamble)}}
+	}
+	if intro := joinParagraphs(preamble); utf8.RuneCountInString(intro) >= minPreambleRunes {
+		chapters = append([]Chapter{{Title: "序章", Content: intro}}, chapters...)
+	}
+	return chapters
+}
+
+// StripChapterNumber 去掉标题中的“第N章”序号，只保留章节名；序章等特殊标题保持不变
+func StripChapterNumber(title string) string {
+	title = strings.TrimSpace(title)
+	loc := chapterHeading.FindStringIndex(title)
+	if loc == nil {
+		return title
+	}
+	return strings.TrimSpace(headingSeparator.ReplaceAllString(title[loc[1]:], ""))
+}
+
+// joinParagraphs 去掉每段的缩进与空行，段落之间以换行分隔
+func joinParagraphs(lines []string) string {
+	paragraphs := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line != "" {
+			paragraphs = append(paragraphs, line)
+		}
+	}
+	return strings.Join(paragraphs, "\n")
+}
+
+// extractSummary 将正文第一段中的“本章概要：”移到章节概要中
+func extractSummary(chapter Chapter) Chapter {
+	first, rest, _ := strings.Cut(chapter.Content, "\n")
+	for _, prefix := range summaryPrefixes {
+		if strings.HasPrefix(first, prefix) {
+			chapter.Summary = strings.TrimSpace(strings.TrimPrefix(first, prefix))
+			chapter.Content = rest
+			break
+		}
+	}
+	return chapter
+}
+
+// stripMarkdown 去掉文本中每一行的 Markdown 标记
+func stripMarkdown(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = stripMarkdownLine(line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+var (
+	markdownRule     = regexp.MustCompile(`^\s*([-*_]\s*){3,}$`)
+	markdownPrefix   = regexp.MustCompile(`^\s*(>\s*)+|^\s*#{1,6}\s+|^\s*[-*+]\s+`)
+	markdownEmphasis = regexp.MustCompile(`(\*\*|__|\*|~~)(\S(?:.*?\S)?)(\*\*|__|\*|~~)`)
+	markdownLink     = regexp.MustCompile(`!?\[([^\]]*)\]\([^)]*\)`)
+)
+
+// stripMarkdownLine 去掉一行中的引用、列表、强调与链接标记，分割线变为空行
+func stripMarkdownLine(line string) string {
+	if markdownRule.MatchString(line) {
+		return ""
+	}
+	line = markdownPrefix.ReplaceAllString(line, "")
+	line = markdownLink.ReplaceAllString(line, "$1")
+	return markdownEmphasis.ReplaceAllString(line, "$2")
+}
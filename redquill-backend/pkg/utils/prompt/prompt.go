@@ -0,0 +1,359 @@
+// Package prompt
+/*
+/@Author: urmsone urmsone@163.com
+/@Date: 2025/10/20 20:44
+/@Name: prompt.go
+/@Description: Prompt template engine on text/template with variable declaration checks
+/*/
+
+package prompt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	// ErrInvalidTemplate 模板语法错误
+	ErrInvalidTemplate = errors.New("invalid prompt template")
+	// ErrUndeclaredVariables 模板内容使用了 Variables 中没有声明的变量
+	ErrUndeclaredVariables = errors.New("undeclared template variables")
+	// ErrUnusedVariables Variables 中声明的变量没有在模板内容中使用
+	ErrUnusedVariables = errors.New("unused template variables")
+	// ErrMissingVariables 渲染时输入数据缺少声明的变量
+	ErrMissingVariables = errors.New("missing template variables")
+)
+
+// Template 解析后的Prompt模板。语法为 text/template，变量写作 {{.chapter_goal}}，
+// 在 range/with 内部通过 {{$.chapter_goal}} 引用
+type Template struct {
+	tmpl *template.Template
+	used []string
+}
+
+// funcs 模板中可用的辅助函数
+var funcs = template.FuncMap{
+	// json 输出值的 JSON 表示，字符串带引号：{"genre": {{json .genre}}}
+	"json": func(v interface{}) (string, error) {
+		return marshalJSON(v)
+	},
+	// jsonEscape 转义为 JSON 字符串内容，不带引号：{"genre": "{{jsonEscape .genre}}"}
+	"jsonEscape": func(v interface{}) (string, error) {
+		s, err := marshalJSON(fmt.Sprint(v))
+		if err != nil {
+			return "", err
+		}
+		return s[1 : len(s)-1], nil
+	},
+	// join 用分隔符连接列表：{{join .key_events "、"}}
+	"join": func(v interface{}, sep string) string {
+		rv := reflect.ValueOf(v)
+		if !rv.IsValid() || (rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array) {
+			return fmt.Sprint(v)
+		}
+		items := make([]string, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			items = append(items, fmt.Sprint(rv.Index(i).Interface()))
+		}
+		return strings.Join(items, sep)
+	},
+	// default 值为空时使用默认值：{{default "无" .previous_summary}}
+	"default": func(fallback, v interface{}) interface{} {
+		if isEmpty(v) {
+			return fallback
+		}
+		return v
+	},
+	"trim": func(v interface{}) string {
+		return strings.TrimSpace(fmt.Sprint(v))
+	},
+}
+
+// Parse 解析模板内容并收集其使用的变量
+func Parse(content string) (*Template, error) {
+	tmpl, err := template.New("prompt").Funcs(funcs).Option("missingkey=error").Parse(content)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+	}
+	used := map[string]bool{}
+	if tmpl.Tree != nil && tmpl.Tree.Root != nil {
+		collectVariables(tmpl.Tree.Root, true, used)
+	}
+	names := make([]string, 0, len(used))
+	for name := range used {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return &Template{tmpl: tmpl, used: names}, nil
+}
+
+// Variables 模板内容使用的变量，按名称排序
+func (t *Template) Variables() []string {
+	return t.used
+}
+
+// Check 检查模板内容使用的变量与声明的变量是否一致
+func (t *Template) Check(declared []string) error {
+	if undeclared := t.undeclared(declared); len(undeclared) > 0 {
+		return fmt.Errorf("%w: %s", ErrUndeclaredVariables, strings.Join(undeclared, ", "))
+	}
+	used := make(map[string]bool, len(t.used))
+	for _, name := range t.used {
+		used[name] = true
+	}
+	var unused []string
+	for _, name := range declared {
+		if !used[name] {
+			unused = append(unused, name)
+		}
+	}
+	if len(unused) > 0 {
+		return fmt.Errorf("%w: %s", ErrUnusedVariables, strings.Join(unused, ", "))
+	}
+	return nil
+}
+
+// Render 渲染模板。输入数据必须包含全部声明的变量，模板内容不能使用未声明的变量；
+// 列表与对象按 JSON 输出，整数值的浮点数按整数输出
+func (t *Template) Render(declared []string, data map[string]interface{}) (string, error) {
+	if undeclared := t.undeclared(declared); len(undeclared) > 0 {
+		return "", fmt.Errorf("%w: %s", ErrUndeclaredVariables, strings.Join(undeclared, ", "))
+	}
+	var missing []string
+	for _, name := range declared {
+		if _, ok := data[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("%w: %s", ErrMissingVariables, strings.Join(missing, ", "))
+	}
+
+	values := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		values[k] = normalize(v)
+	}
+	var buf bytes.Buffer
+	if err := t.tmpl.Execute(&buf, values); err != nil {
+		return "", fmt.Errorf("render prompt template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+func (t *Template) undeclared(declared []string) []string {
+	known := make(map[string]bool, len(declared))
+	for _, name := range declared {
+		known[name] = true
+	}
+	var undeclared []string
+	for _, name := range t.used {
+		if !known[name] {
+			undeclared = append(undeclared, name)
+		}
+	}
+	return undeclared
+}
+
+// Validate 解析模板内容并检查变量声明，用于保存模板前的校验
+func Validate(content string, declared []string) error {
+	t, err := Parse(content)
+	if err != nil {
+		return err
+	}
+	return t.Check(declared)
+}
+
+// Render 解析并渲染模板内容
+func Render(content string, declared []string, data map[string]interface{}) (string, error) {
+	t, err := Parse(content)
+	if err != nil {
+		return "", err
+	}
+	return t.Render(declared, data)
+}
+
+// FormatValue 变量值直接输出时的文本，与 {{.name}} 的渲染结果一致
+func FormatValue(v interface{}) string {
+	return fmt.Sprint(normalize(v))
+}
+
+// legacyPlaceholder 旧版模板的 {name} 占位符
+var legacyPlaceholder = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// ConvertLegacy 将旧版 {name} 占位符写法的模板转换为当前语法：声明的变量改写为 {{.name}}，
+// 内容中原有的 {{ 与 }} 作为文本输出，其余花括号保持不变
+func ConvertLegacy(content string, variables []string) string {
+	declared := make(map[string]bool, len(variables))
+	for _, name := range variables {
+		declared[name] = true
+	}
+
+	// 先转义已有的双花括号，避免被解析为模板动作
+	escaper := strings.NewReplacer("{{", `{{"{{"}}`, "}}", `{{"}}"}}`)
+	var b strings.Builder
+	last := 0
+	for _, loc := range legacyPlaceholder.FindAllStringSubmatchIndex(content, -1) {
+		name := content[loc[2]:loc[3]]
+		if !declared[name] {
+			continue
+		}
+		b.WriteString(escaper.Replace(content[last:loc[0]]))
+		b.WriteString("{{." + name + "}}")
+		last = loc[1]
+	}
+	b.WriteString(escaper.Replace(content[last:]))
+	return b.String()
+}
+
+// collectVariables 收集模板中引用的顶层变量。root 表示当前的 . 是否为输入数据本身，
+// range/with 的主体中 . 为当前元素，只有 $.name 引用输入数据
+func collectVariables(node parse.Node, root bool, used map[string]bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			collectVariables(child, root, used)
+		}
+	case *parse.ActionNode:
+		collectVariables(n.Pipe, root, used)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			collectVariables(cmd, root, used)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			collectVariables(arg, root, used)
+		}
+	case *parse.ChainNode:
+		collectVariables(n.Node, root, used)
+	case *parse.FieldNode:
+		if root && len(n.Ident) > 0 {
+			used[n.Ident[0]] = true
+		}
+	case *parse.VariableNode:
+		if len(n.Ident) > 1 && n.Ident[0] == "$" {
+			used[n.Ident[1]] = true
+		}
+	case *parse.IfNode:
+		collectVariables(n.Pipe, root, used)
+		collectVariables(n.List, root, used)
+		collectVariables(n.ElseList, root, used)
+	case *parse.RangeNode:
+		collectVariables(n.Pipe, root, used)
+		collectVariables(n.List, false, used)
+		collectVariables(n.ElseList, root, used)
+	case *parse.WithNode:
+		collectVariables(n.Pipe, root, used)
+		collectVariables(n.List, false, used)
+		collectVariables(n.ElseList, root, used)
+	case *parse.TemplateNode:
+		collectVariables(n.Pipe, root, used)
+	}
+}
+
+// jsonList 列表值，直接输出时为 JSON 数组，仍可用于 range/index/len
+type jsonList []interface{}
+
+func (l jsonList) String() string {
+	s, err := marshalJSON([]interface{}(l))
+	if err != nil {
+		return fmt.Sprint([]interface{}(l))
+	}
+	return s
+}
+
+// jsonObject 对象值，直接输出时为 JSON 对象，仍可通过 .key 访问字段
+type jsonObject map[string]interface{}
+
+func (o jsonObject) String() string {
+	s, err := marshalJSON(map[string]interface{}(o))
+	if err != nil {
+		return fmt.Sprint(map[string]interface{}(o))
+	}
+	return s
+}
+
+// normalize 将输入数据（JSON 或 BSON 解码结果）转换为便于输出的值：nil 为空字符串，
+// 列表与对象输出为 JSON，整数值的浮点数输出为整数
+func normalize(v interface{}) interface{} {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string, bool, int, int32, int64:
+		return val
+	case float64:
+		if val == math.Trunc(val) && math.Abs(val) < 1e15 {
+			return int64(val)
+		}
+		return val
+	case primitive.D:
+		obj := make(jsonObject, len(val))
+		for _, e := range val {
+			obj[e.Key] = normalize(e.Value)
+		}
+		return obj
+	case []byte:
+		return string(val)
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		list := make(jsonList, rv.Len())
+		for i := range list {
+			list[i] = normalize(rv.Index(i).Interface())
+		}
+		return list
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return v
+		}
+		obj := make(jsonObject, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			obj[iter.Key().String()] = normalize(iter.Value().Interface())
+		}
+		return obj
+	}
+	return v
+}
+
+// marshalJSON 不转义 HTML 字符的 JSON 编码
+func marshalJSON(v interface{}) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(normalize(v)); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// isEmpty 是否为空值：nil、空字符串、空列表或空对象
+func isEmpty(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return rv.Len() == 0
+	}
+	return false
+}